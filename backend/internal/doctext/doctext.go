@@ -0,0 +1,133 @@
+// Package doctext mengekstrak teks dari file dokumen yang diunggah guru dan siswa (PDF, DOCX, PPTX, ODT/ODP,
+// TXT/Markdown, HTML). Setiap format punya extractor sendiri di registry yang dipilih dari ekstensi file,
+// dengan deteksi isi file sebagai cadangan bila ekstensinya tidak dikenal.
+package doctext
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// ErrUnsupportedFormat dikembalikan untuk format file yang tidak punya extractor.
+	ErrUnsupportedFormat = errors.New("unsupported document format")
+	// ErrNoText dikembalikan bila file berhasil dibaca tetapi tidak memuat teks (mis. PDF hasil scan).
+	ErrNoText = errors.New("no readable text found in document")
+	// ErrEncrypted dikembalikan untuk dokumen yang dilindungi password.
+	ErrEncrypted = errors.New("document is password protected")
+	// ErrTooLarge dikembalikan bila isi dokumen setelah didekompresi melewati batas ukuran.
+	ErrTooLarge = errors.New("document is too large to extract")
+)
+
+// Result adalah teks hasil ekstraksi satu file.
+type Result struct {
+	Format string // kunci registry, mis. "pdf", "docx"
+	Text   string
+	// Units adalah jumlah halaman (PDF) atau slide (PPTX/ODP); 0 untuk format tanpa pembagian halaman.
+	Units int
+}
+
+// Extractor membaca isi file dan mengembalikan teks beserta jumlah halaman/slide.
+type Extractor func(data []byte) (text string, units int, err error)
+
+// registry memetakan format ke extractor-nya; extensions memetakan ekstensi file ke format.
+var (
+	registry = map[string]Extractor{
+		"pdf":      extractPDF,
+		"docx":     extractDOCX,
+		"pptx":     extractPPTX,
+		"odt":      extractODF,
+		"odp":      extractODF,
+		"txt":      extractPlainText,
+		"markdown": extractMarkdown,
+		"html":     extractHTML,
+	}
+	extensions = map[string]string{
+		".pdf":      "pdf",
+		".docx":     "docx",
+		".pptx":     "pptx",
+		".odt":      "odt",
+		".odp":      "odp",
+		".txt":      "txt",
+		".text":     "txt",
+		".md":       "markdown",
+		".markdown": "markdown",
+		".html":     "html",
+		".htm":      "html",
+	}
+)
+
+// Supported melaporkan apakah nama file memiliki ekstensi yang bisa diekstrak.
+func Supported(fileName string) bool {
+	_, ok := extensions[strings.ToLower(filepath.Ext(fileName))]
+	return ok
+}
+
+// Extract memilih extractor dari ekstensi fileName (atau dari isi file bila ekstensi tidak dikenal),
+// lalu mengembalikan teks yang sudah dirapikan. Teks kosong dilaporkan sebagai ErrNoText.
+func Extract(fileName string, data []byte) (*Result, error) {
+	format, ok := extensions[strings.ToLower(filepath.Ext(fileName))]
+	if !ok {
+		format = sniffFormat(data)
+	}
+	extractor, ok := registry[format]
+	if !ok {
+		return nil, ErrUnsupportedFormat
+	}
+	if bytes.HasPrefix(data, oleSignature) && format != "txt" && format != "markdown" && format != "html" {
+		// File Office yang dienkripsi disimpan sebagai kontainer OLE, bukan zip.
+		return nil, ErrEncrypted
+	}
+	text, units, err := extractor(data)
+	if err != nil {
+		return nil, err
+	}
+	text = cleanText(text)
+	if text == "" {
+		return &Result{Format: format, Units: units}, ErrNoText
+	}
+	return &Result{Format: format, Text: text, Units: units}, nil
+}
+
+// oleSignature adalah header Compound File Binary (dokumen Office lama atau OOXML terenkripsi).
+var oleSignature = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+
+// sniffFormat mengenali format dari isi file untuk upload tanpa ekstensi yang dikenal.
+func sniffFormat(data []byte) string {
+	head := data[:min(len(data), 1024)]
+	switch {
+	case bytes.Contains(head, []byte("%PDF-")):
+		return "pdf"
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		return sniffZipFormat(data)
+	}
+	lower := bytes.ToLower(bytes.TrimSpace(head))
+	if bytes.HasPrefix(lower, []byte("<!doctype html")) || bytes.HasPrefix(lower, []byte("<html")) {
+		return "html"
+	}
+	return ""
+}
+
+// cleanText menyeragamkan akhir baris, memadatkan spasi per baris, dan menyisakan paling banyak
+// satu baris kosong berturut-turut.
+func cleanText(text string) string {
+	text = strings.NewReplacer("\r\n", "\n", "\r", "\n", "\u00a0", " ", "\u00ad", "", "\ufeff", "").Replace(text)
+	lines := strings.Split(text, "\n")
+	out := make([]string, 0, len(lines))
+	blank := false
+	for _, line := range lines {
+		line = strings.Join(strings.Fields(line), " ")
+		if line == "" {
+			if !blank && len(out) > 0 {
+				out = append(out, "")
+			}
+			blank = true
+			continue
+		}
+		blank = false
+		out = append(out, line)
+	}
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
@@ -0,0 +1,37 @@
+package doctext
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	htmlDropBlock = regexp.MustCompile(`(?is)<(script|style|head|noscript|template|svg)\b.*?</(script|style|head|noscript|template|svg)\s*>|<!--.*?-->`)
+	htmlTag       = regexp.MustCompile(`(?s)</?([a-zA-Z][a-zA-Z0-9]*)\b[^>]*>`)
+)
+
+// htmlBlockTags adalah elemen yang memutus baris saat HTML diubah menjadi teks.
+var htmlBlockTags = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "ul": true, "ol": true, "tr": true, "table": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "section": true, "article": true,
+	"header": true, "footer": true, "blockquote": true, "pre": true, "hr": true, "dt": true, "dd": true,
+	"figcaption": true, "caption": true, "title": true,
+}
+
+// extractHTML mengubah halaman HTML (mis. materi yang disimpan dari browser) menjadi teks: skrip, gaya,
+// dan komentar dibuang, elemen blok menjadi pergantian baris, sel tabel dipisah tab, entity di-decode.
+func extractHTML(data []byte) (string, int, error) {
+	source := htmlDropBlock.ReplaceAllString(decodeText(data), " ")
+	text := htmlTag.ReplaceAllStringFunc(source, func(tag string) string {
+		name := strings.ToLower(htmlTag.FindStringSubmatch(tag)[1])
+		switch {
+		case htmlBlockTags[name]:
+			return "\n"
+		case name == "td" || name == "th":
+			return "\t"
+		}
+		return ""
+	})
+	return html.UnescapeString(text), 0, nil
+}
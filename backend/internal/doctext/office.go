@@ -0,0 +1,337 @@
+package doctext
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxZipEntrySize membatasi ukuran satu berkas XML di dalam DOCX/PPTX/ODT setelah didekompresi.
+const maxZipEntrySize = 32 << 20
+
+// maxZipDocumentSize membatasi total hasil dekompresi semua berkas yang dibaca dari satu dokumen, agar
+// presentasi dengan ratusan slide yang masing-masing di bawah maxZipEntrySize tetap tidak menghabiskan memori.
+const maxZipDocumentSize = 64 << 20
+
+// maxSlides membatasi jumlah slide yang dibaca dari satu presentasi.
+const maxSlides = 500
+
+var slideEntryPattern = regexp.MustCompile(`^ppt/slides/slide(\d+)\.xml$`)
+
+// zipDocument adalah arsip satu dokumen beserta sisa anggaran dekompresi yang dipakai bersama semua entrinya.
+type zipDocument struct {
+	*zip.Reader
+	remaining int64
+}
+
+func openZip(data []byte) (*zipDocument, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open document archive: %w", err)
+	}
+	return &zipDocument{Reader: zr, remaining: maxZipDocumentSize}, nil
+}
+
+// readZipEntry membaca satu entri dan mengurangi anggaran dokumen. ErrTooLarge dikembalikan begitu total
+// dekompresi melewati maxZipDocumentSize; entri berikutnya dari dokumen yang sama juga ditolak.
+func readZipEntry(zr *zipDocument, name string) ([]byte, bool, error) {
+	for _, f := range zr.File {
+		if f.Name != name {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, true, fmt.Errorf("failed to open %s: %w", name, err)
+		}
+		defer rc.Close()
+		limit := min(int64(maxZipEntrySize), zr.remaining)
+		content, err := io.ReadAll(io.LimitReader(rc, limit+1))
+		if err != nil {
+			return nil, true, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		if int64(len(content)) > zr.remaining {
+			zr.remaining = 0
+			return nil, true, fmt.Errorf("%s exceeds the document size budget: %w", name, ErrTooLarge)
+		}
+		if len(content) > maxZipEntrySize {
+			return nil, true, fmt.Errorf("%s is too large: %w", name, ErrTooLarge)
+		}
+		zr.remaining -= int64(len(content))
+		return content, true, nil
+	}
+	return nil, false, nil
+}
+
+// sniffZipFormat membedakan DOCX, PPTX, dan OpenDocument dari isi arsip zip-nya.
+func sniffZipFormat(data []byte) string {
+	zr, err := openZip(data)
+	if err != nil {
+		return ""
+	}
+	for _, f := range zr.File {
+		switch f.Name {
+		case "word/document.xml":
+			return "docx"
+		case "ppt/presentation.xml":
+			return "pptx"
+		}
+	}
+	if mimetype, ok, _ := readZipEntry(zr, "mimetype"); ok {
+		switch strings.TrimSpace(string(mimetype)) {
+		case "application/vnd.oasis.opendocument.text":
+			return "odt"
+		case "application/vnd.oasis.opendocument.presentation":
+			return "odp"
+		}
+	}
+	return ""
+}
+
+// extractDOCX membaca word/document.xml: teks w:t per paragraf, w:tab sebagai tab, w:br/w:cr sebagai
+// pergantian baris. Teks yang dihapus lewat track changes (w:delText) tidak ikut terbaca.
+func extractDOCX(data []byte) (string, int, error) {
+	zr, err := openZip(data)
+	if err != nil {
+		return "", 0, err
+	}
+	content, ok, err := readZipEntry(zr, "word/document.xml")
+	if err != nil {
+		return "", 0, err
+	}
+	if !ok {
+		return "", 0, fmt.Errorf("docx has no word/document.xml")
+	}
+	text, err := ooxmlText(content, "p")
+	if err != nil {
+		return "", 0, err
+	}
+	return text, 0, nil
+}
+
+// extractPPTX membaca slide sesuai urutan di presentation.xml dan memberi penanda "[Slide N]".
+func extractPPTX(data []byte) (string, int, error) {
+	zr, err := openZip(data)
+	if err != nil {
+		return "", 0, err
+	}
+	slides := presentationSlideOrder(zr)
+	if len(slides) == 0 {
+		return "", 0, fmt.Errorf("pptx has no slides")
+	}
+
+	var b strings.Builder
+	for i, name := range slides {
+		if i >= maxSlides {
+			break
+		}
+		content, ok, err := readZipEntry(zr, name)
+		if errors.Is(err, ErrTooLarge) {
+			return "", 0, err
+		}
+		if err != nil || !ok {
+			continue
+		}
+		text, err := ooxmlText(content, "p")
+		if err != nil || strings.TrimSpace(text) == "" {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString("\n\n")
+		}
+		b.WriteString("[Slide " + strconv.Itoa(i+1) + "]\n")
+		b.WriteString(text)
+	}
+	return b.String(), min(len(slides), maxSlides), nil
+}
+
+// presentationSlideOrder mengembalikan path slide sesuai p:sldIdLst di presentation.xml. Bila relasinya
+// tidak terbaca, slide diurutkan dari nomor pada nama filenya.
+func presentationSlideOrder(zr *zipDocument) []string {
+	var ordered []string
+	presentation, ok, _ := readZipEntry(zr, "ppt/presentation.xml")
+	rels, relsOK, _ := readZipEntry(zr, "ppt/_rels/presentation.xml.rels")
+	if ok && relsOK {
+		targets := map[string]string{}
+		dec := xml.NewDecoder(bytes.NewReader(rels))
+		for {
+			tok, err := dec.Token()
+			if err != nil {
+				break
+			}
+			if start, isStart := tok.(xml.StartElement); isStart && start.Name.Local == "Relationship" {
+				targets[xmlAttr(start, "Id")] = xmlAttr(start, "Target")
+			}
+		}
+		dec = xml.NewDecoder(bytes.NewReader(presentation))
+		for {
+			tok, err := dec.Token()
+			if err != nil {
+				break
+			}
+			start, isStart := tok.(xml.StartElement)
+			if !isStart || start.Name.Local != "sldId" {
+				continue
+			}
+			for _, attr := range start.Attr {
+				if attr.Name.Local != "id" || !strings.Contains(attr.Name.Space, "relationships") {
+					continue
+				}
+				if target := targets[attr.Value]; target != "" {
+					ordered = append(ordered, path.Clean(path.Join("ppt", strings.TrimPrefix(target, "/ppt/"))))
+				}
+			}
+		}
+	}
+	if len(ordered) > 0 {
+		return ordered
+	}
+
+	type numbered struct {
+		name string
+		num  int
+	}
+	var found []numbered
+	for _, f := range zr.File {
+		if m := slideEntryPattern.FindStringSubmatch(f.Name); m != nil {
+			num, _ := strconv.Atoi(m[1])
+			found = append(found, numbered{name: f.Name, num: num})
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].num < found[j].num })
+	for _, f := range found {
+		ordered = append(ordered, f.name)
+	}
+	return ordered
+}
+
+// ooxmlText mengambil teks dari XML WordprocessingML/DrawingML: isi elemen t, dengan pemisah baris
+// pada akhir elemen paragraf (paragraphTag) dan pada br/cr.
+func ooxmlText(content []byte, paragraphTag string) (string, error) {
+	dec := xml.NewDecoder(bytes.NewReader(content))
+	var b strings.Builder
+	inText := false
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to parse document xml: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				b.WriteByte('\t')
+			case "br", "cr":
+				b.WriteByte('\n')
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case paragraphTag:
+				b.WriteByte('\n')
+			}
+		case xml.CharData:
+			if inText {
+				b.Write(t)
+			}
+		}
+	}
+	return b.String(), nil
+}
+
+// extractODF membaca content.xml OpenDocument (ODT/ODP). Paragraf dan judul dipisah baris, text:s
+// dikembalikan menjadi spasi, dan setiap draw:page (slide ODP) diberi penanda "[Slide N]".
+func extractODF(data []byte) (string, int, error) {
+	zr, err := openZip(data)
+	if err != nil {
+		return "", 0, err
+	}
+	content, ok, err := readZipEntry(zr, "content.xml")
+	if err != nil {
+		return "", 0, err
+	}
+	if !ok {
+		return "", 0, fmt.Errorf("opendocument has no content.xml")
+	}
+
+	dec := xml.NewDecoder(bytes.NewReader(content))
+	var b strings.Builder
+	paragraphDepth := 0
+	skipDepth := 0
+	pages := 0
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", 0, fmt.Errorf("failed to parse content.xml: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if skipDepth > 0 {
+				skipDepth++
+				continue
+			}
+			switch t.Name.Local {
+			case "tracked-changes", "annotation":
+				// Teks yang dihapus (track changes) dan komentar tidak termasuk isi dokumen.
+				skipDepth = 1
+			case "page":
+				pages++
+				if pages > maxSlides {
+					return b.String(), pages - 1, nil
+				}
+				b.WriteString("\n\n[Slide " + strconv.Itoa(pages) + "]\n")
+			case "p", "h":
+				paragraphDepth++
+			case "s":
+				count, err := strconv.Atoi(xmlAttr(t, "c"))
+				if err != nil || count < 1 {
+					count = 1
+				}
+				b.WriteString(strings.Repeat(" ", min(count, 64)))
+			case "tab":
+				b.WriteByte('\t')
+			case "line-break":
+				b.WriteByte('\n')
+			}
+		case xml.EndElement:
+			if skipDepth > 0 {
+				skipDepth--
+				continue
+			}
+			if t.Name.Local == "p" || t.Name.Local == "h" {
+				paragraphDepth--
+				b.WriteByte('\n')
+			}
+		case xml.CharData:
+			if skipDepth == 0 && paragraphDepth > 0 {
+				b.Write(t)
+			}
+		}
+	}
+	return b.String(), pages, nil
+}
+
+func xmlAttr(start xml.StartElement, local string) string {
+	for _, attr := range start.Attr {
+		if attr.Name.Local == local {
+			return attr.Value
+		}
+	}
+	return ""
+}
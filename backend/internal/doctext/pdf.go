@@ -0,0 +1,21 @@
+package doctext
+
+import (
+	"api-backend/internal/pdftext"
+	"errors"
+)
+
+// extractPDF memakai pdftext; teks diberi penanda "[Halaman N]" dan units berisi jumlah halaman.
+func extractPDF(data []byte) (string, int, error) {
+	pages, err := pdftext.Extract(data)
+	if err != nil {
+		if errors.Is(err, pdftext.ErrEncrypted) {
+			return "", 0, ErrEncrypted
+		}
+		if errors.Is(err, pdftext.ErrTooLarge) {
+			return "", 0, ErrTooLarge
+		}
+		return "", 0, err
+	}
+	return pdftext.FormatPages(pages), len(pages), nil
+}
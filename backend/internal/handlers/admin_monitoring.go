@@ -572,6 +572,75 @@ func (h *AdminOpsHandlers) AdminMonitoringUsersActivityHandler(w http.ResponseWr
 	})
 }
 
+// AdminAgreementReportHandler is the superadmin-wide equivalent of the teacher agreement report.
+// classId and teacherId are optional; other filters mirror the QWK export.
+func (h *AdminOpsHandlers) AdminAgreementReportHandler(w http.ResponseWriter, r *http.Request) {
+	if h.EssaySubmissionService == nil {
+		respondWithError(w, http.StatusInternalServerError, "Submission service is unavailable")
+		return
+	}
+	query := r.URL.Query()
+	classID := strings.TrimSpace(query.Get("classId"))
+	teacherID := strings.TrimSpace(query.Get("teacherId"))
+	materialID := strings.TrimSpace(query.Get("materialId"))
+	studentID := strings.TrimSpace(query.Get("studentId"))
+	aiStatus, err := normalizeAIStatus(query.Get("aiStatus"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid aiStatus")
+		return
+	}
+	reviewStatus, err := normalizeReviewStatus(query.Get("reviewStatus"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid reviewStatus")
+		return
+	}
+	dateFrom, dateTo, err := parseDateRange(query.Get("dateFrom"), query.Get("dateTo"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid date range")
+		return
+	}
+	binSize, err := parseAgreementBinSize(query.Get("binSize"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "binSize harus angka antara 0 dan 100")
+		return
+	}
+	var questionIDs []string
+	if questionID := strings.TrimSpace(query.Get("questionId")); questionID != "" {
+		questionIDs = []string{questionID}
+	}
+
+	report, err := h.EssaySubmissionService.GetAdminAgreementReport(classID, teacherID, materialID, questionIDs, studentID, aiStatus, reviewStatus, dateFrom, dateTo, strings.TrimSpace(query.Get("q")), binSize)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to build agreement report")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, report)
+}
+
+// AdminPromptInjectionSummaryHandler merekap jawaban yang terdeteksi prompt injection.
+// Query opsional: days (default 7, maksimum 90).
+func (h *AdminOpsHandlers) AdminPromptInjectionSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	if h.EssaySubmissionService == nil {
+		respondWithError(w, http.StatusInternalServerError, "Submission service is unavailable")
+		return
+	}
+	days := 7
+	if raw := strings.TrimSpace(r.URL.Query().Get("days")); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > 90 {
+			respondWithError(w, http.StatusBadRequest, "days must be between 1 and 90")
+			return
+		}
+		days = parsed
+	}
+	summary, err := h.EssaySubmissionService.GetPromptInjectionSummary(days)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to load prompt injection summary")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, summary)
+}
+
 func (h *AdminOpsHandlers) AdminOverrideUpdateGradeHandler(w http.ResponseWriter, r *http.Request) {
 	submissionID := mux.Vars(r)["submissionId"]
 	if strings.TrimSpace(submissionID) == "" {
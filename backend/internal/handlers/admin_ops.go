@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"api-backend/internal/models"
 	"api-backend/internal/services"
 	"bufio"
 	"bytes"
@@ -150,6 +151,47 @@ func (h *AdminOpsHandlers) AdminQueueRetryHandler(w http.ResponseWriter, r *http
 	respondWithJSON(w, http.StatusOK, result)
 }
 
+// AdminQueueRegradeByModelHandler memasukkan ulang submission yang dinilai oleh model tertentu
+// (atau sebelum tanggal tertentu) ke antrean grading. dry_run=true hanya menampilkan kandidat.
+func (h *AdminOpsHandlers) AdminQueueRegradeByModelHandler(w http.ResponseWriter, r *http.Request) {
+	var payload models.RegradeByModelRequest
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	gradedBefore, err := parseAdminQueueDate(payload.GradedBefore, false)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid graded_before format")
+		return
+	}
+	if strings.TrimSpace(payload.ModelName) == "" && gradedBefore == nil {
+		respondWithError(w, http.StatusBadRequest, "model_name or graded_before is required")
+		return
+	}
+
+	result, err := h.EssaySubmissionService.RegradeSubmissionsByModel(payload.ModelName, gradedBefore, payload.IncludeUnknownModel, payload.ClassID, payload.Limit, payload.DryRun)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !payload.DryRun {
+		actorID, _ := r.Context().Value("userID").(string)
+		meta := map[string]interface{}{
+			"model_name":            strings.TrimSpace(payload.ModelName),
+			"graded_before":         strings.TrimSpace(payload.GradedBefore),
+			"include_unknown_model": payload.IncludeUnknownModel,
+			"class_id":              strings.TrimSpace(payload.ClassID),
+			"matched":               result.Matched,
+		}
+		if result.Result != nil {
+			meta["accepted"] = result.Result.Accepted
+			meta["skipped"] = result.Result.Skipped
+		}
+		_ = h.AuditService.LogAction(actorID, "regrade_by_model", "essay_submission", nil, meta)
+	}
+	respondWithJSON(w, http.StatusOK, result)
+}
+
 func (h *AdminOpsHandlers) AdminQueueStopHandler(w http.ResponseWriter, r *http.Request) {
 	var payload struct {
 		SubmissionIDs []string `json:"submission_ids"`
@@ -494,8 +536,10 @@ func (h *AdminOpsHandlers) AdminUpdateAIProviderHandler(w http.ResponseWriter, r
 	}
 
 	var payload struct {
-		Provider string `json:"provider"`
-		Model    string `json:"model"`
+		Provider   string  `json:"provider"`
+		Model      string  `json:"model"`
+		FakeSeed   *string `json:"fake_seed,omitempty"`
+		FakeScript *string `json:"fake_script,omitempty"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
 		respondWithError(w, http.StatusBadRequest, "Invalid request body")
@@ -503,8 +547,8 @@ func (h *AdminOpsHandlers) AdminUpdateAIProviderHandler(w http.ResponseWriter, r
 	}
 
 	provider := strings.ToLower(strings.TrimSpace(payload.Provider))
-	if provider != "gemini" && provider != "litellm" {
-		respondWithError(w, http.StatusBadRequest, "provider harus gemini atau litellm")
+	if !services.IsSupportedAIProvider(provider) {
+		respondWithError(w, http.StatusBadRequest, "provider harus salah satu dari: "+strings.Join(services.SupportedAIProviders(), ", "))
 		return
 	}
 	model := strings.TrimSpace(payload.Model)
@@ -536,6 +580,33 @@ func (h *AdminOpsHandlers) AdminUpdateAIProviderHandler(w http.ResponseWriter, r
 			return
 		}
 	}
+	if provider == "fake" {
+		// Provider fake tidak butuh kredensial; seed/skrip opsional untuk skenario deterministik.
+		fakeEnv := map[string]*string{"AI_FAKE_SEED": payload.FakeSeed, "AI_FAKE_SCRIPT": payload.FakeScript}
+		for _, key := range []string{"AI_FAKE_SEED", "AI_FAKE_SCRIPT"} {
+			if fakeEnv[key] == nil || strings.TrimSpace(*fakeEnv[key]) == "" {
+				continue
+			}
+			value := strings.TrimSpace(*fakeEnv[key])
+			if strings.HasPrefix(value, "[") {
+				// Skrip inline dipadatkan ke satu baris agar aman ditulis ke .env.
+				var compacted bytes.Buffer
+				if err := json.Compact(&compacted, []byte(value)); err != nil {
+					respondWithError(w, http.StatusBadRequest, "fake_script harus JSON array yang valid")
+					return
+				}
+				value = compacted.String()
+			}
+			if err := writeEnvVarToEnvFile(key, value); err != nil {
+				respondWithError(w, http.StatusInternalServerError, "Failed to update .env "+key)
+				return
+			}
+			if setErr := os.Setenv(key, value); setErr != nil {
+				respondWithError(w, http.StatusInternalServerError, "Failed to refresh "+key)
+				return
+			}
+		}
+	}
 
 	if err := writeEnvVarToEnvFile("AI_PROVIDER", provider); err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Failed to update .env AI_PROVIDER")
@@ -615,6 +686,16 @@ var adminSettingWhitelist = map[string]adminSettingMeta{
 		Description: "Batas maksimum antrean grading",
 		Type:        "integer",
 	},
+	"grading_queue_paused": {
+		Key:         "grading_queue_paused",
+		Description: "Jeda klaim job baru di antrean grading (job yang sedang diproses tetap selesai)",
+		Type:        "boolean",
+	},
+	"grading_queue_fairness": {
+		Key:         "grading_queue_fairness",
+		Description: "Penjadwalan antrean grading: class (bergilir per kelas), teacher (bergilir per guru), fifo (urutan masuk)",
+		Type:        "enum",
+	},
 	"superadmin_allow_override_grade": {
 		Key:         "superadmin_allow_override_grade",
 		Description: "Izinkan superadmin edit/hapus nilai",
@@ -645,6 +726,96 @@ var adminSettingWhitelist = map[string]adminSettingMeta{
 		Description: "Interval auto-refresh notifikasi realtime (detik)",
 		Type:        "integer",
 	},
+	"scoring_normalization": {
+		Key:         "scoring_normalization",
+		Description: "Normalisasi skor berbobot: weighted_mean atau per_aspect_max",
+		Type:        "enum",
+	},
+	"ensemble_enabled": {
+		Key:         "ensemble_enabled",
+		Description: "Aktifkan grading ensemble (beberapa sampel AI, median per aspek)",
+		Type:        "boolean",
+	},
+	"ensemble_samples": {
+		Key:         "ensemble_samples",
+		Description: "Jumlah sampel AI per esai saat ensemble aktif",
+		Type:        "integer",
+	},
+	"ensemble_models": {
+		Key:         "ensemble_models",
+		Description: "Daftar model yang digilir antarsampel, dipisah koma (minimal dua model; ensemble tidak berjalan bila kurang)",
+		Type:        "string",
+	},
+	"ensemble_spread_threshold": {
+		Key:         "ensemble_spread_threshold",
+		Description: "Batas selisih skor antarsampel (0-100) sebelum ditandai perlu review guru",
+		Type:        "number",
+	},
+	"grading_cache_enabled": {
+		Key:         "grading_cache_enabled",
+		Description: "Pakai ulang hasil grading untuk esai, rubrik, model, dan versi prompt yang identik",
+		Type:        "boolean",
+	},
+	"grading_cache_ttl_hours": {
+		Key:         "grading_cache_ttl_hours",
+		Description: "Umur maksimum entri cache grading dalam jam (0 = tidak kedaluwarsa)",
+		Type:        "integer",
+	},
+	"grading_cache_max_entries": {
+		Key:         "grading_cache_max_entries",
+		Description: "Jumlah maksimum entri cache grading; entri paling lama tidak dipakai dibuang (0 = tanpa batas)",
+		Type:        "integer",
+	},
+	"few_shot_exemplar_count": {
+		Key:         "few_shot_exemplar_count",
+		Description: "Jumlah maksimum contoh jawaban bernilai guru yang disertakan di prompt grading (0 = nonaktif)",
+		Type:        "integer",
+	},
+	"prompt_injection_action": {
+		Key:         "prompt_injection_action",
+		Description: "Aksi bila jawaban terdeteksi prompt injection: flag (tetap dinilai AI, perlu review), hold (ditahan untuk guru), off",
+		Type:        "enum",
+	},
+	"drift_window_days": {
+		Key:         "drift_window_days",
+		Description: "Jendela bergulir (hari) untuk memantau drift skor AI vs guru",
+		Type:        "integer",
+	},
+	"drift_min_samples": {
+		Key:         "drift_min_samples",
+		Description: "Jumlah minimum pasangan skor AI-guru sebelum drift dinilai",
+		Type:        "integer",
+	},
+	"drift_warning_mean_diff": {
+		Key:         "drift_warning_mean_diff",
+		Description: "Rata-rata selisih skor AI - guru (poin) yang memicu alert warning",
+		Type:        "number",
+	},
+	"drift_critical_mean_diff": {
+		Key:         "drift_critical_mean_diff",
+		Description: "Rata-rata selisih skor AI - guru (poin) yang memicu alert critical",
+		Type:        "number",
+	},
+	"similarity_check_enabled": {
+		Key:         "similarity_check_enabled",
+		Description: "Periksa kemiripan jawaban antarsiswa serta terhadap materi dan jawaban ideal",
+		Type:        "boolean",
+	},
+	"similarity_pair_threshold": {
+		Key:         "similarity_pair_threshold",
+		Description: "Batas kemiripan (Jaccard 0-1) dua jawaban siswa sebelum ditandai mirip",
+		Type:        "number",
+	},
+	"similarity_source_threshold": {
+		Key:         "similarity_source_threshold",
+		Description: "Batas porsi jawaban (0-1) yang sama dengan materi/jawaban ideal sebelum ditandai menyalin",
+		Type:        "number",
+	},
+	"ai_quota_exhausted_action": {
+		Key:         "ai_quota_exhausted_action",
+		Description: "Aksi grading bila kuota token AI habis: defer (dijadwalkan ulang saat reset), reject (gagal), teacher_only (dinilai guru)",
+		Type:        "enum",
+	},
 }
 
 func validateSettingValue(key, value string) (string, error) {
@@ -667,6 +838,14 @@ func validateSettingValue(key, value string) (string, error) {
 	case "superadmin_allow_delete_material":
 		fallthrough
 	case "profile_change_auto_approve":
+		fallthrough
+	case "ensemble_enabled":
+		fallthrough
+	case "grading_cache_enabled":
+		fallthrough
+	case "grading_queue_paused":
+		fallthrough
+	case "similarity_check_enabled":
 		v := strings.ToLower(value)
 		if v != "true" && v != "false" {
 			return "", fmt.Errorf("%s must be true or false", key)
@@ -690,6 +869,94 @@ func validateSettingValue(key, value string) (string, error) {
 			return "", fmt.Errorf("notification_poll_interval_seconds must be between 5 and 300")
 		}
 		return strconv.Itoa(n), nil
+	case "scoring_normalization":
+		v, ok := services.NormalizeScoringNormalization(value)
+		if !ok || value == "" {
+			return "", fmt.Errorf("scoring_normalization must be weighted_mean or per_aspect_max")
+		}
+		return v, nil
+	case "ensemble_samples":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 2 || n > services.MaxEnsembleSamples {
+			return "", fmt.Errorf("ensemble_samples must be between 2 and %d", services.MaxEnsembleSamples)
+		}
+		return strconv.Itoa(n), nil
+	case "ensemble_models":
+		modelNames := services.ParseEnsembleModels(value)
+		if len(modelNames) < services.MinEnsembleModels {
+			return "", fmt.Errorf("ensemble_models must contain at least %d distinct model names", services.MinEnsembleModels)
+		}
+		return strings.Join(modelNames, ","), nil
+	case "ensemble_spread_threshold":
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil || n <= 0 || n > 100 {
+			return "", fmt.Errorf("ensemble_spread_threshold must be greater than 0 and at most 100")
+		}
+		return strconv.FormatFloat(n, 'f', -1, 64), nil
+	case "grading_cache_ttl_hours":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 || n > 8760 {
+			return "", fmt.Errorf("grading_cache_ttl_hours must be between 0 and 8760")
+		}
+		return strconv.Itoa(n), nil
+	case "few_shot_exemplar_count":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 || n > services.MaxFewShotExemplarCount {
+			return "", fmt.Errorf("few_shot_exemplar_count must be between 0 and %d", services.MaxFewShotExemplarCount)
+		}
+		return strconv.Itoa(n), nil
+	case "prompt_injection_action":
+		v, ok := services.NormalizePromptInjectionAction(value)
+		if !ok {
+			return "", fmt.Errorf("prompt_injection_action must be flag, hold, or off")
+		}
+		return v, nil
+	case "drift_window_days":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > 365 {
+			return "", fmt.Errorf("drift_window_days must be between 1 and 365")
+		}
+		return strconv.Itoa(n), nil
+	case "drift_min_samples":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > 1000 {
+			return "", fmt.Errorf("drift_min_samples must be between 1 and 1000")
+		}
+		return strconv.Itoa(n), nil
+	case "drift_warning_mean_diff":
+		fallthrough
+	case "drift_critical_mean_diff":
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil || n <= 0 || n > 100 {
+			return "", fmt.Errorf("%s must be greater than 0 and at most 100", key)
+		}
+		return strconv.FormatFloat(n, 'f', -1, 64), nil
+	case "similarity_pair_threshold":
+		fallthrough
+	case "similarity_source_threshold":
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil || n <= 0 || n > 1 {
+			return "", fmt.Errorf("%s must be greater than 0 and at most 1", key)
+		}
+		return strconv.FormatFloat(n, 'f', -1, 64), nil
+	case "ai_quota_exhausted_action":
+		v, ok := services.NormalizeAIQuotaAction(value)
+		if !ok {
+			return "", fmt.Errorf("ai_quota_exhausted_action must be defer, reject, or teacher_only")
+		}
+		return v, nil
+	case "grading_queue_fairness":
+		v, ok := services.NormalizeGradingQueueFairness(value)
+		if !ok || value == "" {
+			return "", fmt.Errorf("grading_queue_fairness must be class, teacher, or fifo")
+		}
+		return v, nil
+	case "grading_cache_max_entries":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 || n > 1000000 {
+			return "", fmt.Errorf("grading_cache_max_entries must be between 0 and 1000000")
+		}
+		return strconv.Itoa(n), nil
 	default:
 		return "", fmt.Errorf("setting is not allowed")
 	}
@@ -714,6 +981,50 @@ func (h *AdminOpsHandlers) AdminListSettingsHandler(w http.ResponseWriter, r *ht
 				meta.Value = mode
 			} else if key == "notification_poll_interval_seconds" {
 				meta.Value = "30"
+			} else if key == "scoring_normalization" {
+				meta.Value = services.ScoringNormalizationWeightedMean
+			} else if key == "ensemble_enabled" {
+				meta.Value = "false"
+			} else if key == "ensemble_samples" {
+				meta.Value = strconv.Itoa(services.DefaultEnsembleSamples)
+			} else if key == "ensemble_spread_threshold" {
+				meta.Value = strconv.FormatFloat(services.DefaultEnsembleSpreadThreshold, 'f', -1, 64)
+			} else if key == "grading_cache_enabled" {
+				meta.Value = "true"
+			} else if key == "grading_cache_ttl_hours" {
+				meta.Value = strconv.Itoa(services.DefaultGradingCacheTTLHours)
+			} else if key == "grading_cache_max_entries" {
+				meta.Value = strconv.Itoa(services.DefaultGradingCacheMaxEntries)
+			} else if key == "few_shot_exemplar_count" {
+				meta.Value = strconv.Itoa(services.DefaultFewShotExemplarCount)
+			} else if key == "prompt_injection_action" {
+				meta.Value = services.PromptInjectionActionFlag
+			} else if key == "drift_window_days" {
+				meta.Value = strconv.Itoa(services.DefaultDriftWindowDays)
+			} else if key == "drift_min_samples" {
+				meta.Value = strconv.Itoa(services.DefaultDriftMinSamples)
+			} else if key == "drift_warning_mean_diff" {
+				meta.Value = strconv.FormatFloat(services.DefaultDriftWarningMeanDiff, 'f', -1, 64)
+			} else if key == "drift_critical_mean_diff" {
+				meta.Value = strconv.FormatFloat(services.DefaultDriftCriticalMeanDiff, 'f', -1, 64)
+			} else if key == "similarity_check_enabled" {
+				meta.Value = "true"
+			} else if key == "similarity_pair_threshold" {
+				meta.Value = strconv.FormatFloat(services.DefaultSimilarityPairThreshold, 'f', -1, 64)
+			} else if key == "similarity_source_threshold" {
+				meta.Value = strconv.FormatFloat(services.DefaultSimilaritySourceThreshold, 'f', -1, 64)
+			} else if key == "ai_quota_exhausted_action" {
+				meta.Value = services.AIQuotaActionDefer
+			} else if key == "ai_retry_enabled" {
+				meta.Value = "true"
+			} else if key == "ai_retry_max_attempts" {
+				meta.Value = strconv.Itoa(services.DefaultGradingRetryMaxAttempts)
+			} else if key == "queue_max_size" {
+				meta.Value = strconv.Itoa(services.DefaultGradingQueueMaxSize)
+			} else if key == "grading_queue_paused" {
+				meta.Value = "false"
+			} else if key == "grading_queue_fairness" {
+				meta.Value = services.GradingQueueFairnessClass
 			} else {
 				meta.Value = ""
 			}
@@ -1,10 +1,13 @@
 package handlers
 
 import (
+	"api-backend/internal/models"
+	"api-backend/internal/services"
 	"api-backend/internal/utils"
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
 	"sort"
 	"strconv"
@@ -335,13 +338,14 @@ func (h *AdminOpsHandlers) AdminAnomalyAlertsHandler(w http.ResponseWriter, r *h
 	}
 
 	type alert struct {
-		ID       string  `json:"id"`
-		Level    string  `json:"level"`
-		Title    string  `json:"title"`
-		Detail   string  `json:"detail"`
-		Value    float64 `json:"value"`
-		Unit     string  `json:"unit"`
-		Observed string  `json:"observed_at"`
+		ID        string      `json:"id"`
+		Level     string      `json:"level"`
+		Title     string      `json:"title"`
+		Detail    string      `json:"detail"`
+		Value     float64     `json:"value"`
+		Unit      string      `json:"unit"`
+		Observed  string      `json:"observed_at"`
+		DrillDown interface{} `json:"drill_down,omitempty"` // Grup drift yang bermasalah beserta soal penyumbangnya.
 	}
 
 	alerts := make([]alert, 0)
@@ -434,6 +438,43 @@ func (h *AdminOpsHandlers) AdminAnomalyAlertsHandler(w http.ResponseWriter, r *h
 		})
 	}
 
+	// Drift AI vs guru memakai jendela sendiri (drift_window_days) karena review guru datang lebih lambat.
+	if driftReport, driftErr := services.NewDriftMonitorService(h.DB, h.SettingService).BuildReport(0); driftErr != nil {
+		log.Printf("WARNING: failed to build drift report for anomaly alerts: %v", driftErr)
+	} else {
+		dimensions := []struct {
+			Key    string
+			Title  string
+			Groups []models.DriftGroup
+		}{
+			{Key: "question", Title: "Drift AI vs guru per soal", Groups: driftReport.ByQuestion},
+			{Key: "teacher", Title: "Drift AI vs guru per guru", Groups: driftReport.ByTeacher},
+			{Key: "model", Title: "Drift AI vs guru per model", Groups: driftReport.ByModel},
+		}
+		for _, dimension := range dimensions {
+			flagged := services.FlaggedGroups(dimension.Groups)
+			if len(flagged) == 0 {
+				continue
+			}
+			worst := flagged[0]
+			detail := fmt.Sprintf("%d %s bermasalah dalam %d hari terakhir; terburuk: %s (selisih %+.1f poin, n=%d)",
+				len(flagged), dimension.Key, driftReport.Thresholds.WindowDays, worst.Label, worst.MeanSignedDiff, worst.N)
+			if worst.QWK != nil {
+				detail += fmt.Sprintf(", QWK %.2f", *worst.QWK)
+			}
+			alerts = append(alerts, alert{
+				ID:        "ai-drift-" + dimension.Key,
+				Level:     worst.Level,
+				Title:     dimension.Title,
+				Detail:    detail,
+				Value:     worst.MeanSignedDiff,
+				Unit:      "points",
+				Observed:  driftReport.GeneratedAt.Format(time.RFC3339),
+				DrillDown: flagged,
+			})
+		}
+	}
+
 	respondWithJSON(w, http.StatusOK, map[string]interface{}{
 		"items":      alerts,
 		"window_day": days,
@@ -441,6 +482,41 @@ func (h *AdminOpsHandlers) AdminAnomalyAlertsHandler(w http.ResponseWriter, r *h
 	})
 }
 
+// AdminGradingDriftHandler mengembalikan laporan drift AI vs guru lengkap per soal, guru, dan model.
+// Query opsional: days (default setting drift_window_days), dimension (question|teacher|model).
+func (h *AdminOpsHandlers) AdminGradingDriftHandler(w http.ResponseWriter, r *http.Request) {
+	days := 0
+	if raw := strings.TrimSpace(r.URL.Query().Get("days")); raw != "" {
+		parsed, parseErr := strconv.Atoi(raw)
+		if parseErr != nil || parsed < 1 || parsed > 365 {
+			respondWithError(w, http.StatusBadRequest, "days must be between 1 and 365")
+			return
+		}
+		days = parsed
+	}
+	dimension := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("dimension")))
+	if dimension != "" && dimension != "question" && dimension != "teacher" && dimension != "model" {
+		respondWithError(w, http.StatusBadRequest, "dimension must be question, teacher, or model")
+		return
+	}
+
+	report, err := services.NewDriftMonitorService(h.DB, h.SettingService).BuildReport(days)
+	if err != nil {
+		log.Printf("ERROR: failed to build drift report: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to build drift report")
+		return
+	}
+	switch dimension {
+	case "question":
+		report.ByTeacher, report.ByModel = nil, nil
+	case "teacher":
+		report.ByQuestion, report.ByModel = nil, nil
+	case "model":
+		report.ByQuestion, report.ByTeacher = nil, nil
+	}
+	respondWithJSON(w, http.StatusOK, report)
+}
+
 func parseReportDate(raw string, isEnd bool) (*time.Time, error) {
 	raw = strings.TrimSpace(raw)
 	if raw == "" {
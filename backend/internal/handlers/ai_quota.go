@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"api-backend/internal/models"
+	"api-backend/internal/services"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// AIQuotaHandlers mengelola kuota token AI per kelas/guru (superadmin) dan sisa kuota guru.
+type AIQuotaHandlers struct {
+	Service      *services.AIQuotaService
+	AuditService *services.AdminAuditService
+}
+
+func NewAIQuotaHandlers(service *services.AIQuotaService, auditService *services.AdminAuditService) *AIQuotaHandlers {
+	return &AIQuotaHandlers{Service: service, AuditService: auditService}
+}
+
+// respondAIQuotaExceeded mengirim 429 bila err adalah kuota token yang habis. Mengembalikan true bila sudah direspons.
+func respondAIQuotaExceeded(w http.ResponseWriter, err error) bool {
+	var quotaErr *services.AIQuotaExceededError
+	if !errors.As(err, &quotaErr) {
+		return false
+	}
+	message := ""
+	scope := "sistem"
+	switch quotaErr.ScopeType {
+	case services.AIQuotaScopeDailyRequests:
+		message = fmt.Sprintf("Batas request AI harian sudah tercapai. Coba lagi setelah %s.", quotaErr.ResetAt.Format("02-01-2006 15:04"))
+	case services.AIQuotaScopeClass:
+		scope = "kelas"
+	case services.AIQuotaScopeTeacher:
+		scope = "guru"
+	}
+	if message == "" {
+		message = fmt.Sprintf("Kuota token AI harian %s sudah habis. Coba lagi setelah %s.", scope, quotaErr.ResetAt.Format("02-01-2006 15:04"))
+	}
+	respondWithJSON(w, http.StatusTooManyRequests, map[string]interface{}{
+		"message":    message,
+		"scope_type": quotaErr.ScopeType,
+		"feature":    quotaErr.Feature,
+		"limit":      quotaErr.Limit,
+		"used":       quotaErr.Used,
+		"reset_at":   quotaErr.ResetAt,
+	})
+	return true
+}
+
+// GetMyAIQuotaHandler mengembalikan sisa kuota AI hari ini untuk guru yang login beserta kelas-kelasnya.
+func (h *AIQuotaHandlers) GetMyAIQuotaHandler(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value("userID").(string)
+	status, err := h.Service.TeacherStatus(userID)
+	if err != nil {
+		log.Printf("ERROR: failed to load AI quota status for %s: %v", userID, err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to load AI quota")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, status)
+}
+
+// AdminListAIQuotasHandler mengembalikan semua kuota beserta pemakaian hari ini.
+func (h *AIQuotaHandlers) AdminListAIQuotasHandler(w http.ResponseWriter, r *http.Request) {
+	items, err := h.Service.List()
+	if err != nil {
+		log.Printf("ERROR: failed to list AI quotas: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to load AI quotas")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"items":            items,
+		"exhausted_action": h.Service.ExhaustedAction(),
+	})
+}
+
+// AdminUpsertAIQuotaHandler membuat atau memperbarui kuota kelas/guru.
+func (h *AIQuotaHandlers) AdminUpsertAIQuotaHandler(w http.ResponseWriter, r *http.Request) {
+	actorID, _ := r.Context().Value("userID").(string)
+	var req models.UpsertAIQuotaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if strings.TrimSpace(req.ScopeID) == "" {
+		respondWithError(w, http.StatusBadRequest, "scope_id is required")
+		return
+	}
+	item, err := h.Service.Upsert(req, actorID)
+	if err != nil {
+		if errors.Is(err, services.ErrAIQuotaScopeMissing) {
+			respondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if errors.Is(err, services.ErrAIQuotaInvalidScope) || !strings.HasPrefix(err.Error(), "failed") {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		log.Printf("ERROR: failed to save AI quota: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to save AI quota")
+		return
+	}
+	_ = h.AuditService.LogAction(actorID, "upsert_ai_quota", "ai_quota", &item.ID, map[string]interface{}{
+		"scope_type":        item.ScopeType,
+		"scope_id":          item.ScopeID,
+		"feature":           item.Feature,
+		"daily_token_limit": item.DailyTokenLimit,
+	})
+	respondWithJSON(w, http.StatusOK, item)
+}
+
+// AdminDeleteAIQuotaHandler menghapus kuota.
+func (h *AIQuotaHandlers) AdminDeleteAIQuotaHandler(w http.ResponseWriter, r *http.Request) {
+	actorID, _ := r.Context().Value("userID").(string)
+	quotaID := mux.Vars(r)["quotaId"]
+	if err := h.Service.Delete(quotaID); err != nil {
+		if errors.Is(err, services.ErrAIQuotaNotFound) {
+			respondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		log.Printf("ERROR: failed to delete AI quota %s: %v", quotaID, err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete AI quota")
+		return
+	}
+	_ = h.AuditService.LogAction(actorID, "delete_ai_quota", "ai_quota", &quotaID, nil)
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "AI quota deleted"})
+}
@@ -70,9 +70,41 @@ func (h *ClassTeachingModuleHandlers) GetClassTeachingModulesByClassIDHandler(w
 		return
 	}
 
+	// Modul lama yang belum pernah diekstrak diproses sekali di sini agar status keterbacaannya tampil ke guru.
+	for i := range modules {
+		if modules[i].ExtractionStatus == models.TeachingModuleTextPending {
+			if _, err := h.Service.TeachingModuleText(&modules[i]); err != nil {
+				log.Printf("INFO: teaching module %s text extraction: %v", modules[i].ID, err)
+			}
+		}
+	}
+
 	respondWithJSON(w, http.StatusOK, modules)
 }
 
+// ReextractClassTeachingModuleHandler mengekstrak ulang teks file modul dan mengembalikan status terbarunya.
+func (h *ClassTeachingModuleHandlers) ReextractClassTeachingModuleHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	moduleID, ok := vars["moduleId"]
+	if !ok || strings.TrimSpace(moduleID) == "" {
+		respondWithError(w, http.StatusBadRequest, "Module ID is missing from URL")
+		return
+	}
+
+	module, err := h.Service.ReextractTeachingModuleText(moduleID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			respondWithError(w, http.StatusNotFound, "Class teaching module not found")
+			return
+		}
+		log.Printf("ERROR: Failed to re-extract class teaching module %s: %v", moduleID, err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to re-extract class teaching module")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, module)
+}
+
 func (h *ClassTeachingModuleHandlers) DeleteClassTeachingModuleHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	moduleID, ok := vars["moduleId"]
@@ -4,6 +4,7 @@ import (
 	"api-backend/internal/models"
 	"api-backend/internal/services"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -48,6 +49,10 @@ func (h *EssayQuestionHandlers) CreateEssayQuestionHandler(w http.ResponseWriter
 
 	createdQuestion, err := h.Service.CreateEssayQuestion(&req)
 	if err != nil {
+		if errors.Is(err, services.ErrInvalidQuestionRubric) {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
 		log.Printf("ERROR: Failed to create essay question: %v", err)
 		respondWithError(w, http.StatusInternalServerError, "Failed to create essay question")
 		return
@@ -98,6 +103,10 @@ func (h *EssayQuestionHandlers) UpdateEssayQuestionHandler(w http.ResponseWriter
 			respondWithError(w, http.StatusNotFound, "Essay question not found")
 			return
 		}
+		if errors.Is(err, services.ErrInvalidQuestionRubric) {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
 		respondWithError(w, http.StatusInternalServerError, "Failed to update essay question")
 		return
 	}
@@ -316,21 +325,32 @@ func isTaskSupportQuestion(q models.EssayQuestion) bool {
 	return false
 }
 
-func buildTeachingModuleContext(modules []models.ClassTeachingModule) string {
+// buildTeachingModuleContext merangkum teks modul ajar kelas untuk prompt generate soal. Teks diambil dari
+// cache ekstraksi di class_teaching_modules sehingga file modul tidak diekstrak ulang di setiap permintaan.
+func (h *EssayQuestionHandlers) buildTeachingModuleContext(classID string) string {
+	if h.ClassTeachingModuleService == nil || strings.TrimSpace(classID) == "" {
+		return ""
+	}
+	modules, err := h.ClassTeachingModuleService.GetClassTeachingModulesByClassID(classID)
+	if err != nil {
+		log.Printf("WARNING: failed to load teaching modules for class %s: %v", classID, err)
+		return ""
+	}
 	if len(modules) == 0 {
 		return ""
 	}
 
 	var b strings.Builder
 	added := 0
-	for i, m := range modules {
+	for i := range modules {
+		m := &modules[i]
 		if strings.TrimSpace(m.NamaModul) == "" {
 			continue
 		}
 		b.WriteString(fmt.Sprintf("Modul %d: %s\n", i+1, strings.TrimSpace(m.NamaModul)))
-		extracted, err := services.ExtractTextFromUploadedPDF(m.FileURL)
+		extracted, err := h.ClassTeachingModuleService.TeachingModuleText(m)
 		if err != nil {
-			b.WriteString("(Teks PDF belum bisa diekstrak, gunakan metadata modul saja)\n\n")
+			b.WriteString("(Teks file modul belum bisa diekstrak, gunakan metadata modul saja)\n\n")
 			continue
 		}
 
@@ -479,9 +499,9 @@ func (h *EssayQuestionHandlers) AutoGenerateEssayQuestionHandler(w http.Response
 		return
 	}
 
-	// RAG source dibatasi hanya dari teks materi.
-	// Konten non-teks seperti media/embed/pdf, termasuk modul PDF kelas, diabaikan.
-	teachingModuleContext := ""
+	// RAG source: teks materi ditambah teks modul ajar kelas yang sudah diekstrak (cache per modul).
+	// Media/embed di dalam materi tetap diabaikan.
+	teachingModuleContext := h.buildTeachingModuleContext(material.ClassID)
 	log.Printf("INFO: Auto-generate source resolved. material_id=%s title=%q chars=%d level=%q", materialID, materialTitle, len([]rune(strings.TrimSpace(plainContent))), targetLevel)
 	if len([]rune(strings.TrimSpace(plainContent))) < 20 {
 		respondWithError(w, http.StatusBadRequest, "Konten materi acuan terlalu pendek. Tambahkan isi materi lebih lengkap sebelum generate.")
@@ -506,8 +526,11 @@ func (h *EssayQuestionHandlers) AutoGenerateEssayQuestionHandler(w http.Response
 		existingQuestionTexts = append(existingQuestionTexts, text)
 	}
 
-	draft, err := h.AIService.GenerateEssayQuestionFromMaterial(materialTitle, plainContent, rubricType, targetLevel, teachingModuleContext, existingQuestionTexts)
+	draft, err := h.AIService.GenerateEssayQuestionFromMaterial(materialTitle, plainContent, rubricType, targetLevel, teachingModuleContext, existingQuestionTexts, h.AIService.UsageScopeForClass(material.ClassID))
 	if err != nil {
+		if respondAIQuotaExceeded(w, err) {
+			return
+		}
 		log.Printf("ERROR: Failed generating essay question draft for material %s: %v", materialID, err)
 		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to auto-generate question: %v", err))
 		return
@@ -632,15 +655,18 @@ func (h *EssayQuestionHandlers) AutoGenerateEssayMetadataHandler(w http.Response
 		return
 	}
 
-	teachingModuleContext := ""
+	teachingModuleContext := h.buildTeachingModuleContext(material.ClassID)
 	log.Printf("INFO: Auto-generate metadata source resolved. material_id=%s title=%q chars=%d level=%q", materialID, materialTitle, len([]rune(strings.TrimSpace(plainContent))), targetLevel)
 	if len([]rune(strings.TrimSpace(plainContent))) < 20 {
 		respondWithError(w, http.StatusBadRequest, "Konten materi acuan terlalu pendek. Tambahkan isi materi lebih lengkap sebelum generate.")
 		return
 	}
 
-	draft, err := h.AIService.GenerateEssayQuestionMetadataFromMaterial(materialTitle, plainContent, req.TeksSoal, rubricType, targetLevel, teachingModuleContext)
+	draft, err := h.AIService.GenerateEssayQuestionMetadataFromMaterial(materialTitle, plainContent, req.TeksSoal, rubricType, targetLevel, teachingModuleContext, h.AIService.UsageScopeForClass(material.ClassID))
 	if err != nil {
+		if respondAIQuotaExceeded(w, err) {
+			return
+		}
 		log.Printf("ERROR: Failed generating essay metadata for material %s: %v", materialID, err)
 		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to auto-generate metadata: %v", err))
 		return
@@ -23,7 +23,7 @@ func normalizeAIStatus(raw string) (string, error) {
 		return "", nil
 	}
 	switch trimmed {
-	case "queued", "processing", "completed", "failed":
+	case "queued", "processing", "completed", "failed", "held", "deferred":
 		return trimmed, nil
 	default:
 		return "", fmt.Errorf("invalid aiStatus")
@@ -36,7 +36,7 @@ func normalizeReviewStatus(raw string) (string, error) {
 		return "", nil
 	}
 	switch trimmed {
-	case "reviewed", "pending":
+	case "reviewed", "pending", "needs_review":
 		return trimmed, nil
 	default:
 		return "", fmt.Errorf("invalid reviewStatus")
@@ -423,6 +423,12 @@ func (h *EssaySubmissionHandlers) CreateEssaySubmissionHandler(w http.ResponseWr
 	}
 
 	newSubmission, gradeResp, err := h.Service.CreateEssaySubmission(req.QuestionID, studentID, req.TeksJawaban)
+	h.respondWithCreatedSubmission(w, newSubmission, gradeResp, err)
+}
+
+// respondWithCreatedSubmission memetakan hasil pembuatan attempt (jawaban diketik atau file yang dikonfirmasi)
+// ke respons HTTP beserta status antrean penilaian AI.
+func (h *EssaySubmissionHandlers) respondWithCreatedSubmission(w http.ResponseWriter, newSubmission *models.EssaySubmission, gradeResp *models.GradeEssayResponse, err error) {
 	if err != nil {
 		if errors.Is(err, services.ErrAttemptLimitReached) {
 			respondWithError(w, http.StatusBadRequest, "Batas attempt sudah tercapai.")
@@ -1078,6 +1084,85 @@ func (h *EssaySubmissionHandlers) ExportClassQWKHandler(w http.ResponseWriter, r
 	writer.Flush()
 }
 
+func parseAgreementBinSize(raw string) (float64, error) {
+	trimmed := strings.TrimSpace(raw)
+	if trimmed == "" {
+		return services.DefaultAgreementScoreBinSize, nil
+	}
+	value, err := strconv.ParseFloat(trimmed, 64)
+	if err != nil || value <= 0 || value > 100 {
+		return 0, fmt.Errorf("invalid binSize")
+	}
+	return value, nil
+}
+
+// GetClassAgreementReportHandler returns AI vs teacher agreement metrics (QWK, Pearson r, MAE,
+// exact/adjacent agreement) per question, aspect, and material using the QWK export filters.
+func (h *EssaySubmissionHandlers) GetClassAgreementReportHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	classID, ok := vars["classId"]
+	if !ok || strings.TrimSpace(classID) == "" {
+		respondWithError(w, http.StatusBadRequest, "Class ID is required")
+		return
+	}
+
+	teacherID, ok := r.Context().Value("userID").(string)
+	if !ok || teacherID == "" {
+		respondWithError(w, http.StatusUnauthorized, "User ID not found in context")
+		return
+	}
+
+	query := r.URL.Query()
+	materialID := strings.TrimSpace(query.Get("materialId"))
+	studentID := strings.TrimSpace(query.Get("studentId"))
+	sectionCardID := strings.TrimSpace(query.Get("sectionCardId"))
+	aiStatus, err := normalizeAIStatus(query.Get("aiStatus"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid aiStatus")
+		return
+	}
+	reviewStatus, err := normalizeReviewStatus(query.Get("reviewStatus"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid reviewStatus")
+		return
+	}
+	dateFrom, dateTo, err := parseDateRange(query.Get("dateFrom"), query.Get("dateTo"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid date range")
+		return
+	}
+	binSize, err := parseAgreementBinSize(query.Get("binSize"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "binSize harus angka antara 0 dan 100")
+		return
+	}
+	q := strings.TrimSpace(query.Get("q"))
+
+	sectionIndex, err := h.Service.BuildSectionCardIndexForClass(classID, teacherID, materialID)
+	if err != nil {
+		log.Printf("ERROR: Failed to build section card index for class %s: %v", classID, err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to resolve section card data")
+		return
+	}
+
+	var questionIDs []string
+	if sectionCardID != "" {
+		questionIDs = filterQuestionIDsBySection(sectionIndex, sectionCardID)
+		if len(questionIDs) == 0 {
+			respondWithJSON(w, http.StatusOK, services.BuildAgreementReport(nil, binSize, false))
+			return
+		}
+	}
+
+	report, err := h.Service.GetClassAgreementReport(classID, teacherID, materialID, questionIDs, studentID, aiStatus, reviewStatus, dateFrom, dateTo, q, sectionIndex, binSize)
+	if err != nil {
+		log.Printf("ERROR: Failed to build agreement report for class %s: %v", classID, err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to build agreement report")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, report)
+}
+
 // ExportClassQuestionSummaryHandler exports per-question summary rows for analysis.
 func (h *EssaySubmissionHandlers) ExportClassQuestionSummaryHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"api-backend/internal/models"
+	"api-backend/internal/services"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// maxSubmissionUploadSize membatasi ukuran file jawaban esai yang diunggah siswa.
+const maxSubmissionUploadSize = 10 << 20
+
+// submissionAttachmentContentTypes memetakan format lampiran ke Content-Type saat diunduh.
+var submissionAttachmentContentTypes = map[string]string{
+	"docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	"pdf":  "application/pdf",
+	"txt":  "text/plain; charset=utf-8",
+}
+
+// CreateSubmissionUploadHandler menerima file jawaban (DOCX/PDF/TXT) dalam form-data `file` beserta
+// `question_id`, lalu mengembalikan teks hasil ekstraksi untuk dikonfirmasi siswa. Jawaban belum dinilai
+// sampai draft dikonfirmasi lewat ConfirmSubmissionUploadHandler.
+func (h *EssaySubmissionHandlers) CreateSubmissionUploadHandler(w http.ResponseWriter, r *http.Request) {
+	studentID, ok := r.Context().Value("userID").(string)
+	if !ok || studentID == "" {
+		respondWithError(w, http.StatusUnauthorized, "User ID not found in context")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxSubmissionUploadSize+(1<<20))
+	if err := r.ParseMultipartForm(maxSubmissionUploadSize); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Ukuran file maksimal 10 MB.")
+		return
+	}
+	questionID := strings.TrimSpace(r.FormValue("question_id"))
+	if questionID == "" {
+		respondWithError(w, http.StatusBadRequest, "Question ID cannot be empty")
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Error retrieving the file")
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxSubmissionUploadSize+1))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Error reading the file")
+		return
+	}
+	if len(data) > maxSubmissionUploadSize {
+		respondWithError(w, http.StatusBadRequest, "Ukuran file maksimal 10 MB.")
+		return
+	}
+
+	upload, err := h.Service.CreateSubmissionUploadDraft(questionID, studentID, header.Filename, data)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrSectionCardUnread):
+			respondWithError(w, http.StatusForbidden, "Selesaikan membaca materi terlebih dahulu sebelum menjawab soal.")
+		case errors.Is(err, services.ErrSubmissionUploadFormat):
+			respondWithError(w, http.StatusBadRequest, "Format file tidak didukung. Unggah file DOCX, PDF, atau TXT.")
+		case errors.Is(err, services.ErrSubmissionUploadEncrypted):
+			respondWithError(w, http.StatusBadRequest, "File dilindungi password. Hapus password lalu unggah ulang.")
+		case errors.Is(err, services.ErrSubmissionUploadNoText):
+			respondWithError(w, http.StatusBadRequest, "Tidak ada teks yang bisa dibaca dari file. File hasil scan/foto belum didukung.")
+		case errors.Is(err, services.ErrSubmissionUploadUnreadable):
+			respondWithError(w, http.StatusBadRequest, "File rusak atau tidak bisa dibaca.")
+		case strings.Contains(err.Error(), "not found"):
+			respondWithError(w, http.StatusNotFound, "Essay question not found")
+		default:
+			log.Printf("ERROR: Failed to create submission upload: %v", err)
+			respondWithError(w, http.StatusInternalServerError, "Failed to process uploaded answer")
+		}
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, upload)
+}
+
+// ConfirmSubmissionUploadHandler mengirim draft upload sebagai jawaban. Body opsional `teks_jawaban` berisi
+// teks yang sudah diperbaiki siswa; tanpa body, teks hasil ekstraksi dipakai apa adanya.
+func (h *EssaySubmissionHandlers) ConfirmSubmissionUploadHandler(w http.ResponseWriter, r *http.Request) {
+	studentID, ok := r.Context().Value("userID").(string)
+	if !ok || studentID == "" {
+		respondWithError(w, http.StatusUnauthorized, "User ID not found in context")
+		return
+	}
+	uploadID := mux.Vars(r)["uploadId"]
+	if uploadID == "" {
+		respondWithError(w, http.StatusBadRequest, "Upload ID is missing")
+		return
+	}
+
+	var req models.ConfirmSubmissionUploadRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			respondWithError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+
+	newSubmission, gradeResp, err := h.Service.ConfirmSubmissionUpload(uploadID, studentID, req.TeksJawaban)
+	switch {
+	case errors.Is(err, services.ErrSubmissionUploadNotFound):
+		respondWithError(w, http.StatusNotFound, "Upload not found")
+		return
+	case errors.Is(err, services.ErrSubmissionUploadConfirmed):
+		respondWithError(w, http.StatusConflict, "Jawaban dari file ini sudah dikirim.")
+		return
+	case errors.Is(err, services.ErrSubmissionUploadEmptyText):
+		respondWithError(w, http.StatusBadRequest, "Teks jawaban tidak boleh kosong.")
+		return
+	}
+	h.respondWithCreatedSubmission(w, newSubmission, gradeResp, err)
+}
+
+// DownloadSubmissionAttachmentHandler mengunduh file jawaban asli dari attempt terakhir submission.
+func (h *EssaySubmissionHandlers) DownloadSubmissionAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value("userID").(string)
+	userRole, _ := r.Context().Value("userRole").(string)
+	if strings.TrimSpace(userID) == "" || strings.TrimSpace(userRole) == "" {
+		respondWithError(w, http.StatusUnauthorized, "User context invalid")
+		return
+	}
+	submissionID := mux.Vars(r)["submissionId"]
+	if submissionID == "" {
+		respondWithError(w, http.StatusBadRequest, "Submission ID is missing")
+		return
+	}
+
+	upload, path, err := h.Service.GetSubmissionAttachmentFile(submissionID, userID, userRole)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrSubmissionAttachmentMissing):
+			respondWithError(w, http.StatusNotFound, "Submission attachment not found")
+		case errors.Is(err, services.ErrSubmissionAttachmentDenied):
+			respondWithError(w, http.StatusForbidden, "You are not allowed to download this attachment")
+		default:
+			log.Printf("ERROR: Failed to load attachment for submission %s: %v", submissionID, err)
+			respondWithError(w, http.StatusInternalServerError, "Failed to retrieve submission attachment")
+		}
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		log.Printf("ERROR: Failed to open attachment for submission %s: %v", submissionID, err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to retrieve submission attachment")
+		return
+	}
+	defer f.Close()
+
+	if contentType, ok := submissionAttachmentContentTypes[upload.FileFormat]; ok {
+		w.Header().Set("Content-Type", contentType)
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": upload.OriginalFileName}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, upload.OriginalFileName, upload.CreatedAt, f)
+}
@@ -37,6 +37,9 @@ func (h *GradeEssayHandlers) GradeEssayHandler(w http.ResponseWriter, r *http.Re
 	// Call the service to perform the grading logic
 	response, err := h.AIService.GradeEssay(req)
 	if err != nil {
+		if respondAIQuotaExceeded(w, err) {
+			return
+		}
 		respondWithError(w, http.StatusInternalServerError, "An error occurred while grading the essay.")
 		return
 	}
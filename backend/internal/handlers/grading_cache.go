@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"api-backend/internal/models"
+	"api-backend/internal/services"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// GradingCacheHandlers menyediakan statistik dan pembersihan cache grading untuk superadmin.
+type GradingCacheHandlers struct {
+	Service      *services.GradingCacheService
+	AuditService *services.AdminAuditService
+}
+
+func NewGradingCacheHandlers(service *services.GradingCacheService, auditService *services.AdminAuditService) *GradingCacheHandlers {
+	return &GradingCacheHandlers{Service: service, AuditService: auditService}
+}
+
+// AdminGradingCacheStatsHandler mengembalikan jumlah entri, hit/miss harian, dan rincian per model.
+// Query opsional: days (default 30, maksimum 365).
+func (h *GradingCacheHandlers) AdminGradingCacheStatsHandler(w http.ResponseWriter, r *http.Request) {
+	days := 30
+	if raw := strings.TrimSpace(r.URL.Query().Get("days")); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			respondWithError(w, http.StatusBadRequest, "days must be a positive integer")
+			return
+		}
+		days = parsed
+	}
+	stats, err := h.Service.Stats(days)
+	if err != nil {
+		log.Printf("ERROR: failed to load grading cache stats: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to load grading cache stats")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, stats)
+}
+
+// AdminPurgeGradingCacheHandler menghapus entri cache per soal, model, umur, atau yang kedaluwarsa.
+func (h *GradingCacheHandlers) AdminPurgeGradingCacheHandler(w http.ResponseWriter, r *http.Request) {
+	actorID, _ := r.Context().Value("userID").(string)
+	var req models.GradingCachePurgeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.OlderThanDays < 0 {
+		respondWithError(w, http.StatusBadRequest, "older_than_days must not be negative")
+		return
+	}
+	if strings.TrimSpace(req.QuestionID) == "" && strings.TrimSpace(req.ModelName) == "" && req.OlderThanDays == 0 && !req.ExpiredOnly && !req.All {
+		respondWithError(w, http.StatusBadRequest, "Provide question_id, model_name, older_than_days, expired_only, or all=true")
+		return
+	}
+
+	deleted, err := h.Service.Purge(req)
+	if err != nil {
+		log.Printf("ERROR: failed to purge grading cache: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to purge grading cache")
+		return
+	}
+	_ = h.AuditService.LogAction(actorID, "purge_grading_cache", "ai_grading_cache", nil, map[string]interface{}{
+		"question_id":     strings.TrimSpace(req.QuestionID),
+		"model_name":      strings.TrimSpace(req.ModelName),
+		"older_than_days": req.OlderThanDays,
+		"expired_only":    req.ExpiredOnly,
+		"all":             req.All,
+		"deleted":         deleted,
+	})
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{"deleted": deleted})
+}
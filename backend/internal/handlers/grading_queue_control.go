@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"api-backend/internal/models"
+	"api-backend/internal/services"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// AdminQueueControlHandler mengembalikan status pause, mode penjadwalan, throughput, dan ETA antrean per kelas.
+func (h *AdminOpsHandlers) AdminQueueControlHandler(w http.ResponseWriter, r *http.Request) {
+	status, err := h.EssaySubmissionService.GetGradingQueueControlStatus()
+	if err != nil {
+		log.Printf("ERROR: failed to load grading queue control status: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to load grading queue status")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, status)
+}
+
+// AdminQueuePauseHandler menjeda klaim job baru di semua worker.
+func (h *AdminOpsHandlers) AdminQueuePauseHandler(w http.ResponseWriter, r *http.Request) {
+	h.setQueuePaused(w, r, true)
+}
+
+// AdminQueueResumeHandler melanjutkan antrean yang dijeda.
+func (h *AdminOpsHandlers) AdminQueueResumeHandler(w http.ResponseWriter, r *http.Request) {
+	h.setQueuePaused(w, r, false)
+}
+
+func (h *AdminOpsHandlers) setQueuePaused(w http.ResponseWriter, r *http.Request, paused bool) {
+	if err := h.EssaySubmissionService.SetGradingQueuePaused(paused); err != nil {
+		log.Printf("ERROR: failed to set grading queue paused=%t: %v", paused, err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to update grading queue state")
+		return
+	}
+	action := "resume_grading_queue"
+	if paused {
+		action = "pause_grading_queue"
+	}
+	actorID, _ := r.Context().Value("userID").(string)
+	_ = h.AuditService.LogAction(actorID, action, "grading_queue", nil, nil)
+	respondWithJSON(w, http.StatusOK, map[string]bool{"paused": paused})
+}
+
+// AdminQueuePriorityHandler menaikkan/menurunkan prioritas job queued.
+func (h *AdminOpsHandlers) AdminQueuePriorityHandler(w http.ResponseWriter, r *http.Request) {
+	var payload models.SetQueuePriorityRequest
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if len(payload.SubmissionIDs) == 0 {
+		respondWithError(w, http.StatusBadRequest, "submission_ids is required")
+		return
+	}
+	if len(payload.SubmissionIDs) > 100 {
+		respondWithError(w, http.StatusBadRequest, "Maximum 100 submission_ids per request")
+		return
+	}
+
+	result, err := h.EssaySubmissionService.SetQueuePriority(payload.SubmissionIDs, payload.Priority)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	actorID, _ := r.Context().Value("userID").(string)
+	_ = h.AuditService.LogAction(actorID, "set_grading_queue_priority", "essay_submission", nil, map[string]interface{}{
+		"submission_ids": payload.SubmissionIDs,
+		"priority":       payload.Priority,
+		"updated":        result.Updated,
+		"skipped":        result.Skipped,
+	})
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+// AdminListQueueClassLimitsHandler mengembalikan batas konkurensi per kelas.
+func (h *AdminOpsHandlers) AdminListQueueClassLimitsHandler(w http.ResponseWriter, r *http.Request) {
+	items, err := h.EssaySubmissionService.ListQueueClassLimits()
+	if err != nil {
+		log.Printf("ERROR: failed to list grading queue class limits: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to load class limits")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{"items": items})
+}
+
+// AdminUpsertQueueClassLimitHandler menetapkan batas konkurensi satu kelas.
+func (h *AdminOpsHandlers) AdminUpsertQueueClassLimitHandler(w http.ResponseWriter, r *http.Request) {
+	actorID, _ := r.Context().Value("userID").(string)
+	var req models.UpsertGradingQueueClassLimitRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	item, err := h.EssaySubmissionService.UpsertQueueClassLimit(req, actorID)
+	if err != nil {
+		if errors.Is(err, services.ErrQueueClassNotFound) {
+			respondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if !strings.HasPrefix(err.Error(), "failed") {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		log.Printf("ERROR: failed to save grading queue class limit: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to save class limit")
+		return
+	}
+	_ = h.AuditService.LogAction(actorID, "upsert_grading_queue_class_limit", "class", &item.ClassID, map[string]interface{}{
+		"max_concurrency": item.MaxConcurrency,
+	})
+	respondWithJSON(w, http.StatusOK, item)
+}
+
+// AdminDeleteQueueClassLimitHandler menghapus batas konkurensi kelas.
+func (h *AdminOpsHandlers) AdminDeleteQueueClassLimitHandler(w http.ResponseWriter, r *http.Request) {
+	actorID, _ := r.Context().Value("userID").(string)
+	classID := mux.Vars(r)["classId"]
+	if err := h.EssaySubmissionService.DeleteQueueClassLimit(classID); err != nil {
+		if errors.Is(err, services.ErrQueueClassLimitNotFound) {
+			respondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		log.Printf("ERROR: failed to delete grading queue class limit %s: %v", classID, err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete class limit")
+		return
+	}
+	_ = h.AuditService.LogAction(actorID, "delete_grading_queue_class_limit", "class", &classID, nil)
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Class limit deleted"})
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"api-backend/internal/models"
+	"api-backend/internal/services"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// PromptTemplateHandlers menyediakan CRUD, preview, dan aktivasi template prompt untuk superadmin.
+type PromptTemplateHandlers struct {
+	Service      *services.PromptTemplateService
+	AuditService *services.AdminAuditService
+}
+
+func NewPromptTemplateHandlers(service *services.PromptTemplateService, auditService *services.AdminAuditService) *PromptTemplateHandlers {
+	return &PromptTemplateHandlers{Service: service, AuditService: auditService}
+}
+
+func respondPromptTemplateError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case errors.Is(err, services.ErrPromptTemplateNotFound):
+		respondWithError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrInvalidPromptTemplate):
+		respondWithError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrPromptTemplateLocked), errors.Is(err, services.ErrPromptTemplateActive):
+		respondWithError(w, http.StatusConflict, err.Error())
+	default:
+		log.Printf("ERROR: %s: %v", fallback, err)
+		respondWithError(w, http.StatusInternalServerError, fallback)
+	}
+}
+
+// AdminListPromptTemplatesHandler mengembalikan ringkasan per jenis template dan semua versinya.
+// Query opsional: name.
+func (h *PromptTemplateHandlers) AdminListPromptTemplatesHandler(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimSpace(r.URL.Query().Get("name"))
+	if name != "" && !services.IsKnownPromptTemplateName(name) {
+		respondWithError(w, http.StatusBadRequest, "Invalid template name")
+		return
+	}
+	summaries, err := h.Service.ListSummaries()
+	if err != nil {
+		respondPromptTemplateError(w, err, "Failed to load prompt templates")
+		return
+	}
+	items, err := h.Service.List(name)
+	if err != nil {
+		respondPromptTemplateError(w, err, "Failed to load prompt templates")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"templates": summaries,
+		"items":     items,
+	})
+}
+
+func (h *PromptTemplateHandlers) AdminGetPromptTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	item, err := h.Service.GetByID(mux.Vars(r)["templateId"])
+	if err != nil {
+		respondPromptTemplateError(w, err, "Failed to load prompt template")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, item)
+}
+
+func (h *PromptTemplateHandlers) AdminCreatePromptTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	actorID, _ := r.Context().Value("userID").(string)
+	var req models.CreatePromptTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if !services.IsKnownPromptTemplateName(req.Name) {
+		respondWithError(w, http.StatusBadRequest, "Invalid template name")
+		return
+	}
+
+	created, err := h.Service.Create(actorID, req)
+	if err != nil {
+		respondPromptTemplateError(w, err, "Failed to create prompt template")
+		return
+	}
+	_ = h.AuditService.LogAction(actorID, "create_prompt_template", "prompt_template", &created.ID, map[string]interface{}{
+		"name":    created.Name,
+		"version": created.Version,
+	})
+	respondWithJSON(w, http.StatusCreated, created)
+}
+
+func (h *PromptTemplateHandlers) AdminUpdatePromptTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	actorID, _ := r.Context().Value("userID").(string)
+	templateID := mux.Vars(r)["templateId"]
+	var req models.UpdatePromptTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Content == nil && req.Description == nil {
+		respondWithError(w, http.StatusBadRequest, "No fields to update")
+		return
+	}
+
+	updated, err := h.Service.Update(templateID, req)
+	if err != nil {
+		respondPromptTemplateError(w, err, "Failed to update prompt template")
+		return
+	}
+	_ = h.AuditService.LogAction(actorID, "update_prompt_template", "prompt_template", &templateID, map[string]interface{}{
+		"name":            updated.Name,
+		"version":         updated.Version,
+		"content_changed": req.Content != nil,
+	})
+	respondWithJSON(w, http.StatusOK, updated)
+}
+
+func (h *PromptTemplateHandlers) AdminDeletePromptTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	actorID, _ := r.Context().Value("userID").(string)
+	templateID := mux.Vars(r)["templateId"]
+	if err := h.Service.Delete(templateID); err != nil {
+		respondPromptTemplateError(w, err, "Failed to delete prompt template")
+		return
+	}
+	_ = h.AuditService.LogAction(actorID, "delete_prompt_template", "prompt_template", &templateID, nil)
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Prompt template deleted"})
+}
+
+// AdminActivatePromptTemplateHandler menjadikan satu versi aktif; versi aktif sebelumnya dinonaktifkan.
+func (h *PromptTemplateHandlers) AdminActivatePromptTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	actorID, _ := r.Context().Value("userID").(string)
+	templateID := mux.Vars(r)["templateId"]
+	activated, err := h.Service.Activate(templateID)
+	if err != nil {
+		respondPromptTemplateError(w, err, "Failed to activate prompt template")
+		return
+	}
+	_ = h.AuditService.LogAction(actorID, "activate_prompt_template", "prompt_template", &templateID, map[string]interface{}{
+		"name":    activated.Name,
+		"version": activated.Version,
+	})
+	respondWithJSON(w, http.StatusOK, activated)
+}
+
+// AdminResetPromptTemplateHandler mengembalikan jenis template ke versi bawaan.
+func (h *PromptTemplateHandlers) AdminResetPromptTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	actorID, _ := r.Context().Value("userID").(string)
+	name := strings.TrimSpace(mux.Vars(r)["name"])
+	if err := h.Service.ResetToBuiltin(name); err != nil {
+		respondPromptTemplateError(w, err, "Failed to reset prompt template")
+		return
+	}
+	_ = h.AuditService.LogAction(actorID, "reset_prompt_template", "prompt_template", nil, map[string]interface{}{
+		"name": name,
+	})
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Prompt template reset to builtin", "name": name})
+}
+
+// AdminPreviewPromptTemplateHandler merender template dengan data contoh tanpa memanggil model.
+func (h *PromptTemplateHandlers) AdminPreviewPromptTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.PreviewPromptTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if strings.TrimSpace(req.TemplateID) == "" && !services.IsKnownPromptTemplateName(req.Name) {
+		respondWithError(w, http.StatusBadRequest, "Invalid template name")
+		return
+	}
+	preview, err := h.Service.Preview(req)
+	if err != nil {
+		respondPromptTemplateError(w, err, "Failed to preview prompt template")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, preview)
+}
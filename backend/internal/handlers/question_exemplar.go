@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"api-backend/internal/models"
+	"api-backend/internal/services"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// QuestionExemplarHandlers menangani contoh kalibrasi (few-shot) yang dipasang guru per soal.
+type QuestionExemplarHandlers struct {
+	Service *services.QuestionExemplarService
+}
+
+func NewQuestionExemplarHandlers(service *services.QuestionExemplarService) *QuestionExemplarHandlers {
+	return &QuestionExemplarHandlers{Service: service}
+}
+
+func respondQuestionExemplarError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case errors.Is(err, services.ErrExemplarQuestionAccess), errors.Is(err, services.ErrExemplarNotFound):
+		respondWithError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrExemplarNotReviewed), errors.Is(err, services.ErrExemplarWrongQuestion):
+		respondWithError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrExemplarLimitReached):
+		respondWithError(w, http.StatusConflict, err.Error())
+	default:
+		log.Printf("ERROR: %s: %v", fallback, err)
+		respondWithError(w, http.StatusInternalServerError, fallback)
+	}
+}
+
+// authorizeQuestion memastikan pemanggil adalah guru pemilik kelas soal (atau superadmin).
+func (h *QuestionExemplarHandlers) authorizeQuestion(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	userID, _ := r.Context().Value("userID").(string)
+	role, _ := r.Context().Value("userRole").(string)
+	questionID := mux.Vars(r)["questionId"]
+	if err := h.Service.EnsureQuestionAccess(questionID, userID, role); err != nil {
+		respondQuestionExemplarError(w, err, "Failed to validate question access")
+		return "", "", false
+	}
+	return questionID, userID, true
+}
+
+// ListQuestionExemplarsHandler mengembalikan contoh yang dipasang pada soal.
+func (h *QuestionExemplarHandlers) ListQuestionExemplarsHandler(w http.ResponseWriter, r *http.Request) {
+	questionID, _, ok := h.authorizeQuestion(w, r)
+	if !ok {
+		return
+	}
+	items, err := h.Service.List(questionID)
+	if err != nil {
+		respondQuestionExemplarError(w, err, "Failed to load exemplars")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{"items": items})
+}
+
+// PinQuestionExemplarHandler memasang submission yang sudah direview sebagai contoh kalibrasi.
+func (h *QuestionExemplarHandlers) PinQuestionExemplarHandler(w http.ResponseWriter, r *http.Request) {
+	questionID, userID, ok := h.authorizeQuestion(w, r)
+	if !ok {
+		return
+	}
+	var req models.PinQuestionExemplarRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if strings.TrimSpace(req.SubmissionID) == "" {
+		respondWithError(w, http.StatusBadRequest, "submission_id is required")
+		return
+	}
+	item, err := h.Service.Pin(questionID, userID, req)
+	if err != nil {
+		respondQuestionExemplarError(w, err, "Failed to pin exemplar")
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, item)
+}
+
+// UnpinQuestionExemplarHandler melepas contoh dari soal.
+func (h *QuestionExemplarHandlers) UnpinQuestionExemplarHandler(w http.ResponseWriter, r *http.Request) {
+	questionID, _, ok := h.authorizeQuestion(w, r)
+	if !ok {
+		return
+	}
+	if err := h.Service.Unpin(questionID, mux.Vars(r)["exemplarId"]); err != nil {
+		respondQuestionExemplarError(w, err, "Failed to unpin exemplar")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Exemplar unpinned"})
+}
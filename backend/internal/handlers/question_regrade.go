@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"api-backend/internal/models"
+	"api-backend/internal/services"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// QuestionRegradeHandlers menangani re-grade massal soal setelah rubrik berubah beserta laporan diff skornya.
+type QuestionRegradeHandlers struct {
+	Service *services.EssaySubmissionService
+}
+
+func NewQuestionRegradeHandlers(service *services.EssaySubmissionService) *QuestionRegradeHandlers {
+	return &QuestionRegradeHandlers{Service: service}
+}
+
+func respondRegradeError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case errors.Is(err, services.ErrRegradeQuestionAccess), errors.Is(err, services.ErrRegradeSubmissionAccess), errors.Is(err, services.ErrRegradeBatchNotFound):
+		respondWithError(w, http.StatusNotFound, err.Error())
+	case !strings.HasPrefix(err.Error(), "failed") && !strings.HasPrefix(err.Error(), "error"):
+		respondWithError(w, http.StatusBadRequest, err.Error())
+	default:
+		log.Printf("ERROR: %s: %v", fallback, err)
+		respondWithError(w, http.StatusInternalServerError, fallback)
+	}
+}
+
+// RegradeQuestionHandler memasukkan ulang semua submission soal ke antrean dengan rubrik terbaru.
+func (h *QuestionRegradeHandlers) RegradeQuestionHandler(w http.ResponseWriter, r *http.Request) {
+	questionID := mux.Vars(r)["questionId"]
+	userID, role := similarityActor(r)
+	if err := h.Service.EnsureRegradeQuestionAccess(questionID, userID, role); err != nil {
+		respondRegradeError(w, err, "Failed to validate question access")
+		return
+	}
+	var req models.QuestionRegradeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	report, err := h.Service.RegradeQuestion(questionID, userID, req)
+	if err != nil {
+		respondRegradeError(w, err, "Failed to regrade question")
+		return
+	}
+	status := http.StatusAccepted
+	if report.DryRun {
+		status = http.StatusOK
+	}
+	respondWithJSON(w, status, report)
+}
+
+// ListQuestionRegradeBatchesHandler mengembalikan riwayat re-grade soal.
+func (h *QuestionRegradeHandlers) ListQuestionRegradeBatchesHandler(w http.ResponseWriter, r *http.Request) {
+	questionID := mux.Vars(r)["questionId"]
+	userID, role := similarityActor(r)
+	if err := h.Service.EnsureRegradeQuestionAccess(questionID, userID, role); err != nil {
+		respondRegradeError(w, err, "Failed to validate question access")
+		return
+	}
+	items, err := h.Service.ListQuestionRegradeBatches(questionID)
+	if err != nil {
+		respondRegradeError(w, err, "Failed to load regrade batches")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{"items": items})
+}
+
+// GetQuestionRegradeReportHandler mengembalikan diff skor lama vs baru per siswa untuk satu batch.
+func (h *QuestionRegradeHandlers) GetQuestionRegradeReportHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	questionID := vars["questionId"]
+	userID, role := similarityActor(r)
+	if err := h.Service.EnsureRegradeQuestionAccess(questionID, userID, role); err != nil {
+		respondRegradeError(w, err, "Failed to validate question access")
+		return
+	}
+	report, err := h.Service.GetQuestionRegradeReport(questionID, vars["batchId"])
+	if err != nil {
+		respondRegradeError(w, err, "Failed to load regrade report")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, report)
+}
+
+// GetAIResultHistoryHandler mengembalikan versi hasil AI sebelumnya untuk satu submission.
+func (h *QuestionRegradeHandlers) GetAIResultHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	submissionID := mux.Vars(r)["submissionId"]
+	userID, role := similarityActor(r)
+	if err := h.Service.EnsureRegradeSubmissionAccess(submissionID, userID, role); err != nil {
+		respondRegradeError(w, err, "Failed to validate submission access")
+		return
+	}
+	items, err := h.Service.GetAIResultHistory(submissionID)
+	if err != nil {
+		respondRegradeError(w, err, "Failed to load AI result history")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{"items": items})
+}
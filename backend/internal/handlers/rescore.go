@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"api-backend/internal/models"
+	"api-backend/internal/services"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// RescoreHandlers menangani rescoring what-if dari skor aspek tersimpan tanpa memanggil model AI.
+type RescoreHandlers struct {
+	Service      *services.EssaySubmissionService
+	AuditService *services.AdminAuditService
+}
+
+func NewRescoreHandlers(service *services.EssaySubmissionService, auditService *services.AdminAuditService) *RescoreHandlers {
+	return &RescoreHandlers{Service: service, AuditService: auditService}
+}
+
+func respondRescoreError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case errors.Is(err, services.ErrRescoreScopeAccess):
+		respondWithError(w, http.StatusNotFound, err.Error())
+	case !strings.HasPrefix(err.Error(), "failed") && !strings.HasPrefix(err.Error(), "error"):
+		respondWithError(w, http.StatusBadRequest, err.Error())
+	default:
+		log.Printf("ERROR: %s: %v", fallback, err)
+		respondWithError(w, http.StatusInternalServerError, fallback)
+	}
+}
+
+func (h *RescoreHandlers) decodeRescoreRequest(w http.ResponseWriter, r *http.Request) (models.RescoreRequest, string, string, bool) {
+	var req models.RescoreRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return req, "", "", false
+	}
+	userID, role := similarityActor(r)
+	scopeType, scopeID, err := h.Service.EnsureRescoreAccess(req, userID, role)
+	if err != nil {
+		respondRescoreError(w, err, "Failed to validate rescore scope")
+		return req, "", "", false
+	}
+	return req, scopeType, scopeID, true
+}
+
+// PreviewRescoreHandler menampilkan delta skor per siswa dan pergeseran distribusi untuk konfigurasi yang diusulkan.
+func (h *RescoreHandlers) PreviewRescoreHandler(w http.ResponseWriter, r *http.Request) {
+	req, scopeType, scopeID, ok := h.decodeRescoreRequest(w, r)
+	if !ok {
+		return
+	}
+	result, err := h.Service.PreviewRescore(scopeType, scopeID, req.Config)
+	if err != nil {
+		respondRescoreError(w, err, "Failed to preview rescore")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+// ApplyRescoreHandler menyimpan skor hasil rescoring dan mencatat jejak auditnya.
+func (h *RescoreHandlers) ApplyRescoreHandler(w http.ResponseWriter, r *http.Request) {
+	req, scopeType, scopeID, ok := h.decodeRescoreRequest(w, r)
+	if !ok {
+		return
+	}
+	userID, _ := similarityActor(r)
+	result, err := h.Service.ApplyRescore(scopeType, scopeID, userID, req)
+	if err != nil {
+		respondRescoreError(w, err, "Failed to apply rescore")
+		return
+	}
+	if h.AuditService != nil {
+		_ = h.AuditService.LogAction(userID, "apply_rescore", "score_rescore_run", result.RunID, map[string]interface{}{
+			"scope_type":             scopeType,
+			"scope_id":               scopeID,
+			"changed":                result.Changed,
+			"mean_delta":             result.MeanDelta,
+			"update_question_config": req.UpdateQuestionConfig,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+// ListRescoreRunsHandler mengembalikan riwayat apply rescoring untuk ?question_id= atau ?class_id=.
+func (h *RescoreHandlers) ListRescoreRunsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	req := models.RescoreRequest{QuestionID: query.Get("question_id"), ClassID: query.Get("class_id")}
+	userID, role := similarityActor(r)
+	scopeType, scopeID, err := h.Service.EnsureRescoreAccess(req, userID, role)
+	if err != nil {
+		respondRescoreError(w, err, "Failed to validate rescore scope")
+		return
+	}
+	items, err := h.Service.ListRescoreRuns(scopeType, scopeID)
+	if err != nil {
+		respondRescoreError(w, err, "Failed to load rescore runs")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{"items": items})
+}
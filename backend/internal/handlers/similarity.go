@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"api-backend/internal/services"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// SimilarityHandlers menampilkan jawaban yang mirip antarsiswa atau menyalin materi/jawaban ideal.
+type SimilarityHandlers struct {
+	Service *services.SimilarityService
+}
+
+func NewSimilarityHandlers(service *services.SimilarityService) *SimilarityHandlers {
+	return &SimilarityHandlers{Service: service}
+}
+
+func respondSimilarityError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case errors.Is(err, services.ErrSimilarityQuestionAccess), errors.Is(err, services.ErrSimilarityClassAccess):
+		respondWithError(w, http.StatusNotFound, err.Error())
+	default:
+		log.Printf("ERROR: %s: %v", fallback, err)
+		respondWithError(w, http.StatusInternalServerError, fallback)
+	}
+}
+
+func similarityActor(r *http.Request) (string, string) {
+	userID, _ := r.Context().Value("userID").(string)
+	role, _ := r.Context().Value("userRole").(string)
+	return userID, role
+}
+
+// GetQuestionSimilarityHandler mengembalikan pasangan jawaban mirip beserta potongan teks yang sama untuk satu soal.
+func (h *SimilarityHandlers) GetQuestionSimilarityHandler(w http.ResponseWriter, r *http.Request) {
+	questionID := mux.Vars(r)["questionId"]
+	userID, role := similarityActor(r)
+	if err := h.Service.EnsureQuestionAccess(questionID, userID, role); err != nil {
+		respondSimilarityError(w, err, "Failed to validate question access")
+		return
+	}
+	report, err := h.Service.QuestionReport(questionID)
+	if err != nil {
+		respondSimilarityError(w, err, "Failed to load similarity report")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, report)
+}
+
+// RecomputeQuestionSimilarityHandler menghitung ulang kemiripan semua submission soal (mis. setelah ambang diubah).
+func (h *SimilarityHandlers) RecomputeQuestionSimilarityHandler(w http.ResponseWriter, r *http.Request) {
+	questionID := mux.Vars(r)["questionId"]
+	userID, role := similarityActor(r)
+	if err := h.Service.EnsureQuestionAccess(questionID, userID, role); err != nil {
+		respondSimilarityError(w, err, "Failed to validate question access")
+		return
+	}
+	checked, err := h.Service.RecomputeQuestion(questionID)
+	if err != nil {
+		respondSimilarityError(w, err, "Failed to recompute similarity")
+		return
+	}
+	report, err := h.Service.QuestionReport(questionID)
+	if err != nil {
+		respondSimilarityError(w, err, "Failed to load similarity report")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"checked_submissions": checked,
+		"report":              report,
+	})
+}
+
+// GetClassSimilarityClustersHandler mengelompokkan siswa dengan jawaban saling mirip di seluruh soal kelas.
+func (h *SimilarityHandlers) GetClassSimilarityClustersHandler(w http.ResponseWriter, r *http.Request) {
+	classID := mux.Vars(r)["classId"]
+	userID, role := similarityActor(r)
+	if err := h.Service.EnsureClassAccess(classID, userID, role); err != nil {
+		respondSimilarityError(w, err, "Failed to validate class access")
+		return
+	}
+	report, err := h.Service.ClassReport(classID)
+	if err != nil {
+		respondSimilarityError(w, err, "Failed to build similarity clusters")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, report)
+}
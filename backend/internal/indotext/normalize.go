@@ -0,0 +1,122 @@
+// Package indotext menyediakan normalisasi, tokenisasi, stopword, dan stemming teks Bahasa Indonesia
+// untuk pencocokan jawaban siswa dengan materi (grounding, pencarian bank soal, deteksi kemiripan).
+package indotext
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Token adalah satu kata ternormalisasi beserta posisinya (offset byte) pada teks asli.
+type Token struct {
+	Text       string
+	Start, End int
+}
+
+// diacriticFold memetakan huruf Latin berdiakritik ke huruf dasarnya (é -> e, ñ -> n).
+var diacriticFold = map[rune]rune{
+	'à': 'a', 'á': 'a', 'â': 'a', 'ã': 'a', 'ä': 'a', 'å': 'a', 'ā': 'a',
+	'ç': 'c', 'č': 'c',
+	'è': 'e', 'é': 'e', 'ê': 'e', 'ë': 'e', 'ē': 'e', 'ě': 'e',
+	'ì': 'i', 'í': 'i', 'î': 'i', 'ï': 'i', 'ī': 'i',
+	'ñ': 'n', 'ń': 'n',
+	'ò': 'o', 'ó': 'o', 'ô': 'o', 'õ': 'o', 'ö': 'o', 'ø': 'o', 'ō': 'o',
+	'š': 's', 'ś': 's',
+	'ù': 'u', 'ú': 'u', 'û': 'u', 'ü': 'u', 'ū': 'u',
+	'ý': 'y', 'ÿ': 'y',
+	'ž': 'z', 'ź': 'z', 'ż': 'z',
+}
+
+// FoldRune menurunkan huruf ke huruf kecil dan melepas diakritiknya.
+func FoldRune(r rune) rune {
+	r = unicode.ToLower(r)
+	if folded, ok := diacriticFold[r]; ok {
+		return folded
+	}
+	return r
+}
+
+// Normalize mengembalikan teks huruf kecil tanpa diakritik, dengan tanda baca diganti spasi
+// dan spasi beruntun dipadatkan. Bentuk ulang ("anak-anak") diringkas menjadi satu kata.
+func Normalize(text string) string {
+	tokens := Tokenize(text)
+	words := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		words = append(words, token.Text)
+	}
+	return strings.Join(words, " ")
+}
+
+// Tokenize memecah teks menjadi kata (huruf/angka). Tanda hubung di tengah kata dipertahankan
+// sebagai bagian kata agar bentuk ulang bisa dikenali, lalu "kata-kata" diringkas menjadi "kata".
+func Tokenize(text string) []Token {
+	tokens := make([]Token, 0, len(text)/5)
+	start := -1
+	var word strings.Builder
+	flush := func(end int) {
+		if start < 0 {
+			return
+		}
+		value := strings.Trim(word.String(), "-")
+		if value != "" {
+			tokens = append(tokens, Token{Text: collapseReduplication(value), Start: start, End: end})
+		}
+		start = -1
+		word.Reset()
+	}
+	for i, r := range text {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if start < 0 {
+				start = i
+			}
+			word.WriteRune(FoldRune(r))
+		case r == '-' && start >= 0 && nextIsWordRune(text[i+1:]):
+			word.WriteRune('-')
+		case unicode.Is(unicode.Mn, r) && start >= 0:
+			// Tanda diakritik terpisah (bentuk NFD) diabaikan tanpa memutus kata.
+		default:
+			flush(i)
+		}
+	}
+	flush(len(text))
+	return tokens
+}
+
+func nextIsWordRune(rest string) bool {
+	r, size := utf8.DecodeRuneInString(rest)
+	return size > 0 && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// collapseReduplication meringkas bentuk ulang ("buku-buku" -> "buku", "tumbuh-tumbuhan" -> "tumbuhan",
+// "bermain-main" -> "bermain"). Kata berhubung lain ("sayur-mayur", "e-mail") digabung tanpa tanda hubung.
+func collapseReduplication(value string) string {
+	if !strings.Contains(value, "-") {
+		return value
+	}
+	parts := strings.Split(value, "-")
+	if len(parts) == 2 {
+		switch {
+		case strings.HasPrefix(parts[1], parts[0]):
+			return parts[1]
+		case strings.HasSuffix(parts[0], parts[1]):
+			return parts[0]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// Terms menghasilkan term pencocokan: token ternormalisasi tanpa stopword dan kata < 3 huruf, lalu di-stem.
+// Urutan dan duplikat dipertahankan agar pemanggil bisa menghitung frekuensi term.
+func Terms(text string) []string {
+	tokens := Tokenize(text)
+	out := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		if utf8.RuneCountInString(token.Text) < 3 || IsStopword(token.Text) {
+			continue
+		}
+		out = append(out, Stem(token.Text))
+	}
+	return out
+}
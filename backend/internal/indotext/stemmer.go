@@ -0,0 +1,226 @@
+package indotext
+
+import "strings"
+
+// minStemLength adalah panjang minimum sisa kata setelah satu imbuhan dilepas. Kata dasar Indonesia
+// umumnya dua suku kata, jadi sisa yang lebih pendek dianggap bukan hasil pemotongan imbuhan.
+const minStemLength = 4
+
+// protectedEndings adalah kata dasar umum yang akhirannya menyerupai partikel/kata ganti milik
+// (-lah, -kah, -tah, -pun, -nya) sehingga tidak boleh dipotong: sekolah, bertanya, hanya.
+var protectedEndings = []string{
+	"sekolah", "salah", "kalah", "jumlah", "langkah", "nikah", "perintah", "ampun", "rumpun",
+	"tanya", "punya", "hanya",
+}
+
+// nasalRoots adalah kata dasar yang memang diawali m-/n-/ny-, sehingga setelah me-/pe- bunyi awalnya
+// tidak dipulihkan ke p-/t-/s-: menikmati -> nikmat (bukan tikmat), menyatakan -> nyata (bukan sata).
+var nasalRoots = map[string]bool{
+	"makan": true, "main": true, "masak": true, "masuk": true, "marah": true, "maaf": true, "makna": true,
+	"minum": true, "minta": true, "milik": true, "mimpi": true, "mohon": true, "muat": true, "mulai": true,
+	"menang": true, "nilai": true, "nikmat": true, "nama": true, "nasihat": true, "nyata": true,
+	"nyanyi": true, "nyala": true, "nyaman": true,
+}
+
+// suffixRoots adalah kata dasar yang akhirannya menyerupai -an/-kan/-i. Bila akhiran yang dilepas membuat
+// awalan tidak bisa dilepas lagi (memakan -> mema, berperan -> berper), kata dasar ini dipulihkan.
+var suffixRoots = map[string]bool{
+	"makan": true, "peran": true, "jalan": true, "tahan": true, "teman": true, "tangan": true, "badan": true,
+	"bulan": true, "hujan": true, "taman": true, "kawan": true, "lawan": true, "depan": true, "cari": true,
+	"beri": true,
+}
+
+// maxPrefixPasses membatasi jumlah awalan bertumpuk yang dilepas (mis. mem-per-, di-per-).
+const maxPrefixPasses = 3
+
+// Stem melepas imbuhan Bahasa Indonesia dari kata ternormalisasi (huruf kecil, tanpa diakritik).
+//
+// Stemmer ini berbasis aturan tanpa kamus kata dasar (mengikuti urutan confix-stripping: partikel,
+// kata ganti milik, akhiran turunan, lalu awalan dengan perubahan bunyi me-/pe-). Hasilnya adalah
+// kunci pencocokan yang konsisten, bukan selalu kata dasar baku: "pendidikan" dan "mendidik"
+// sama-sama menjadi "didik", tetapi kata dasar yang kebetulan mirip imbuhan bisa ikut terpotong.
+func Stem(word string) string {
+	if len(word) < minStemLength+1 || !isASCIILetters(word) {
+		return word
+	}
+
+	stem := word
+	if !hasProtectedEnding(stem) {
+		stem = stripSuffix(stem, "lah", "kah", "tah", "pun")
+	}
+	if !hasProtectedEnding(stem) {
+		stem = stripSuffix(stem, "nya", "ku", "mu")
+	}
+
+	base := stem
+	stem, suffixRemoved := stripDerivationalSuffix(stem)
+	stem, passes := stripPrefixes(stem, suffixRemoved == "an")
+	if suffixRemoved != "" && passes == 0 {
+		// Sisa kata terlalu pendek untuk melepas awalan, tanda akhiran tadi bagian kata dasar: awalan dilepas
+		// lebih dulu dari kata utuh dan hasilnya dipakai bila merupakan kata dasar yang dikenal.
+		if root, rootPasses := stripPrefixes(base, false); rootPasses > 0 && suffixRoots[root] {
+			return root
+		}
+	}
+	return stem
+}
+
+// stripDerivationalSuffix melepas satu akhiran turunan (-an, -kan, -i) dan mengembalikan akhiran yang dilepas.
+func stripDerivationalSuffix(word string) (string, string) {
+	// Kata benda pe-/ke-/per- memakai konfiks -an ("pendidikan" = pe- + didik + -an), sedangkan -kan
+	// dan -i adalah akhiran kata kerja (me-, di-, ter-, memper-).
+	nounConfix := strings.HasPrefix(word, "pe") || strings.HasPrefix(word, "ke")
+	switch {
+	case nounConfix && canStrip(word, "an"):
+		return word[:len(word)-2], "an"
+	case canStrip(word, "kan"):
+		return word[:len(word)-3], "kan"
+	case canStrip(word, "an"):
+		return word[:len(word)-2], "an"
+	case hasVerbalPrefix(word) && canStrip(word, "i") && !strings.HasSuffix(word, "ai") && !strings.HasSuffix(word, "si"):
+		// -ai (pakai, mulai) dan -si (diskusi, informasi) hampir selalu bagian kata dasar.
+		return word[:len(word)-1], "i"
+	}
+	return word, ""
+}
+
+// stripPrefixes melepas awalan bertumpuk dan mengembalikan jumlah awalan yang berhasil dilepas. Pelepasan
+// berhenti di kata dasar nasalRoots agar kemenangan tidak terpotong lagi menjadi tang.
+func stripPrefixes(word string, keConfix bool) (string, int) {
+	passes := 0
+	for ; passes < maxPrefixPasses && !nasalRoots[word]; passes++ {
+		next, ok := stripPrefix(word, keConfix)
+		if !ok {
+			break
+		}
+		word = next
+	}
+	return word, passes
+}
+
+func hasProtectedEnding(word string) bool {
+	for _, ending := range protectedEndings {
+		if strings.HasSuffix(word, ending) {
+			return true
+		}
+	}
+	return false
+}
+
+func isASCIILetters(word string) bool {
+	for i := 0; i < len(word); i++ {
+		if word[i] < 'a' || word[i] > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+func canStrip(word, suffix string) bool {
+	return strings.HasSuffix(word, suffix) && len(word)-len(suffix) >= minStemLength
+}
+
+func stripSuffix(word string, suffixes ...string) string {
+	for _, suffix := range suffixes {
+		if canStrip(word, suffix) {
+			return word[:len(word)-len(suffix)]
+		}
+	}
+	return word
+}
+
+func hasVerbalPrefix(word string) bool {
+	for _, prefix := range []string{"me", "di", "ter", "per"} {
+		if strings.HasPrefix(word, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func isVowel(c byte) bool {
+	return c == 'a' || c == 'i' || c == 'u' || c == 'e' || c == 'o'
+}
+
+// stripPrefix melepas satu awalan. keConfix mengizinkan ke- hanya bila akhiran -an ikut dilepas (konfiks ke-an),
+// karena banyak kata dasar diawali "ke" (kelas, kertas).
+func stripPrefix(word string, keConfix bool) (string, bool) {
+	accept := func(stem string) (string, bool) {
+		if len(stem) < minStemLength {
+			return word, false
+		}
+		return stem, true
+	}
+
+	switch {
+	case strings.HasPrefix(word, "me") || strings.HasPrefix(word, "pe") && !strings.HasPrefix(word, "per"):
+		return stripNasalPrefix(word, accept)
+	case strings.HasPrefix(word, "per"):
+		return accept(word[3:])
+	case strings.HasPrefix(word, "bel") && strings.HasPrefix(word[3:], "ajar"):
+		return accept(word[3:])
+	case strings.HasPrefix(word, "ber"):
+		return accept(word[3:])
+	case strings.HasPrefix(word, "be") && len(word) > 5 && !isVowel(word[2]) && word[3:5] == "er":
+		// be- sebelum suku kata -er- pertama: bekerja -> kerja, beserta -> serta.
+		return accept(word[2:])
+	case strings.HasPrefix(word, "ter"):
+		return accept(word[3:])
+	case strings.HasPrefix(word, "di"):
+		return accept(word[2:])
+	case keConfix && strings.HasPrefix(word, "ke"):
+		return accept(word[2:])
+	}
+	return word, false
+}
+
+// stripNasalPrefix menangani me-/pe- beserta peluluhan bunyi awal kata dasar:
+// meny+vokal <- s (menyapu/sapu), mem+vokal <- p (memakai/pakai), men+vokal <- t (menulis/tulis).
+// meng+vokal tidak dipulihkan ke k- karena tanpa kamus tidak bisa dibedakan dari kata dasar berawalan vokal.
+// Kata dasar di nasalRoots (nikmat, nyata) dipertahankan apa adanya.
+func stripNasalPrefix(word string, accept func(string) (string, bool)) (string, bool) {
+	if strings.HasPrefix(word, "pel") && strings.HasPrefix(word[3:], "ajar") {
+		return accept(word[3:])
+	}
+	// meng-/meny-/peng-/peny- cukup khas sehingga sisa tiga huruf masih diterima (menguap -> uap).
+	acceptShort := func(stem string) (string, bool) {
+		if len(stem) < minStemLength-1 {
+			return word, false
+		}
+		return stem, true
+	}
+	rest := word[2:]
+	switch {
+	case nasalRoots[rest]:
+		return accept(rest)
+	case strings.HasPrefix(rest, "nge") && len(rest) > 4 && !isVowel(rest[3]) && isVowel(rest[4]) && len(rest)-3 >= minStemLength:
+		// menge-/penge- sebelum kata dasar bersuku kata awal terbuka: pengetahuan -> tahu, pengelola -> lola.
+		return accept(rest[3:])
+	case strings.HasPrefix(rest, "ng"):
+		return acceptShort(rest[2:])
+	case strings.HasPrefix(rest, "ny") && len(rest) > 2 && isVowel(rest[2]):
+		return acceptShort("s" + rest[2:])
+	case strings.HasPrefix(rest, "m") && len(rest) > 1:
+		next := rest[1]
+		if isVowel(next) {
+			return accept("p" + rest[1:])
+		}
+		if next == 'b' || next == 'f' || next == 'p' || next == 'v' {
+			return accept(rest[1:])
+		}
+	case strings.HasPrefix(rest, "n") && len(rest) > 1:
+		next := rest[1]
+		if isVowel(next) {
+			return accept("t" + rest[1:])
+		}
+		if strings.IndexByte("cdjstz", next) >= 0 {
+			return accept(rest[1:])
+		}
+	case len(rest) > 0 && strings.IndexByte("lrwy", rest[0]) >= 0:
+		return accept(rest)
+	case strings.HasPrefix(word, "pe") && len(rest) > 0 && !isVowel(rest[0]):
+		// pe- tanpa nasal: pekerja -> kerja, petani -> tani.
+		return accept(rest)
+	}
+	return word, false
+}
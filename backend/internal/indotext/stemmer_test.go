@@ -0,0 +1,74 @@
+package indotext
+
+import (
+	"os"
+	"testing"
+)
+
+func TestStem(t *testing.T) {
+	cases := []struct {
+		word string
+		want string
+	}{
+		// Akhiran yang ternyata bagian kata dasar.
+		{"memakan", "makan"},
+		{"makanan", "makan"},
+		{"dimakan", "makan"},
+		{"berperan", "peran"},
+		{"peran", "peran"},
+		{"berjalan", "jalan"},
+		{"perjalanan", "jalan"},
+		{"memberi", "beri"},
+		{"memberikan", "beri"},
+		{"mencari", "cari"},
+		// Kata dasar berawalan nasal.
+		{"menikmati", "nikmat"},
+		{"menyatakan", "nyata"},
+		{"kenyataan", "nyata"},
+		{"pernyataan", "nyata"},
+		{"pemain", "main"},
+		{"menilai", "nilai"},
+		{"kemenangan", "menang"},
+		{"memimpin", "pimpin"},
+		// menge-/penge-.
+		{"pengetahuan", "tahu"},
+		{"mengetahui", "tahu"},
+		// Peluluhan me-/pe- dan awalan lain.
+		{"pendidikan", "didik"},
+		{"mendidik", "didik"},
+		{"menyapu", "sapu"},
+		{"memakai", "pakai"},
+		{"menulis", "tulis"},
+		{"menguap", "uap"},
+		{"bekerja", "kerja"},
+		{"pekerja", "kerja"},
+		{"petani", "tani"},
+		{"belajar", "ajar"},
+		{"pelajaran", "ajar"},
+		// Kata dasar yang tidak boleh dipotong.
+		{"sekolah", "sekolah"},
+		{"bertanya", "tanya"},
+		{"hanya", "hanya"},
+		{"kelas", "kelas"},
+		{"bersihkan", "bersih"},
+		{"terangkan", "terang"},
+	}
+	for _, tc := range cases {
+		if got := Stem(tc.word); got != tc.want {
+			t.Errorf("Stem(%q) = %q, want %q", tc.word, got, tc.want)
+		}
+	}
+}
+
+func BenchmarkTerms(b *testing.B) {
+	data, err := os.ReadFile("testdata/materi_sample.txt")
+	if err != nil {
+		b.Fatal(err)
+	}
+	text := string(data)
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Terms(text)
+	}
+}
@@ -7,7 +7,15 @@ type AdminQueueSummary struct {
 	Processing int64 `json:"processing"`
 	Completed  int64 `json:"completed"`
 	Failed     int64 `json:"failed"`
-	Total      int64 `json:"total"`
+	Held       int64 `json:"held"`     // Ditahan untuk penilaian guru (prompt injection atau kuota AI habis).
+	Deferred   int64 `json:"deferred"` // Ditunda ke jendela kuota token berikutnya.
+	// InjectionFlagged menghitung submission yang pemeriksaan terakhirnya cocok dengan aturan prompt injection.
+	InjectionFlagged int64 `json:"injection_flagged"`
+	Total            int64 `json:"total"`
+	// RetryScheduled adalah job queued yang menunggu backoff setelah percobaan gagal.
+	RetryScheduled int64 `json:"retry_scheduled"`
+	// DeadLetter adalah job yang gagal setelah semua percobaan habis; bisa di-retry manual.
+	DeadLetter int64 `json:"dead_letter"`
 }
 
 type AdminQueueJob struct {
@@ -26,6 +34,10 @@ type AdminQueueJob struct {
 	AIGradedAt      *time.Time `json:"ai_graded_at,omitempty"`
 	Score           *float64   `json:"score,omitempty"`
 	FeedbackPreview *string    `json:"feedback_preview,omitempty"`
+	JobStatus       *string    `json:"job_status,omitempty"` // Status di grading_jobs: queued | processing | done | failed | dead | cancelled.
+	Attempts        *int       `json:"attempts,omitempty"`
+	MaxAttempts     *int       `json:"max_attempts,omitempty"`
+	NextAttemptAt   *time.Time `json:"next_attempt_at,omitempty"`
 }
 
 type AdminQueueJobListResponse struct {
@@ -58,3 +70,75 @@ type StopQueueResponse struct {
 	Skipped  int                   `json:"skipped"`
 	Details  []StopQueueItemResult `json:"details"`
 }
+
+// RegradeByModelRequest memilih submission yang sudah dinilai untuk dinilai ulang,
+// mis. saat pindah dari gemini-2.5-flash ke model baru.
+type RegradeByModelRequest struct {
+	ModelName           string `json:"model_name,omitempty"`            // Model yang menghasilkan nilai lama.
+	GradedBefore        string `json:"graded_before,omitempty"`         // YYYY-MM-DD atau RFC3339; hasil sebelum waktu ini.
+	IncludeUnknownModel bool   `json:"include_unknown_model,omitempty"` // Sertakan hasil lama yang belum mencatat model.
+	ClassID             string `json:"class_id,omitempty"`
+	Limit               int    `json:"limit,omitempty"`
+	DryRun              bool   `json:"dry_run,omitempty"` // Hanya hitung kandidat tanpa memasukkan ke antrean.
+}
+
+type RegradeByModelCandidate struct {
+	SubmissionID string    `json:"submission_id"`
+	ModelName    *string   `json:"model_name,omitempty"`
+	GeneratedAt  time.Time `json:"generated_at"`
+}
+
+type RegradeByModelResponse struct {
+	Matched    int                       `json:"matched"`
+	DryRun     bool                      `json:"dry_run"`
+	Candidates []RegradeByModelCandidate `json:"candidates"`
+	Result     *RetryQueueResponse       `json:"result,omitempty"`
+}
+
+// GradingQueueClassLimit membatasi jumlah job yang diproses bersamaan untuk satu kelas.
+type GradingQueueClassLimit struct {
+	ClassID        string    `json:"class_id"`
+	ClassName      string    `json:"class_name"`
+	MaxConcurrency int       `json:"max_concurrency"`
+	UpdatedBy      *string   `json:"updated_by,omitempty"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+type UpsertGradingQueueClassLimitRequest struct {
+	ClassID        string `json:"class_id"`
+	MaxConcurrency int    `json:"max_concurrency"`
+}
+
+// SetQueuePriorityRequest menaikkan/menurunkan prioritas job queued; prioritas lebih tinggi diproses lebih dulu.
+type SetQueuePriorityRequest struct {
+	SubmissionIDs []string `json:"submission_ids"`
+	Priority      int      `json:"priority"`
+}
+
+type SetQueuePriorityResponse struct {
+	Updated int `json:"updated"`
+	Skipped int `json:"skipped"`
+}
+
+// GradingQueueClassStatus adalah kondisi antrean satu kelas beserta perkiraan waktu habis.
+type GradingQueueClassStatus struct {
+	ClassID          string     `json:"class_id"`
+	ClassName        string     `json:"class_name"`
+	Queued           int64      `json:"queued"`
+	Processing       int64      `json:"processing"`
+	MaxConcurrency   *int       `json:"max_concurrency,omitempty"`
+	EstimatedDrainAt *time.Time `json:"estimated_drain_at,omitempty"`
+}
+
+// GradingQueueControlStatus adalah status control plane antrean grading.
+type GradingQueueControlStatus struct {
+	Paused               bool                      `json:"paused"`
+	Fairness             string                    `json:"fairness"` // fifo | class | teacher
+	LocalWorkers         int                       `json:"local_workers"`
+	Queued               int64                     `json:"queued"`
+	Processing           int64                     `json:"processing"`
+	ThroughputPerMinute  float64                   `json:"throughput_per_minute"`
+	AvgProcessingSeconds float64                   `json:"avg_processing_seconds"`
+	EstimatedDrainAt     *time.Time                `json:"estimated_drain_at,omitempty"`
+	Classes              []GradingQueueClassStatus `json:"classes"`
+}
@@ -0,0 +1,67 @@
+package models
+
+import "time"
+
+// ConfusionMatrix memetakan kategori skor guru (baris) terhadap kategori skor AI (kolom).
+type ConfusionMatrix struct {
+	Labels []string `json:"labels"`
+	Counts [][]int  `json:"counts"`
+}
+
+// AgreementMetrics berisi metrik kesepakatan AI vs guru untuk satu kelompok data.
+// Nilai nil berarti metrik tidak terdefinisi (mis. data kurang atau varians nol).
+type AgreementMetrics struct {
+	N                 int              `json:"n"`
+	QWK               *float64         `json:"qwk"`
+	PearsonR          *float64         `json:"pearson_r"`
+	MAE               *float64         `json:"mae"`
+	ExactAgreement    *float64         `json:"exact_agreement"`
+	AdjacentAgreement *float64         `json:"adjacent_agreement"`
+	ConfusionMatrix   *ConfusionMatrix `json:"confusion_matrix,omitempty"`
+}
+
+type QuestionAgreement struct {
+	QuestionID    string           `json:"question_id"`
+	QuestionText  string           `json:"question_text"`
+	MaterialID    string           `json:"material_id"`
+	MaterialTitle string           `json:"material_title"`
+	SectionCardID string           `json:"section_card_id,omitempty"`
+	SectionTitle  string           `json:"section_title,omitempty"`
+	Metrics       AgreementMetrics `json:"metrics"`
+}
+
+type AspectAgreement struct {
+	QuestionID   string           `json:"question_id"`
+	QuestionText string           `json:"question_text"`
+	Aspek        string           `json:"aspek"`
+	Metrics      AgreementMetrics `json:"metrics"`
+}
+
+type MaterialAgreement struct {
+	MaterialID    string           `json:"material_id"`
+	MaterialTitle string           `json:"material_title"`
+	ClassID       string           `json:"class_id"`
+	ClassName     string           `json:"class_name"`
+	Metrics       AgreementMetrics `json:"metrics"`
+}
+
+type ClassAgreement struct {
+	ClassID   string           `json:"class_id"`
+	ClassName string           `json:"class_name"`
+	Metrics   AgreementMetrics `json:"metrics"`
+}
+
+// AgreementReport adalah laporan kesepakatan AI vs nilai revisi guru.
+// Skor akhir (0-100) dikelompokkan per ScoreBinSize untuk QWK, exact/adjacent, dan confusion matrix;
+// skor aspek memakai skala integer rubrik apa adanya.
+type AgreementReport struct {
+	ScoreBinSize      float64             `json:"score_bin_size"`
+	TotalSubmissions  int                 `json:"total_submissions"`
+	PairedSubmissions int                 `json:"paired_submissions"`
+	Overall           AgreementMetrics    `json:"overall"`
+	ByQuestion        []QuestionAgreement `json:"by_question"`
+	ByAspect          []AspectAgreement   `json:"by_aspect"`
+	ByMaterial        []MaterialAgreement `json:"by_material"`
+	ByClass           []ClassAgreement    `json:"by_class,omitempty"`
+	GeneratedAt       time.Time           `json:"generated_at"`
+}
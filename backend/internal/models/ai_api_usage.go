@@ -53,6 +53,30 @@ type AdminAPIStatisticsSummary struct {
 	RPDLimit         int64   `json:"rpd_limit"`
 	TodayPromptToken int64   `json:"today_prompt_tokens"`
 	TodayOutputToken int64   `json:"today_output_tokens"`
+	// TokenBudgetExhausted true bila GEMINI_DAILY_TOKEN_LIMIT tercapai; panggilan AI baru diblokir sampai reset harian.
+	TokenBudgetExhausted bool `json:"token_budget_exhausted"`
+	// Limiter adalah utilisasi live token bucket RPM/TPM/RPD yang dipakai semua panggilan AI.
+	Limiter *AIRateLimiterStatus `json:"limiter,omitempty"`
+}
+
+// AIRateLimiterStatus adalah kondisi limiter AI saat ini (bukan dari log): sisa bucket,
+// persentase utilisasi, dan jumlah panggilan yang sedang menunggu per prioritas.
+type AIRateLimiterStatus struct {
+	RPMLimit              int64     `json:"rpm_limit"`
+	RPMAvailable          float64   `json:"rpm_available"`
+	RPMUtilization        float64   `json:"rpm_utilization_percent"`
+	TPMLimit              int64     `json:"tpm_limit"`
+	TPMAvailable          int64     `json:"tpm_available"`
+	TPMUtilization        float64   `json:"tpm_utilization_percent"`
+	RPDLimit              int64     `json:"rpd_limit"`
+	RPDUsed               int64     `json:"rpd_used"`
+	RPDUtilization        float64   `json:"rpd_utilization_percent"`
+	InteractiveWaiting    int       `json:"interactive_waiting"`
+	BulkWaiting           int       `json:"bulk_waiting"`
+	InteractiveThrottled  uint64    `json:"interactive_throttled_total"`
+	BulkThrottled         uint64    `json:"bulk_throttled_total"`
+	NextRequestAvailableS float64   `json:"next_request_in_seconds"`
+	DailyResetAt          time.Time `json:"daily_reset_at"`
 }
 
 type AdminAPIStatisticsResponse struct {
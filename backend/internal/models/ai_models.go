@@ -9,6 +9,9 @@ type RubricCriterion struct {
 // RubricAspect represents a single grading aspect in a rubric for AI grading,
 // containing a list of score criteria.
 type RubricAspect struct {
-	Aspek    string            `json:"aspek"`    // Name of the aspect (e.g., "Coherence").
-	Kriteria []RubricCriterion `json:"kriteria"` // List of criteria for this aspect.
+	Aspek    string            `json:"aspek"`           // Name of the aspect (e.g., "Coherence").
+	Kriteria []RubricCriterion `json:"kriteria"`        // List of criteria for this aspect.
+	Bobot    float64           `json:"bobot,omitempty"` // Aspect weight (Rubric.Bobot); <= 0 is treated as 1.
+	// SkorBand is used by holistic rubrics only: explicit band -> 0-100 score mapping.
+	SkorBand map[int]float64 `json:"skor_band,omitempty"`
 }
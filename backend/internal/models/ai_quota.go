@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// AIQuota adalah kuota token harian untuk satu kelas atau satu guru.
+// Feature: grading, generation (generate soal/metadata), atau all.
+type AIQuota struct {
+	ID              string    `json:"id"`
+	ScopeType       string    `json:"scope_type"` // class | teacher
+	ScopeID         string    `json:"scope_id"`
+	ScopeLabel      string    `json:"scope_label"`
+	Feature         string    `json:"feature"`
+	DailyTokenLimit int64     `json:"daily_token_limit"`
+	UsedToday       int64     `json:"used_today"`
+	Remaining       int64     `json:"remaining"`
+	CreatedBy       *string   `json:"created_by,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// UpsertAIQuotaRequest membuat atau memperbarui kuota untuk kombinasi scope dan feature.
+type UpsertAIQuotaRequest struct {
+	ScopeType       string `json:"scope_type"`
+	ScopeID         string `json:"scope_id"`
+	Feature         string `json:"feature"`
+	DailyTokenLimit int64  `json:"daily_token_limit"`
+}
+
+// AIQuotaUsage adalah pemakaian hari ini terhadap satu batas (global, kelas, atau guru).
+type AIQuotaUsage struct {
+	ScopeType string `json:"scope_type"` // global | class | teacher
+	ScopeID   string `json:"scope_id,omitempty"`
+	Label     string `json:"label"`
+	Feature   string `json:"feature"`
+	Limit     int64  `json:"limit"`
+	Used      int64  `json:"used"`
+	Remaining int64  `json:"remaining"`
+	Exhausted bool   `json:"exhausted"`
+}
+
+// TeacherAIQuotaStatus adalah sisa kuota AI yang terlihat oleh guru.
+type TeacherAIQuotaStatus struct {
+	Global          *AIQuotaUsage  `json:"global,omitempty"`
+	Teacher         []AIQuotaUsage `json:"teacher"`
+	Classes         []AIQuotaUsage `json:"classes"`
+	ExhaustedAction string         `json:"exhausted_action"`
+	ResetAt         time.Time      `json:"reset_at"`
+}
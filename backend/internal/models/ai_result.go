@@ -12,7 +12,23 @@ type AIResult struct {
 	SkorAI         float64   `json:"skor_ai"`                 // Skor numerik yang diberikan oleh AI.
 	UmpanBalikAI   *string   `json:"umpan_balik_ai,omitempty"`// Umpan balik tekstual dari AI (opsional, bisa NULL di DB).
 	LogsRAG        *string   `json:"logs_rag,omitempty"`      // Log dari proses Retrieval Augmented Generation (RAG) jika digunakan (opsional).
-	RawResponse    string    `json:"raw_response,omitempty"`  // Respons mentah dari model AI (opsional).
+	RawResponse    *string   `json:"raw_response,omitempty"`  // Respons mentah dari model AI (opsional).
+	RubricScores   *string   `json:"rubric_scores,omitempty"`   // Skor AI per aspek (JSON GradeEssayAspectScore).
+	AspectEvidence *string   `json:"aspect_evidence,omitempty"` // Justifikasi + kutipan bukti per aspek (JSON AspectEvidence).
+	HolisticBand   *string   `json:"holistic_band,omitempty"`   // Band terpilih untuk rubrik holistik (JSON HolisticBand).
+	ScoringFormula *string   `json:"scoring_formula,omitempty"` // Rumus skor yang dipakai saat hasil ini dibuat.
+	ScoringDetails *string   `json:"scoring_details,omitempty"` // Rincian perhitungan skor (JSON ScoringBreakdown).
+	ScoreSpread    *float64  `json:"score_spread,omitempty"`    // Selisih skor antarsampel ensemble (opsional).
+	EnsembleDetails *string  `json:"ensemble_details,omitempty"` // Rincian sampel ensemble (JSON EnsembleSummary).
+	PromptTemplateVersion *string `json:"prompt_template_version,omitempty"` // Versi template prompt grading, mis. grade_essay@v2.
+	AIProvider       *string `json:"ai_provider,omitempty"`       // Provider yang menghasilkan skor (gemini, litellm, fake).
+	ModelName        *string `json:"model_name,omitempty"`        // Model yang dipanggil; ensemble berisi daftar dipisah koma.
+	PromptHash       *string `json:"prompt_hash,omitempty"`       // SHA-256 prompt grading.
+	GenerationParams *string `json:"generation_params,omitempty"` // Parameter generasi provider (JSON).
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CandidatesTokens int64   `json:"candidates_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+	FromCache        bool    `json:"from_cache"` // true bila skor diambil dari ai_grading_cache.
 	GeneratedAt    time.Time `json:"generated_at"`            // Timestamp ketika hasil AI ini dibuat.
 }
 
@@ -2,14 +2,31 @@ package models
 
 import "time"
 
+// Status ekstraksi teks modul ajar.
+const (
+	TeachingModuleTextPending     = "pending"
+	TeachingModuleTextReady       = "ready"
+	TeachingModuleTextEmpty       = "empty"
+	TeachingModuleTextUnsupported = "unsupported"
+	TeachingModuleTextFailed      = "failed"
+)
+
 type ClassTeachingModule struct {
-	ID         string    `json:"id"`
-	ClassID    string    `json:"class_id"`
-	UploadedBy *string   `json:"uploaded_by,omitempty"`
-	NamaModul  string    `json:"nama_modul"`
-	FileURL    string    `json:"file_url"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
+	ID         string  `json:"id"`
+	ClassID    string  `json:"class_id"`
+	UploadedBy *string `json:"uploaded_by,omitempty"`
+	NamaModul  string  `json:"nama_modul"`
+	FileURL    string  `json:"file_url"`
+	// Teks hasil ekstraksi (dengan penanda halaman) tidak ikut dikirim ke klien karena bisa sangat panjang.
+	ExtractedText      *string    `json:"-"`
+	ExtractedPageCount *int       `json:"extracted_page_count,omitempty"`
+	TextExtractedAt    *time.Time `json:"text_extracted_at,omitempty"`
+	TextSourceURL      *string    `json:"-"`
+	// Status ekstraksi teks (TeachingModuleText*) dan alasannya bila file tidak bisa dibaca AI.
+	ExtractionStatus string    `json:"extraction_status"`
+	ExtractionError  *string   `json:"extraction_error,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
 }
 
 type CreateClassTeachingModuleRequest struct {
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// DriftQuestionRef adalah ringkasan drift satu soal, dipakai untuk drill-down dari alert.
+type DriftQuestionRef struct {
+	QuestionID     string   `json:"question_id"`
+	QuestionText   string   `json:"question_text"`
+	N              int      `json:"n"`
+	MeanSignedDiff float64  `json:"mean_signed_diff"`
+	QWK            *float64 `json:"qwk"`
+}
+
+// DriftGroup adalah statistik AI vs guru untuk satu soal, guru, atau model dalam jendela waktu.
+// MeanSignedDiff = rata-rata (skor AI - skor revisi guru); positif berarti AI memberi skor terlalu tinggi.
+type DriftGroup struct {
+	Dimension      string             `json:"dimension"` // question | teacher | model
+	Key            string             `json:"key"`
+	Label          string             `json:"label"`
+	N              int                `json:"n"`
+	MeanSignedDiff float64            `json:"mean_signed_diff"`
+	MAE            float64            `json:"mae"`
+	QWK            *float64           `json:"qwk"`
+	Level          string             `json:"level"`               // ok | warning | critical | insufficient_data
+	Direction      string             `json:"direction,omitempty"` // over | under
+	Reasons        []string           `json:"reasons,omitempty"`
+	Questions      []DriftQuestionRef `json:"questions,omitempty"` // Soal penyumbang drift terbesar (untuk guru/model).
+}
+
+// DriftThresholds adalah batas yang dipakai untuk menentukan level drift.
+type DriftThresholds struct {
+	WindowDays       int     `json:"window_days"`
+	MinSamples       int     `json:"min_samples"`
+	WarningMeanDiff  float64 `json:"warning_mean_diff"`
+	CriticalMeanDiff float64 `json:"critical_mean_diff"`
+	WarningQWK       float64 `json:"warning_qwk"`
+	CriticalQWK      float64 `json:"critical_qwk"`
+}
+
+// DriftReport merangkum drift AI vs guru per soal, guru, dan model.
+type DriftReport struct {
+	Thresholds        DriftThresholds `json:"thresholds"`
+	PairedSubmissions int             `json:"paired_submissions"`
+	Overall           DriftGroup      `json:"overall"`
+	ByQuestion        []DriftGroup    `json:"by_question"`
+	ByTeacher         []DriftGroup    `json:"by_teacher"`
+	ByModel           []DriftGroup    `json:"by_model"`
+	GeneratedAt       time.Time       `json:"generated_at"`
+}
@@ -0,0 +1,32 @@
+package models
+
+// EnsembleSample adalah hasil satu panggilan model dalam mode ensemble.
+type EnsembleSample struct {
+	Model        string                  `json:"model"`
+	Score        float64                 `json:"score"`
+	AspectScores []GradeEssayAspectScore `json:"aspect_scores"`
+}
+
+// EnsembleAspectSpread merangkum sebaran skor satu aspek rubrik antarsampel.
+type EnsembleAspectSpread struct {
+	Aspek  string `json:"aspek"`
+	Scores []int  `json:"scores"`
+	Median int    `json:"median"` // Median bawah agar tetap berada di skala rubrik.
+	Min    int    `json:"min"`
+	Max    int    `json:"max"`
+	Spread int    `json:"spread"` // Max - Min.
+}
+
+// EnsembleSummary disimpan di ai_results.ensemble_details agar guru dapat melihat
+// seberapa konsisten model menilai satu jawaban.
+type EnsembleSummary struct {
+	RequestedSamples int                    `json:"requested_samples"`
+	Samples          []EnsembleSample       `json:"samples"`
+	FailedSamples    int                    `json:"failed_samples"`
+	Aspects          []EnsembleAspectSpread `json:"aspects"`
+	ScoreSpread      float64                `json:"score_spread"` // Selisih skor akhir tertinggi dan terendah (skala 0-100).
+	MaxAspectSpread  int                    `json:"max_aspect_spread"`
+	Threshold        float64                `json:"threshold"`
+	NeedsReview      bool                   `json:"needs_review"`
+	ReviewReason     string                 `json:"review_reason,omitempty"`
+}
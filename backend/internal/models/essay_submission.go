@@ -7,24 +7,33 @@ import (
 // EssaySubmission merepresentasikan submission esai seorang siswa untuk sebuah pertanyaan esai.
 // Struktur ini berkorespondensi dengan tabel `essay_submissions` di database.
 type EssaySubmission struct {
-	ID              string                  `json:"id"`                         // ID unik submission esai, biasanya UUID.
-	QuestionID      string                  `json:"question_id"`                // ID pertanyaan esai yang dijawab (Foreign Key ke tabel essay_questions).
-	StudentID       string                  `json:"student_id"`                 // ID siswa yang membuat submission (Foreign Key ke tabel users).
-	SubmissionType  string                  `json:"submission_type"`            // essay|task
-	AttemptCount    int                     `json:"attempt_count"`              // Jumlah percobaan submit untuk soal yang sama.
-	TeksJawaban     string                  `json:"teks_jawaban"`               // Teks jawaban esai yang disubmit.
-	SubmittedAt     time.Time               `json:"submitted_at"`               // Timestamp ketika esai disubmit.
-	AIGradingStatus string                  `json:"ai_grading_status"`          // queued|processing|completed|failed
-	AIGradingError  *string                 `json:"ai_grading_error,omitempty"` // Error terakhir proses AI (opsional).
-	AIGradedAt      *time.Time              `json:"ai_graded_at,omitempty"`     // Waktu selesai dinilai AI (opsional).
-	StudentName     string                  `json:"student_name"`               // Nama siswa yang melakukan submission (denormalized).
-	StudentEmail    string                  `json:"student_email"`              // Email siswa yang melakukan submission (denormalized).
-	SkorAI          *float64                `json:"skor_ai,omitempty"`          // Skor AI untuk submission ini (opsional).
-	UmpanBalikAI    *string                 `json:"umpan_balik_ai,omitempty"`   // Umpan balik AI untuk submission ini (opsional).
-	ReviewID        *string                 `json:"review_id,omitempty"`        // ID review guru yang terkait (opsional).
-	RevisedScore    *float64                `json:"revised_score,omitempty"`    // Skor revisi dari guru (opsional).
-	TeacherFeedback *string                 `json:"teacher_feedback,omitempty"` // Umpan balik dari guru (opsional).
-	RubricScores    []GradeEssayAspectScore `json:"rubric_scores,omitempty"`    // Skor AI per aspek rubrik (opsional).
+	ID              string                  `json:"id"`                            // ID unik submission esai, biasanya UUID.
+	QuestionID      string                  `json:"question_id"`                   // ID pertanyaan esai yang dijawab (Foreign Key ke tabel essay_questions).
+	StudentID       string                  `json:"student_id"`                    // ID siswa yang membuat submission (Foreign Key ke tabel users).
+	SubmissionType  string                  `json:"submission_type"`               // essay|task
+	AttemptCount    int                     `json:"attempt_count"`                 // Jumlah percobaan submit untuk soal yang sama.
+	TeksJawaban     string                  `json:"teks_jawaban"`                  // Teks jawaban esai yang disubmit.
+	SubmittedAt     time.Time               `json:"submitted_at"`                  // Timestamp ketika esai disubmit.
+	AIGradingStatus string                  `json:"ai_grading_status"`             // queued|processing|completed|failed
+	AIGradingError  *string                 `json:"ai_grading_error,omitempty"`    // Error terakhir proses AI (opsional).
+	AIGradedAt      *time.Time              `json:"ai_graded_at,omitempty"`        // Waktu selesai dinilai AI (opsional).
+	StudentName     string                  `json:"student_name"`                  // Nama siswa yang melakukan submission (denormalized).
+	StudentEmail    string                  `json:"student_email"`                 // Email siswa yang melakukan submission (denormalized).
+	SkorAI          *float64                `json:"skor_ai,omitempty"`             // Skor AI untuk submission ini (opsional).
+	UmpanBalikAI    *string                 `json:"umpan_balik_ai,omitempty"`      // Umpan balik AI untuk submission ini (opsional).
+	ReviewID        *string                 `json:"review_id,omitempty"`           // ID review guru yang terkait (opsional).
+	RevisedScore    *float64                `json:"revised_score,omitempty"`       // Skor revisi dari guru (opsional).
+	TeacherFeedback *string                 `json:"teacher_feedback,omitempty"`    // Umpan balik dari guru (opsional).
+	RubricScores    []GradeEssayAspectScore `json:"rubric_scores,omitempty"`       // Skor AI per aspek rubrik (opsional).
+	HolisticBand    *HolisticBand           `json:"holistic_band,omitempty"`       // Band AI untuk rubrik holistik (opsional, tanpa skor per aspek).
+	NeedsReview     bool                    `json:"needs_teacher_review"`          // True jika sampel ensemble AI tidak sepakat dan perlu dicek guru.
+	NeedsReviewNote *string                 `json:"needs_review_reason,omitempty"` // Alasan penandaan review (opsional).
+	ScoreSpread     *float64                `json:"score_spread,omitempty"`        // Selisih skor antarsampel ensemble (opsional).
+	QueuePosition   *int                    `json:"queue_position,omitempty"`      // Jumlah job di depan submission ini saat status queued (opsional).
+	EstimatedAt     *time.Time              `json:"estimated_graded_at,omitempty"` // Perkiraan selesai dinilai AI dari throughput terkini (opsional).
+	QueuePaused     bool                    `json:"queue_paused,omitempty"`        // True bila antrean grading sedang dijeda admin.
+	ReviewRecheck   bool                    `json:"review_recheck,omitempty"`      // True bila review guru perlu dicek ulang setelah soal dinilai ulang.
+	Attachment      *SubmissionAttachment   `json:"attachment,omitempty"`          // File jawaban yang diunggah siswa untuk attempt ini (opsional).
 }
 
 // CreateEssaySubmissionRequest mendefinisikan struktur data untuk permintaan
@@ -41,14 +50,15 @@ type UpdateEssaySubmissionRequest struct {
 }
 
 type MaterialStudentSubmissionSummary struct {
-	StudentID           string     `json:"student_id"`
-	StudentName         string     `json:"student_name"`
-	StudentEmail        string     `json:"student_email"`
-	TotalSubmissions    int        `json:"total_submissions"`
-	ReviewedSubmissions int        `json:"reviewed_submissions"`
-	PendingSubmissions  int        `json:"pending_submissions"`
-	AverageFinalScore   *float64   `json:"average_final_score,omitempty"`
-	LatestSubmittedAt   *time.Time `json:"latest_submitted_at,omitempty"`
+	StudentID              string     `json:"student_id"`
+	StudentName            string     `json:"student_name"`
+	StudentEmail           string     `json:"student_email"`
+	TotalSubmissions       int        `json:"total_submissions"`
+	ReviewedSubmissions    int        `json:"reviewed_submissions"`
+	PendingSubmissions     int        `json:"pending_submissions"`
+	NeedsReviewSubmissions int        `json:"needs_review_submissions"`
+	AverageFinalScore      *float64   `json:"average_final_score,omitempty"`
+	LatestSubmittedAt      *time.Time `json:"latest_submitted_at,omitempty"`
 }
 
 type MaterialStudentSubmissionSummaryListResponse struct {
@@ -63,23 +73,24 @@ type ClassStudentSubmissionSummary = MaterialStudentSubmissionSummary
 type ClassStudentSubmissionSummaryListResponse = MaterialStudentSubmissionSummaryListResponse
 
 type QWKExportRow struct {
-	ClassID       string
-	ClassName     string
-	MaterialID    string
-	MaterialTitle string
-	SectionCardID string
-	SectionTitle  string
-	QuestionID    string
-	QuestionText  string
-	StudentID     string
-	StudentName   string
-	StudentEmail  string
-	SubmissionID  string
-	SubmittedAt   time.Time
-	AIScore       *float64
-	RevisedScore  *float64
-	AIStatus      string
-	RubricScores  *string
+	ClassID             string
+	ClassName           string
+	MaterialID          string
+	MaterialTitle       string
+	SectionCardID       string
+	SectionTitle        string
+	QuestionID          string
+	QuestionText        string
+	StudentID           string
+	StudentName         string
+	StudentEmail        string
+	SubmissionID        string
+	SubmittedAt         time.Time
+	AIScore             *float64
+	RevisedScore        *float64
+	AIStatus            string
+	RubricScores        *string
+	TeacherAspectScores *string
 }
 
 type QuestionExportRow struct {
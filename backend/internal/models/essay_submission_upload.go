@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// Status draft upload jawaban esai.
+const (
+	SubmissionUploadDraft     = "draft"
+	SubmissionUploadConfirmed = "confirmed"
+)
+
+// EssaySubmissionUpload adalah file jawaban esai yang diunggah siswa beserta teks hasil ekstraksinya.
+// Berkorespondensi dengan tabel `essay_submission_uploads`; FilePath tidak pernah dikirim ke klien.
+type EssaySubmissionUpload struct {
+	ID               string     `json:"upload_id"`
+	QuestionID       string     `json:"question_id"`
+	StudentID        string     `json:"student_id"`
+	FilePath         string     `json:"-"`
+	OriginalFileName string     `json:"file_name"`
+	FileFormat       string     `json:"file_format"`
+	FileSize         int64      `json:"file_size"`
+	ExtractedText    string     `json:"extracted_text"`
+	PageCount        int        `json:"page_count"`
+	Status           string     `json:"status"`
+	SubmissionID     *string    `json:"submission_id,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	ConfirmedAt      *time.Time `json:"confirmed_at,omitempty"`
+}
+
+// ConfirmSubmissionUploadRequest dikirim siswa setelah memeriksa teks hasil ekstraksi. TeksJawaban opsional;
+// bila diisi, teks yang sudah dirapikan siswa dipakai sebagai jawaban menggantikan hasil ekstraksi.
+type ConfirmSubmissionUploadRequest struct {
+	TeksJawaban *string `json:"teks_jawaban,omitempty"`
+}
+
+// SubmissionAttachment adalah ringkasan file lampiran yang ditampilkan pada submission.
+type SubmissionAttachment struct {
+	UploadID    string    `json:"upload_id"`
+	FileName    string    `json:"file_name"`
+	FileFormat  string    `json:"file_format"`
+	FileSize    int64     `json:"file_size"`
+	DownloadURL string    `json:"download_url"`
+	UploadedAt  time.Time `json:"uploaded_at"`
+}
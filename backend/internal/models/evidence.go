@@ -0,0 +1,17 @@
+package models
+
+// EvidenceQuote adalah kutipan jawaban siswa yang dikutip AI sebagai bukti penilaian.
+type EvidenceQuote struct {
+	Text     string `json:"text"`
+	Verified bool   `json:"verified"` // True jika kutipan benar-benar ada di teks jawaban siswa.
+	Match    string `json:"match"`    // exact | normalized | not_found
+}
+
+// AspectEvidence berisi alasan singkat dan kutipan bukti untuk satu aspek rubrik.
+type AspectEvidence struct {
+	Aspek            string          `json:"aspek"`
+	SkorDiperoleh    int             `json:"skor_diperoleh"`
+	Justifikasi      string          `json:"justifikasi"`
+	Kutipan          []EvidenceQuote `json:"kutipan"`
+	UnverifiedQuotes int             `json:"unverified_quotes"` // Jumlah kutipan yang tidak ditemukan di jawaban (kemungkinan dikarang AI).
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// QuestionExemplar adalah submission yang sudah direview guru dan dipasang sebagai contoh
+// kalibrasi untuk grading AI pada soal yang sama.
+type QuestionExemplar struct {
+	ID              string                  `json:"id"`
+	QuestionID      string                  `json:"question_id"`
+	SubmissionID    string                  `json:"submission_id"`
+	PinnedBy        *string                 `json:"pinned_by,omitempty"`
+	Note            *string                 `json:"note,omitempty"`
+	EssayText       string                  `json:"essay_text"`
+	RevisedScore    float64                 `json:"revised_score"`
+	AspectScores    []GradeEssayAspectScore `json:"aspect_scores,omitempty"`
+	TeacherFeedback *string                 `json:"teacher_feedback,omitempty"`
+	CreatedAt       time.Time               `json:"created_at"`
+}
+
+type PinQuestionExemplarRequest struct {
+	SubmissionID string  `json:"submission_id"`
+	Note         *string `json:"note,omitempty"`
+}
+
+// GradingExemplar adalah contoh yang ikut dikirim dalam prompt grading.
+type GradingExemplar struct {
+	SubmissionID string                  `json:"submission_id"`
+	Essay        string                  `json:"essay"`
+	Score        float64                 `json:"score"`
+	AspectScores []GradeEssayAspectScore `json:"aspect_scores,omitempty"`
+	Feedback     string                  `json:"feedback,omitempty"`
+}
@@ -11,8 +11,20 @@ type GradeEssayRequest struct {
 	Rubric            json.RawMessage `json:"rubric"`                       // Rubrik penilaian dalam format JSON mentah.
 	Essay             string          `json:"essay"`                        // Teks esai yang akan dinilai.
 	RubricMode        string          `json:"rubric_mode,omitempty"`        // Metadata sumber rubrik efektif (mis. effective_question_rubric).
+	RubricType        string          `json:"rubric_type,omitempty"`        // analitik | holistik (kosong = analitik).
 	GroundingContext  string          `json:"grounding_context,omitempty"`  // Konteks materi/RAG yang relevan untuk validasi konsep.
 	GroundingSource   string          `json:"grounding_source,omitempty"`   // Deskripsi singkat sumber konteks.
+	GroundingLog      *GroundingLog   `json:"-"`                            // Sumber potongan hasil retrieval; disimpan ke ai_results.logs_rag.
+	ScoringNormalization string       `json:"scoring_normalization,omitempty"` // Mode normalisasi skor (weighted_mean | per_aspect_max).
+	EnsembleSamples         int      `json:"ensemble_samples,omitempty"`          // Jumlah sampel ensemble (<= 1 berarti satu panggilan biasa).
+	EnsembleModels          []string `json:"ensemble_models,omitempty"`           // Model yang digilir antarsampel (minimal dua model berbeda).
+	EnsembleSpreadThreshold float64  `json:"ensemble_spread_threshold,omitempty"` // Batas selisih skor antarsampel sebelum ditandai perlu review guru.
+	BypassCache             bool     `json:"-"`                                   // Paksa panggilan model baru (mis. re-grade setelah ganti model).
+	QuestionID              string   `json:"-"`                                   // Soal asal; disimpan di cache agar bisa dihapus per soal.
+	Exemplars               []GradingExemplar `json:"exemplars,omitempty"`               // Contoh yang sudah dinilai guru untuk kalibrasi (few-shot).
+	ClassID                 string   `json:"-"`                                   // Kelas asal soal; dipakai untuk atribusi dan kuota token.
+	TeacherID               string   `json:"-"`                                   // Guru pemilik kelas; dipakai untuk atribusi dan kuota token.
+	Bulk                    bool     `json:"-"`                                   // Job antrean grading; limiter AI mendahulukan panggilan interaktif.
 }
 
 // GradeEssayResponse mendefinisikan struktur data untuk respons dari proses penilaian esai.
@@ -21,6 +33,27 @@ type GradeEssayResponse struct {
 	Score        string                  `json:"score"`                    // Skor yang diberikan pada esai.
 	Feedback     string                  `json:"feedback"`                 // Umpan balik tekstual tentang esai.
 	AspectScores []GradeEssayAspectScore `json:"aspect_scores,omitempty"`  // Skor per aspek rubrik.
+	AspectEvidence   []AspectEvidence  `json:"aspect_evidence,omitempty"`   // Justifikasi dan kutipan bukti per aspek.
+	ScoringFormula   string            `json:"scoring_formula,omitempty"`   // Rumus yang dipakai untuk menghitung skor akhir.
+	ScoringBreakdown *ScoringBreakdown `json:"scoring_breakdown,omitempty"` // Rincian perhitungan skor per aspek.
+	Ensemble         *EnsembleSummary  `json:"ensemble,omitempty"`          // Ringkasan sampel bila mode ensemble aktif.
+	Holistic         *HolisticBand     `json:"holistic,omitempty"`          // Band terpilih untuk rubrik holistik (AspectScores kosong).
+	PromptTemplateVersion string       `json:"prompt_template_version,omitempty"` // Versi template prompt grading, mis. grade_essay@v2.
+	Provenance            *GradingProvenance `json:"provenance,omitempty"`          // Provider, model, dan pemakaian token yang menghasilkan skor ini.
+}
+
+// GradingProvenance mencatat asal-usul satu hasil grading agar hasil dapat diaudit
+// dan dinilai ulang ketika model diganti.
+type GradingProvenance struct {
+	Provider         string                 `json:"provider"`
+	Model            string                 `json:"model"`       // Untuk ensemble: daftar model dipisah koma.
+	PromptHash       string                 `json:"prompt_hash"` // SHA-256 dari prompt grading yang dikirim.
+	GenerationParams map[string]interface{} `json:"generation_params,omitempty"`
+	PromptTokens     int64                  `json:"prompt_tokens"`
+	CandidateTokens  int64                  `json:"candidates_tokens"`
+	TotalTokens      int64                  `json:"total_tokens"`
+	FromCache        bool                   `json:"from_cache"`
+	RawResponse      string                 `json:"-"` // Output mentah model; hanya disimpan di ai_results.
 }
 
 // GradeEssayAspectScore merepresentasikan skor AI untuk satu aspek rubrik.
@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// GradingCacheStats merangkum isi cache grading dan rasio hit/miss pada periode tertentu.
+type GradingCacheStats struct {
+	Enabled        bool                    `json:"enabled"`
+	TTLHours       int                     `json:"ttl_hours"`
+	MaxEntries     int                     `json:"max_entries"`
+	Entries        int64                   `json:"entries"`
+	EntryHits      int64                   `json:"entry_hits"` // Total hit_count seluruh entri yang masih ada.
+	ExpiredEntries int64                   `json:"expired_entries"`
+	OldestEntryAt  *time.Time              `json:"oldest_entry_at,omitempty"`
+	NewestEntryAt  *time.Time              `json:"newest_entry_at,omitempty"`
+	TableBytes     int64                   `json:"table_bytes"`
+	Days           int                     `json:"days"`
+	Hits           int64                   `json:"hits"`
+	Misses         int64                   `json:"misses"` // Termasuk entri yang ditemukan tetapi sudah kedaluwarsa.
+	Expired        int64                   `json:"expired"`
+	Writes         int64                   `json:"writes"`
+	Evictions      int64                   `json:"evictions"`
+	HitRate        float64                 `json:"hit_rate"`
+	Daily          []GradingCacheDailyStat `json:"daily"`
+	ByModel        []GradingCacheModelStat `json:"by_model"`
+}
+
+type GradingCacheDailyStat struct {
+	Day       string `json:"day"`
+	Hits      int64  `json:"hits"`
+	Misses    int64  `json:"misses"`
+	Expired   int64  `json:"expired"`
+	Writes    int64  `json:"writes"`
+	Evictions int64  `json:"evictions"`
+}
+
+type GradingCacheModelStat struct {
+	Provider              string `json:"provider"`
+	Model                 string `json:"model"`
+	PromptTemplateVersion string `json:"prompt_template_version"`
+	Entries               int64  `json:"entries"`
+	Hits                  int64  `json:"hits"`
+}
+
+// GradingCachePurgeRequest memilih entri cache yang dihapus. Filter digabung dengan AND.
+type GradingCachePurgeRequest struct {
+	QuestionID    string `json:"question_id,omitempty"`
+	ModelName     string `json:"model_name,omitempty"`
+	OlderThanDays int    `json:"older_than_days,omitempty"`
+	ExpiredOnly   bool   `json:"expired_only,omitempty"`
+	All           bool   `json:"all,omitempty"`
+}
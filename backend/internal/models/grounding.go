@@ -0,0 +1,19 @@
+package models
+
+// GroundingPassage adalah satu potongan materi/modul yang diambil retriever untuk konteks grading.
+type GroundingPassage struct {
+	Source     string  `json:"source"`                // Label sumber, mis. materi/Judul/section_card_2 atau modul/Nama/bagian_1.
+	SourceType string  `json:"source_type"`           // material_summary | material_text | section_card | teaching_module
+	MaterialID string  `json:"material_id,omitempty"` // Materi asal (kosong untuk modul ajar).
+	ModuleID   string  `json:"module_id,omitempty"`   // Modul ajar asal (kosong untuk materi).
+	Score      float64 `json:"score"`                 // Skor BM25 setelah boost materi soal.
+	Text       string  `json:"-"`                     // Teks potongan; hanya dikirim ke prompt, tidak dicatat di log.
+}
+
+// GroundingLog dicatat di ai_results.logs_rag agar sumber konteks grading bisa ditelusuri.
+type GroundingLog struct {
+	Retriever        string             `json:"retriever"`         // Saat ini selalu bm25.
+	IndexFingerprint string             `json:"index_fingerprint"` // Versi isi kelas saat indeks dibangun.
+	IndexedChunks    int                `json:"indexed_chunks"`    // Jumlah potongan di indeks kelas.
+	Passages         []GroundingPassage `json:"passages"`
+}
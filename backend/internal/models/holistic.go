@@ -0,0 +1,20 @@
+package models
+
+// Tipe rubrik soal esai.
+const (
+	RubricTypeAnalitik = "analitik" // Beberapa aspek dinilai terpisah lalu dijumlahkan berbobot.
+	RubricTypeHolistik = "holistik" // Satu skala band; AI memilih satu band beserta deskriptornya.
+)
+
+// HolisticBand adalah band yang dipilih AI untuk rubrik holistik beserta pemetaannya ke skor 0-100.
+type HolisticBand struct {
+	Skala       string          `json:"skala"`             // Nama skala holistik (nama_aspek rubrik).
+	Band        int             `json:"band"`              // Band yang dipilih.
+	MaxBand     int             `json:"max_band"`          // Band tertinggi pada skala.
+	Deskriptor  string          `json:"deskriptor"`        // Deskriptor band yang dipilih (dari rubrik, bukan karangan AI).
+	Skor        float64         `json:"skor"`              // Skor 0-100 hasil pemetaan band sebelum pembulatan.
+	Mapping     string          `json:"mapping"`           // linear | custom (skor_band dari rubrik)
+	Justifikasi string          `json:"justifikasi"`       // Alasan singkat pemilihan band.
+	Kutipan     []EvidenceQuote `json:"kutipan,omitempty"` // Kutipan bukti dari jawaban siswa.
+	Unverified  int             `json:"unverified_quotes"` // Jumlah kutipan yang tidak ditemukan di jawaban.
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// PromptInjectionMatch adalah satu aturan deteksi prompt injection yang cocok dengan jawaban siswa.
+type PromptInjectionMatch struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"` // high | medium
+	Excerpt  string `json:"excerpt"`
+}
+
+type PromptInjectionRuleCount struct {
+	Rule  string `json:"rule"`
+	Count int64  `json:"count"`
+}
+
+type PromptInjectionFlaggedSubmission struct {
+	SubmissionID string                 `json:"submission_id"`
+	QuestionID   string                 `json:"question_id"`
+	StudentID    string                 `json:"student_id"`
+	StudentName  string                 `json:"student_name"`
+	ClassName    string                 `json:"class_name"`
+	Status       string                 `json:"status"`
+	Matches      []PromptInjectionMatch `json:"matches"`
+	CheckedAt    time.Time              `json:"checked_at"`
+}
+
+// PromptInjectionSummary adalah rekap deteksi prompt injection untuk monitoring admin.
+type PromptInjectionSummary struct {
+	Days    int                                `json:"days"`
+	Action  string                             `json:"action"`
+	Checked int64                              `json:"checked"`
+	Flagged int64                              `json:"flagged"`
+	Held    int64                              `json:"held"`
+	ByRule  []PromptInjectionRuleCount         `json:"by_rule"`
+	Recent  []PromptInjectionFlaggedSubmission `json:"recent"`
+}
@@ -0,0 +1,74 @@
+package models
+
+import "time"
+
+// PromptTemplate adalah satu versi template prompt AI yang disimpan di database.
+// Versi yang sudah pernah diaktifkan tidak boleh diubah; buat versi baru untuk perubahan.
+type PromptTemplate struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"` // grade_essay | generate_question | generate_question_metadata
+	Version     int        `json:"version"`
+	Content     string     `json:"content"`
+	Description *string    `json:"description,omitempty"`
+	IsActive    bool       `json:"is_active"`
+	CreatedBy   *string    `json:"created_by,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	ActivatedAt *time.Time `json:"activated_at,omitempty"`
+}
+
+// PromptTemplateSummary merangkum template per nama beserta versi yang sedang dipakai.
+type PromptTemplateSummary struct {
+	Name           string   `json:"name"`
+	ActiveVersion  string   `json:"active_version"` // mis. grade_essay@v3 atau grade_essay@builtin
+	Placeholders   []string `json:"placeholders"`
+	Required       []string `json:"required_placeholders"`
+	BuiltinContent string   `json:"builtin_content"`
+	VersionCount   int      `json:"version_count"`
+}
+
+type CreatePromptTemplateRequest struct {
+	Name        string  `json:"name"`
+	Content     string  `json:"content"`
+	Description *string `json:"description,omitempty"`
+}
+
+type UpdatePromptTemplateRequest struct {
+	Content     *string `json:"content,omitempty"`
+	Description *string `json:"description,omitempty"`
+}
+
+// PreviewPromptTemplateRequest merender template dengan data contoh (atau data yang dikirim).
+// Bila TemplateID diisi, konten diambil dari versi tersebut; bila Content diisi, konten itu yang dipakai.
+type PreviewPromptTemplateRequest struct {
+	Name       string              `json:"name"`
+	TemplateID string              `json:"template_id,omitempty"`
+	Content    string              `json:"content,omitempty"`
+	Data       *PromptTemplateData `json:"data,omitempty"`
+}
+
+type PromptTemplatePreview struct {
+	Name      string `json:"name"`
+	Version   string `json:"version"`
+	Rendered  string `json:"rendered"`
+	CharCount int    `json:"char_count"`
+}
+
+// PromptTemplateData adalah placeholder yang tersedia untuk semua template prompt.
+type PromptTemplateData struct {
+	Question              string   `json:"question,omitempty"`
+	Rubric                string   `json:"rubric,omitempty"`
+	RubricMode            string   `json:"rubric_mode,omitempty"`
+	IdealAnswer           string   `json:"ideal_answer,omitempty"`
+	Grounding             string   `json:"grounding,omitempty"`
+	GroundingSource       string   `json:"grounding_source,omitempty"`
+	Essay                 string   `json:"essay,omitempty"`
+	Keywords              string   `json:"keywords,omitempty"`
+	Exemplars             string   `json:"exemplars,omitempty"`
+	MaterialTitle         string   `json:"material_title,omitempty"`
+	MaterialContent       string   `json:"material_content,omitempty"`
+	TeachingModuleContext string   `json:"teaching_module_context,omitempty"`
+	ExistingQuestions     []string `json:"existing_questions,omitempty"`
+	RubricType            string   `json:"rubric_type,omitempty"`
+	TargetLevel           string   `json:"target_level,omitempty"`
+}
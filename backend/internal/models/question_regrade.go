@@ -0,0 +1,77 @@
+package models
+
+import "time"
+
+// QuestionRegradeRequest meminta re-grade semua submission satu soal setelah rubrik/jawaban ideal/kata kunci berubah.
+type QuestionRegradeRequest struct {
+	ReviewPolicy string `json:"review_policy"` // keep | flag (default flag)
+	Reason       string `json:"reason,omitempty"`
+	DryRun       bool   `json:"dry_run"`
+}
+
+// QuestionRegradeBatch adalah satu kali re-grade massal beserta progresnya.
+type QuestionRegradeBatch struct {
+	ID             string    `json:"id"`
+	QuestionID     string    `json:"question_id"`
+	RequestedBy    *string   `json:"requested_by,omitempty"`
+	ReviewPolicy   string    `json:"review_policy"`
+	Reason         *string   `json:"reason,omitempty"`
+	Total          int       `json:"total"`
+	Accepted       int       `json:"accepted"`
+	Skipped        int       `json:"skipped"`
+	FlaggedReviews int       `json:"flagged_reviews"`
+	Completed      int       `json:"completed"`
+	Pending        int       `json:"pending"`
+	Failed         int       `json:"failed"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// QuestionRegradeDiffItem membandingkan skor AI lama dan baru satu siswa.
+type QuestionRegradeDiffItem struct {
+	SubmissionID       string     `json:"submission_id"`
+	StudentID          string     `json:"student_id"`
+	StudentName        string     `json:"student_name"`
+	Status             string     `json:"status"` // queued | completed | failed | skipped
+	Message            *string    `json:"message,omitempty"`
+	OldScore           *float64   `json:"old_score,omitempty"`
+	NewScore           *float64   `json:"new_score,omitempty"`
+	Delta              *float64   `json:"delta,omitempty"`
+	TeacherScore       *float64   `json:"teacher_score,omitempty"`
+	ReviewAction       string     `json:"review_action"` // none | kept | flagged
+	ReviewNeedsRecheck bool       `json:"review_needs_recheck"`
+	RegradedAt         *time.Time `json:"regraded_at,omitempty"`
+}
+
+// QuestionRegradeSummary merangkum arah perubahan skor dalam batch.
+type QuestionRegradeSummary struct {
+	Increased    int     `json:"increased"`
+	Decreased    int     `json:"decreased"`
+	Unchanged    int     `json:"unchanged"`
+	MeanDelta    float64 `json:"mean_delta"`
+	MeanAbsDelta float64 `json:"mean_abs_delta"`
+	MaxAbsDelta  float64 `json:"max_abs_delta"`
+}
+
+// QuestionRegradeReport adalah laporan diff skor lama vs baru per siswa.
+type QuestionRegradeReport struct {
+	DryRun  bool                      `json:"dry_run"`
+	Batch   *QuestionRegradeBatch     `json:"batch,omitempty"`
+	Summary QuestionRegradeSummary    `json:"summary"`
+	Items   []QuestionRegradeDiffItem `json:"items"`
+}
+
+// AIResultHistoryEntry adalah versi ai_results yang diarsipkan sebelum ditimpa re-grade.
+type AIResultHistoryEntry struct {
+	ID                    string                  `json:"id"`
+	SubmissionID          string                  `json:"submission_id"`
+	RegradeBatchID        *string                 `json:"regrade_batch_id,omitempty"`
+	SkorAI                float64                 `json:"skor_ai"`
+	UmpanBalikAI          *string                 `json:"umpan_balik_ai,omitempty"`
+	RubricScores          []GradeEssayAspectScore `json:"rubric_scores,omitempty"`
+	HolisticBand          *HolisticBand           `json:"holistic_band,omitempty"`
+	ScoringFormula        *string                 `json:"scoring_formula,omitempty"`
+	ModelName             *string                 `json:"model_name,omitempty"`
+	PromptTemplateVersion *string                 `json:"prompt_template_version,omitempty"`
+	GeneratedAt           time.Time               `json:"generated_at"`
+	ArchivedAt            time.Time               `json:"archived_at"`
+}
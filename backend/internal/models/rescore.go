@@ -0,0 +1,87 @@
+package models
+
+import "time"
+
+// RescoreConfig adalah konfigurasi skor yang diusulkan. Field kosong memakai konfigurasi soal/sistem saat ini.
+type RescoreConfig struct {
+	AspectWeights        map[string]float64 `json:"aspect_weights,omitempty"`        // Bobot baru per nama aspek (tidak peka huruf besar).
+	RoundScoreTo5        *bool              `json:"round_score_to_5,omitempty"`      // Aktif/nonaktif pembulatan skor.
+	RoundScoreStep       *float64           `json:"round_score_step,omitempty"`      // Kelipatan pembulatan.
+	ScoringNormalization string             `json:"scoring_normalization,omitempty"` // weighted_mean | per_aspect_max
+}
+
+// RescoreRequest menghitung ulang skor akhir dari rubric_scores tersimpan untuk satu soal atau satu kelas.
+type RescoreRequest struct {
+	QuestionID           string        `json:"question_id,omitempty"`
+	ClassID              string        `json:"class_id,omitempty"`
+	Config               RescoreConfig `json:"config"`
+	UpdateQuestionConfig bool          `json:"update_question_config"` // Saat apply: simpan bobot/pembulatan ke soal agar grading berikutnya konsisten.
+	Note                 string        `json:"note,omitempty"`
+}
+
+// RescoreItem adalah skor lama vs skor hasil rescoring satu submission.
+type RescoreItem struct {
+	SubmissionID string   `json:"submission_id"`
+	QuestionID   string   `json:"question_id"`
+	StudentID    string   `json:"student_id"`
+	StudentName  string   `json:"student_name"`
+	OldScore     float64  `json:"old_score"`
+	NewScore     *float64 `json:"new_score,omitempty"`
+	Delta        *float64 `json:"delta,omitempty"`
+	TeacherScore *float64 `json:"teacher_score,omitempty"` // Nilai akhir tetap nilai guru bila ada.
+	Skipped      bool     `json:"skipped"`
+	Reason       *string  `json:"reason,omitempty"`
+}
+
+type ScoreBucket struct {
+	Label string `json:"label"`
+	Count int    `json:"count"`
+}
+
+// ScoreDistribution merangkum sebaran skor AI sebelum/sesudah rescoring.
+type ScoreDistribution struct {
+	Count   int           `json:"count"`
+	Mean    float64       `json:"mean"`
+	Median  float64       `json:"median"`
+	StdDev  float64       `json:"std_dev"`
+	Min     float64       `json:"min"`
+	Max     float64       `json:"max"`
+	Buckets []ScoreBucket `json:"buckets"`
+}
+
+// RescoreResult adalah hasil preview atau apply rescoring.
+type RescoreResult struct {
+	Applied          bool              `json:"applied"`
+	RunID            *string           `json:"run_id,omitempty"`
+	ScopeType        string            `json:"scope_type"`
+	ScopeID          string            `json:"scope_id"`
+	Config           RescoreConfig     `json:"config"`
+	UnmatchedAspects []string          `json:"unmatched_aspects,omitempty"` // Nama aspek di aspect_weights yang tidak ada di rubrik mana pun.
+	Matched          int               `json:"matched"`
+	Changed          int               `json:"changed"`
+	Skipped          int               `json:"skipped"`
+	Increased        int               `json:"increased"`
+	Decreased        int               `json:"decreased"`
+	MeanDelta        float64           `json:"mean_delta"`
+	MeanAbsDelta     float64           `json:"mean_abs_delta"`
+	MaxAbsDelta      float64           `json:"max_abs_delta"`
+	Before           ScoreDistribution `json:"before"`
+	After            ScoreDistribution `json:"after"`
+	Items            []RescoreItem     `json:"items"`
+}
+
+// RescoreRun adalah jejak audit satu apply rescoring.
+type RescoreRun struct {
+	ID                    string        `json:"id"`
+	ScopeType             string        `json:"scope_type"`
+	ScopeID               string        `json:"scope_id"`
+	Config                RescoreConfig `json:"config"`
+	RequestedBy           *string       `json:"requested_by,omitempty"`
+	RequestedByName       *string       `json:"requested_by_name,omitempty"`
+	Note                  *string       `json:"note,omitempty"`
+	Matched               int           `json:"matched"`
+	Changed               int           `json:"changed"`
+	MeanDelta             float64       `json:"mean_delta"`
+	UpdatedQuestionConfig bool          `json:"updated_question_config"`
+	CreatedAt             time.Time     `json:"created_at"`
+}
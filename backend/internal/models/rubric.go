@@ -10,6 +10,7 @@ type Rubric struct {
 	MaxScore   int               `json:"max_score"`    // Skor maksimum yang dapat dicapai untuk aspek ini.
 	Descriptors map[int]string   `json:"descriptors"`  // Deskriptor skor, memetakan skor (int) ke deskripsi tekstual (string).
 	Bobot      float64           `json:"bobot"`        // Bobot aspek ini dalam penilaian keseluruhan.
+	SkorBand   map[int]float64   `json:"skor_band,omitempty"` // Rubrik holistik: pemetaan band ke skor 0-100 (opsional, default linear).
 }
 
 // CreateRubricRequest mendefinisikan struktur data untuk permintaan pembuatan rubrik baru.
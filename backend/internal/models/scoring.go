@@ -0,0 +1,24 @@
+package models
+
+// ScoringAspectBreakdown merekam kontribusi satu aspek rubrik pada skor akhir.
+type ScoringAspectBreakdown struct {
+	Aspek      string  `json:"aspek"`
+	Bobot      float64 `json:"bobot"`      // Bobot efektif (bobot rubrik <= 0 dianggap 1).
+	Skor       int     `json:"skor"`       // Skor yang diperoleh (sudah di-clamp ke rentang rubrik).
+	MaxScore   int     `json:"max_score"`  // Skor maksimum aspek pada rubrik.
+	Normalized float64 `json:"normalized"` // Skor / MaxScore (0..1).
+	Matched    bool    `json:"matched"`    // False jika AI tidak mengembalikan skor untuk aspek ini.
+}
+
+// ScoringBreakdown menjelaskan bagaimana skor akhir dihitung agar skor lama tetap dapat dijelaskan
+// meskipun rumus/konfigurasi berubah di kemudian hari.
+type ScoringBreakdown struct {
+	Version       string                   `json:"version"`
+	Normalization string                   `json:"normalization"`
+	Formula       string                   `json:"formula"`
+	Aspects       []ScoringAspectBreakdown `json:"aspects"`
+	Holistic      *HolisticBand            `json:"holistic,omitempty"`      // Diisi untuk rubrik holistik; Aspects kosong.
+	RawScore      float64                  `json:"raw_score"`               // Skor 0-100 sebelum pembulatan.
+	RoundingStep  *float64                 `json:"rounding_step,omitempty"` // Kelipatan pembulatan bila aktif.
+	FinalScore    float64                  `json:"final_score"`
+}
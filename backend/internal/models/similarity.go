@@ -0,0 +1,93 @@
+package models
+
+import "time"
+
+// SimilarityHighlight adalah potongan teks yang sama persis antara jawaban dan sumber pembanding.
+// Offset dihitung dalam karakter (rune) terhadap teks asli masing-masing.
+type SimilarityHighlight struct {
+	Text       string `json:"text"`
+	Words      int    `json:"words"`
+	Start      int    `json:"start"`
+	End        int    `json:"end"`
+	OtherText  string `json:"other_text,omitempty"`
+	OtherStart *int   `json:"other_start,omitempty"`
+	OtherEnd   *int   `json:"other_end,omitempty"`
+}
+
+// SimilarityPair adalah pasangan mirip yang tersimpan untuk satu soal.
+// Similarity adalah Jaccard shingle kata; Containment adalah porsi shingle jawaban yang ditemukan di sumber.
+type SimilarityPair struct {
+	ID                string                `json:"id"`
+	QuestionID        string                `json:"question_id"`
+	SubmissionID      string                `json:"submission_id"`
+	StudentID         string                `json:"student_id"`
+	StudentName       string                `json:"student_name"`
+	SourceType        string                `json:"source_type"` // submission | material | ideal_answer
+	SourceLabel       *string               `json:"source_label,omitempty"`
+	OtherSubmissionID *string               `json:"other_submission_id,omitempty"`
+	OtherStudentID    *string               `json:"other_student_id,omitempty"`
+	OtherStudentName  *string               `json:"other_student_name,omitempty"`
+	Similarity        float64               `json:"similarity"`
+	Containment       float64               `json:"containment"`
+	Highlights        []SimilarityHighlight `json:"highlights"`
+	CreatedAt         time.Time             `json:"created_at"`
+}
+
+// SimilarityConfig adalah ambang deteksi kemiripan dari system_settings.
+type SimilarityConfig struct {
+	Enabled         bool    `json:"enabled"`
+	PairThreshold   float64 `json:"pair_threshold"`
+	SourceThreshold float64 `json:"source_threshold"`
+}
+
+// QuestionSimilarityReport adalah daftar pasangan mirip untuk satu soal.
+type QuestionSimilarityReport struct {
+	QuestionID       string           `json:"question_id"`
+	Config           SimilarityConfig `json:"config"`
+	SubmissionCount  int              `json:"submission_count"`
+	FingerprintCount int              `json:"fingerprint_count"`
+	Pairs            []SimilarityPair `json:"pairs"`
+	SourceMatches    []SimilarityPair `json:"source_matches"`
+}
+
+// SimilarityClusterStudent adalah anggota kelompok jawaban mirip.
+type SimilarityClusterStudent struct {
+	StudentID   string `json:"student_id"`
+	StudentName string `json:"student_name"`
+}
+
+// SimilarityClusterQuestion adalah soal tempat anggota kelompok saling mirip.
+type SimilarityClusterQuestion struct {
+	QuestionID    string  `json:"question_id"`
+	QuestionText  string  `json:"question_text"`
+	PairCount     int     `json:"pair_count"`
+	MaxSimilarity float64 `json:"max_similarity"`
+}
+
+// SimilarityCluster adalah kelompok siswa yang terhubung oleh pasangan jawaban mirip (komponen terhubung).
+type SimilarityCluster struct {
+	Students      []SimilarityClusterStudent  `json:"students"`
+	Questions     []SimilarityClusterQuestion `json:"questions"`
+	PairCount     int                         `json:"pair_count"`
+	MaxSimilarity float64                     `json:"max_similarity"`
+	AvgSimilarity float64                     `json:"avg_similarity"`
+}
+
+// SimilaritySourceCopy merangkum siswa yang jawabannya banyak menyalin materi atau jawaban ideal.
+type SimilaritySourceCopy struct {
+	StudentID      string  `json:"student_id"`
+	StudentName    string  `json:"student_name"`
+	MaterialCount  int     `json:"material_count"`
+	IdealCount     int     `json:"ideal_answer_count"`
+	MaxContainment float64 `json:"max_containment"`
+}
+
+// ClassSimilarityReport adalah laporan kemiripan jawaban tingkat kelas.
+type ClassSimilarityReport struct {
+	ClassID     string                 `json:"class_id"`
+	Config      SimilarityConfig       `json:"config"`
+	PairCount   int                    `json:"pair_count"`
+	Clusters    []SimilarityCluster    `json:"clusters"`
+	SourceCopy  []SimilaritySourceCopy `json:"source_copies"`
+	GeneratedAt time.Time              `json:"generated_at"`
+}
@@ -6,32 +6,38 @@ import (
 
 // TeacherReview represents a teacher's review of a student's essay submission.
 type TeacherReview struct {
-	ID              string    `json:"id"`
-	SubmissionID    string    `json:"submission_id"`
-	TeacherID       string    `json:"teacher_id"`
-	RevisedScore    float64   `json:"revised_score"`
-	TeacherFeedback *string   `json:"teacher_feedback,omitempty"`
-	CreatedAt       time.Time `json:"created_at"`
-	UpdatedAt       time.Time `json:"updated_at"`
+	ID              string                  `json:"id"`
+	SubmissionID    string                  `json:"submission_id"`
+	TeacherID       string                  `json:"teacher_id"`
+	RevisedScore    float64                 `json:"revised_score"`
+	TeacherFeedback *string                 `json:"teacher_feedback,omitempty"`
+	AspectScores    []GradeEssayAspectScore `json:"aspect_scores,omitempty"` // Skor guru per aspek rubrik (opsional).
+	CreatedAt       time.Time               `json:"created_at"`
+	UpdatedAt       time.Time               `json:"updated_at"`
+	NeedsRecheck    bool                    `json:"needs_recheck"`            // True bila soal dinilai ulang AI setelah review ini dibuat.
+	RecheckReason   *string                 `json:"recheck_reason,omitempty"` // Alasan review perlu dicek ulang (opsional).
 }
 
 // CreateTeacherReviewRequest defines the structure for a request to create a new teacher review.
 type CreateTeacherReviewRequest struct {
-	SubmissionID    string  `json:"submission_id"`
-	RevisedScore    float64 `json:"revised_score"`
-	TeacherFeedback *string `json:"teacher_feedback,omitempty"`
+	SubmissionID    string                  `json:"submission_id"`
+	RevisedScore    float64                 `json:"revised_score"`
+	TeacherFeedback *string                 `json:"teacher_feedback,omitempty"`
+	AspectScores    []GradeEssayAspectScore `json:"aspect_scores,omitempty"`
 }
 
 // UpdateTeacherReviewRequest defines the structure for a request to update an existing teacher review.
 type UpdateTeacherReviewRequest struct {
-	RevisedScore    *float64 `json:"revised_score,omitempty"`
-	TeacherFeedback *string  `json:"teacher_feedback,omitempty"`
+	RevisedScore    *float64                 `json:"revised_score,omitempty"`
+	TeacherFeedback *string                  `json:"teacher_feedback,omitempty"`
+	AspectScores    *[]GradeEssayAspectScore `json:"aspect_scores,omitempty"`
 }
 
 type BatchTeacherReviewUpdate struct {
-	SubmissionID    string   `json:"submission_id"`
-	RevisedScore    *float64 `json:"revised_score,omitempty"`
-	TeacherFeedback *string  `json:"teacher_feedback,omitempty"`
+	SubmissionID    string                  `json:"submission_id"`
+	RevisedScore    *float64                `json:"revised_score,omitempty"`
+	TeacherFeedback *string                 `json:"teacher_feedback,omitempty"`
+	AspectScores    []GradeEssayAspectScore `json:"aspect_scores,omitempty"`
 }
 
 type BatchTeacherReviewRequest struct {
@@ -0,0 +1,221 @@
+package pdftext
+
+import (
+	"strings"
+	"unicode/utf16"
+)
+
+// codeRange adalah satu rentang codespace CMap; low dan high selalu sama panjang.
+type codeRange struct {
+	low, high []byte
+}
+
+func (r codeRange) contains(code []byte) bool {
+	if len(code) != len(r.low) {
+		return false
+	}
+	for i, c := range code {
+		if c < r.low[i] || c > r.high[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// bfRange adalah entri beginbfrange: kode low..high dipetakan ke dst yang dinaikkan per kode, atau ke
+// elemen array bila bentuk array dipakai.
+type bfRange struct {
+	low, high uint32
+	size      int
+	dst       []byte
+	array     []string
+}
+
+type cidRange struct {
+	low, high uint32
+	size      int
+	cid       int
+}
+
+// cmap menampung isi CMap yang dibutuhkan ekstraksi: codespace untuk memecah string menjadi kode,
+// pemetaan ke Unicode (ToUnicode), dan pemetaan kode ke CID (CMap /Encoding font Type0).
+type cmap struct {
+	codespace []codeRange
+	unicode   map[string]string
+	bfRanges  []bfRange
+	cids      map[string]int
+	cidRanges []cidRange
+}
+
+func codeValue(code []byte) uint32 {
+	var v uint32
+	for _, c := range code {
+		v = v<<8 | uint32(c)
+	}
+	return v
+}
+
+// decodeUTF16 membaca tujuan bfchar/bfrange (UTF-16BE). Beberapa generator menulis satu byte saja.
+func decodeUTF16(b []byte) string {
+	if len(b) == 1 {
+		return string(rune(b[0]))
+	}
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
+
+// parseCMap membaca CMap (ToUnicode atau CMap encoding tertanam). Operator yang tidak relevan diabaikan
+// sehingga CMap yang sedikit rusak tetap menghasilkan pemetaan sebanyak mungkin.
+func parseCMap(data []byte) *cmap {
+	m := &cmap{unicode: map[string]string{}, cids: map[string]int{}}
+	l := newLexer(data, 0)
+	var operands []interface{}
+	for !l.eof() {
+		obj, err := l.readObject()
+		if err != nil {
+			break
+		}
+		kw, ok := obj.(Keyword)
+		if !ok {
+			operands = append(operands, obj)
+			continue
+		}
+		switch kw {
+		case "endcodespacerange":
+			for i := 0; i+1 < len(operands); i += 2 {
+				low, _ := operands[i].(String)
+				high, _ := operands[i+1].(String)
+				if len(low) > 0 && len(low) == len(high) {
+					m.codespace = append(m.codespace, codeRange{low: low, high: high})
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, _ := operands[i].(String)
+				switch dst := operands[i+1].(type) {
+				case String:
+					m.unicode[string(src)] = decodeUTF16(dst)
+				case Name:
+					m.unicode[string(src)] = glyphRune(string(dst))
+				}
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				low, _ := operands[i].(String)
+				high, _ := operands[i+1].(String)
+				if len(low) == 0 || len(low) != len(high) || len(low) > 4 {
+					continue
+				}
+				r := bfRange{low: codeValue(low), high: codeValue(high), size: len(low)}
+				switch dst := operands[i+2].(type) {
+				case String:
+					r.dst = dst
+				case Array:
+					for _, item := range dst {
+						s, _ := item.(String)
+						r.array = append(r.array, decodeUTF16(s))
+					}
+				default:
+					continue
+				}
+				m.bfRanges = append(m.bfRanges, r)
+			}
+		case "endcidchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, _ := operands[i].(String)
+				if cid, ok := operands[i+1].(int); ok {
+					m.cids[string(src)] = cid
+				}
+			}
+		case "endcidrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				low, _ := operands[i].(String)
+				high, _ := operands[i+1].(String)
+				cid, ok := operands[i+2].(int)
+				if !ok || len(low) == 0 || len(low) != len(high) || len(low) > 4 {
+					continue
+				}
+				m.cidRanges = append(m.cidRanges, cidRange{low: codeValue(low), high: codeValue(high), size: len(low), cid: cid})
+			}
+		}
+		operands = operands[:0]
+	}
+	return m
+}
+
+// lookupUnicode mengembalikan teks untuk satu kode, dan false bila CMap tidak memetakannya.
+func (m *cmap) lookupUnicode(code []byte) (string, bool) {
+	if text, ok := m.unicode[string(code)]; ok {
+		return text, true
+	}
+	v := codeValue(code)
+	for _, r := range m.bfRanges {
+		if r.size != len(code) || v < r.low || v > r.high {
+			continue
+		}
+		offset := v - r.low
+		if r.array != nil {
+			if int(offset) < len(r.array) {
+				return r.array[offset], true
+			}
+			return "", false
+		}
+		dst := append([]byte(nil), r.dst...)
+		// Offset ditambahkan ke byte terakhir tujuan (dengan carry), sesuai ISO 32000-1 9.10.3.
+		carry := offset
+		for i := len(dst) - 1; i >= 0 && carry > 0; i-- {
+			sum := uint32(dst[i]) + carry
+			dst[i] = byte(sum)
+			carry = sum >> 8
+		}
+		return decodeUTF16(dst), true
+	}
+	return "", false
+}
+
+func (m *cmap) lookupCID(code []byte) (int, bool) {
+	if cid, ok := m.cids[string(code)]; ok {
+		return cid, true
+	}
+	v := codeValue(code)
+	for _, r := range m.cidRanges {
+		if r.size == len(code) && v >= r.low && v <= r.high {
+			return r.cid + int(v-r.low), true
+		}
+	}
+	return 0, false
+}
+
+// nextCode memotong kode pertama dari s berdasarkan codespace. Bila tidak ada codespace yang cocok,
+// dipakai fallbackLen byte (1 untuk font sederhana, 2 untuk font komposit).
+func nextCode(codespace []codeRange, s []byte, fallbackLen int) []byte {
+	for n := 1; n <= 4 && n <= len(s); n++ {
+		for _, r := range codespace {
+			if r.contains(s[:n]) {
+				return s[:n]
+			}
+		}
+	}
+	n := fallbackLen
+	if len(codespace) > 0 {
+		// Kode di luar codespace: pakai panjang codespace terpendek yang memuat byte pertama.
+		for _, r := range codespace {
+			if len(r.low) > 0 && s[0] >= r.low[0] && s[0] <= r.high[0] {
+				n = len(r.low)
+				break
+			}
+		}
+	}
+	if n > len(s) {
+		n = len(s)
+	}
+	return s[:n]
+}
+
+// isUnicodeCMapName mengenali CMap predefined yang kodenya sudah berupa UCS-2/UTF-16BE.
+func isUnicodeCMapName(name Name) bool {
+	return strings.Contains(string(name), "UCS2") || strings.Contains(string(name), "UTF16")
+}
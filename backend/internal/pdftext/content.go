@@ -0,0 +1,312 @@
+package pdftext
+
+import (
+	"bytes"
+	"math"
+	"strings"
+)
+
+// maxFormDepth membatasi Form XObject bersarang (form yang memanggil form lain).
+const maxFormDepth = 16
+
+// matrix adalah matriks transformasi PDF [a b c d e f] dengan konvensi vektor baris.
+type matrix [6]float64
+
+var identityMatrix = matrix{1, 0, 0, 1, 0, 0}
+
+func (m matrix) mul(n matrix) matrix {
+	return matrix{
+		m[0]*n[0] + m[1]*n[2], m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2], m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4], m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+func translate(tx, ty float64) matrix {
+	return matrix{1, 0, 0, 1, tx, ty}
+}
+
+func matrixFrom(operands []interface{}) (matrix, bool) {
+	if len(operands) < 6 {
+		return identityMatrix, false
+	}
+	var m matrix
+	for i := range m {
+		m[i] = number(operands[len(operands)-6+i])
+	}
+	return m, true
+}
+
+// graphicsState memuat bagian state grafis yang memengaruhi posisi teks; disimpan/dipulihkan oleh q/Q.
+type graphicsState struct {
+	ctm       matrix
+	font      *font
+	fontSize  float64
+	charSpace float64
+	wordSpace float64
+	hScale    float64
+	leading   float64
+	rise      float64
+}
+
+// pageWriter menyusun teks halaman dari potongan teks berposisi: pergantian baris dikenali dari
+// perubahan posisi vertikal, spasi antarkata dari jarak horizontal antarpotongan.
+type pageWriter struct {
+	b       strings.Builder
+	hasLast bool
+	lastX   float64
+	lastY   float64
+	size    float64
+}
+
+func (w *pageWriter) write(text string, x0, y0, x1, y1, size float64) {
+	if text == "" {
+		return
+	}
+	if w.hasLast {
+		height := math.Max(size, w.size)
+		dy := math.Abs(y0 - w.lastY)
+		switch {
+		case dy > 2*height:
+			w.b.WriteString("\n\n")
+		case dy > 0.5*height:
+			w.b.WriteByte('\n')
+		case math.Abs(x0-w.lastX) > 0.15*height && !strings.HasPrefix(text, " ") && !w.endsWithSpace():
+			w.b.WriteByte(' ')
+		}
+	}
+	w.b.WriteString(text)
+	w.hasLast = true
+	w.lastX, w.lastY, w.size = x1, y1, size
+}
+
+func (w *pageWriter) endsWithSpace() bool {
+	s := w.b.String()
+	return s == "" || strings.HasSuffix(s, " ") || strings.HasSuffix(s, "\n")
+}
+
+// contentExtractor menjalankan operator teks content stream satu halaman.
+type contentExtractor struct {
+	doc   *Document
+	fonts map[Ref]*font
+	out   pageWriter
+	forms map[*Stream]bool
+}
+
+func (e *contentExtractor) run(content []byte, resources Dict, state graphicsState, depth int) {
+	var (
+		stack    []graphicsState
+		tm, tlm  = identityMatrix, identityMatrix
+		operands []interface{}
+	)
+	nextLine := func(tx, ty float64) {
+		tlm = translate(tx, ty).mul(tlm)
+		tm = tlm
+	}
+	l := newLexer(content, 0)
+	for !l.eof() {
+		obj, err := l.readObject()
+		if err != nil {
+			return
+		}
+		op, ok := obj.(Keyword)
+		if !ok {
+			operands = append(operands, obj)
+			continue
+		}
+		n := len(operands)
+		switch op {
+		case "q":
+			stack = append(stack, state)
+		case "Q":
+			if len(stack) > 0 {
+				state = stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+			}
+		case "cm":
+			if m, ok := matrixFrom(operands); ok {
+				state.ctm = m.mul(state.ctm)
+			}
+		case "BT":
+			tm, tlm = identityMatrix, identityMatrix
+		case "Tc":
+			if n >= 1 {
+				state.charSpace = number(operands[n-1])
+			}
+		case "Tw":
+			if n >= 1 {
+				state.wordSpace = number(operands[n-1])
+			}
+		case "Tz":
+			if n >= 1 {
+				state.hScale = number(operands[n-1]) / 100
+			}
+		case "TL":
+			if n >= 1 {
+				state.leading = number(operands[n-1])
+			}
+		case "Ts":
+			if n >= 1 {
+				state.rise = number(operands[n-1])
+			}
+		case "Tf":
+			if n >= 2 {
+				name, _ := operands[n-2].(Name)
+				state.font = e.font(resources, name)
+				state.fontSize = number(operands[n-1])
+			}
+		case "Td", "TD":
+			if n >= 2 {
+				ty := number(operands[n-1])
+				if op == "TD" {
+					state.leading = -ty
+				}
+				nextLine(number(operands[n-2]), ty)
+			}
+		case "Tm":
+			if m, ok := matrixFrom(operands); ok {
+				tm, tlm = m, m
+			}
+		case "T*":
+			nextLine(0, -state.leading)
+		case "Tj", "'", "\"":
+			if op == "\"" && n >= 3 {
+				state.wordSpace = number(operands[n-3])
+				state.charSpace = number(operands[n-2])
+			}
+			if op != "Tj" {
+				nextLine(0, -state.leading)
+			}
+			if n >= 1 {
+				if s, ok := operands[n-1].(String); ok {
+					tm = e.show(s, tm, &state)
+				}
+			}
+		case "TJ":
+			if n >= 1 {
+				items, _ := operands[n-1].(Array)
+				for _, item := range items {
+					switch v := item.(type) {
+					case String:
+						tm = e.show(v, tm, &state)
+					case int, float64:
+						tx := -number(v) / 1000 * state.fontSize * state.hScale
+						tm = translate(tx, 0).mul(tm)
+					}
+				}
+			}
+		case "Do":
+			if n >= 1 && depth < maxFormDepth {
+				name, _ := operands[n-1].(Name)
+				e.form(resources, name, state, depth)
+			}
+		case "BI":
+			skipInlineImage(l)
+		}
+		operands = operands[:0]
+	}
+}
+
+// show menampilkan satu string dan mengembalikan matriks teks setelah semua glyph dimajukan.
+func (e *contentExtractor) show(s String, tm matrix, state *graphicsState) matrix {
+	f := state.font
+	if f == nil {
+		f = e.doc.loadFont(nil)
+		state.font = f
+	}
+	render := matrix{state.fontSize * state.hScale, 0, 0, state.fontSize, 0, state.rise}
+	start := render.mul(tm).mul(state.ctm)
+	var text strings.Builder
+	for _, g := range f.decode(s) {
+		text.WriteString(g.text)
+		tx := g.width*state.fontSize + state.charSpace
+		if g.isSpace {
+			tx += state.wordSpace
+		}
+		tm = translate(tx*state.hScale, 0).mul(tm)
+	}
+	end := render.mul(tm).mul(state.ctm)
+	device := tm.mul(state.ctm)
+	size := math.Abs(state.fontSize) * math.Hypot(device[2], device[3])
+	e.out.write(text.String(), start[4], start[5], end[4], end[5], size)
+	return tm
+}
+
+func (e *contentExtractor) font(resources Dict, name Name) *font {
+	fonts, _ := e.doc.resolve(resources["Font"]).(Dict)
+	obj := fonts[name]
+	ref, isRef := obj.(Ref)
+	if isRef {
+		if f, ok := e.fonts[ref]; ok {
+			return f
+		}
+	}
+	f := e.doc.loadFont(obj)
+	if isRef {
+		e.fonts[ref] = f
+	}
+	return f
+}
+
+// form menjalankan Form XObject dengan /Resources dan /Matrix miliknya. Image XObject diabaikan.
+func (e *contentExtractor) form(resources Dict, name Name, state graphicsState, depth int) {
+	xobjects, _ := e.doc.resolve(resources["XObject"]).(Dict)
+	stream, ok := e.doc.resolve(xobjects[name]).(*Stream)
+	if !ok || e.forms[stream] {
+		return
+	}
+	if subtype, _ := stream.Dict["Subtype"].(Name); subtype != "Form" {
+		return
+	}
+	data, err := e.doc.StreamData(stream)
+	if err != nil {
+		return
+	}
+	if m, ok := matrixFrom(e.doc.resolveArray(stream.Dict["Matrix"])); ok {
+		state.ctm = m.mul(state.ctm)
+	}
+	formResources, ok := e.doc.resolve(stream.Dict["Resources"]).(Dict)
+	if !ok {
+		formResources = resources
+	}
+	e.forms[stream] = true
+	e.run(data, formResources, state, depth+1)
+	delete(e.forms, stream)
+}
+
+func (d *Document) resolveArray(obj interface{}) []interface{} {
+	arr, _ := d.resolve(obj).(Array)
+	out := make([]interface{}, len(arr))
+	for i, item := range arr {
+		out[i] = d.resolve(item)
+	}
+	return out
+}
+
+// skipInlineImage melompati data gambar inline (BI ... ID <biner> EI) agar byte gambar tidak dibaca sebagai operator.
+func skipInlineImage(l *lexer) {
+	for !l.eof() {
+		obj, err := l.readObject()
+		if err != nil {
+			return
+		}
+		if kw, ok := obj.(Keyword); ok && kw == "ID" {
+			break
+		}
+	}
+	l.pos++
+	for l.pos < len(l.data) {
+		idx := bytes.Index(l.data[l.pos:], []byte("EI"))
+		if idx < 0 {
+			l.pos = len(l.data)
+			return
+		}
+		at := l.pos + idx
+		before := at == 0 || isWhitespace(l.data[at-1])
+		after := at+2 >= len(l.data) || isWhitespace(l.data[at+2])
+		l.pos = at + 2
+		if before && after {
+			return
+		}
+	}
+}
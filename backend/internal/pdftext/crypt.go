@@ -0,0 +1,261 @@
+package pdftext
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rc4"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+)
+
+// passwordPadding adalah string padding 32 byte dari Standard Security Handler (ISO 32000-1 7.6.3.3).
+var passwordPadding = []byte{
+	0x28, 0xBF, 0x4E, 0x5E, 0x4E, 0x75, 0x8A, 0x41, 0x64, 0x00, 0x4E, 0x56, 0xFF, 0xFA, 0x01, 0x08,
+	0x2E, 0x2E, 0x00, 0xB6, 0xD0, 0x68, 0x3E, 0x80, 0x2F, 0x0C, 0xA9, 0xFE, 0x64, 0x53, 0x69, 0x7A,
+}
+
+// securityHandler mendekripsi string dan stream PDF yang dilindungi Standard Security Handler dengan
+// password user kosong (kasus umum: PDF hanya dibatasi izin cetak/salin, tetap bisa dibuka tanpa password).
+type securityHandler struct {
+	key       []byte
+	revision  int
+	streamAES bool
+	stringAES bool
+	streamNop bool // /StmF /Identity
+	stringNop bool // /StrF /Identity
+}
+
+func newSecurityHandler(encrypt Dict, fileID []byte) (*securityHandler, error) {
+	if filter, _ := encrypt["Filter"].(Name); filter != "Standard" {
+		return nil, fmt.Errorf("%w: unsupported security handler %s", ErrEncrypted, filter)
+	}
+	version := intOr(encrypt["V"], 0)
+	revision := intOr(encrypt["R"], 0)
+	owner, _ := encrypt["O"].(String)
+	user, _ := encrypt["U"].(String)
+	h := &securityHandler{revision: revision}
+
+	if version >= 4 {
+		cfs, _ := encrypt["CF"].(Dict)
+		method := func(filterName interface{}) (aesMode, identity bool) {
+			name, _ := filterName.(Name)
+			if name == "" || name == "Identity" {
+				return false, name == "Identity"
+			}
+			cf, _ := cfs[name].(Dict)
+			cfm, _ := cf["CFM"].(Name)
+			return cfm == "AESV2" || cfm == "AESV3", cfm == "None"
+		}
+		h.streamAES, h.streamNop = method(encrypt["StmF"])
+		h.stringAES, h.stringNop = method(encrypt["StrF"])
+	}
+
+	if revision >= 5 {
+		key, err := aes256FileKey(revision, user, encrypt)
+		if err != nil {
+			return nil, err
+		}
+		h.key = key
+		return h, nil
+	}
+
+	length := intOr(encrypt["Length"], 40) / 8
+	if revision == 2 || length < 5 {
+		length = 5
+	}
+	if length > 16 {
+		length = 16
+	}
+	permissions := uint32(int32(intOr(encrypt["P"], 0)))
+	encryptMetadata := true
+	if v, ok := encrypt["EncryptMetadata"].(bool); ok {
+		encryptMetadata = v
+	}
+
+	hash := md5.New()
+	hash.Write(passwordPadding)
+	hash.Write(owner)
+	var p [4]byte
+	binary.LittleEndian.PutUint32(p[:], permissions)
+	hash.Write(p[:])
+	hash.Write(fileID)
+	if revision >= 4 && !encryptMetadata {
+		hash.Write([]byte{0xFF, 0xFF, 0xFF, 0xFF})
+	}
+	key := hash.Sum(nil)
+	if revision >= 3 {
+		for i := 0; i < 50; i++ {
+			sum := md5.Sum(key[:length])
+			key = sum[:]
+		}
+	}
+	h.key = key[:length]
+
+	if !h.userPasswordMatches(user, fileID) {
+		return nil, fmt.Errorf("%w: a user password is required", ErrEncrypted)
+	}
+	return h, nil
+}
+
+// userPasswordMatches memeriksa bahwa kunci dari password kosong cocok dengan entri /U (Algorithm 6).
+func (h *securityHandler) userPasswordMatches(user, fileID []byte) bool {
+	if len(user) < 16 {
+		return false
+	}
+	if h.revision == 2 {
+		c, err := rc4.NewCipher(h.key)
+		if err != nil {
+			return false
+		}
+		out := make([]byte, 32)
+		c.XORKeyStream(out, passwordPadding)
+		return bytes.Equal(out, user[:min(32, len(user))])
+	}
+	hash := md5.New()
+	hash.Write(passwordPadding)
+	hash.Write(fileID)
+	out := hash.Sum(nil)
+	for i := 0; i < 20; i++ {
+		k := make([]byte, len(h.key))
+		for j := range h.key {
+			k[j] = h.key[j] ^ byte(i)
+		}
+		c, err := rc4.NewCipher(k)
+		if err != nil {
+			return false
+		}
+		c.XORKeyStream(out, out)
+	}
+	return bytes.Equal(out[:16], user[:16])
+}
+
+// aes256FileKey menurunkan kunci file AES-256 (R5/R6) dari password user kosong.
+func aes256FileKey(revision int, user String, encrypt Dict) ([]byte, error) {
+	userEnc, _ := encrypt["UE"].(String)
+	if len(user) < 48 || len(userEnc) < 32 {
+		return nil, fmt.Errorf("%w: malformed AES-256 encryption dictionary", ErrEncrypted)
+	}
+	validationSalt, keySalt := user[32:40], user[40:48]
+	if !bytes.Equal(revisionHash(revision, nil, validationSalt), user[:32]) {
+		return nil, fmt.Errorf("%w: a user password is required", ErrEncrypted)
+	}
+	intermediate := revisionHash(revision, nil, keySalt)
+	block, err := aes.NewCipher(intermediate)
+	if err != nil {
+		return nil, err
+	}
+	key := make([]byte, 32)
+	cipher.NewCBCDecrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(key, userEnc[:32])
+	return key, nil
+}
+
+// revisionHash adalah hash password R5 (SHA-256) atau R6 (Algorithm 2.B) untuk password user.
+func revisionHash(revision int, password, salt []byte) []byte {
+	input := append(append([]byte(nil), password...), salt...)
+	sum := sha256.Sum256(input)
+	k := sum[:]
+	if revision < 6 {
+		return k
+	}
+	for round := 0; ; round++ {
+		unit := append(append([]byte(nil), password...), k...)
+		k1 := bytes.Repeat(unit, 64)
+		block, _ := aes.NewCipher(k[:16])
+		e := make([]byte, len(k1))
+		cipher.NewCBCEncrypter(block, k[16:32]).CryptBlocks(e, k1)
+		mod := new(big.Int).Mod(new(big.Int).SetBytes(e[:16]), big.NewInt(3)).Int64()
+		switch mod {
+		case 0:
+			s := sha256.Sum256(e)
+			k = s[:]
+		case 1:
+			s := sha512.Sum384(e)
+			k = s[:]
+		default:
+			s := sha512.Sum512(e)
+			k = s[:]
+		}
+		if done := round + 1; done >= 64 && int(e[len(e)-1]) <= done-32 {
+			break
+		}
+	}
+	return k[:32]
+}
+
+func (h *securityHandler) objectKey(ref Ref, useAES bool) []byte {
+	if h.revision >= 5 {
+		return h.key
+	}
+	hash := md5.New()
+	hash.Write(h.key)
+	hash.Write([]byte{byte(ref.Num), byte(ref.Num >> 8), byte(ref.Num >> 16), byte(ref.Gen), byte(ref.Gen >> 8)})
+	if useAES {
+		hash.Write([]byte("sAlT"))
+	}
+	key := hash.Sum(nil)
+	n := len(h.key) + 5
+	if n > 16 {
+		n = 16
+	}
+	return key[:n]
+}
+
+func (h *securityHandler) decrypt(ref Ref, data []byte, isStream bool) []byte {
+	useAES, nop := h.stringAES, h.stringNop
+	if isStream {
+		useAES, nop = h.streamAES, h.streamNop
+	}
+	if nop {
+		return data
+	}
+	key := h.objectKey(ref, useAES)
+	if useAES {
+		if len(data) < 2*aes.BlockSize || len(data)%aes.BlockSize != 0 {
+			return data
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return data
+		}
+		out := make([]byte, len(data)-aes.BlockSize)
+		cipher.NewCBCDecrypter(block, data[:aes.BlockSize]).CryptBlocks(out, data[aes.BlockSize:])
+		if pad := int(out[len(out)-1]); pad > 0 && pad <= aes.BlockSize && pad <= len(out) {
+			out = out[:len(out)-pad]
+		}
+		return out
+	}
+	c, err := rc4.NewCipher(key)
+	if err != nil {
+		return data
+	}
+	out := make([]byte, len(data))
+	c.XORKeyStream(out, data)
+	return out
+}
+
+// decryptObject mendekripsi semua string di dalam objek (rekursif). Stream didekripsi terpisah saat didekode.
+func (h *securityHandler) decryptObject(ref Ref, obj interface{}) interface{} {
+	switch v := obj.(type) {
+	case String:
+		return String(h.decrypt(ref, v, false))
+	case Array:
+		for i := range v {
+			v[i] = h.decryptObject(ref, v[i])
+		}
+		return v
+	case Dict:
+		for k := range v {
+			v[k] = h.decryptObject(ref, v[k])
+		}
+		return v
+	case *Stream:
+		h.decryptObject(ref, v.Dict)
+		return v
+	}
+	return obj
+}
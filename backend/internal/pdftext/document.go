@@ -0,0 +1,586 @@
+package pdftext
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+var (
+	// ErrEncrypted dikembalikan untuk PDF yang butuh password untuk dibuka.
+	ErrEncrypted = errors.New("pdf is encrypted")
+	// ErrNotPDF dikembalikan bila data tidak diawali header %PDF dan tidak memuat objek PDF sama sekali.
+	ErrNotPDF = errors.New("file is not a pdf")
+	// ErrTooLarge dikembalikan bila total hasil dekode stream satu dokumen melewati maxDecodedDocumentSize.
+	ErrTooLarge = errors.New("pdf decoded content is too large")
+
+	errUnexpectedEOF = errors.New("unexpected end of pdf data")
+
+	objHeaderPattern = regexp.MustCompile(`(\d+)[ \t\r\n\f\x00]+(\d+)[ \t\r\n\f\x00]+obj\b`)
+)
+
+// maxObjectResolveDepth membatasi rantai referensi (objek yang menunjuk referensi lain) agar siklus tidak berputar terus.
+const maxObjectResolveDepth = 32
+
+type xrefEntry struct {
+	offset    int // offset byte objek (tipe 1)
+	gen       int
+	objStream int // nomor object stream (tipe 2)
+	compress  bool
+}
+
+// Document adalah PDF yang sudah dibaca tabel xref-nya. Objek di-parse secara malas saat dibutuhkan.
+type Document struct {
+	data     []byte
+	xref     map[int]xrefEntry
+	trailer  Dict
+	objects  map[int]interface{}
+	objStms  map[int]map[int]interface{}
+	crypt    *securityHandler
+	repaired bool
+	// decodeBudget adalah sisa byte hasil dekode untuk seluruh dokumen. Stream yang sama bisa dirujuk berkali-kali
+	// (mis. /Contents berisi referensi berulang), jadi batas per stream saja tidak cukup.
+	decodeBudget int64
+}
+
+// Open membaca struktur PDF: tabel xref klasik, xref stream (PDF 1.5+), rantai /Prev untuk
+// pembaruan inkremental, dan rekonstruksi dari pemindaian "n g obj" bila xref rusak.
+func Open(data []byte) (*Document, error) {
+	doc := &Document{
+		data:    data,
+		xref:    map[int]xrefEntry{},
+		objects: map[int]interface{}{},
+		objStms: map[int]map[int]interface{}{},
+
+		decodeBudget: maxDecodedDocumentSize,
+	}
+	if !bytes.Contains(data[:min(len(data), 1024)], []byte("%PDF")) && !objHeaderPattern.Match(data[:min(len(data), 4096)]) {
+		return nil, ErrNotPDF
+	}
+
+	if err := doc.loadXref(); err != nil || doc.catalog() == nil {
+		if repairErr := doc.repair(); repairErr != nil {
+			return nil, repairErr
+		}
+	}
+
+	if encrypt, ok := doc.resolve(doc.trailer["Encrypt"]).(Dict); ok {
+		var fileID []byte
+		if ids, ok := doc.resolve(doc.trailer["ID"]).(Array); ok && len(ids) > 0 {
+			if first, ok := ids[0].(String); ok {
+				fileID = first
+			}
+		}
+		handler, err := newSecurityHandler(encrypt, fileID)
+		if err != nil {
+			return nil, err
+		}
+		doc.crypt = handler
+		// Objek yang ter-cache sebelum handler siap (mis. kamus Encrypt itu sendiri) dibaca ulang nanti.
+		doc.objects = map[int]interface{}{}
+		doc.objStms = map[int]map[int]interface{}{}
+	}
+	if doc.catalog() == nil {
+		return nil, fmt.Errorf("pdf has no document catalog")
+	}
+	return doc, nil
+}
+
+func (d *Document) catalog() Dict {
+	root, _ := d.resolve(d.trailer["Root"]).(Dict)
+	return root
+}
+
+func (d *Document) loadXref() error {
+	tail := d.data[max(0, len(d.data)-2048):]
+	idx := bytes.LastIndex(tail, []byte("startxref"))
+	if idx < 0 {
+		return fmt.Errorf("startxref not found")
+	}
+	l := newLexer(tail, idx+len("startxref"))
+	offsetObj, err := l.readObject()
+	if err != nil {
+		return err
+	}
+	offset, ok := offsetObj.(int)
+	if !ok {
+		return fmt.Errorf("invalid startxref offset")
+	}
+
+	seen := map[int]bool{}
+	for offset > 0 && offset < len(d.data) && !seen[offset] {
+		seen[offset] = true
+		trailer, err := d.readXrefSection(offset)
+		if err != nil {
+			return err
+		}
+		if d.trailer == nil {
+			d.trailer = trailer
+		} else {
+			for k, v := range trailer {
+				if _, exists := d.trailer[k]; !exists {
+					d.trailer[k] = v
+				}
+			}
+		}
+		// File hybrid menyimpan objek terkompresi di /XRefStm di samping tabel klasik.
+		if stmOffset, ok := trailer["XRefStm"].(int); ok && !seen[stmOffset] {
+			seen[stmOffset] = true
+			if _, err := d.readXrefSection(stmOffset); err != nil {
+				return err
+			}
+		}
+		prev, ok := trailer["Prev"].(int)
+		if !ok {
+			break
+		}
+		offset = prev
+	}
+	if d.trailer == nil {
+		return fmt.Errorf("trailer not found")
+	}
+	// Objek yang terbaca sebelum seluruh rantai xref dimuat bisa berasal dari entri lama.
+	d.objects = map[int]interface{}{}
+	d.objStms = map[int]map[int]interface{}{}
+	return nil
+}
+
+// readXrefSection membaca satu bagian xref (tabel klasik atau xref stream). Entri yang sudah ada dari
+// bagian yang lebih baru tidak ditimpa.
+func (d *Document) readXrefSection(offset int) (Dict, error) {
+	l := newLexer(d.data, offset)
+	l.skipSpace()
+	if bytes.HasPrefix(d.data[l.pos:], []byte("xref")) {
+		l.pos += len("xref")
+		return d.readXrefTable(l)
+	}
+	obj, err := d.parseIndirectAt(offset, nil)
+	if err != nil {
+		return nil, err
+	}
+	stream, ok := obj.(*Stream)
+	if !ok {
+		return nil, fmt.Errorf("xref offset does not point to an xref stream")
+	}
+	if err := d.readXrefStream(stream); err != nil {
+		return nil, err
+	}
+	return stream.Dict, nil
+}
+
+func (d *Document) readXrefTable(l *lexer) (Dict, error) {
+	for {
+		l.skipSpace()
+		if bytes.HasPrefix(d.data[l.pos:], []byte("trailer")) {
+			l.pos += len("trailer")
+			obj, err := l.readObject()
+			if err != nil {
+				return nil, err
+			}
+			trailer, ok := obj.(Dict)
+			if !ok {
+				return nil, fmt.Errorf("invalid trailer")
+			}
+			return trailer, nil
+		}
+		startObj, err := l.readObject()
+		if err != nil {
+			return nil, err
+		}
+		countObj, err := l.readObject()
+		if err != nil {
+			return nil, err
+		}
+		start, ok1 := startObj.(int)
+		count, ok2 := countObj.(int)
+		if !ok1 || !ok2 || count < 0 {
+			return nil, fmt.Errorf("invalid xref subsection header")
+		}
+		for i := 0; i < count; i++ {
+			l.skipSpace()
+			offsetTok := l.readRegular()
+			l.skipSpace()
+			genTok := l.readRegular()
+			l.skipSpace()
+			kind := l.readRegular()
+			offset, err1 := strconv.Atoi(offsetTok)
+			gen, err2 := strconv.Atoi(genTok)
+			if err1 != nil || err2 != nil {
+				return nil, fmt.Errorf("invalid xref entry")
+			}
+			num := start + i
+			if _, exists := d.xref[num]; exists || kind != "n" {
+				continue
+			}
+			d.xref[num] = xrefEntry{offset: offset, gen: gen}
+		}
+	}
+}
+
+func (d *Document) readXrefStream(stream *Stream) error {
+	data, err := d.decodeStreamData(stream, false)
+	if err != nil {
+		return err
+	}
+	widths, _ := stream.Dict["W"].(Array)
+	if len(widths) < 3 {
+		return fmt.Errorf("invalid xref stream /W")
+	}
+	w := [3]int{intOr(widths[0], 0), intOr(widths[1], 0), intOr(widths[2], 0)}
+	rowLen := w[0] + w[1] + w[2]
+	if rowLen <= 0 {
+		return fmt.Errorf("invalid xref stream /W")
+	}
+	index, _ := stream.Dict["Index"].(Array)
+	if len(index) == 0 {
+		index = Array{0, intOr(stream.Dict["Size"], 0)}
+	}
+	readField := func(row []byte, width int, fallback int) int {
+		if width == 0 {
+			return fallback
+		}
+		v := 0
+		for _, b := range row[:width] {
+			v = v<<8 | int(b)
+		}
+		return v
+	}
+	pos := 0
+	for i := 0; i+1 < len(index); i += 2 {
+		start, count := intOr(index[i], 0), intOr(index[i+1], 0)
+		for j := 0; j < count && pos+rowLen <= len(data); j++ {
+			row := data[pos : pos+rowLen]
+			pos += rowLen
+			kind := readField(row, w[0], 1)
+			f2 := readField(row[w[0]:], w[1], 0)
+			f3 := readField(row[w[0]+w[1]:], w[2], 0)
+			num := start + j
+			if _, exists := d.xref[num]; exists {
+				continue
+			}
+			switch kind {
+			case 1:
+				d.xref[num] = xrefEntry{offset: f2, gen: f3}
+			case 2:
+				d.xref[num] = xrefEntry{objStream: f2, compress: true}
+			}
+		}
+	}
+	return nil
+}
+
+// repair membangun ulang xref dengan memindai seluruh file untuk "n g obj" (kemunculan terakhir menang,
+// sesuai pembaruan inkremental) dan mencari trailer atau katalog.
+func (d *Document) repair() error {
+	if d.repaired {
+		return fmt.Errorf("pdf structure is damaged beyond repair")
+	}
+	d.repaired = true
+	d.xref = map[int]xrefEntry{}
+	d.objects = map[int]interface{}{}
+	for _, m := range objHeaderPattern.FindAllSubmatchIndex(d.data, -1) {
+		num, _ := strconv.Atoi(string(d.data[m[2]:m[3]]))
+		gen, _ := strconv.Atoi(string(d.data[m[4]:m[5]]))
+		d.xref[num] = xrefEntry{offset: m[0], gen: gen}
+	}
+	if len(d.xref) == 0 {
+		return ErrNotPDF
+	}
+
+	trailer := Dict{}
+	searchEnd := len(d.data)
+	for {
+		idx := bytes.LastIndex(d.data[:searchEnd], []byte("trailer"))
+		if idx < 0 {
+			break
+		}
+		if obj, err := newLexer(d.data, idx+len("trailer")).readObject(); err == nil {
+			if dict, ok := obj.(Dict); ok {
+				for k, v := range dict {
+					if _, exists := trailer[k]; !exists {
+						trailer[k] = v
+					}
+				}
+			}
+		}
+		searchEnd = idx
+	}
+	d.trailer = trailer
+
+	// Objek di dalam object stream tidak terlihat oleh pemindaian; daftarkan isinya dari setiap /ObjStm.
+	for num, entry := range d.xref {
+		obj, err := d.parseIndirectAt(entry.offset, &Ref{Num: num, Gen: entry.gen})
+		if err != nil {
+			continue
+		}
+		stream, ok := obj.(*Stream)
+		if !ok {
+			continue
+		}
+		switch stream.Dict["Type"] {
+		case Name("ObjStm"):
+			nums, _ := d.objectStreamHeader(stream)
+			for _, objNum := range nums {
+				if _, exists := d.xref[objNum]; !exists {
+					d.xref[objNum] = xrefEntry{objStream: num, compress: true}
+				}
+			}
+		case Name("XRef"):
+			for _, key := range []Name{"Root", "Info", "ID", "Encrypt"} {
+				if _, exists := d.trailer[key]; !exists && stream.Dict[key] != nil {
+					d.trailer[key] = stream.Dict[key]
+				}
+			}
+		}
+	}
+
+	if d.catalog() == nil {
+		for num := range d.xref {
+			if dict, ok := d.object(num).(Dict); ok && dict["Type"] == Name("Catalog") {
+				d.trailer["Root"] = Ref{Num: num, Gen: d.xref[num].gen}
+				break
+			}
+		}
+	}
+	if d.catalog() == nil {
+		return fmt.Errorf("pdf has no document catalog")
+	}
+	return nil
+}
+
+// resolve mengikuti referensi tidak langsung sampai mendapat objek nyata.
+func (d *Document) resolve(obj interface{}) interface{} {
+	for depth := 0; depth < maxObjectResolveDepth; depth++ {
+		ref, ok := obj.(Ref)
+		if !ok {
+			return obj
+		}
+		obj = d.object(ref.Num)
+	}
+	return nil
+}
+
+func (d *Document) object(num int) interface{} {
+	if obj, ok := d.objects[num]; ok {
+		return obj
+	}
+	// Tandai dulu agar referensi melingkar (mis. /Length menunjuk objek yang sedang dibaca) tidak berulang.
+	d.objects[num] = nil
+	entry, ok := d.xref[num]
+	if !ok {
+		delete(d.objects, num)
+		return nil
+	}
+	var obj interface{}
+	if entry.compress {
+		obj = d.objectFromStream(entry.objStream, num)
+	} else {
+		ref := Ref{Num: num, Gen: entry.gen}
+		parsed, err := d.parseIndirectAt(entry.offset, &ref)
+		if err != nil && !d.repaired {
+			// Offset xref meleset (file hasil edit yang rusak); bangun ulang xref lalu coba sekali lagi.
+			if d.repair() == nil {
+				return d.object(num)
+			}
+		}
+		if d.crypt != nil && parsed != nil {
+			parsed = d.crypt.decryptObject(ref, parsed)
+		}
+		obj = parsed
+	}
+	d.objects[num] = obj
+	return obj
+}
+
+// parseIndirectAt mem-parse "n g obj ... endobj" pada offset. Bila expect diisi, nomor objek harus cocok.
+func (d *Document) parseIndirectAt(offset int, expect *Ref) (interface{}, error) {
+	if offset < 0 || offset >= len(d.data) {
+		return nil, fmt.Errorf("object offset out of range")
+	}
+	l := newLexer(d.data, offset)
+	numObj, err := l.readObject()
+	if err != nil {
+		return nil, err
+	}
+	// "n g obj" terbaca sebagai angka lalu kata kunci; tryReadRef tidak cocok karena tidak ada R.
+	num, ok := numObj.(int)
+	if !ok {
+		return nil, fmt.Errorf("object header not found at offset %d", offset)
+	}
+	genObj, _ := l.readObject()
+	gen, _ := genObj.(int)
+	if kw, _ := l.readObject(); kw != Keyword("obj") {
+		return nil, fmt.Errorf("object header not found at offset %d", offset)
+	}
+	if expect != nil && expect.Num != num {
+		return nil, fmt.Errorf("object %d not found at offset %d", expect.Num, offset)
+	}
+	obj, err := l.readObject()
+	if err != nil {
+		return nil, err
+	}
+	dict, isDict := obj.(Dict)
+	if !isDict {
+		return obj, nil
+	}
+	save := l.pos
+	l.skipSpace()
+	if !bytes.HasPrefix(d.data[l.pos:], []byte("stream")) {
+		l.pos = save
+		return dict, nil
+	}
+	start := l.pos + len("stream")
+	if start < len(d.data) && d.data[start] == '\r' {
+		start++
+	}
+	if start < len(d.data) && d.data[start] == '\n' {
+		start++
+	}
+	return &Stream{Dict: dict, Data: d.streamBytes(dict, start), ref: Ref{Num: num, Gen: gen}}, nil
+}
+
+// streamBytes mengambil data stream memakai /Length; bila panjangnya tidak cocok dengan letak "endstream",
+// batas dicari langsung di file.
+func (d *Document) streamBytes(dict Dict, start int) []byte {
+	length := -1
+	switch v := dict["Length"].(type) {
+	case int:
+		length = v
+	case Ref:
+		// Objek yang sedang dibaca ditandai nil di cache; jangan di-resolve ulang agar tidak rekursif.
+		cached, inCache := d.objects[v.Num]
+		if !inCache {
+			cached = d.resolve(v)
+		}
+		if n, ok := cached.(int); ok {
+			length = n
+		}
+	}
+	if length >= 0 && start+length <= len(d.data) {
+		tail := d.data[start+length:]
+		trimmed := bytes.TrimLeft(tail[:min(len(tail), 32)], " \t\r\n\f\x00")
+		if bytes.HasPrefix(trimmed, []byte("endstream")) {
+			return d.data[start : start+length]
+		}
+	}
+	end := bytes.Index(d.data[start:], []byte("endstream"))
+	if end < 0 {
+		return d.data[start:]
+	}
+	data := d.data[start : start+end]
+	if bytes.HasSuffix(data, []byte("\r\n")) {
+		data = data[:len(data)-2]
+	} else if bytes.HasSuffix(data, []byte("\n")) || bytes.HasSuffix(data, []byte("\r")) {
+		data = data[:len(data)-1]
+	}
+	return data
+}
+
+func (d *Document) objectStreamHeader(stream *Stream) ([]int, []int) {
+	data, err := d.decodeStreamData(stream, true)
+	if err != nil {
+		return nil, nil
+	}
+	n := intOr(stream.Dict["N"], 0)
+	l := newLexer(data, 0)
+	nums := make([]int, 0, n)
+	offsets := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		numObj, err1 := l.readObject()
+		offObj, err2 := l.readObject()
+		num, ok1 := numObj.(int)
+		off, ok2 := offObj.(int)
+		if err1 != nil || err2 != nil || !ok1 || !ok2 {
+			break
+		}
+		nums = append(nums, num)
+		offsets = append(offsets, off)
+	}
+	return nums, offsets
+}
+
+func (d *Document) objectFromStream(streamNum, num int) interface{} {
+	parsed, ok := d.objStms[streamNum]
+	if !ok {
+		parsed = map[int]interface{}{}
+		d.objStms[streamNum] = parsed
+		if stream, isStream := d.object(streamNum).(*Stream); isStream {
+			data, err := d.decodeStreamData(stream, true)
+			if err == nil {
+				first := intOr(stream.Dict["First"], 0)
+				nums, offsets := d.objectStreamHeader(stream)
+				for i := range nums {
+					if first+offsets[i] >= len(data) {
+						continue
+					}
+					obj, err := newLexer(data, first+offsets[i]).readObject()
+					if err == nil {
+						parsed[nums[i]] = obj
+					}
+				}
+			}
+		}
+	}
+	return parsed[num]
+}
+
+// decodeStreamData mendekripsi (bila perlu) lalu mendekode filter stream. Xref stream tidak pernah dienkripsi.
+// Setiap hasil dekode mengurangi decodeBudget; setelah anggaran habis semua stream berikutnya ditolak dengan ErrTooLarge.
+func (d *Document) decodeStreamData(stream *Stream, decrypt bool) ([]byte, error) {
+	if d.decodeBudget <= 0 {
+		return nil, ErrTooLarge
+	}
+	data := stream.Data
+	if decrypt && d.crypt != nil && stream.Dict["Type"] != Name("XRef") {
+		data = d.crypt.decrypt(stream.ref, data, true)
+	}
+	var filters []Name
+	var params []Dict
+	switch f := d.resolve(stream.Dict["Filter"]).(type) {
+	case Name:
+		filters = []Name{f}
+	case Array:
+		for _, item := range f {
+			if name, ok := d.resolve(item).(Name); ok {
+				filters = append(filters, name)
+			}
+		}
+	}
+	switch p := d.resolve(stream.Dict["DecodeParms"]).(type) {
+	case Dict:
+		params = []Dict{p}
+	case Array:
+		for _, item := range p {
+			dict, _ := d.resolve(item).(Dict)
+			params = append(params, dict)
+		}
+	}
+	// Batas per stream tetap maxDecodedStreamSize; menjelang anggaran habis, dekompresi berhenti tepat setelah
+	// melewati sisa anggaran agar tidak membuang waktu mendekode data yang pasti ditolak.
+	limit := min(int64(maxDecodedStreamSize), d.decodeBudget+1)
+	decoded, err := decodeStream(data, filters, params, limit)
+	if err != nil {
+		if errors.Is(err, ErrTooLarge) && limit > d.decodeBudget {
+			d.decodeBudget = 0
+		}
+		return nil, err
+	}
+	if int64(len(decoded)) > d.decodeBudget {
+		d.decodeBudget = 0
+		return nil, ErrTooLarge
+	}
+	d.decodeBudget -= int64(len(decoded))
+	return decoded, nil
+}
+
+// overBudget melaporkan apakah anggaran dekode dokumen sudah habis.
+func (d *Document) overBudget() bool {
+	return d.decodeBudget <= 0
+}
+
+// StreamData mengembalikan isi stream yang sudah didekode.
+func (d *Document) StreamData(stream *Stream) ([]byte, error) {
+	return d.decodeStreamData(stream, true)
+}
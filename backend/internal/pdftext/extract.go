@@ -0,0 +1,170 @@
+// Package pdftext mengekstrak teks dari PDF tanpa dependensi eksternal: membaca tabel xref (termasuk
+// xref stream dan object stream), menelusuri pohon halaman, mendekode font lewat ToUnicode CMap atau
+// encoding font sederhana, lalu menyusun teks per halaman dari posisi glyph.
+package pdftext
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Page adalah teks satu halaman PDF. Number dimulai dari 1 sesuai urutan pohon halaman.
+type Page struct {
+	Number int
+	Text   string
+}
+
+// maxPages membatasi jumlah halaman yang diproses dari satu dokumen.
+const maxPages = 2000
+
+// Extract membuka PDF dan mengembalikan teks setiap halaman. Halaman yang gagal diproses (content stream
+// rusak, font tidak terbaca) tetap muncul dengan teks kosong agar penomoran halaman tidak bergeser. Dokumen yang
+// hasil dekode stream-nya melewati maxDecodedDocumentSize ditolak dengan ErrTooLarge.
+func Extract(data []byte) (pages []Page, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			pages, err = nil, fmt.Errorf("malformed pdf: %v", r)
+		}
+	}()
+	doc, err := Open(data)
+	if err != nil {
+		return nil, err
+	}
+	root := doc.catalog()
+	if root == nil {
+		return nil, fmt.Errorf("pdf has no document catalog")
+	}
+	var nodes []pageNode
+	doc.collectPages(root["Pages"], nil, map[Ref]bool{}, &nodes)
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("pdf has no pages")
+	}
+
+	fonts := map[Ref]*font{}
+	result := make([]Page, 0, len(nodes))
+	for i, page := range nodes {
+		result = append(result, Page{Number: i + 1, Text: doc.pageText(page, fonts)})
+		if doc.overBudget() {
+			return nil, ErrTooLarge
+		}
+	}
+	return result, nil
+}
+
+// FormatPages menggabungkan teks halaman dengan penanda "[Halaman N]" agar potongan teks yang dipakai
+// sebagai konteks AI tetap bisa dirujuk ke halaman sumbernya. Halaman kosong dilewati.
+func FormatPages(pages []Page) string {
+	var b strings.Builder
+	for _, page := range pages {
+		if page.Text == "" {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString("\n\n")
+		}
+		b.WriteString("[Halaman ")
+		b.WriteString(strconv.Itoa(page.Number))
+		b.WriteString("]\n")
+		b.WriteString(page.Text)
+	}
+	return b.String()
+}
+
+type pageNode struct {
+	dict      Dict
+	resources Dict
+}
+
+// collectPages menelusuri pohon /Pages secara berurutan. /Resources diwariskan dari node induk bila halaman
+// tidak memilikinya; node yang sudah dikunjungi dilewati agar pohon melingkar tidak berputar terus.
+func (d *Document) collectPages(obj interface{}, inherited Dict, visited map[Ref]bool, out *[]pageNode) {
+	if ref, ok := obj.(Ref); ok {
+		if visited[ref] {
+			return
+		}
+		visited[ref] = true
+	}
+	node, ok := d.resolve(obj).(Dict)
+	if !ok || len(*out) >= maxPages {
+		return
+	}
+	resources := inherited
+	if own, ok := d.resolve(node["Resources"]).(Dict); ok {
+		resources = own
+	}
+	kids, hasKids := d.resolve(node["Kids"]).(Array)
+	if typ, _ := node["Type"].(Name); typ == "Pages" || (typ != "Page" && hasKids) {
+		for _, kid := range kids {
+			d.collectPages(kid, resources, visited, out)
+		}
+		return
+	}
+	*out = append(*out, pageNode{dict: node, resources: resources})
+}
+
+func (d *Document) pageText(page pageNode, fonts map[Ref]*font) (text string) {
+	defer func() {
+		// Parser toleran terhadap data rusak, tetapi satu halaman yang memicu panic tidak boleh
+		// menggagalkan ekstraksi halaman lain.
+		if recover() != nil {
+			text = ""
+		}
+	}()
+	var content []byte
+	switch c := d.resolve(page.dict["Contents"]).(type) {
+	case *Stream:
+		content, _ = d.StreamData(c)
+	case Array:
+		for _, item := range c {
+			if stream, ok := d.resolve(item).(*Stream); ok {
+				if data, err := d.StreamData(stream); err == nil {
+					content = append(content, data...)
+					content = append(content, '\n')
+				}
+			}
+		}
+	}
+	if len(content) == 0 {
+		return ""
+	}
+	e := &contentExtractor{doc: d, fonts: fonts, forms: map[*Stream]bool{}}
+	e.run(content, page.resources, graphicsState{ctm: identityMatrix, hScale: 1}, 0)
+	return cleanText(e.out.b.String())
+}
+
+// ligatureReplacer memecah ligatur Unicode (ﬁ, ﬂ, ...) agar kata tetap cocok saat dicari dan di-stem.
+var ligatureReplacer = strings.NewReplacer("\ufb00", "ff", "\ufb01", "fi", "\ufb02", "fl", "\ufb03", "ffi", "\ufb04", "ffl", "\ufb05", "st", "\ufb06", "st")
+
+// cleanText membuang karakter kontrol dan soft hyphen, memadatkan spasi per baris, dan menyisakan paling
+// banyak satu baris kosong berturut-turut.
+func cleanText(text string) string {
+	lines := strings.Split(text, "\n")
+	out := make([]string, 0, len(lines))
+	blank := false
+	for _, line := range lines {
+		line = strings.Map(func(r rune) rune {
+			switch {
+			case r == '\u00ad' || r == '\ufeff' || r == unicode.ReplacementChar:
+				return -1
+			case unicode.IsSpace(r):
+				return ' '
+			case unicode.IsControl(r):
+				return -1
+			}
+			return r
+		}, line)
+		line = ligatureReplacer.Replace(strings.Join(strings.Fields(line), " "))
+		if line == "" {
+			if !blank && len(out) > 0 {
+				out = append(out, "")
+			}
+			blank = true
+			continue
+		}
+		blank = false
+		out = append(out, line)
+	}
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
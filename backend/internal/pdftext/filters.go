@@ -0,0 +1,321 @@
+package pdftext
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"fmt"
+	"io"
+)
+
+// maxDecodedStreamSize membatasi hasil dekompresi satu stream agar PDF "zip bomb" tidak menghabiskan memori.
+const maxDecodedStreamSize = 64 << 20
+
+// maxDecodedDocumentSize membatasi total hasil dekompresi semua stream dalam satu dokumen, termasuk stream yang
+// dirujuk berulang dari /Contents atau Form XObject.
+const maxDecodedDocumentSize = 256 << 20
+
+// decodeStream menerapkan rantai /Filter beserta /DecodeParms. Filter gambar (DCT, JBIG2, CCITT, JPX)
+// tidak didukung karena tidak memuat teks. limit membatasi hasil dekompresi flate/LZW.
+func decodeStream(data []byte, filters []Name, params []Dict, limit int64) ([]byte, error) {
+	var err error
+	for i, filter := range filters {
+		var param Dict
+		if i < len(params) {
+			param = params[i]
+		}
+		switch filter {
+		case "FlateDecode", "Fl":
+			data, err = inflate(data, limit)
+			if err == nil {
+				data, err = applyPredictor(data, param)
+			}
+		case "LZWDecode", "LZW":
+			early := 1
+			if v, ok := param["EarlyChange"].(int); ok {
+				early = v
+			}
+			data, err = lzwDecode(data, early == 1, limit)
+			if err == nil {
+				data, err = applyPredictor(data, param)
+			}
+		case "ASCIIHexDecode", "AHx":
+			data = asciiHexDecode(data)
+		case "ASCII85Decode", "A85":
+			data, err = ascii85Decode(data)
+		case "RunLengthDecode", "RL":
+			data = runLengthDecode(data)
+		case "Crypt":
+			// Filter Crypt /Identity: data tidak berubah; crypt filter lain sudah ditangani saat dekripsi stream.
+		default:
+			return nil, fmt.Errorf("unsupported stream filter %s", filter)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// inflate mendekompresi zlib; stream tanpa header zlib dicoba sebagai deflate mentah. Data yang terpotong
+// tetap dikembalikan sejauh bisa dibaca karena banyak PDF menyimpan stream dengan checksum rusak.
+func inflate(data []byte, limit int64) ([]byte, error) {
+	if zr, err := zlib.NewReader(bytes.NewReader(data)); err == nil {
+		out, readErr := io.ReadAll(io.LimitReader(zr, limit))
+		if len(out) > 0 || readErr == nil {
+			return out, nil
+		}
+	}
+	out, readErr := io.ReadAll(io.LimitReader(flate.NewReader(bytes.NewReader(data)), limit))
+	if len(out) > 0 || readErr == nil {
+		return out, nil
+	}
+	return nil, fmt.Errorf("flate decode failed: %w", readErr)
+}
+
+// applyPredictor membalik predictor PNG (>= 10) dan TIFF (2) dari /DecodeParms.
+func applyPredictor(data []byte, param Dict) ([]byte, error) {
+	predictor, _ := param["Predictor"].(int)
+	if predictor <= 1 {
+		return data, nil
+	}
+	colors := intOr(param["Colors"], 1)
+	bpc := intOr(param["BitsPerComponent"], 8)
+	columns := intOr(param["Columns"], 1)
+	bpp := (colors*bpc + 7) / 8
+	rowLen := (colors*bpc*columns + 7) / 8
+	if rowLen <= 0 || bpp <= 0 {
+		return nil, fmt.Errorf("invalid predictor parameters")
+	}
+
+	if predictor == 2 {
+		if bpc != 8 {
+			return data, nil
+		}
+		out := append([]byte(nil), data...)
+		for row := 0; row+rowLen <= len(out); row += rowLen {
+			for i := bpp; i < rowLen; i++ {
+				out[row+i] += out[row+i-bpp]
+			}
+		}
+		return out, nil
+	}
+
+	out := make([]byte, 0, len(data))
+	prev := make([]byte, rowLen)
+	for pos := 0; pos < len(data); pos += rowLen + 1 {
+		filterType := data[pos]
+		end := pos + 1 + rowLen
+		if end > len(data) {
+			end = len(data)
+		}
+		row := make([]byte, rowLen)
+		copy(row, data[pos+1:end])
+		for i := 0; i < rowLen; i++ {
+			var left, up, upLeft byte
+			if i >= bpp {
+				left = row[i-bpp]
+				upLeft = prev[i-bpp]
+			}
+			up = prev[i]
+			switch filterType {
+			case 1:
+				row[i] += left
+			case 2:
+				row[i] += up
+			case 3:
+				row[i] += byte((int(left) + int(up)) / 2)
+			case 4:
+				row[i] += paeth(left, up, upLeft)
+			}
+		}
+		out = append(out, row[:end-pos-1]...)
+		prev = row
+	}
+	return out, nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	if pa <= pb && pa <= pc {
+		return a
+	}
+	if pb <= pc {
+		return b
+	}
+	return c
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func intOr(value interface{}, fallback int) int {
+	switch v := value.(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	}
+	return fallback
+}
+
+// lzwDecode mengimplementasikan LZW varian PDF (kode 9-12 bit, MSB-first). compress/lzw tidak mendukung
+// EarlyChange sehingga ditulis sendiri.
+func lzwDecode(data []byte, earlyChange bool, limit int64) ([]byte, error) {
+	const (
+		clearCode = 256
+		eodCode   = 257
+	)
+	var out bytes.Buffer
+	table := make([][]byte, 258, 4096)
+	for i := 0; i < 256; i++ {
+		table[i] = []byte{byte(i)}
+	}
+	codeLen := 9
+	var bitBuf uint32
+	bitCount := 0
+	var prev []byte
+	early := 0
+	if earlyChange {
+		early = 1
+	}
+	for _, b := range data {
+		bitBuf = bitBuf<<8 | uint32(b)
+		bitCount += 8
+		for bitCount >= codeLen {
+			code := int(bitBuf>>(uint(bitCount-codeLen))) & (1<<uint(codeLen) - 1)
+			bitCount -= codeLen
+			switch {
+			case code == clearCode:
+				table = table[:258]
+				codeLen = 9
+				prev = nil
+				continue
+			case code == eodCode:
+				return out.Bytes(), nil
+			}
+			var entry []byte
+			switch {
+			case code < len(table):
+				entry = table[code]
+			case code == len(table) && prev != nil:
+				entry = append(append([]byte(nil), prev...), prev[0])
+			default:
+				return out.Bytes(), fmt.Errorf("invalid lzw code %d", code)
+			}
+			out.Write(entry)
+			if int64(out.Len()) > limit {
+				return nil, fmt.Errorf("lzw stream too large: %w", ErrTooLarge)
+			}
+			if prev != nil && len(table) < 4096 {
+				table = append(table, append(append([]byte(nil), prev...), entry[0]))
+			}
+			prev = entry
+			if len(table)+early >= 1<<uint(codeLen) && codeLen < 12 {
+				codeLen++
+			}
+		}
+	}
+	return out.Bytes(), nil
+}
+
+func asciiHexDecode(data []byte) []byte {
+	var out bytes.Buffer
+	var hi byte
+	haveHi := false
+	for _, c := range data {
+		if c == '>' {
+			break
+		}
+		v, ok := hexValue(c)
+		if !ok {
+			continue
+		}
+		if haveHi {
+			out.WriteByte(hi<<4 | v)
+			haveHi = false
+		} else {
+			hi, haveHi = v, true
+		}
+	}
+	if haveHi {
+		out.WriteByte(hi << 4)
+	}
+	return out.Bytes()
+}
+
+func ascii85Decode(data []byte) ([]byte, error) {
+	var out bytes.Buffer
+	var group [5]byte
+	n := 0
+	if bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte("<~")) {
+		data = bytes.TrimLeft(data, " \t\r\n")[2:]
+	}
+	for _, c := range data {
+		if c == '~' {
+			break
+		}
+		if isWhitespace(c) {
+			continue
+		}
+		if c == 'z' && n == 0 {
+			out.Write([]byte{0, 0, 0, 0})
+			continue
+		}
+		if c < '!' || c > 'u' {
+			return nil, fmt.Errorf("invalid ascii85 character")
+		}
+		group[n] = c - '!'
+		n++
+		if n == 5 {
+			v := uint32(0)
+			for _, d := range group {
+				v = v*85 + uint32(d)
+			}
+			out.Write([]byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)})
+			n = 0
+		}
+	}
+	if n > 1 {
+		for i := n; i < 5; i++ {
+			group[i] = 84
+		}
+		v := uint32(0)
+		for _, d := range group {
+			v = v*85 + uint32(d)
+		}
+		full := []byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}
+		out.Write(full[:n-1])
+	}
+	return out.Bytes(), nil
+}
+
+func runLengthDecode(data []byte) []byte {
+	var out bytes.Buffer
+	for i := 0; i < len(data); {
+		length := int(data[i])
+		i++
+		switch {
+		case length == 128:
+			return out.Bytes()
+		case length < 128:
+			end := i + length + 1
+			if end > len(data) {
+				end = len(data)
+			}
+			out.Write(data[i:end])
+			i = end
+		default:
+			if i < len(data) {
+				out.Write(bytes.Repeat([]byte{data[i]}, 257-length))
+				i++
+			}
+		}
+	}
+	return out.Bytes()
+}
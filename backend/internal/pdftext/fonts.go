@@ -0,0 +1,239 @@
+package pdftext
+
+import "strings"
+
+// font adalah hasil baca satu kamus font: cara memecah string menjadi kode, memetakan kode ke Unicode,
+// dan lebar glyph untuk menghitung posisi teks (deteksi spasi antarkata).
+type font struct {
+	composite    bool
+	codespace    []codeRange
+	toUnicode    *cmap
+	encodingCMap *cmap // CMap /Encoding tertanam pada font Type0
+	unicodeCodes bool  // font Type0 dengan CMap predefined UCS2/UTF16: kode = UTF-16BE
+	simpleTable  [256]string
+	widths       map[int]float64
+	defaultWidth float64
+	widthScale   float64 // satuan glyph -> satuan teks (1/1000, atau FontMatrix untuk Type3)
+}
+
+// glyph adalah satu kode hasil decode string yang ditampilkan.
+type glyph struct {
+	text    string
+	width   float64 // lebar dalam satuan teks sebelum dikali ukuran font
+	isSpace bool    // kode satu byte 32: word spacing (Tw) berlaku
+}
+
+// defaultGlyphWidth dipakai font sederhana tanpa /Widths (font standar 14) sebagai perkiraan rata-rata.
+const defaultGlyphWidth = 500
+
+func (d *Document) loadFont(obj interface{}) *font {
+	dict, _ := d.resolve(obj).(Dict)
+	f := &font{widths: map[int]float64{}, widthScale: 0.001}
+	if dict == nil {
+		f.setBaseEncoding(encodingLatin1)
+		f.defaultWidth = defaultGlyphWidth
+		return f
+	}
+	if stream, ok := d.resolve(dict["ToUnicode"]).(*Stream); ok {
+		if data, err := d.StreamData(stream); err == nil {
+			f.toUnicode = parseCMap(data)
+		}
+	}
+	if subtype, _ := dict["Subtype"].(Name); subtype == "Type0" {
+		d.loadCompositeFont(f, dict)
+	} else {
+		d.loadSimpleFont(f, dict, subtype)
+	}
+	return f
+}
+
+func (d *Document) loadCompositeFont(f *font, dict Dict) {
+	f.composite = true
+	switch enc := d.resolve(dict["Encoding"]).(type) {
+	case Name:
+		if isUnicodeCMapName(enc) {
+			f.unicodeCodes = true
+		}
+		if enc == "Identity-H" || enc == "Identity-V" || f.unicodeCodes {
+			f.codespace = []codeRange{{low: []byte{0x00, 0x00}, high: []byte{0xFF, 0xFF}}}
+		}
+	case *Stream:
+		if data, err := d.StreamData(enc); err == nil {
+			f.encodingCMap = parseCMap(data)
+			f.codespace = f.encodingCMap.codespace
+		}
+	}
+	if len(f.codespace) == 0 && f.toUnicode != nil {
+		f.codespace = f.toUnicode.codespace
+	}
+
+	f.defaultWidth = 1000
+	descendants, _ := d.resolve(dict["DescendantFonts"]).(Array)
+	if len(descendants) == 0 {
+		return
+	}
+	cidFont, _ := d.resolve(descendants[0]).(Dict)
+	if cidFont == nil {
+		return
+	}
+	if dw, ok := d.resolve(cidFont["DW"]).(int); ok {
+		f.defaultWidth = float64(dw)
+	}
+	// /W berisi "c [w1 w2 ...]" atau "cFirst cLast w".
+	w, _ := d.resolve(cidFont["W"]).(Array)
+	for i := 0; i < len(w); {
+		first, ok := d.resolve(w[i]).(int)
+		if !ok || i+1 >= len(w) {
+			break
+		}
+		if list, isList := d.resolve(w[i+1]).(Array); isList {
+			for j, item := range list {
+				f.widths[first+j] = number(d.resolve(item))
+			}
+			i += 2
+			continue
+		}
+		last, ok := d.resolve(w[i+1]).(int)
+		if !ok || i+2 >= len(w) || last-first > 0xFFFF {
+			break
+		}
+		width := number(d.resolve(w[i+2]))
+		for cid := first; cid <= last; cid++ {
+			f.widths[cid] = width
+		}
+		i += 3
+	}
+}
+
+func (d *Document) loadSimpleFont(f *font, dict Dict, subtype Name) {
+	descriptor, _ := d.resolve(dict["FontDescriptor"]).(Dict)
+	symbolic := intOr(d.resolve(descriptor["Flags"]), 0)&4 != 0
+
+	base := encodingStandard
+	if subtype == "TrueType" {
+		base = encodingWinAnsi
+	}
+	if symbolic {
+		base = encodingLatin1
+	}
+	var differences Array
+	switch enc := d.resolve(dict["Encoding"]).(type) {
+	case Name:
+		base = encodingByName(enc, base)
+	case Dict:
+		if name, ok := d.resolve(enc["BaseEncoding"]).(Name); ok {
+			base = encodingByName(name, base)
+		}
+		differences, _ = d.resolve(enc["Differences"]).(Array)
+	}
+	f.setBaseEncoding(base)
+	code := 0
+	for _, item := range differences {
+		switch v := d.resolve(item).(type) {
+		case int:
+			code = v
+		case Name:
+			if code >= 0 && code < 256 {
+				if text := glyphRune(string(v)); text != "" {
+					f.simpleTable[code] = text
+				}
+			}
+			code++
+		}
+	}
+
+	if subtype == "Type3" {
+		if matrix, ok := d.resolve(dict["FontMatrix"]).(Array); ok && len(matrix) > 0 {
+			f.widthScale = number(d.resolve(matrix[0]))
+		}
+	}
+	f.defaultWidth = defaultGlyphWidth
+	if missing := number(d.resolve(descriptor["MissingWidth"])); missing > 0 {
+		f.defaultWidth = missing
+	}
+	firstChar := intOr(d.resolve(dict["FirstChar"]), 0)
+	widths, _ := d.resolve(dict["Widths"]).(Array)
+	for i, item := range widths {
+		f.widths[firstChar+i] = number(d.resolve(item))
+	}
+}
+
+func (f *font) setBaseEncoding(enc baseEncoding) {
+	for code, r := range baseEncodingTable(enc) {
+		if r != 0 {
+			f.simpleTable[code] = string(r)
+		}
+	}
+}
+
+func encodingByName(name Name, fallback baseEncoding) baseEncoding {
+	switch name {
+	case "WinAnsiEncoding":
+		return encodingWinAnsi
+	case "MacRomanEncoding", "MacExpertEncoding":
+		return encodingMacRoman
+	case "StandardEncoding":
+		return encodingStandard
+	}
+	return fallback
+}
+
+// decode memecah string yang ditampilkan operator Tj/TJ menjadi glyph.
+func (f *font) decode(s []byte) []glyph {
+	glyphs := make([]glyph, 0, len(s))
+	for len(s) > 0 {
+		var code []byte
+		if f.composite {
+			code = nextCode(f.codespace, s, 2)
+		} else {
+			code = s[:1]
+		}
+		s = s[len(code):]
+		glyphs = append(glyphs, glyph{
+			text:    f.codeText(code),
+			width:   f.codeWidth(code) * f.widthScale,
+			isSpace: len(code) == 1 && code[0] == ' ',
+		})
+	}
+	return glyphs
+}
+
+func (f *font) codeText(code []byte) string {
+	if f.toUnicode != nil {
+		// Sebagian generator memetakan ligatur ke U+0000; encoding font dipakai sebagai gantinya.
+		if text, ok := f.toUnicode.lookupUnicode(code); ok && strings.Trim(text, "\x00") != "" {
+			return text
+		}
+	}
+	if f.composite {
+		if f.unicodeCodes {
+			return decodeUTF16(code)
+		}
+		// Font CID tanpa ToUnicode (mis. Identity-H dengan font subset) tidak bisa dipetakan ke teks.
+		return ""
+	}
+	return f.simpleTable[code[0]]
+}
+
+func (f *font) codeWidth(code []byte) float64 {
+	key := int(codeValue(code))
+	if f.composite && f.encodingCMap != nil {
+		if cid, ok := f.encodingCMap.lookupCID(code); ok {
+			key = cid
+		}
+	}
+	if width, ok := f.widths[key]; ok {
+		return width
+	}
+	return f.defaultWidth
+}
+
+func number(value interface{}) float64 {
+	switch v := value.(type) {
+	case int:
+		return float64(v)
+	case float64:
+		return v
+	}
+	return 0
+}
@@ -0,0 +1,347 @@
+package pdftext
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+)
+
+// Tipe objek PDF (ISO 32000-1 bagian 7.3). Angka bulat disimpan sebagai int, angka real sebagai float64,
+// boolean sebagai bool, dan null sebagai nil.
+type (
+	Name    string
+	Dict    map[Name]interface{}
+	Array   []interface{}
+	String  []byte
+	Keyword string // operator content stream atau kata kunci struktur file (obj, stream, R, ...)
+	Ref     struct{ Num, Gen int }
+)
+
+// Stream adalah objek stream; Data masih mentah (terenkripsi/terkompresi) sampai didekode oleh Document.
+type Stream struct {
+	Dict Dict
+	Data []byte
+	ref  Ref
+}
+
+func isWhitespace(c byte) bool {
+	return c == 0 || c == '\t' || c == '\n' || c == '\f' || c == '\r' || c == ' '
+}
+
+func isDelimiter(c byte) bool {
+	switch c {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
+
+// lexer membaca objek PDF dari buffer. Stream di dalam file dibaca oleh Document karena /Length bisa berupa referensi.
+type lexer struct {
+	data []byte
+	pos  int
+}
+
+func newLexer(data []byte, pos int) *lexer {
+	return &lexer{data: data, pos: pos}
+}
+
+func (l *lexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if isWhitespace(c) {
+			l.pos++
+			continue
+		}
+		if c == '%' {
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+			continue
+		}
+		return
+	}
+}
+
+func (l *lexer) eof() bool {
+	l.skipSpace()
+	return l.pos >= len(l.data)
+}
+
+// readObject membaca satu objek. Referensi "n g R" dikenali bila dua angka bulat diikuti kata kunci R.
+func (l *lexer) readObject() (interface{}, error) {
+	return l.readObjectDepth(0)
+}
+
+const maxNestingDepth = 256
+
+func (l *lexer) readObjectDepth(depth int) (interface{}, error) {
+	if depth > maxNestingDepth {
+		return nil, fmt.Errorf("pdf object nested too deeply")
+	}
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil, errUnexpectedEOF
+	}
+	c := l.data[l.pos]
+	switch {
+	case c == '/':
+		l.pos++
+		return l.readName(), nil
+	case c == '(':
+		l.pos++
+		return l.readLiteralString(), nil
+	case c == '<':
+		if l.pos+1 < len(l.data) && l.data[l.pos+1] == '<' {
+			l.pos += 2
+			return l.readDict(depth)
+		}
+		l.pos++
+		return l.readHexString(), nil
+	case c == '[':
+		l.pos++
+		return l.readArray(depth)
+	case c == ']' || c == '>' || c == ')' || c == '}' || c == '{':
+		l.pos++
+		return Keyword(string(c)), nil
+	}
+
+	token := l.readRegular()
+	if token == "" {
+		l.pos++
+		return Keyword(string(c)), nil
+	}
+	switch token {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	if num, ok := parseNumber(token); ok {
+		if n, isInt := num.(int); isInt && n >= 0 {
+			if ref, ok := l.tryReadRef(n); ok {
+				return ref, nil
+			}
+		}
+		return num, nil
+	}
+	return Keyword(token), nil
+}
+
+func (l *lexer) tryReadRef(num int) (Ref, bool) {
+	save := l.pos
+	l.skipSpace()
+	gen, ok := parseNumber(l.readRegular())
+	genInt, isInt := gen.(int)
+	if !ok || !isInt || genInt < 0 {
+		l.pos = save
+		return Ref{}, false
+	}
+	l.skipSpace()
+	if l.readRegular() != "R" {
+		l.pos = save
+		return Ref{}, false
+	}
+	return Ref{Num: num, Gen: genInt}, true
+}
+
+func (l *lexer) readRegular() string {
+	start := l.pos
+	for l.pos < len(l.data) && !isWhitespace(l.data[l.pos]) && !isDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	return string(l.data[start:l.pos])
+}
+
+func parseNumber(token string) (interface{}, bool) {
+	if token == "" {
+		return nil, false
+	}
+	c := token[0]
+	if !(c >= '0' && c <= '9') && c != '-' && c != '+' && c != '.' {
+		return nil, false
+	}
+	if n, err := strconv.Atoi(token); err == nil {
+		return n, true
+	}
+	if f, err := strconv.ParseFloat(token, 64); err == nil {
+		return f, true
+	}
+	// Beberapa generator menulis angka rusak seperti "--5" atau "0.0.1"; diperlakukan sebagai 0.
+	return 0.0, true
+}
+
+func (l *lexer) readName() Name {
+	var b bytes.Buffer
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if isWhitespace(c) || isDelimiter(c) {
+			break
+		}
+		if c == '#' && l.pos+2 < len(l.data) {
+			if v, err := strconv.ParseUint(string(l.data[l.pos+1:l.pos+3]), 16, 8); err == nil {
+				b.WriteByte(byte(v))
+				l.pos += 3
+				continue
+			}
+		}
+		b.WriteByte(c)
+		l.pos++
+	}
+	return Name(b.String())
+}
+
+func (l *lexer) readLiteralString() String {
+	var b bytes.Buffer
+	depth := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+			b.WriteByte(c)
+		case ')':
+			depth--
+			if depth == 0 {
+				return String(b.Bytes())
+			}
+			b.WriteByte(c)
+		case '\\':
+			if l.pos >= len(l.data) {
+				return String(b.Bytes())
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case '\r':
+				// Backslash di akhir baris menyambung string tanpa menulis pemisah baris.
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+			case '\n':
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						v = v*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					b.WriteByte(byte(v))
+				} else {
+					b.WriteByte(e)
+				}
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return String(b.Bytes())
+}
+
+func (l *lexer) readHexString() String {
+	var b bytes.Buffer
+	var hi byte
+	haveHi := false
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		if c == '>' {
+			break
+		}
+		v, ok := hexValue(c)
+		if !ok {
+			continue
+		}
+		if haveHi {
+			b.WriteByte(hi<<4 | v)
+			haveHi = false
+		} else {
+			hi, haveHi = v, true
+		}
+	}
+	if haveHi {
+		b.WriteByte(hi << 4)
+	}
+	return String(b.Bytes())
+}
+
+func hexValue(c byte) (byte, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0', true
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10, true
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
+
+func (l *lexer) readArray(depth int) (Array, error) {
+	arr := Array{}
+	for {
+		l.skipSpace()
+		if l.pos >= len(l.data) {
+			return arr, errUnexpectedEOF
+		}
+		if l.data[l.pos] == ']' {
+			l.pos++
+			return arr, nil
+		}
+		obj, err := l.readObjectDepth(depth + 1)
+		if err != nil {
+			return arr, err
+		}
+		if kw, ok := obj.(Keyword); ok && (kw == "endobj" || kw == "stream") {
+			// Array tidak ditutup; berhenti agar objek berikutnya tetap bisa dibaca.
+			return arr, nil
+		}
+		arr = append(arr, obj)
+	}
+}
+
+func (l *lexer) readDict(depth int) (Dict, error) {
+	dict := Dict{}
+	for {
+		l.skipSpace()
+		if l.pos >= len(l.data) {
+			return dict, errUnexpectedEOF
+		}
+		if l.data[l.pos] == '>' {
+			l.pos++
+			if l.pos < len(l.data) && l.data[l.pos] == '>' {
+				l.pos++
+			}
+			return dict, nil
+		}
+		keyObj, err := l.readObjectDepth(depth + 1)
+		if err != nil {
+			return dict, err
+		}
+		key, ok := keyObj.(Name)
+		if !ok {
+			if kw, isKw := keyObj.(Keyword); isKw && (kw == "endobj" || kw == "stream") {
+				return dict, nil
+			}
+			continue
+		}
+		value, err := l.readObjectDepth(depth + 1)
+		if err != nil {
+			return dict, err
+		}
+		dict[key] = value
+	}
+}
@@ -54,6 +54,10 @@ func SetupRoutes(router *mux.Router, db *sql.DB, materialService *services.Mater
 	questionBankService := services.NewQuestionBankService(db)
 	rubricTemplateService := services.NewRubricTemplateService(db)
 	sectionService := services.NewSectionService(db)
+	promptTemplateService := services.NewPromptTemplateService(db)
+	if aiService != nil {
+		aiService.SetPromptTemplateService(promptTemplateService)
+	}
 
 	// --- Inisialisasi Handler ---
 	// Handler menerima permintaan HTTP dan memanggil metode dari layanan.
@@ -66,6 +70,10 @@ func SetupRoutes(router *mux.Router, db *sql.DB, materialService *services.Mater
 	taskSubmissionHandlers := handlers.NewTaskSubmissionHandlers(essaySubmissionService)
 	aiResultHandlers := handlers.NewAIResultHandlers(aiResultService)
 	teacherReviewHandlers := handlers.NewTeacherReviewHandlers(teacherReviewService)
+	questionExemplarHandlers := handlers.NewQuestionExemplarHandlers(services.NewQuestionExemplarService(db))
+	similarityHandlers := handlers.NewSimilarityHandlers(services.NewSimilarityService(db, systemSettingService))
+	questionRegradeHandlers := handlers.NewQuestionRegradeHandlers(essaySubmissionService)
+	rescoreHandlers := handlers.NewRescoreHandlers(essaySubmissionService, adminAuditService)
 	gradeAppealHandlers := handlers.NewGradeAppealHandlers(gradeAppealService)
 	notificationHandlers := handlers.NewNotificationHandlers(notificationService)
 	notificationRealtimeHandlers := handlers.NewNotificationRealtimeHandlers()
@@ -75,6 +83,9 @@ func SetupRoutes(router *mux.Router, db *sql.DB, materialService *services.Mater
 	questionBankHandlers := handlers.NewQuestionBankHandlers(questionBankService, materialService)
 	rubricTemplateHandlers := handlers.NewRubricTemplateHandlers(rubricTemplateService)
 	sectionHandlers := handlers.NewSectionHandlers(sectionService)
+	promptTemplateHandlers := handlers.NewPromptTemplateHandlers(promptTemplateService, adminAuditService)
+	gradingCacheHandlers := handlers.NewGradingCacheHandlers(services.NewGradingCacheService(db, systemSettingService), adminAuditService)
+	aiQuotaHandlers := handlers.NewAIQuotaHandlers(services.NewAIQuotaService(db, systemSettingService, services.DailyTokenLimitFromEnv()), adminAuditService)
 	uploadHandler := handlers.NewUploadHandler()
 	adminOpsHandlers := handlers.NewAdminOpsHandlers(db, authService, essaySubmissionService, aiService, systemSettingService, adminAuditService, questionBankService)
 
@@ -138,9 +149,12 @@ func SetupRoutes(router *mux.Router, db *sql.DB, materialService *services.Mater
 
 	// Rute untuk submission esai.
 	protectedRouter.HandleFunc("/submissions", essaySubmissionHandlers.CreateEssaySubmissionHandler).Methods("POST")
+	protectedRouter.HandleFunc("/submissions/uploads", essaySubmissionHandlers.CreateSubmissionUploadHandler).Methods("POST")
+	protectedRouter.HandleFunc("/submissions/uploads/{uploadId}/confirm", essaySubmissionHandlers.ConfirmSubmissionUploadHandler).Methods("POST")
 	protectedRouter.HandleFunc("/submissions/{submissionId}", essaySubmissionHandlers.GetEssaySubmissionByIDHandler).Methods("GET")
 	protectedRouter.HandleFunc("/submissions/{submissionId}", essaySubmissionHandlers.UpdateEssaySubmissionHandler).Methods("PUT")
 	protectedRouter.HandleFunc("/submissions/{submissionId}", essaySubmissionHandlers.DeleteEssaySubmissionHandler).Methods("DELETE")
+	protectedRouter.HandleFunc("/submissions/{submissionId}/attachment", essaySubmissionHandlers.DownloadSubmissionAttachmentHandler).Methods("GET")
 	protectedRouter.HandleFunc("/students/{studentId}/submissions", essaySubmissionHandlers.GetEssaySubmissionsByStudentIDHandler).Methods("GET")
 	protectedRouter.HandleFunc("/task-submissions", taskSubmissionHandlers.CreateTaskSubmissionHandler).Methods("POST")
 	protectedRouter.HandleFunc("/task-submissions/{submissionId}", taskSubmissionHandlers.GetTaskSubmissionByIDHandler).Methods("GET")
@@ -185,6 +199,7 @@ func SetupRoutes(router *mux.Router, db *sql.DB, materialService *services.Mater
 	teacherRouter.HandleFunc("/classes/{classId}/teaching-modules", classTeachingModuleHandlers.GetClassTeachingModulesByClassIDHandler).Methods("GET")
 	teacherRouter.HandleFunc("/classes/{classId}/teaching-modules", classTeachingModuleHandlers.CreateClassTeachingModuleHandler).Methods("POST")
 	teacherRouter.HandleFunc("/teaching-modules/{moduleId}", classTeachingModuleHandlers.DeleteClassTeachingModuleHandler).Methods("DELETE")
+	teacherRouter.HandleFunc("/teaching-modules/{moduleId}/extract", classTeachingModuleHandlers.ReextractClassTeachingModuleHandler).Methods("POST")
 	teacherRouter.HandleFunc("/classes/{classId}/sections", sectionHandlers.GetSectionsByClassIDHandler).Methods("GET")
 	teacherRouter.HandleFunc("/classes/{classId}/sections", sectionHandlers.CreateSectionHandler).Methods("POST")
 	teacherRouter.HandleFunc("/sections/{sectionId}/contents", sectionHandlers.CreateSectionContentHandler).Methods("POST")
@@ -219,12 +234,27 @@ func SetupRoutes(router *mux.Router, db *sql.DB, materialService *services.Mater
 
 	// Rute terkait submission esai khusus guru.
 	teacherRouter.HandleFunc("/essay-questions/{questionId}/submissions", essaySubmissionHandlers.GetEssaySubmissionsByQuestionIDHandler).Methods("GET") // Mendapatkan submission esai berdasarkan pertanyaan.
+	teacherRouter.HandleFunc("/essay-questions/{questionId}/exemplars", questionExemplarHandlers.ListQuestionExemplarsHandler).Methods("GET")
+	teacherRouter.HandleFunc("/essay-questions/{questionId}/exemplars", questionExemplarHandlers.PinQuestionExemplarHandler).Methods("POST")
+	teacherRouter.HandleFunc("/essay-questions/{questionId}/exemplars/{exemplarId}", questionExemplarHandlers.UnpinQuestionExemplarHandler).Methods("DELETE")
+	teacherRouter.HandleFunc("/essay-questions/{questionId}/similarity", similarityHandlers.GetQuestionSimilarityHandler).Methods("GET")
+	teacherRouter.HandleFunc("/essay-questions/{questionId}/similarity/recompute", similarityHandlers.RecomputeQuestionSimilarityHandler).Methods("POST")
+	teacherRouter.HandleFunc("/essay-questions/{questionId}/regrade", questionRegradeHandlers.RegradeQuestionHandler).Methods("POST")
+	teacherRouter.HandleFunc("/essay-questions/{questionId}/regrade-batches", questionRegradeHandlers.ListQuestionRegradeBatchesHandler).Methods("GET")
+	teacherRouter.HandleFunc("/essay-questions/{questionId}/regrade-batches/{batchId}", questionRegradeHandlers.GetQuestionRegradeReportHandler).Methods("GET")
+	teacherRouter.HandleFunc("/submissions/{submissionId}/ai-result-history", questionRegradeHandlers.GetAIResultHistoryHandler).Methods("GET")
+	teacherRouter.HandleFunc("/rescore/preview", rescoreHandlers.PreviewRescoreHandler).Methods("POST")
+	teacherRouter.HandleFunc("/rescore/apply", rescoreHandlers.ApplyRescoreHandler).Methods("POST")
+	teacherRouter.HandleFunc("/rescore/runs", rescoreHandlers.ListRescoreRunsHandler).Methods("GET")
 	teacherRouter.HandleFunc("/materials/{materialId}/student-submission-summaries", essaySubmissionHandlers.GetMaterialStudentSubmissionSummariesHandler).Methods("GET")
 	teacherRouter.HandleFunc("/materials/{materialId}/students/{studentId}/submissions", essaySubmissionHandlers.GetMaterialSubmissionsByStudentHandler).Methods("GET")
 	teacherRouter.HandleFunc("/reports/classes/{classId}/students", essaySubmissionHandlers.GetClassStudentSubmissionSummariesHandler).Methods("GET")
 	teacherRouter.HandleFunc("/reports/classes/{classId}/distribution", essaySubmissionHandlers.GetClassScoreDistributionHandler).Methods("GET")
 	teacherRouter.HandleFunc("/reports/classes/{classId}/export", essaySubmissionHandlers.ExportClassStudentSummariesHandler).Methods("GET")
 	teacherRouter.HandleFunc("/reports/classes/{classId}/export-qwk", essaySubmissionHandlers.ExportClassQWKHandler).Methods("GET")
+	teacherRouter.HandleFunc("/reports/classes/{classId}/agreement", essaySubmissionHandlers.GetClassAgreementReportHandler).Methods("GET")
+	teacherRouter.HandleFunc("/ai-quota", aiQuotaHandlers.GetMyAIQuotaHandler).Methods("GET") // Sisa kuota token AI hari ini.
+	teacherRouter.HandleFunc("/reports/classes/{classId}/similarity-clusters", similarityHandlers.GetClassSimilarityClustersHandler).Methods("GET")
 	teacherRouter.HandleFunc("/reports/classes/{classId}/export-questions", essaySubmissionHandlers.ExportClassQuestionSummaryHandler).Methods("GET")
 	teacherRouter.HandleFunc("/reports/classes/{classId}/export-rubric-template", essaySubmissionHandlers.ExportClassRubricTemplateHandler).Methods("GET")
 	teacherRouter.HandleFunc("/reports/classes/{classId}/export-rubric-scores", essaySubmissionHandlers.ExportClassRubricScoresHandler).Methods("GET")
@@ -248,10 +278,31 @@ func SetupRoutes(router *mux.Router, db *sql.DB, materialService *services.Mater
 	adminRouter.HandleFunc("/settings/grading-mode", authHandlers.AdminGetGradingModeHandler).Methods("GET")
 	adminRouter.HandleFunc("/settings/grading-mode", authHandlers.AdminSetGradingModeHandler).Methods("PUT")
 	adminRouter.HandleFunc("/settings/{key}", adminOpsHandlers.AdminUpdateSettingHandler).Methods("PUT")
+	adminRouter.HandleFunc("/prompt-templates", promptTemplateHandlers.AdminListPromptTemplatesHandler).Methods("GET")
+	adminRouter.HandleFunc("/prompt-templates", promptTemplateHandlers.AdminCreatePromptTemplateHandler).Methods("POST")
+	adminRouter.HandleFunc("/prompt-templates/preview", promptTemplateHandlers.AdminPreviewPromptTemplateHandler).Methods("POST")
+	adminRouter.HandleFunc("/prompt-templates/{name}/reset", promptTemplateHandlers.AdminResetPromptTemplateHandler).Methods("POST")
+	adminRouter.HandleFunc("/prompt-templates/{templateId}", promptTemplateHandlers.AdminGetPromptTemplateHandler).Methods("GET")
+	adminRouter.HandleFunc("/prompt-templates/{templateId}", promptTemplateHandlers.AdminUpdatePromptTemplateHandler).Methods("PUT")
+	adminRouter.HandleFunc("/prompt-templates/{templateId}", promptTemplateHandlers.AdminDeletePromptTemplateHandler).Methods("DELETE")
+	adminRouter.HandleFunc("/prompt-templates/{templateId}/activate", promptTemplateHandlers.AdminActivatePromptTemplateHandler).Methods("POST")
+	adminRouter.HandleFunc("/grading-cache/stats", gradingCacheHandlers.AdminGradingCacheStatsHandler).Methods("GET")
+	adminRouter.HandleFunc("/grading-cache/purge", gradingCacheHandlers.AdminPurgeGradingCacheHandler).Methods("POST")
+	adminRouter.HandleFunc("/ai-quotas", aiQuotaHandlers.AdminListAIQuotasHandler).Methods("GET")
+	adminRouter.HandleFunc("/ai-quotas", aiQuotaHandlers.AdminUpsertAIQuotaHandler).Methods("PUT")
+	adminRouter.HandleFunc("/ai-quotas/{quotaId}", aiQuotaHandlers.AdminDeleteAIQuotaHandler).Methods("DELETE")
 	adminRouter.HandleFunc("/grading-queue/summary", adminOpsHandlers.AdminQueueSummaryHandler).Methods("GET")
 	adminRouter.HandleFunc("/grading-queue/jobs", adminOpsHandlers.AdminQueueJobsHandler).Methods("GET")
 	adminRouter.HandleFunc("/grading-queue/retry", adminOpsHandlers.AdminQueueRetryHandler).Methods("POST")
 	adminRouter.HandleFunc("/grading-queue/stop", adminOpsHandlers.AdminQueueStopHandler).Methods("POST")
+	adminRouter.HandleFunc("/grading-queue/regrade-by-model", adminOpsHandlers.AdminQueueRegradeByModelHandler).Methods("POST")
+	adminRouter.HandleFunc("/grading-queue/control", adminOpsHandlers.AdminQueueControlHandler).Methods("GET")
+	adminRouter.HandleFunc("/grading-queue/pause", adminOpsHandlers.AdminQueuePauseHandler).Methods("POST")
+	adminRouter.HandleFunc("/grading-queue/resume", adminOpsHandlers.AdminQueueResumeHandler).Methods("POST")
+	adminRouter.HandleFunc("/grading-queue/priority", adminOpsHandlers.AdminQueuePriorityHandler).Methods("POST")
+	adminRouter.HandleFunc("/grading-queue/class-limits", adminOpsHandlers.AdminListQueueClassLimitsHandler).Methods("GET")
+	adminRouter.HandleFunc("/grading-queue/class-limits", adminOpsHandlers.AdminUpsertQueueClassLimitHandler).Methods("PUT")
+	adminRouter.HandleFunc("/grading-queue/class-limits/{classId}", adminOpsHandlers.AdminDeleteQueueClassLimitHandler).Methods("DELETE")
 	adminRouter.HandleFunc("/users", authHandlers.AdminListUsersHandler).Methods("GET")
 	adminRouter.HandleFunc("/users/{userId}", authHandlers.AdminUserDetailHandler).Methods("GET")
 	adminRouter.HandleFunc("/users/{userId}", authHandlers.AdminUpdateUserHandler).Methods("PUT")
@@ -278,7 +329,10 @@ func SetupRoutes(router *mux.Router, db *sql.DB, materialService *services.Mater
 	adminRouter.HandleFunc("/feature-flags", adminOpsHandlers.AdminListFeatureFlagsHandler).Methods("GET")
 	adminRouter.HandleFunc("/feature-flags/{key}", adminOpsHandlers.AdminUpdateFeatureFlagHandler).Methods("PUT")
 	adminRouter.HandleFunc("/anomaly-alerts", adminOpsHandlers.AdminAnomalyAlertsHandler).Methods("GET")
+	adminRouter.HandleFunc("/grading-drift", adminOpsHandlers.AdminGradingDriftHandler).Methods("GET")
+	adminRouter.HandleFunc("/monitoring/prompt-injection", adminOpsHandlers.AdminPromptInjectionSummaryHandler).Methods("GET")
 	adminRouter.HandleFunc("/reports/build", adminOpsHandlers.AdminBuildReportHandler).Methods("POST")
+	adminRouter.HandleFunc("/reports/agreement", adminOpsHandlers.AdminAgreementReportHandler).Methods("GET")
 	adminRouter.HandleFunc("/announcements", adminOpsHandlers.AdminListAnnouncementsHandler).Methods("GET")
 	adminRouter.HandleFunc("/announcements", adminOpsHandlers.AdminCreateAnnouncementHandler).Methods("POST")
 	adminRouter.HandleFunc("/announcements/{announcementId}", adminOpsHandlers.AdminUpdateAnnouncementHandler).Methods("PUT")
@@ -0,0 +1,324 @@
+package services
+
+import (
+	"api-backend/internal/models"
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultAgreementScoreBinSize adalah lebar kategori skor akhir (0-100) untuk QWK/confusion matrix.
+const DefaultAgreementScoreBinSize = 10.0
+
+// agreementPair adalah pasangan skor guru (rater A) dan AI (rater B).
+type agreementPair struct {
+	Teacher float64
+	AI      float64
+}
+
+// computeAgreementMetrics menghitung QWK, Pearson r, MAE, dan exact/adjacent agreement.
+// binSize > 0 dipakai untuk mengelompokkan skor ke kategori ordinal (floor(score / binSize)).
+func computeAgreementMetrics(pairs []agreementPair, binSize float64) models.AgreementMetrics {
+	metrics := models.AgreementMetrics{N: len(pairs)}
+	if len(pairs) == 0 {
+		return metrics
+	}
+	if binSize <= 0 {
+		binSize = 1
+	}
+
+	toCategory := func(score float64) int {
+		return int(math.Floor(score/binSize + 1e-9))
+	}
+
+	minCat, maxCat := math.MaxInt32, math.MinInt32
+	var absErrSum float64
+	exact, adjacent := 0, 0
+	for _, p := range pairs {
+		tc, ac := toCategory(p.Teacher), toCategory(p.AI)
+		for _, c := range []int{tc, ac} {
+			if c < minCat {
+				minCat = c
+			}
+			if c > maxCat {
+				maxCat = c
+			}
+		}
+		absErrSum += math.Abs(p.Teacher - p.AI)
+		diff := tc - ac
+		if diff == 0 {
+			exact++
+		}
+		if diff >= -1 && diff <= 1 {
+			adjacent++
+		}
+	}
+
+	n := float64(len(pairs))
+	mae := absErrSum / n
+	exactRate := float64(exact) / n
+	adjacentRate := float64(adjacent) / n
+	metrics.MAE = &mae
+	metrics.ExactAgreement = &exactRate
+	metrics.AdjacentAgreement = &adjacentRate
+
+	k := maxCat - minCat + 1
+	matrix := make([][]int, k)
+	for i := range matrix {
+		matrix[i] = make([]int, k)
+	}
+	for _, p := range pairs {
+		matrix[toCategory(p.Teacher)-minCat][toCategory(p.AI)-minCat]++
+	}
+	labels := make([]string, k)
+	for i := 0; i < k; i++ {
+		cat := minCat + i
+		if binSize == 1 {
+			labels[i] = strconv.Itoa(cat)
+		} else if float64(cat)*binSize >= 100 {
+			labels[i] = "100"
+		} else {
+			lower := float64(cat) * binSize
+			labels[i] = strconv.FormatFloat(lower, 'f', -1, 64) + "-" + strconv.FormatFloat(lower+binSize, 'f', -1, 64)
+		}
+	}
+	metrics.ConfusionMatrix = &models.ConfusionMatrix{Labels: labels, Counts: matrix}
+
+	if qwk, ok := quadraticWeightedKappa(matrix); ok {
+		metrics.QWK = &qwk
+	}
+	if r, ok := pearsonCorrelation(pairs); ok {
+		metrics.PearsonR = &r
+	}
+	return metrics
+}
+
+// quadraticWeightedKappa menghitung QWK dari confusion matrix k×k.
+// Tidak terdefinisi bila hanya ada satu kategori atau expected disagreement nol.
+func quadraticWeightedKappa(matrix [][]int) (float64, bool) {
+	k := len(matrix)
+	if k < 2 {
+		return 0, false
+	}
+	rowTotals := make([]float64, k)
+	colTotals := make([]float64, k)
+	total := 0.0
+	for i := 0; i < k; i++ {
+		for j := 0; j < k; j++ {
+			v := float64(matrix[i][j])
+			rowTotals[i] += v
+			colTotals[j] += v
+			total += v
+		}
+	}
+	if total == 0 {
+		return 0, false
+	}
+	var observed, expected float64
+	denom := float64((k - 1) * (k - 1))
+	for i := 0; i < k; i++ {
+		for j := 0; j < k; j++ {
+			weight := float64((i-j)*(i-j)) / denom
+			observed += weight * float64(matrix[i][j])
+			expected += weight * rowTotals[i] * colTotals[j] / total
+		}
+	}
+	if expected == 0 {
+		return 0, false
+	}
+	return 1 - observed/expected, true
+}
+
+func pearsonCorrelation(pairs []agreementPair) (float64, bool) {
+	if len(pairs) < 2 {
+		return 0, false
+	}
+	n := float64(len(pairs))
+	var sumT, sumA float64
+	for _, p := range pairs {
+		sumT += p.Teacher
+		sumA += p.AI
+	}
+	meanT, meanA := sumT/n, sumA/n
+	var cov, varT, varA float64
+	for _, p := range pairs {
+		dt, da := p.Teacher-meanT, p.AI-meanA
+		cov += dt * da
+		varT += dt * dt
+		varA += da * da
+	}
+	if varT == 0 || varA == 0 {
+		return 0, false
+	}
+	return cov / math.Sqrt(varT*varA), true
+}
+
+func parseAspectScoreList(raw *string) []models.GradeEssayAspectScore {
+	if raw == nil || strings.TrimSpace(*raw) == "" {
+		return nil
+	}
+	var scores []models.GradeEssayAspectScore
+	if err := json.Unmarshal([]byte(*raw), &scores); err != nil {
+		return nil
+	}
+	return scores
+}
+
+// BuildAgreementReport menyusun laporan kesepakatan dari baris ekspor QWK.
+// Hanya submission yang memiliki skor AI dan nilai revisi guru yang dipasangkan.
+func BuildAgreementReport(rows []models.QWKExportRow, binSize float64, includeClasses bool) *models.AgreementReport {
+	if binSize <= 0 {
+		binSize = DefaultAgreementScoreBinSize
+	}
+	report := &models.AgreementReport{
+		ScoreBinSize:     binSize,
+		TotalSubmissions: len(rows),
+		ByQuestion:       []models.QuestionAgreement{},
+		ByAspect:         []models.AspectAgreement{},
+		ByMaterial:       []models.MaterialAgreement{},
+		GeneratedAt:      time.Now(),
+	}
+
+	type aspectKey struct{ questionID, aspect string }
+	var overall []agreementPair
+	questionPairs := map[string][]agreementPair{}
+	questionInfo := map[string]models.QuestionAgreement{}
+	materialPairs := map[string][]agreementPair{}
+	materialInfo := map[string]models.MaterialAgreement{}
+	classPairs := map[string][]agreementPair{}
+	classInfo := map[string]models.ClassAgreement{}
+	aspectPairs := map[aspectKey][]agreementPair{}
+	aspectInfo := map[aspectKey]models.AspectAgreement{}
+
+	for _, row := range rows {
+		if row.AIScore == nil || row.RevisedScore == nil {
+			continue
+		}
+		pair := agreementPair{Teacher: *row.RevisedScore, AI: *row.AIScore}
+		overall = append(overall, pair)
+
+		questionPairs[row.QuestionID] = append(questionPairs[row.QuestionID], pair)
+		if _, ok := questionInfo[row.QuestionID]; !ok {
+			questionInfo[row.QuestionID] = models.QuestionAgreement{
+				QuestionID:    row.QuestionID,
+				QuestionText:  row.QuestionText,
+				MaterialID:    row.MaterialID,
+				MaterialTitle: row.MaterialTitle,
+				SectionCardID: row.SectionCardID,
+				SectionTitle:  row.SectionTitle,
+			}
+		}
+		materialPairs[row.MaterialID] = append(materialPairs[row.MaterialID], pair)
+		if _, ok := materialInfo[row.MaterialID]; !ok {
+			materialInfo[row.MaterialID] = models.MaterialAgreement{
+				MaterialID:    row.MaterialID,
+				MaterialTitle: row.MaterialTitle,
+				ClassID:       row.ClassID,
+				ClassName:     row.ClassName,
+			}
+		}
+		if includeClasses {
+			classPairs[row.ClassID] = append(classPairs[row.ClassID], pair)
+			if _, ok := classInfo[row.ClassID]; !ok {
+				classInfo[row.ClassID] = models.ClassAgreement{ClassID: row.ClassID, ClassName: row.ClassName}
+			}
+		}
+
+		// Skor aspek AI dipasangkan dengan skor aspek guru berdasarkan nama aspek.
+		teacherAspects := map[string]int{}
+		for _, item := range parseAspectScoreList(row.TeacherAspectScores) {
+			teacherAspects[strings.ToLower(strings.TrimSpace(item.Aspek))] = item.SkorDiperoleh
+		}
+		if len(teacherAspects) == 0 {
+			continue
+		}
+		for _, item := range parseAspectScoreList(row.RubricScores) {
+			normalized := strings.ToLower(strings.TrimSpace(item.Aspek))
+			teacherScore, ok := teacherAspects[normalized]
+			if !ok {
+				continue
+			}
+			key := aspectKey{questionID: row.QuestionID, aspect: normalized}
+			aspectPairs[key] = append(aspectPairs[key], agreementPair{Teacher: float64(teacherScore), AI: float64(item.SkorDiperoleh)})
+			if _, exists := aspectInfo[key]; !exists {
+				aspectInfo[key] = models.AspectAgreement{
+					QuestionID:   row.QuestionID,
+					QuestionText: row.QuestionText,
+					Aspek:        strings.TrimSpace(item.Aspek),
+				}
+			}
+		}
+	}
+
+	report.PairedSubmissions = len(overall)
+	report.Overall = computeAgreementMetrics(overall, binSize)
+
+	for id, pairs := range questionPairs {
+		item := questionInfo[id]
+		item.Metrics = computeAgreementMetrics(pairs, binSize)
+		report.ByQuestion = append(report.ByQuestion, item)
+	}
+	sort.Slice(report.ByQuestion, func(i, j int) bool {
+		if report.ByQuestion[i].MaterialTitle != report.ByQuestion[j].MaterialTitle {
+			return report.ByQuestion[i].MaterialTitle < report.ByQuestion[j].MaterialTitle
+		}
+		return report.ByQuestion[i].QuestionText < report.ByQuestion[j].QuestionText
+	})
+
+	for key, pairs := range aspectPairs {
+		item := aspectInfo[key]
+		item.Metrics = computeAgreementMetrics(pairs, 1)
+		report.ByAspect = append(report.ByAspect, item)
+	}
+	sort.Slice(report.ByAspect, func(i, j int) bool {
+		if report.ByAspect[i].QuestionText != report.ByAspect[j].QuestionText {
+			return report.ByAspect[i].QuestionText < report.ByAspect[j].QuestionText
+		}
+		return report.ByAspect[i].Aspek < report.ByAspect[j].Aspek
+	})
+
+	for id, pairs := range materialPairs {
+		item := materialInfo[id]
+		item.Metrics = computeAgreementMetrics(pairs, binSize)
+		report.ByMaterial = append(report.ByMaterial, item)
+	}
+	sort.Slice(report.ByMaterial, func(i, j int) bool {
+		return report.ByMaterial[i].MaterialTitle < report.ByMaterial[j].MaterialTitle
+	})
+
+	if includeClasses {
+		report.ByClass = []models.ClassAgreement{}
+		for id, pairs := range classPairs {
+			item := classInfo[id]
+			item.Metrics = computeAgreementMetrics(pairs, binSize)
+			report.ByClass = append(report.ByClass, item)
+		}
+		sort.Slice(report.ByClass, func(i, j int) bool {
+			return report.ByClass[i].ClassName < report.ByClass[j].ClassName
+		})
+	}
+
+	return report
+}
+
+// GetClassAgreementReport menghitung metrik kesepakatan AI vs guru untuk satu kelas,
+// memakai filter yang sama dengan ekspor QWK.
+func (s *EssaySubmissionService) GetClassAgreementReport(classID, teacherID, materialID string, questionIDs []string, studentID, aiStatus, reviewStatus string, from, to *time.Time, query string, sectionIndex map[string]SectionCardInfo, binSize float64) (*models.AgreementReport, error) {
+	rows, err := s.ListClassQWKExportRows(classID, teacherID, materialID, questionIDs, studentID, aiStatus, reviewStatus, from, to, query, sectionIndex, true)
+	if err != nil {
+		return nil, err
+	}
+	return BuildAgreementReport(rows, binSize, false), nil
+}
+
+// GetAdminAgreementReport menghitung metrik kesepakatan lintas kelas untuk superadmin.
+func (s *EssaySubmissionService) GetAdminAgreementReport(classID, teacherID, materialID string, questionIDs []string, studentID, aiStatus, reviewStatus string, from, to *time.Time, query string, binSize float64) (*models.AgreementReport, error) {
+	rows, err := s.ListAdminQWKExportRows(classID, teacherID, materialID, questionIDs, studentID, aiStatus, reviewStatus, from, to, query, true)
+	if err != nil {
+		return nil, err
+	}
+	return BuildAgreementReport(rows, binSize, true), nil
+}
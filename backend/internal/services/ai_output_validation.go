@@ -0,0 +1,230 @@
+package services
+
+import (
+	"api-backend/internal/models"
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultAIRepairMaxAttempts adalah jumlah maksimum prompt perbaikan per panggilan grading.
+	defaultAIRepairMaxAttempts = 2
+
+	aiErrorTypeInvalidJSON      = "invalid_json"
+	aiErrorTypeRubricValidation = "rubric_validation"
+)
+
+// RubricViolation menjelaskan satu pelanggaran kontrak output grading terhadap rubrik.
+type RubricViolation struct {
+	Code   string `json:"code"` // invalid_json | missing_aspect | duplicate_aspect | unknown_aspect | invalid_score
+	Aspek  string `json:"aspek,omitempty"`
+	Detail string `json:"detail"`
+}
+
+// AIOutputValidationError dikembalikan bila output AI tetap melanggar rubrik
+// setelah seluruh percobaan perbaikan habis.
+type AIOutputValidationError struct {
+	ErrorType      string
+	Violations     []RubricViolation
+	RepairAttempts int
+}
+
+func (e *AIOutputValidationError) Error() string {
+	if e == nil {
+		return "AI output failed rubric validation"
+	}
+	details := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		details = append(details, v.Detail)
+	}
+	return fmt.Sprintf("AI output failed rubric validation after %d repair attempt(s): %s", e.RepairAttempts, strings.Join(details, "; "))
+}
+
+// validateAIResponseAgainstRubric memastikan setiap aspek rubrik muncul tepat satu kali
+// dan skornya termasuk salah satu Kriteria.Skor yang diizinkan.
+func validateAIResponseAgainstRubric(resp *AIResponse, rubric []models.RubricAspect) []RubricViolation {
+	violations := make([]RubricViolation, 0)
+	if resp == nil {
+		return append(violations, RubricViolation{Code: aiErrorTypeInvalidJSON, Detail: "response is empty"})
+	}
+
+	allowed := make(map[string]map[int]struct{}, len(rubric))
+	names := make(map[string]string, len(rubric))
+	for _, aspect := range rubric {
+		key := strings.ToLower(strings.TrimSpace(aspect.Aspek))
+		names[key] = aspect.Aspek
+		scores := make(map[int]struct{}, len(aspect.Kriteria))
+		for _, criterion := range aspect.Kriteria {
+			scores[criterion.Skor] = struct{}{}
+		}
+		allowed[key] = scores
+	}
+
+	seen := make(map[string]int, len(resp.SkorAspek))
+	for _, item := range resp.SkorAspek {
+		key := strings.ToLower(strings.TrimSpace(item.Aspek))
+		scores, ok := allowed[key]
+		if !ok {
+			violations = append(violations, RubricViolation{
+				Code:   "unknown_aspect",
+				Aspek:  item.Aspek,
+				Detail: fmt.Sprintf("aspect %q is not part of the rubric", item.Aspek),
+			})
+			continue
+		}
+		seen[key]++
+		if seen[key] == 2 {
+			violations = append(violations, RubricViolation{
+				Code:   "duplicate_aspect",
+				Aspek:  names[key],
+				Detail: fmt.Sprintf("aspect %q appears more than once", names[key]),
+			})
+		}
+		if _, valid := scores[item.SkorDiperoleh]; !valid && len(scores) > 0 {
+			violations = append(violations, RubricViolation{
+				Code:   "invalid_score",
+				Aspek:  names[key],
+				Detail: fmt.Sprintf("score %d for aspect %q must be one of %s", item.SkorDiperoleh, names[key], formatAllowedScores(scores)),
+			})
+		}
+	}
+	for _, aspect := range rubric {
+		key := strings.ToLower(strings.TrimSpace(aspect.Aspek))
+		if seen[key] == 0 {
+			violations = append(violations, RubricViolation{
+				Code:   "missing_aspect",
+				Aspek:  aspect.Aspek,
+				Detail: fmt.Sprintf("aspect %q is missing", aspect.Aspek),
+			})
+		}
+	}
+	return violations
+}
+
+// adoptHolisticBand memetakan output holistik ({"band": n}) ke satu entri skor_aspek untuk skala
+// holistik, sehingga validasi, perbaikan, dan ensemble memakai jalur yang sama dengan rubrik analitik.
+func adoptHolisticBand(resp *AIResponse, rubric []models.RubricAspect) {
+	if resp == nil || resp.Band == nil || len(resp.SkorAspek) > 0 || len(rubric) != 1 {
+		return
+	}
+	resp.SkorAspek = []AIAspectScore{{
+		Aspek:         rubric[0].Aspek,
+		SkorDiperoleh: *resp.Band,
+		Justifikasi:   resp.Justifikasi,
+		Kutipan:       resp.Kutipan,
+	}}
+}
+
+func formatAllowedScores(scores map[int]struct{}) string {
+	values := make([]int, 0, len(scores))
+	for score := range scores {
+		values = append(values, score)
+	}
+	sort.Ints(values)
+	parts := make([]string, 0, len(values))
+	for _, v := range values {
+		parts = append(parts, strconv.Itoa(v))
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+// buildRepairPrompt menyusun prompt perbaikan yang menyebut pelanggaran secara spesifik
+// sehingga model cukup mengoreksi bagian yang salah, bukan menilai ulang dari nol.
+func buildRepairPrompt(originalPrompt, previousOutput string, violations []RubricViolation) string {
+	var b bytes.Buffer
+	b.WriteString(originalPrompt)
+	b.WriteString("\nYOUR PREVIOUS RESPONSE WAS REJECTED BY THE VALIDATOR:\n")
+	b.WriteString(clampPromptText(previousOutput, 4000))
+	b.WriteString("\n\nVALIDATION ERRORS:\n")
+	for i, v := range violations {
+		b.WriteString(fmt.Sprintf("%d. %s\n", i+1, v.Detail))
+	}
+	b.WriteString("\nFix ONLY these errors. Keep every valid aspect score unchanged. ")
+	b.WriteString("Return the complete corrected JSON object following the same schema, with every rubric aspect exactly once.\n")
+	return b.String()
+}
+
+func violationsErrorType(violations []RubricViolation) string {
+	for _, v := range violations {
+		if v.Code != aiErrorTypeInvalidJSON {
+			return aiErrorTypeRubricValidation
+		}
+	}
+	return aiErrorTypeInvalidJSON
+}
+
+// validatedGrading adalah output grading yang lolos validasi beserta jejak panggilannya.
+type validatedGrading struct {
+	response *AIResponse
+	rawText  string  // Output mentah model pada percobaan yang diterima.
+	model    string  // Model yang dipanggil.
+	usage    aiUsage // Akumulasi token termasuk percobaan perbaikan.
+}
+
+// gradingPriority memetakan request grading ke prioritas limiter.
+func gradingPriority(req models.GradeEssayRequest) AIRequestPriority {
+	if req.Bulk {
+		return AIPriorityBulk
+	}
+	return AIPriorityInteractive
+}
+
+// generateValidatedGrading memanggil model, memvalidasi output terhadap rubrik, dan
+// mengirim prompt perbaikan maksimal s.repairMaxAttempts kali. Setiap panggilan dicatat
+// di ai_api_usage_logs; kegagalan validasi memakai error_type invalid_json/rubric_validation.
+func (s *AIService) generateValidatedGrading(scope AIUsageScope, priority AIRequestPriority, prompt, promptVersion, modelName, feature string, rubric []models.RubricAspect) (*validatedGrading, error) {
+	if strings.TrimSpace(modelName) == "" {
+		s.modelMu.RLock()
+		modelName = s.modelName
+		s.modelMu.RUnlock()
+	}
+
+	currentPrompt := prompt
+	currentFeature := feature
+	var violations []RubricViolation
+	var totalUsage aiUsage
+	for attempt := 0; attempt <= s.repairMaxAttempts; attempt++ {
+		startedAt := time.Now()
+		resp, err := s.generateContentWithModel(priority, currentPrompt, modelName)
+		if err != nil {
+			// Batas request harian limiter: model tidak dipanggil, teruskan agar antrean bisa menunda job.
+			var quotaErr *AIQuotaExceededError
+			if errors.As(err, &quotaErr) {
+				return nil, err
+			}
+			log.Printf("ERROR: AI API call failed: %v", err)
+			s.logAPIUsageForModel(scope, modelName, promptVersion, currentFeature, "error", detectAIErrorType(err), err.Error(), 0, 0, 0, time.Since(startedAt).Milliseconds())
+			return nil, fmt.Errorf("failed to generate content from AI service")
+		}
+		elapsed := time.Since(startedAt).Milliseconds()
+		totalUsage.PromptTokens += resp.Usage.PromptTokens
+		totalUsage.CandidateTokens += resp.Usage.CandidateTokens
+		totalUsage.TotalTokens += resp.Usage.TotalTokens
+
+		aiResponse, parseErr := parseAIResponse(resp.Text)
+		if parseErr != nil {
+			violations = []RubricViolation{{Code: aiErrorTypeInvalidJSON, Detail: "response is not a single valid JSON object matching the schema"}}
+		} else {
+			adoptHolisticBand(aiResponse, rubric)
+			violations = validateAIResponseAgainstRubric(aiResponse, rubric)
+		}
+		if len(violations) == 0 {
+			s.logAPIUsageForModel(scope, modelName, promptVersion, currentFeature, "success", "", "", resp.Usage.PromptTokens, resp.Usage.CandidateTokens, resp.Usage.TotalTokens, elapsed)
+			return &validatedGrading{response: aiResponse, rawText: resp.Text, model: modelName, usage: totalUsage}, nil
+		}
+
+		validationErr := &AIOutputValidationError{ErrorType: violationsErrorType(violations), Violations: violations, RepairAttempts: attempt}
+		s.logAPIUsageForModel(scope, modelName, promptVersion, currentFeature, "error", validationErr.ErrorType, validationErr.Error(), resp.Usage.PromptTokens, resp.Usage.CandidateTokens, resp.Usage.TotalTokens, elapsed)
+		log.Printf("WARNING: AI grading output rejected (attempt %d): %s", attempt+1, validationErr.Error())
+
+		currentPrompt = buildRepairPrompt(prompt, resp.Text, violations)
+		currentFeature = feature + "_repair"
+	}
+	return nil, &AIOutputValidationError{ErrorType: violationsErrorType(violations), Violations: violations, RepairAttempts: s.repairMaxAttempts}
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/option"
+)
+
+// aiProviderClient adalah kontrak minimal yang wajib dipenuhi setiap provider AI.
+// Semua fitur (grading, generate soal, test koneksi) hanya bergantung pada interface ini,
+// sehingga provider baru cukup didaftarkan di aiProviderFactories.
+type aiProviderClient interface {
+	generate(ctx context.Context, modelName, prompt string) (string, aiUsage, error)
+}
+
+// aiGenerationParamsReporter diimplementasikan provider yang dapat melaporkan parameter generasi
+// yang benar-benar dikirim ke model. Nilainya disimpan bersama hasil grading.
+type aiGenerationParamsReporter interface {
+	generationParams() map[string]interface{}
+}
+
+// aiProviderFactory membangun client provider dari variabel lingkungan.
+type aiProviderFactory func(modelName string) (aiProviderClient, error)
+
+var aiProviderFactories = map[aiProvider]aiProviderFactory{
+	aiProviderGemini:  newGeminiProviderFromEnv,
+	aiProviderLiteLLM: newLiteLLMProviderFromEnv,
+	aiProviderFake:    newFakeProviderFromEnv,
+}
+
+// SupportedAIProviders mengembalikan daftar nama provider yang terdaftar (terurut).
+func SupportedAIProviders() []string {
+	names := make([]string, 0, len(aiProviderFactories))
+	for name := range aiProviderFactories {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IsSupportedAIProvider memeriksa apakah nama provider dikenali.
+func IsSupportedAIProvider(name string) bool {
+	_, ok := aiProviderFactories[aiProvider(strings.ToLower(strings.TrimSpace(name)))]
+	return ok
+}
+
+// geminiProviderClient membungkus client Gemini agar memenuhi aiProviderClient.
+// Model dibuat per nama dan di-cache sehingga ensemble dapat menggilir beberapa model Gemini.
+type geminiProviderClient struct {
+	client       *genai.Client
+	defaultModel string
+	mu           sync.Mutex
+	models       map[string]*genai.GenerativeModel
+}
+
+func newGeminiProviderClient(apiKey, modelName string) (*geminiProviderClient, error) {
+	client, err := genai.NewClient(context.Background(), option.WithAPIKey(apiKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new genai client: %w", err)
+	}
+	provider := &geminiProviderClient{
+		client:       client,
+		defaultModel: modelName,
+		models:       make(map[string]*genai.GenerativeModel),
+	}
+	provider.modelFor(modelName)
+	return provider, nil
+}
+
+// close menutup koneksi client Gemini yang tidak lagi dipakai.
+func (c *geminiProviderClient) close() {
+	if c.client != nil {
+		_ = c.client.Close()
+	}
+}
+
+func newGeminiProviderFromEnv(modelName string) (aiProviderClient, error) {
+	apiKey := strings.TrimSpace(os.Getenv("GEMINI_API_KEY"))
+	if apiKey == "" {
+		return nil, fmt.Errorf("GEMINI_API_KEY environment variable not set")
+	}
+	return newGeminiProviderClient(apiKey, modelName)
+}
+
+func (c *geminiProviderClient) modelFor(modelName string) *genai.GenerativeModel {
+	if strings.TrimSpace(modelName) == "" {
+		modelName = c.defaultModel
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if model, ok := c.models[modelName]; ok {
+		return model
+	}
+	model := c.client.GenerativeModel(modelName)
+	model.GenerationConfig.ResponseMIMEType = "application/json"
+	c.models[modelName] = model
+	return model
+}
+
+func (c *geminiProviderClient) generationParams() map[string]interface{} {
+	// Temperature tidak diatur sehingga memakai default model Gemini.
+	return map[string]interface{}{"response_mime_type": "application/json"}
+}
+
+func (c *geminiProviderClient) generate(ctx context.Context, modelName, prompt string) (string, aiUsage, error) {
+	if c.client == nil {
+		return "", aiUsage{}, fmt.Errorf("AI model is unavailable")
+	}
+	resp, err := c.modelFor(modelName).GenerateContent(ctx, genai.Text(prompt))
+	if err != nil {
+		return "", aiUsage{}, err
+	}
+	text, err := extractGeminiText(resp)
+	if err != nil {
+		return "", aiUsage{}, err
+	}
+	usage := aiUsage{}
+	if resp.UsageMetadata != nil {
+		usage = aiUsage{
+			PromptTokens:    int64(resp.UsageMetadata.PromptTokenCount),
+			CandidateTokens: int64(resp.UsageMetadata.CandidatesTokenCount),
+			TotalTokens:     int64(resp.UsageMetadata.TotalTokenCount),
+		}
+	}
+	return text, usage, nil
+}
+
+func (c *liteLLMClient) generationParams() map[string]interface{} {
+	// Temperature 0 terbuang oleh omitempty, sehingga proxy memakai default model.
+	return map[string]interface{}{"response_format": "json_object"}
+}
+
+func newLiteLLMProviderFromEnv(modelName string) (aiProviderClient, error) {
+	baseURL := strings.TrimSpace(os.Getenv("LITELLM_BASE_URL"))
+	apiKey := strings.TrimSpace(os.Getenv("LITELLM_API_KEY"))
+	if baseURL == "" {
+		return nil, fmt.Errorf("LITELLM_BASE_URL environment variable not set")
+	}
+	if apiKey == "" {
+		return nil, fmt.Errorf("LITELLM_API_KEY environment variable not set")
+	}
+	return &liteLLMClient{
+		baseURL:    baseURL,
+		apiKey:     apiKey,
+		httpClient: &http.Client{Timeout: 60 * time.Second},
+	}, nil
+}
@@ -0,0 +1,400 @@
+package services
+
+import (
+	"api-backend/internal/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fakeAIScriptStep adalah satu langkah skrip provider "fake".
+// Langkah dipakai bergiliran per panggilan sehingga skenario seperti
+// "sukses, rate limit, JSON rusak" bisa diulang persis sama di lingkungan dev/CI.
+type fakeAIScriptStep struct {
+	Mode     string   `json:"mode"`     // score (default) | off_rubric | error | invalid_json | empty
+	Ratio    *float64 `json:"ratio"`    // 0..1, porsi skor maksimum tiap aspek; kosong = pseudo-acak dari seed
+	Feedback string   `json:"feedback"` // feedback_keseluruhan yang dikembalikan
+	Error    string   `json:"error"`    // pesan error untuk mode "error"
+	DelayMs  int      `json:"delay_ms"` // simulasi latensi provider
+	// FabricateQuote membuat kutipan yang tidak ada di jawaban siswa untuk menguji validasi bukti.
+	FabricateQuote bool `json:"fabricate_quote"`
+}
+
+// fakeProviderClient menghasilkan respons JSON berbentuk rubrik secara deterministik
+// tanpa memanggil layanan eksternal. Dipakai untuk pengujian lokal dan demo offline.
+type fakeProviderClient struct {
+	seed   int64
+	script []fakeAIScriptStep
+	mu     sync.Mutex
+	calls  int
+}
+
+var (
+	fakeRubricAspectPattern    = regexp.MustCompile(`^Aspek:\s*(.+)$`)
+	fakeRubricCriterionPattern = regexp.MustCompile(`^-\s*Skor\s+(-?\d+)\s*:`)
+	fakeHolisticBandPattern    = regexp.MustCompile(`^-\s*Band\s+(-?\d+)\s*:`)
+)
+
+// newFakeProviderFromEnv membaca AI_FAKE_SEED dan AI_FAKE_SCRIPT.
+// AI_FAKE_SCRIPT boleh berupa JSON array langsung atau path ke file JSON.
+func newFakeProviderFromEnv(modelName string) (aiProviderClient, error) {
+	seed := int64(1)
+	if raw := strings.TrimSpace(os.Getenv("AI_FAKE_SEED")); raw != "" {
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid AI_FAKE_SEED: %w", err)
+		}
+		seed = parsed
+	}
+	script, err := loadFakeAIScript(strings.TrimSpace(os.Getenv("AI_FAKE_SCRIPT")))
+	if err != nil {
+		return nil, err
+	}
+	return &fakeProviderClient{seed: seed, script: script}, nil
+}
+
+func loadFakeAIScript(raw string) ([]fakeAIScriptStep, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	payload := []byte(raw)
+	if !strings.HasPrefix(raw, "[") {
+		content, err := os.ReadFile(raw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read AI_FAKE_SCRIPT file: %w", err)
+		}
+		payload = content
+	}
+	var steps []fakeAIScriptStep
+	if err := json.Unmarshal(payload, &steps); err != nil {
+		return nil, fmt.Errorf("invalid AI_FAKE_SCRIPT JSON: %w", err)
+	}
+	for i := range steps {
+		steps[i].Mode = strings.ToLower(strings.TrimSpace(steps[i].Mode))
+		if steps[i].Mode == "" {
+			steps[i].Mode = "score"
+		}
+		switch steps[i].Mode {
+		case "score", "off_rubric", "error", "invalid_json", "empty":
+		default:
+			return nil, fmt.Errorf("invalid AI_FAKE_SCRIPT mode at step %d: %s", i, steps[i].Mode)
+		}
+		if steps[i].Ratio != nil && (*steps[i].Ratio < 0 || *steps[i].Ratio > 1) {
+			return nil, fmt.Errorf("AI_FAKE_SCRIPT ratio at step %d must be between 0 and 1", i)
+		}
+	}
+	return steps, nil
+}
+
+func (c *fakeProviderClient) nextStep() fakeAIScriptStep {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	step := fakeAIScriptStep{Mode: "score"}
+	if len(c.script) > 0 {
+		step = c.script[c.calls%len(c.script)]
+	}
+	c.calls++
+	return step
+}
+
+func (c *fakeProviderClient) generationParams() map[string]interface{} {
+	return map[string]interface{}{"seed": c.seed, "scripted_steps": len(c.script)}
+}
+
+func (c *fakeProviderClient) generate(ctx context.Context, modelName, prompt string) (string, aiUsage, error) {
+	step := c.nextStep()
+	if step.DelayMs > 0 {
+		select {
+		case <-ctx.Done():
+			return "", aiUsage{}, ctx.Err()
+		case <-time.After(time.Duration(step.DelayMs) * time.Millisecond):
+		}
+	}
+
+	var text string
+	switch step.Mode {
+	case "error":
+		msg := strings.TrimSpace(step.Error)
+		if msg == "" {
+			msg = "fake provider scripted error"
+		}
+		return "", aiUsage{}, fmt.Errorf("%s", msg)
+	case "invalid_json":
+		text = "fake provider: not a JSON payload"
+	case "empty":
+		return "", aiUsage{}, fmt.Errorf("received an empty response from AI service")
+	default:
+		var err error
+		text, err = c.buildResponse(prompt, step)
+		if err != nil {
+			return "", aiUsage{}, err
+		}
+	}
+
+	usage := aiUsage{
+		PromptTokens:    estimateFakeTokens(prompt),
+		CandidateTokens: estimateFakeTokens(text),
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CandidateTokens
+	return text, usage, nil
+}
+
+func (c *fakeProviderClient) buildResponse(prompt string, step fakeAIScriptStep) (string, error) {
+	switch {
+	case strings.Contains(prompt, "HOLISTIC SCALE"):
+		return c.buildHolisticResponse(prompt, step)
+	case strings.Contains(prompt, "skor_aspek"):
+		return c.buildGradingResponse(prompt, step)
+	case strings.Contains(prompt, "teks_soal"):
+		return buildFakeQuestionDraft(prompt)
+	default:
+		return `{"ok": true}`, nil
+	}
+}
+
+type fakeRubricAspect struct {
+	name   string
+	scores []int
+}
+
+// parseFakeRubricFromPrompt membaca kembali rubrik dari blok "GRADING RUBRIC"
+// yang disusun formatRubricForPrompt.
+func parseFakeRubricFromPrompt(prompt string) []fakeRubricAspect {
+	section := prompt
+	if idx := strings.Index(section, "GRADING RUBRIC:"); idx >= 0 {
+		section = section[idx+len("GRADING RUBRIC:"):]
+		for _, marker := range []string{"IDEAL ANSWER", "GROUNDING CONTEXT", "CALIBRATION EXAMPLES", "STUDENT'S ESSAY"} {
+			if end := strings.Index(section, marker); end >= 0 {
+				section = section[:end]
+			}
+		}
+	}
+
+	aspects := make([]fakeRubricAspect, 0)
+	for _, line := range strings.Split(section, "\n") {
+		line = strings.TrimSpace(line)
+		if m := fakeRubricAspectPattern.FindStringSubmatch(line); m != nil {
+			aspects = append(aspects, fakeRubricAspect{name: strings.TrimSpace(m[1])})
+			continue
+		}
+		if m := fakeRubricCriterionPattern.FindStringSubmatch(line); m != nil && len(aspects) > 0 {
+			if score, err := strconv.Atoi(m[1]); err == nil {
+				last := &aspects[len(aspects)-1]
+				last.scores = append(last.scores, score)
+			}
+		}
+	}
+	for i := range aspects {
+		sort.Ints(aspects[i].scores)
+		if len(aspects[i].scores) == 0 {
+			aspects[i].scores = []int{0}
+		}
+	}
+	return aspects
+}
+
+func (c *fakeProviderClient) buildGradingResponse(prompt string, step fakeAIScriptStep) (string, error) {
+	aspects := parseFakeRubricFromPrompt(prompt)
+	rng := c.promptRand(prompt)
+
+	sentences := fakeEssaySentences(prompt)
+	resp := AIResponse{SkorAspek: make([]AIAspectScore, 0, len(aspects))}
+	for _, aspect := range aspects {
+		var score int
+		if step.Ratio != nil {
+			maxScore := aspect.scores[len(aspect.scores)-1]
+			minScore := aspect.scores[0]
+			target := float64(minScore) + *step.Ratio*float64(maxScore-minScore)
+			score = aspect.scores[0]
+			for _, candidate := range aspect.scores {
+				if absFloat(float64(candidate)-target) < absFloat(float64(score)-target) {
+					score = candidate
+				}
+			}
+		} else {
+			score = aspect.scores[rng.Intn(len(aspect.scores))]
+		}
+		item := AIAspectScore{
+			Aspek:         aspect.name,
+			SkorDiperoleh: score,
+			Justifikasi:   fmt.Sprintf("Skor %d dipilih oleh provider simulasi untuk aspek %s.", score, aspect.name),
+			Kutipan:       []string{},
+		}
+		if len(sentences) > 0 {
+			item.Kutipan = append(item.Kutipan, sentences[rng.Intn(len(sentences))])
+		}
+		if step.FabricateQuote {
+			item.Kutipan = append(item.Kutipan, "kalimat ini tidak pernah ditulis oleh siswa")
+		}
+		resp.SkorAspek = append(resp.SkorAspek, item)
+	}
+	if step.Mode == "off_rubric" && len(resp.SkorAspek) > 0 {
+		// Skor di luar skala dan aspek terakhir hilang, untuk menguji validasi + re-prompt perbaikan.
+		first := aspects[0]
+		resp.SkorAspek[0].SkorDiperoleh = first.scores[len(first.scores)-1] + 1
+		if len(resp.SkorAspek) > 1 {
+			resp.SkorAspek = resp.SkorAspek[:len(resp.SkorAspek)-1]
+		}
+	}
+	resp.FeedbackKeseluruhan = strings.TrimSpace(step.Feedback)
+	if resp.FeedbackKeseluruhan == "" {
+		resp.FeedbackKeseluruhan = "Jawaban sudah dinilai oleh provider simulasi (fake)."
+	}
+
+	payload, err := json.Marshal(resp)
+	if err != nil {
+		return "", err
+	}
+	return string(payload), nil
+}
+
+// buildHolisticResponse memilih satu band dari blok "HOLISTIC SCALE" yang disusun
+// formatHolisticScaleForPrompt, dengan aturan ratio/seed yang sama seperti rubrik analitik.
+func (c *fakeProviderClient) buildHolisticResponse(prompt string, step fakeAIScriptStep) (string, error) {
+	section := prompt[strings.Index(prompt, "HOLISTIC SCALE"):]
+	bands := make([]int, 0)
+	for _, line := range strings.Split(section, "\n") {
+		if m := fakeHolisticBandPattern.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+			if band, err := strconv.Atoi(m[1]); err == nil {
+				bands = append(bands, band)
+			}
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "STUDENT'S ESSAY") {
+			break
+		}
+	}
+	sort.Ints(bands)
+	if len(bands) == 0 {
+		bands = []int{0}
+	}
+	rng := c.promptRand(prompt)
+
+	band := bands[rng.Intn(len(bands))]
+	if step.Ratio != nil {
+		target := float64(bands[0]) + *step.Ratio*float64(bands[len(bands)-1]-bands[0])
+		band = bands[0]
+		for _, candidate := range bands {
+			if absFloat(float64(candidate)-target) < absFloat(float64(band)-target) {
+				band = candidate
+			}
+		}
+	}
+	if step.Mode == "off_rubric" {
+		band = bands[len(bands)-1] + 1
+	}
+	resp := AIResponse{
+		Band:        &band,
+		Justifikasi: fmt.Sprintf("Band %d dipilih oleh provider simulasi.", band),
+		Kutipan:     []string{},
+	}
+	if sentences := fakeEssaySentences(prompt); len(sentences) > 0 {
+		resp.Kutipan = append(resp.Kutipan, sentences[rng.Intn(len(sentences))])
+	}
+	if step.FabricateQuote {
+		resp.Kutipan = append(resp.Kutipan, "kalimat ini tidak pernah ditulis oleh siswa")
+	}
+	resp.FeedbackKeseluruhan = strings.TrimSpace(step.Feedback)
+	if resp.FeedbackKeseluruhan == "" {
+		resp.FeedbackKeseluruhan = "Jawaban sudah dinilai oleh provider simulasi (fake)."
+	}
+	payload, err := json.Marshal(resp)
+	if err != nil {
+		return "", err
+	}
+	return string(payload), nil
+}
+
+// promptRand membuat sumber acak dari seed dan isi prompt tanpa nonce batas jawaban, sehingga prompt yang sama
+// dengan seed yang sama selalu menghasilkan respons yang sama.
+func (c *fakeProviderClient) promptRand(prompt string) *rand.Rand {
+	hasher := fnv.New64a()
+	_, _ = hasher.Write([]byte(stableEssayBoundaries(prompt)))
+	return rand.New(rand.NewSource(c.seed ^ int64(hasher.Sum64())))
+}
+
+// fakeEssayStartPattern mengenali penanda awal blok jawaban siswa beserta nonce-nya.
+var fakeEssayStartPattern = regexp.MustCompile(`<<<ESSAY-([0-9a-f]+)>>>\n`)
+
+// fakeEssaySentences mengambil kalimat dari blok jawaban siswa pada prompt grading
+// (maksimal 12 kata per kalimat) untuk dijadikan kutipan bukti.
+func fakeEssaySentences(prompt string) []string {
+	loc := fakeEssayStartPattern.FindStringSubmatchIndex(prompt)
+	if loc == nil {
+		return nil
+	}
+	essay := prompt[loc[1]:]
+	end := strings.Index(essay, "\n<<<END-"+prompt[loc[2]:loc[3]]+">>>")
+	if end < 0 {
+		return nil
+	}
+	essay = essay[:end]
+	sentences := make([]string, 0)
+	for _, part := range strings.FieldsFunc(essay, func(r rune) bool { return r == '.' || r == '!' || r == '?' || r == '\n' }) {
+		words := strings.Fields(part)
+		if len(words) < 2 {
+			continue
+		}
+		if len(words) > 12 {
+			words = words[:12]
+		}
+		sentences = append(sentences, strings.Join(words, " "))
+	}
+	return sentences
+}
+
+func buildFakeQuestionDraft(prompt string) (string, error) {
+	weight := 10.0
+	draft := models.AutoGeneratedEssayQuestion{
+		TeksSoal:      "Jelaskan secara singkat peristiwa sejarah utama yang dibahas pada materi ini!",
+		LevelKognitif: "C2",
+		Keywords:      []string{"peristiwa sejarah", "tokoh", "konteks waktu"},
+		IdealAnswer:   "Siswa menjelaskan peristiwa utama, tokoh terkait, serta konteks waktu secara ringkas sesuai materi.",
+		Weight:        &weight,
+		RubricType:    "analitik",
+		Rubrics: []models.GeneratedRubricAspect{
+			{
+				NamaAspek: "Ketepatan Konsep",
+				Descriptors: []models.GeneratedDescriptor{
+					{Score: 0, Description: "Belum memenuhi kriteria."},
+					{Score: 1, Description: "Sebagian kecil kriteria terpenuhi."},
+					{Score: 2, Description: "Sebagian besar kriteria terpenuhi."},
+					{Score: 3, Description: "Seluruh kriteria terpenuhi dengan baik."},
+				},
+			},
+			{
+				NamaAspek: "Kelengkapan Jawaban",
+				Descriptors: []models.GeneratedDescriptor{
+					{Score: 0, Description: "Belum memenuhi kriteria."},
+					{Score: 1, Description: "Sebagian kecil kriteria terpenuhi."},
+					{Score: 2, Description: "Sebagian besar kriteria terpenuhi."},
+					{Score: 3, Description: "Seluruh kriteria terpenuhi dengan baik."},
+				},
+			},
+		},
+	}
+	payload, err := json.Marshal(draft)
+	if err != nil {
+		return "", err
+	}
+	return string(payload), nil
+}
+
+func estimateFakeTokens(text string) int64 {
+	return int64(len([]rune(text))/4) + 1
+}
+
+func absFloat(value float64) float64 {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
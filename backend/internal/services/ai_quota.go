@@ -0,0 +1,620 @@
+package services
+
+import (
+	"api-backend/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	AIQuotaFeatureGrading    = "grading"
+	AIQuotaFeatureGeneration = "generation"
+	AIQuotaFeatureAll        = "all"
+
+	AIQuotaScopeClass   = "class"
+	AIQuotaScopeTeacher = "teacher"
+
+	// AIQuotaActionDefer menunda job grading ke jendela kuota berikutnya.
+	AIQuotaActionDefer = "defer"
+	// AIQuotaActionReject menggagalkan job grading; siswa/guru bisa mengulang setelah kuota pulih.
+	AIQuotaActionReject = "reject"
+	// AIQuotaActionTeacherOnly menahan submission untuk dinilai guru tanpa AI.
+	AIQuotaActionTeacherOnly = "teacher_only"
+
+	aiQuotaActionSettingKey = "ai_quota_exhausted_action"
+)
+
+// aiQuotaLocation mengikuti zona waktu statistik API admin (Asia/Jakarta, tanpa DST).
+var aiQuotaLocation = time.FixedZone("WIB", 7*60*60)
+
+var (
+	ErrAIQuotaNotFound     = errors.New("quota not found")
+	ErrAIQuotaInvalidScope = errors.New("scope_type must be class or teacher")
+	ErrAIQuotaScopeMissing = errors.New("class or teacher not found")
+)
+
+// AIUsageScope adalah atribusi pemakaian token ke kelas dan guru pemilik kelas.
+type AIUsageScope struct {
+	ClassID   string
+	TeacherID string
+}
+
+// AIQuotaExceededError dikembalikan sebelum model dipanggil bila salah satu kuota harian sudah habis.
+type AIQuotaExceededError struct {
+	ScopeType string // global | class | teacher | daily_requests
+	ScopeID   string
+	Feature   string
+	Limit     int64
+	Used      int64
+	ResetAt   time.Time
+}
+
+func (e *AIQuotaExceededError) Error() string {
+	if e.ScopeType == AIQuotaScopeDailyRequests {
+		return fmt.Sprintf("AI daily request limit reached: %d of %d requests, resets at %s",
+			e.Used, e.Limit, e.ResetAt.Format(time.RFC3339))
+	}
+	return fmt.Sprintf("AI token quota exhausted (%s %s): used %d of %d tokens, resets at %s",
+		e.ScopeType, e.Feature, e.Used, e.Limit, e.ResetAt.Format(time.RFC3339))
+}
+
+// NormalizeAIQuotaAction memvalidasi aksi saat kuota habis. String kosong dianggap defer.
+func NormalizeAIQuotaAction(value string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", AIQuotaActionDefer:
+		return AIQuotaActionDefer, true
+	case AIQuotaActionReject:
+		return AIQuotaActionReject, true
+	case AIQuotaActionTeacherOnly:
+		return AIQuotaActionTeacherOnly, true
+	default:
+		return "", false
+	}
+}
+
+// NormalizeAIQuotaFeature memvalidasi feature kuota.
+func NormalizeAIQuotaFeature(value string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", AIQuotaFeatureAll:
+		return AIQuotaFeatureAll, true
+	case AIQuotaFeatureGrading:
+		return AIQuotaFeatureGrading, true
+	case AIQuotaFeatureGeneration:
+		return AIQuotaFeatureGeneration, true
+	default:
+		return "", false
+	}
+}
+
+// DailyTokenLimitFromEnv membaca GEMINI_DAILY_TOKEN_LIMIT (0 = tanpa batas global).
+func DailyTokenLimitFromEnv() int64 {
+	if value := strings.TrimSpace(os.Getenv("GEMINI_DAILY_TOKEN_LIMIT")); value != "" {
+		if parsed, err := strconv.ParseInt(value, 10, 64); err == nil && parsed > 0 {
+			return parsed
+		}
+	}
+	return 0
+}
+
+// aiQuotaWindow mengembalikan awal hari berjalan dan waktu reset kuota berikutnya.
+func aiQuotaWindow(now time.Time) (time.Time, time.Time) {
+	local := now.In(aiQuotaLocation)
+	start := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, aiQuotaLocation)
+	return start, start.AddDate(0, 0, 1)
+}
+
+// aiQuotaFeaturePattern memetakan feature kuota ke pola kolom feature di ai_api_usage_logs.
+func aiQuotaFeaturePattern(feature string) string {
+	switch feature {
+	case AIQuotaFeatureGrading:
+		return "grade_essay%"
+	case AIQuotaFeatureGeneration:
+		return "auto_generate%"
+	default:
+		return "%"
+	}
+}
+
+// AIQuotaService menegakkan batas token harian global (GEMINI_DAILY_TOKEN_LIMIT) serta kuota per kelas/guru.
+type AIQuotaService struct {
+	db               *sql.DB
+	settings         *SystemSettingService
+	globalDailyLimit int64
+}
+
+func NewAIQuotaService(db *sql.DB, settings *SystemSettingService, globalDailyLimit int64) *AIQuotaService {
+	return &AIQuotaService{db: db, settings: settings, globalDailyLimit: globalDailyLimit}
+}
+
+// ExhaustedAction membaca aksi saat kuota habis dari system_settings (default defer).
+func (s *AIQuotaService) ExhaustedAction() string {
+	if s.settings == nil {
+		return AIQuotaActionDefer
+	}
+	value, err := s.settings.GetSetting(aiQuotaActionSettingKey)
+	if err != nil {
+		return AIQuotaActionDefer
+	}
+	action, ok := NormalizeAIQuotaAction(value)
+	if !ok {
+		return AIQuotaActionDefer
+	}
+	return action
+}
+
+// ScopeForClass mengembalikan atribusi pemakaian untuk kelas beserta guru pemiliknya.
+func (s *AIQuotaService) ScopeForClass(classID string) AIUsageScope {
+	scope := AIUsageScope{ClassID: strings.TrimSpace(classID)}
+	if s == nil || s.db == nil || scope.ClassID == "" {
+		return scope
+	}
+	var teacherID sql.NullString
+	if err := s.db.QueryRowContext(
+		context.Background(),
+		`SELECT teacher_id FROM classes WHERE id = $1`,
+		scope.ClassID,
+	).Scan(&teacherID); err == nil && teacherID.Valid {
+		scope.TeacherID = teacherID.String
+	}
+	return scope
+}
+
+// ScopeForQuestion mengembalikan atribusi kelas/guru dari soal (soal -> materi -> kelas).
+func (s *AIQuotaService) ScopeForQuestion(questionID string) AIUsageScope {
+	scope := AIUsageScope{}
+	if s == nil || s.db == nil || strings.TrimSpace(questionID) == "" {
+		return scope
+	}
+	var classID, teacherID sql.NullString
+	if err := s.db.QueryRowContext(
+		context.Background(),
+		`SELECT c.id, c.teacher_id
+		 FROM essay_questions eq
+		 JOIN materials m ON m.id = eq.material_id
+		 JOIN classes c ON c.id = m.class_id
+		 WHERE eq.id = $1`,
+		questionID,
+	).Scan(&classID, &teacherID); err != nil {
+		return scope
+	}
+	scope.ClassID, scope.TeacherID = classID.String, teacherID.String
+	return scope
+}
+
+// Check memastikan kuota global, kelas, dan guru untuk feature masih tersisa.
+// Mengembalikan *AIQuotaExceededError untuk batas pertama yang sudah habis.
+func (s *AIQuotaService) Check(scope AIUsageScope, feature string) error {
+	if s == nil || s.db == nil {
+		return nil
+	}
+	windowStart, resetAt := aiQuotaWindow(time.Now())
+	if s.globalDailyLimit > 0 {
+		used, err := s.usedTokens("", "", AIQuotaFeatureAll, windowStart)
+		if err != nil {
+			return err
+		}
+		if used >= s.globalDailyLimit {
+			return &AIQuotaExceededError{ScopeType: "global", Feature: AIQuotaFeatureAll, Limit: s.globalDailyLimit, Used: used, ResetAt: resetAt}
+		}
+	}
+	if scope.ClassID == "" && scope.TeacherID == "" {
+		return nil
+	}
+
+	rows, err := s.db.QueryContext(
+		context.Background(),
+		`SELECT scope_type, scope_id, feature, daily_token_limit
+		 FROM ai_quotas
+		 WHERE feature IN ($1, 'all')
+		   AND ((scope_type = 'class' AND scope_id::text = $2) OR (scope_type = 'teacher' AND scope_id::text = $3))`,
+		feature, scope.ClassID, scope.TeacherID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to load AI quotas: %w", err)
+	}
+	type quotaRow struct {
+		scopeType, scopeID, feature string
+		limit                       int64
+	}
+	quotas := make([]quotaRow, 0, 4)
+	for rows.Next() {
+		var item quotaRow
+		if err := rows.Scan(&item.scopeType, &item.scopeID, &item.feature, &item.limit); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan AI quota: %w", err)
+		}
+		quotas = append(quotas, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate AI quotas: %w", err)
+	}
+
+	for _, quota := range quotas {
+		used, err := s.usedTokens(quota.scopeType, quota.scopeID, quota.feature, windowStart)
+		if err != nil {
+			return err
+		}
+		if used >= quota.limit {
+			return &AIQuotaExceededError{ScopeType: quota.scopeType, ScopeID: quota.scopeID, Feature: quota.feature, Limit: quota.limit, Used: used, ResetAt: resetAt}
+		}
+	}
+	return nil
+}
+
+// usedTokens menjumlahkan token sejak windowStart. scopeType kosong berarti seluruh pemakaian.
+func (s *AIQuotaService) usedTokens(scopeType, scopeID, feature string, windowStart time.Time) (int64, error) {
+	query := `SELECT COALESCE(SUM(total_tokens), 0) FROM ai_api_usage_logs WHERE created_at >= $1 AND feature LIKE $2`
+	args := []interface{}{windowStart, aiQuotaFeaturePattern(feature)}
+	switch scopeType {
+	case AIQuotaScopeClass:
+		query += ` AND class_id::text = $3`
+		args = append(args, scopeID)
+	case AIQuotaScopeTeacher:
+		query += ` AND teacher_id::text = $3`
+		args = append(args, scopeID)
+	}
+	var used int64
+	if err := s.db.QueryRowContext(context.Background(), query, args...).Scan(&used); err != nil {
+		return 0, fmt.Errorf("failed to sum AI token usage: %w", err)
+	}
+	return used, nil
+}
+
+func (s *AIQuotaService) usage(scopeType, scopeID, label, feature string, limit int64, windowStart time.Time) (models.AIQuotaUsage, error) {
+	used, err := s.usedTokens(scopeType, scopeID, feature, windowStart)
+	if err != nil {
+		return models.AIQuotaUsage{}, err
+	}
+	item := models.AIQuotaUsage{
+		ScopeType: scopeType,
+		ScopeID:   scopeID,
+		Label:     label,
+		Feature:   feature,
+		Limit:     limit,
+		Used:      used,
+		Exhausted: used >= limit,
+	}
+	if remaining := limit - used; remaining > 0 {
+		item.Remaining = remaining
+	}
+	return item, nil
+}
+
+const aiQuotaSelect = `
+	SELECT q.id, q.scope_type, q.scope_id, q.feature, q.daily_token_limit, q.created_by, q.created_at, q.updated_at,
+	       COALESCE(CASE WHEN q.scope_type = 'class' THEN c.class_name ELSE u.nama_lengkap END, '')
+	FROM ai_quotas q
+	LEFT JOIN classes c ON q.scope_type = 'class' AND c.id = q.scope_id
+	LEFT JOIN users u ON q.scope_type = 'teacher' AND u.id = q.scope_id`
+
+func scanAIQuota(scanner interface{ Scan(...interface{}) error }) (*models.AIQuota, error) {
+	var (
+		item      models.AIQuota
+		createdBy sql.NullString
+	)
+	if err := scanner.Scan(
+		&item.ID, &item.ScopeType, &item.ScopeID, &item.Feature, &item.DailyTokenLimit, &createdBy,
+		&item.CreatedAt, &item.UpdatedAt, &item.ScopeLabel,
+	); err != nil {
+		return nil, err
+	}
+	if createdBy.Valid {
+		item.CreatedBy = &createdBy.String
+	}
+	return &item, nil
+}
+
+func (s *AIQuotaService) fillQuotaUsage(item *models.AIQuota, windowStart time.Time) error {
+	used, err := s.usedTokens(item.ScopeType, item.ScopeID, item.Feature, windowStart)
+	if err != nil {
+		return err
+	}
+	item.UsedToday = used
+	if remaining := item.DailyTokenLimit - used; remaining > 0 {
+		item.Remaining = remaining
+	}
+	return nil
+}
+
+// List mengembalikan semua kuota beserta pemakaian hari ini.
+func (s *AIQuotaService) List() ([]models.AIQuota, error) {
+	windowStart, _ := aiQuotaWindow(time.Now())
+	rows, err := s.db.QueryContext(
+		context.Background(),
+		aiQuotaSelect+` ORDER BY q.scope_type ASC, 9 ASC, q.feature ASC`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AI quotas: %w", err)
+	}
+	items := []models.AIQuota{}
+	for rows.Next() {
+		item, err := scanAIQuota(rows)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan AI quota: %w", err)
+		}
+		items = append(items, *item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate AI quotas: %w", err)
+	}
+	for i := range items {
+		if err := s.fillQuotaUsage(&items[i], windowStart); err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+// Upsert membuat atau memperbarui kuota untuk kombinasi scope dan feature.
+func (s *AIQuotaService) Upsert(req models.UpsertAIQuotaRequest, actorID string) (*models.AIQuota, error) {
+	scopeType := strings.ToLower(strings.TrimSpace(req.ScopeType))
+	if scopeType != AIQuotaScopeClass && scopeType != AIQuotaScopeTeacher {
+		return nil, ErrAIQuotaInvalidScope
+	}
+	feature, ok := NormalizeAIQuotaFeature(req.Feature)
+	if !ok {
+		return nil, fmt.Errorf("feature must be grading, generation, or all")
+	}
+	if req.DailyTokenLimit <= 0 {
+		return nil, fmt.Errorf("daily_token_limit must be greater than 0")
+	}
+	scopeID := strings.TrimSpace(req.ScopeID)
+
+	var exists bool
+	existsQuery := `SELECT EXISTS (SELECT 1 FROM classes WHERE id::text = $1)`
+	if scopeType == AIQuotaScopeTeacher {
+		existsQuery = `SELECT EXISTS (SELECT 1 FROM users WHERE id::text = $1 AND peran = 'teacher')`
+	}
+	if err := s.db.QueryRowContext(context.Background(), existsQuery, scopeID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to validate quota scope: %w", err)
+	}
+	if !exists {
+		return nil, ErrAIQuotaScopeMissing
+	}
+
+	var id string
+	if err := s.db.QueryRowContext(
+		context.Background(),
+		`INSERT INTO ai_quotas (scope_type, scope_id, feature, daily_token_limit, created_by)
+		 VALUES ($1, $2, $3, $4, $5)
+		 ON CONFLICT (scope_type, scope_id, feature) DO UPDATE
+		 SET daily_token_limit = EXCLUDED.daily_token_limit,
+		     updated_at = NOW()
+		 RETURNING id`,
+		scopeType, scopeID, feature, req.DailyTokenLimit, nullIfEmpty(actorID),
+	).Scan(&id); err != nil {
+		return nil, fmt.Errorf("failed to save AI quota: %w", err)
+	}
+
+	windowStart, _ := aiQuotaWindow(time.Now())
+	item, err := scanAIQuota(s.db.QueryRowContext(context.Background(), aiQuotaSelect+` WHERE q.id = $1`, id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AI quota: %w", err)
+	}
+	if err := s.fillQuotaUsage(item, windowStart); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// Delete menghapus kuota.
+func (s *AIQuotaService) Delete(id string) error {
+	res, err := s.db.ExecContext(context.Background(), `DELETE FROM ai_quotas WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete AI quota: %w", err)
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return ErrAIQuotaNotFound
+	}
+	return nil
+}
+
+// TeacherStatus merangkum sisa kuota yang berlaku untuk guru: batas global, kuota guru, dan kuota kelas miliknya.
+func (s *AIQuotaService) TeacherStatus(teacherID string) (*models.TeacherAIQuotaStatus, error) {
+	windowStart, resetAt := aiQuotaWindow(time.Now())
+	status := &models.TeacherAIQuotaStatus{
+		Teacher:         []models.AIQuotaUsage{},
+		Classes:         []models.AIQuotaUsage{},
+		ExhaustedAction: s.ExhaustedAction(),
+		ResetAt:         resetAt,
+	}
+	if s.globalDailyLimit > 0 {
+		global, err := s.usage("global", "", "Batas harian sistem", AIQuotaFeatureAll, s.globalDailyLimit, windowStart)
+		if err != nil {
+			return nil, err
+		}
+		status.Global = &global
+	}
+
+	rows, err := s.db.QueryContext(
+		context.Background(),
+		aiQuotaSelect+`
+		 WHERE (q.scope_type = 'teacher' AND q.scope_id::text = $1)
+		    OR (q.scope_type = 'class' AND c.teacher_id::text = $1)
+		 ORDER BY q.scope_type DESC, 9 ASC, q.feature ASC`,
+		teacherID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load teacher AI quotas: %w", err)
+	}
+	quotas := make([]models.AIQuota, 0)
+	for rows.Next() {
+		item, err := scanAIQuota(rows)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan AI quota: %w", err)
+		}
+		quotas = append(quotas, *item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate teacher AI quotas: %w", err)
+	}
+
+	for _, quota := range quotas {
+		item, err := s.usage(quota.ScopeType, quota.ScopeID, quota.ScopeLabel, quota.Feature, quota.DailyTokenLimit, windowStart)
+		if err != nil {
+			return nil, err
+		}
+		if quota.ScopeType == AIQuotaScopeTeacher {
+			status.Teacher = append(status.Teacher, item)
+		} else {
+			status.Classes = append(status.Classes, item)
+		}
+	}
+	return status, nil
+}
+
+// deferredGradingResumeInterval adalah jeda pemeriksaan submission yang ditunda karena kuota habis.
+const deferredGradingResumeInterval = 5 * time.Minute
+
+// gradingDeferredError dikembalikan untuk job antrean yang ditunda karena kuota habis. Worker menjadwalkan ulang
+// baris grading_jobs-nya ke Until sehingga flag job (force_replace, regrade_batch_id) tetap terbawa.
+type gradingDeferredError struct {
+	Until time.Time
+}
+
+func (e *gradingDeferredError) Error() string {
+	return "grading deferred until AI quota resets at " + e.Until.Format(time.RFC3339)
+}
+
+// handleGradingQuotaExceeded menerapkan setting ai_quota_exhausted_action pada job grading
+// yang tertahan kuota: defer (dijadwalkan ulang saat kuota reset), teacher_only (ditahan untuk guru),
+// atau reject (gagal; bisa di-retry manual).
+func (s *EssaySubmissionService) handleGradingQuotaExceeded(job essayGradingJob, quotaErr *AIQuotaExceededError) (*models.GradeEssayResponse, error) {
+	action := s.aiService.QuotaService().ExhaustedAction()
+	log.Printf("WARNING: AI quota exhausted for submission %s (%s %s, used %d/%d): action=%s",
+		job.SubmissionID, quotaErr.ScopeType, quotaErr.Feature, quotaErr.Used, quotaErr.Limit, action)
+	resetLabel := quotaErr.ResetAt.Format("02-01-2006 15:04")
+
+	switch action {
+	case AIQuotaActionTeacherOnly:
+		if applied, _ := s.updateJobGradingStatus(job, "held", "Kuota token AI habis. Jawaban menunggu penilaian guru.", nil); !applied {
+			return nil, errGradingSuperseded
+		}
+		if err := s.setSubmissionReviewFlag(job.SubmissionID, true, "Kuota token AI habis; jawaban dinilai guru tanpa AI"); err != nil {
+			log.Printf("WARNING: failed to flag submission %s for teacher grading: %v", job.SubmissionID, err)
+		}
+		return nil, nil
+	case AIQuotaActionReject:
+		_, _ = s.updateJobGradingStatus(job, "failed", fmt.Sprintf("Kuota token AI harian habis. Coba lagi setelah %s.", resetLabel), nil)
+		return nil, quotaErr
+	default:
+		if _, err := s.db.ExecContext(
+			context.Background(),
+			`UPDATE essay_submissions es
+			 SET ai_grading_status = 'deferred',
+			     ai_grading_error = $1,
+			     ai_graded_at = NULL,
+			     ai_deferred_until = $2
+			 WHERE es.id = $3
+			   AND `+gradingAttemptGuard(4),
+			fmt.Sprintf("Kuota token AI habis. Penilaian dijadwalkan ulang setelah %s.", resetLabel),
+			quotaErr.ResetAt,
+			job.SubmissionID,
+			job.TeksJawaban,
+			job.JobID,
+			job.LeaseID,
+		); err != nil {
+			log.Printf("WARNING: failed to defer submission %s: %v", job.SubmissionID, err)
+		}
+		if job.JobID != "" {
+			return nil, &gradingDeferredError{Until: quotaErr.ResetAt}
+		}
+		return nil, nil
+	}
+}
+
+func (s *EssaySubmissionService) runDeferredGradingResumer() {
+	ticker := time.NewTicker(deferredGradingResumeInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.resumeDeferredGrading()
+	}
+}
+
+// resumeDeferredGrading memasukkan kembali submission yang ditunda ke antrean setelah jendela kuota berganti.
+// Bila kuota masih habis, job akan ditunda lagi oleh gradeJob. Submission yang job antreannya masih aktif
+// dilewati karena job tersebut sudah dijadwalkan ulang ke waktu reset kuota.
+func (s *EssaySubmissionService) resumeDeferredGrading() {
+	if s.aiService == nil || s.db == nil {
+		return
+	}
+	rows, err := s.db.QueryContext(
+		context.Background(),
+		`SELECT es.id, es.soal_id, es.siswa_id, es.teks_jawaban
+		 FROM essay_submissions es
+		 WHERE es.submission_type = 'essay'
+		   AND es.ai_grading_status = 'deferred'
+		   AND (es.ai_deferred_until IS NULL OR es.ai_deferred_until <= NOW())
+		   AND NOT EXISTS (
+			SELECT 1 FROM grading_jobs gj
+			WHERE gj.submission_id = es.id AND gj.status IN ('queued', 'processing')
+		   )
+		 ORDER BY es.submitted_at ASC
+		 LIMIT 200`,
+	)
+	if err != nil {
+		log.Printf("WARNING: failed to load deferred submissions: %v", err)
+		return
+	}
+	jobs := make([]essayGradingJob, 0)
+	for rows.Next() {
+		var job essayGradingJob
+		if err := rows.Scan(&job.SubmissionID, &job.QuestionID, &job.StudentID, &job.TeksJawaban); err != nil {
+			log.Printf("WARNING: failed to scan deferred submission: %v", err)
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	rows.Close()
+
+	resumed := 0
+	for _, job := range jobs {
+		config, cfgErr := s.loadQuizAttemptConfig(job.QuestionID)
+		if cfgErr != nil {
+			log.Printf("WARNING: failed to load attempt config for deferred submission %s: %v", job.SubmissionID, cfgErr)
+			continue
+		}
+		job.ScoringMethod = config.AttemptScoring
+		res, err := s.db.ExecContext(
+			context.Background(),
+			`UPDATE essay_submissions
+			 SET ai_grading_status = 'queued',
+			     ai_grading_error = NULL,
+			     ai_deferred_until = NULL
+			 WHERE id = $1 AND ai_grading_status = 'deferred'`,
+			job.SubmissionID,
+		)
+		if err != nil {
+			log.Printf("WARNING: failed to requeue deferred submission %s: %v", job.SubmissionID, err)
+			continue
+		}
+		if affected, _ := res.RowsAffected(); affected == 0 {
+			continue
+		}
+		if err := s.enqueueGradingJob(job); err != nil {
+			// Antrean penuh: kembalikan ke deferred dan coba lagi pada putaran berikutnya.
+			_, _ = s.db.ExecContext(
+				context.Background(),
+				`UPDATE essay_submissions SET ai_grading_status = 'deferred', ai_deferred_until = NOW() WHERE id = $1`,
+				job.SubmissionID,
+			)
+			break
+		}
+		resumed++
+	}
+	if resumed > 0 {
+		log.Printf("Deferred grading resumed: %d submission(s)", resumed)
+	}
+}
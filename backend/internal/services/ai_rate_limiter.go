@@ -0,0 +1,303 @@
+package services
+
+import (
+	"api-backend/internal/models"
+	"context"
+	"database/sql"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AIRequestPriority menentukan urutan antrean limiter. Panggilan interaktif (guru/siswa menunggu
+// respons) selalu didahulukan; job antrean grading hanya jalan saat tidak ada panggilan interaktif menunggu.
+type AIRequestPriority int
+
+const (
+	AIPriorityInteractive AIRequestPriority = iota
+	AIPriorityBulk
+)
+
+const (
+	// AIQuotaScopeDailyRequests dipakai AIQuotaExceededError saat batas request harian (RPD) tercapai.
+	AIQuotaScopeDailyRequests = "daily_requests"
+
+	// aiRateOutputTokenEstimate adalah perkiraan token output per panggilan untuk reservasi TPM.
+	aiRateOutputTokenEstimate = 800
+	// aiRateMinWait mencegah busy-loop saat job bulk mengalah ke panggilan interaktif.
+	aiRateMinWait = 20 * time.Millisecond
+)
+
+// AIRateLimiter adalah token bucket tunggal untuk RPM dan TPM serta penghitung RPD harian (WIB).
+// Semua panggilan provider AI melewati limiter ini sehingga batas yang ditampilkan di statistik API
+// admin sama dengan batas yang ditegakkan.
+type AIRateLimiter struct {
+	mu         sync.Mutex
+	rpmLimit   int64
+	tpmLimit   int64
+	rpdLimit   int64
+	requests   float64 // Sisa token bucket request (maks rpmLimit).
+	tokens     float64 // Sisa token bucket TPM; boleh negatif bila pemakaian aktual melebihi estimasi.
+	lastRefill time.Time
+	dayStart   time.Time
+	dayCount   int64
+	daySeeded  bool
+	waiting    [2]int
+	throttled  [2]uint64
+}
+
+// AIRateReservation adalah slot yang sudah diambil; Settle menyesuaikan TPM dengan token aktual.
+type AIRateReservation struct {
+	limiter         *AIRateLimiter
+	estimatedTokens int64
+}
+
+var (
+	sharedAIRateLimiter     *AIRateLimiter
+	sharedAIRateLimiterOnce sync.Once
+)
+
+// SharedAIRateLimiter mengembalikan limiter proses yang dipakai AIService, antrean grading, dan statistik admin.
+// Batas dibaca dari GEMINI_LIMIT_RPM/TPM/RPD saat pertama dipanggil (setelah .env dimuat).
+func SharedAIRateLimiter() *AIRateLimiter {
+	sharedAIRateLimiterOnce.Do(func() {
+		sharedAIRateLimiter = NewAIRateLimiter(
+			aiRateLimitFromEnv("GEMINI_LIMIT_RPM", 5),
+			aiRateLimitFromEnv("GEMINI_LIMIT_TPM", 250000),
+			aiRateLimitFromEnv("GEMINI_LIMIT_RPD", 20),
+		)
+	})
+	return sharedAIRateLimiter
+}
+
+func aiRateLimitFromEnv(key string, fallback int64) int64 {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || parsed <= 0 {
+		return fallback
+	}
+	return parsed
+}
+
+// NewAIRateLimiter membuat limiter dengan bucket penuh. Batas <= 0 berarti tidak dibatasi.
+func NewAIRateLimiter(rpm, tpm, rpd int64) *AIRateLimiter {
+	now := time.Now()
+	dayStart, _ := aiQuotaWindow(now)
+	return &AIRateLimiter{
+		rpmLimit:   rpm,
+		tpmLimit:   tpm,
+		rpdLimit:   rpd,
+		requests:   float64(rpm),
+		tokens:     float64(tpm),
+		lastRefill: now,
+		dayStart:   dayStart,
+	}
+}
+
+// SeedDailyCount mengisi penghitung RPD dari ai_api_usage_logs hari ini agar restart tidak mereset batas harian.
+func (l *AIRateLimiter) SeedDailyCount(db *sql.DB) {
+	if l == nil || db == nil {
+		return
+	}
+	l.mu.Lock()
+	seeded := l.daySeeded
+	l.mu.Unlock()
+	if seeded {
+		return
+	}
+	var count int64
+	if err := db.QueryRowContext(
+		context.Background(),
+		`SELECT COUNT(*)
+		 FROM ai_api_usage_logs
+		 WHERE DATE(created_at AT TIME ZONE 'Asia/Jakarta') = DATE(NOW() AT TIME ZONE 'Asia/Jakarta')`,
+	).Scan(&count); err != nil {
+		log.Printf("WARNING: failed to seed AI daily request counter: %v", err)
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.daySeeded && count > l.dayCount {
+		l.dayCount = count
+	}
+	l.daySeeded = true
+}
+
+// Acquire menunggu slot RPM dan TPM untuk prompt yang diberikan. Mengembalikan *AIQuotaExceededError
+// tanpa menunggu bila batas RPD hari ini sudah tercapai.
+func (l *AIRateLimiter) Acquire(priority AIRequestPriority, prompt string) (*AIRateReservation, error) {
+	if l == nil {
+		return nil, nil
+	}
+	if priority != AIPriorityInteractive {
+		priority = AIPriorityBulk
+	}
+	estimate := estimatePromptTokens(prompt) + aiRateOutputTokenEstimate
+
+	l.mu.Lock()
+	l.waiting[priority]++
+	defer func() {
+		l.waiting[priority]--
+		l.mu.Unlock()
+	}()
+
+	waited := false
+	for {
+		now := time.Now()
+		l.refillLocked(now)
+		if l.rpdLimit > 0 && l.dayCount >= l.rpdLimit {
+			_, resetAt := aiQuotaWindow(now)
+			return nil, &AIQuotaExceededError{
+				ScopeType: AIQuotaScopeDailyRequests,
+				Feature:   AIQuotaFeatureAll,
+				Limit:     l.rpdLimit,
+				Used:      l.dayCount,
+				ResetAt:   resetAt,
+			}
+		}
+
+		wait := l.waitLocked(estimate)
+		if priority == AIPriorityBulk && l.waiting[AIPriorityInteractive] > 0 && wait < aiRateMinWait {
+			wait = aiRateMinWait
+		}
+		if wait <= 0 {
+			if l.rpmLimit > 0 {
+				l.requests--
+			}
+			if l.tpmLimit > 0 {
+				l.tokens -= float64(estimate)
+			}
+			l.dayCount++
+			if waited {
+				l.throttled[priority]++
+			}
+			return &AIRateReservation{limiter: l, estimatedTokens: estimate}, nil
+		}
+
+		waited = true
+		l.mu.Unlock()
+		time.Sleep(wait)
+		l.mu.Lock()
+	}
+}
+
+// Settle mengganti estimasi token dengan pemakaian aktual. totalTokens 0 (tidak dilaporkan
+// provider) mempertahankan estimasi; failed true mengembalikan estimasi karena tidak ada token terpakai.
+func (r *AIRateReservation) Settle(totalTokens int64, failed bool) {
+	if r == nil || r.limiter == nil || r.limiter.tpmLimit <= 0 {
+		return
+	}
+	actual := totalTokens
+	if failed {
+		actual = 0
+	} else if actual <= 0 {
+		return
+	}
+	l := r.limiter
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refillLocked(time.Now())
+	l.tokens += float64(r.estimatedTokens - actual)
+	if l.tokens > float64(l.tpmLimit) {
+		l.tokens = float64(l.tpmLimit)
+	}
+}
+
+// Status mengembalikan utilisasi limiter saat ini untuk statistik API admin.
+func (l *AIRateLimiter) Status() models.AIRateLimiterStatus {
+	if l == nil {
+		return models.AIRateLimiterStatus{}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.refillLocked(now)
+	_, resetAt := aiQuotaWindow(now)
+	status := models.AIRateLimiterStatus{
+		RPMLimit:              l.rpmLimit,
+		TPMLimit:              l.tpmLimit,
+		RPDLimit:              l.rpdLimit,
+		RPDUsed:               l.dayCount,
+		InteractiveWaiting:    l.waiting[AIPriorityInteractive],
+		BulkWaiting:           l.waiting[AIPriorityBulk],
+		InteractiveThrottled:  l.throttled[AIPriorityInteractive],
+		BulkThrottled:         l.throttled[AIPriorityBulk],
+		DailyResetAt:          resetAt,
+		NextRequestAvailableS: math.Round(l.waitLocked(0).Seconds()*100) / 100,
+	}
+	if l.rpmLimit > 0 {
+		status.RPMAvailable = math.Floor(math.Max(l.requests, 0))
+		status.RPMUtilization = utilisationPercent(float64(l.rpmLimit)-l.requests, float64(l.rpmLimit))
+	}
+	if l.tpmLimit > 0 {
+		status.TPMAvailable = int64(math.Max(l.tokens, 0))
+		status.TPMUtilization = utilisationPercent(float64(l.tpmLimit)-l.tokens, float64(l.tpmLimit))
+	}
+	if l.rpdLimit > 0 {
+		status.RPDUtilization = utilisationPercent(float64(l.dayCount), float64(l.rpdLimit))
+	}
+	return status
+}
+
+// refillLocked mengisi bucket sesuai waktu berlalu dan mereset penghitung RPD saat hari (WIB) berganti.
+func (l *AIRateLimiter) refillLocked(now time.Time) {
+	elapsed := now.Sub(l.lastRefill)
+	if elapsed > 0 {
+		minutes := elapsed.Minutes()
+		if l.rpmLimit > 0 {
+			l.requests = math.Min(float64(l.rpmLimit), l.requests+minutes*float64(l.rpmLimit))
+		}
+		if l.tpmLimit > 0 {
+			l.tokens = math.Min(float64(l.tpmLimit), l.tokens+minutes*float64(l.tpmLimit))
+		}
+		l.lastRefill = now
+	}
+	dayStart, _ := aiQuotaWindow(now)
+	if dayStart.After(l.dayStart) {
+		l.dayStart = dayStart
+		l.dayCount = 0
+		l.daySeeded = true
+	}
+}
+
+// waitLocked menghitung jeda sampai bucket cukup untuk satu request dengan estimasi token tersebut.
+// Estimasi di atas kapasitas TPM cukup menunggu bucket penuh.
+func (l *AIRateLimiter) waitLocked(estimate int64) time.Duration {
+	var wait time.Duration
+	if l.rpmLimit > 0 && l.requests < 1 {
+		wait = time.Duration((1 - l.requests) / float64(l.rpmLimit) * float64(time.Minute))
+	}
+	if l.tpmLimit > 0 && estimate > 0 {
+		need := math.Min(float64(estimate), float64(l.tpmLimit))
+		if l.tokens < need {
+			tokenWait := time.Duration((need - l.tokens) / float64(l.tpmLimit) * float64(time.Minute))
+			if tokenWait > wait {
+				wait = tokenWait
+			}
+		}
+	}
+	return wait
+}
+
+// estimatePromptTokens memperkirakan token prompt (~4 karakter per token) untuk reservasi TPM.
+func estimatePromptTokens(prompt string) int64 {
+	return int64(len(prompt)/4) + 1
+}
+
+func utilisationPercent(used, limit float64) float64 {
+	if limit <= 0 {
+		return 0
+	}
+	pct := used / limit * 100
+	if pct < 0 {
+		pct = 0
+	}
+	return math.Round(pct*10) / 10
+}
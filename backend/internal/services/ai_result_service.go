@@ -58,7 +58,8 @@ func (s *AIResultService) CreateAIResult(submissionID string, skorAI float64, um
 // Mengembalikan objek AIResult atau error jika tidak ditemukan.
 func (s *AIResultService) GetAIResultByID(resultID string) (*models.AIResult, error) {
 	query := `
-		SELECT id, submission_id, skor_ai, umpan_balik_ai, logs_rag, generated_at
+		SELECT id, submission_id, skor_ai, umpan_balik_ai, logs_rag, rubric_scores::text, aspect_evidence::text, holistic_band::text, scoring_formula, scoring_details::text, score_spread, ensemble_details::text, prompt_template_version,
+		       ai_provider, model_name, prompt_hash, generation_params::text, COALESCE(prompt_tokens, 0), COALESCE(candidates_tokens, 0), COALESCE(total_tokens, 0), raw_response, COALESCE(from_cache, FALSE), generated_at
 		FROM ai_results
 		WHERE id = $1
 	`
@@ -66,7 +67,8 @@ func (s *AIResultService) GetAIResultByID(resultID string) (*models.AIResult, er
 	var ar models.AIResult // Objek untuk menampung hasil query.
 	// Menjalankan query dan memindai hasilnya.
 	err := s.db.QueryRow(query, resultID).Scan(
-		&ar.ID, &ar.SubmissionID, &ar.SkorAI, &ar.UmpanBalikAI, &ar.LogsRAG, &ar.GeneratedAt,
+		&ar.ID, &ar.SubmissionID, &ar.SkorAI, &ar.UmpanBalikAI, &ar.LogsRAG, &ar.RubricScores, &ar.AspectEvidence, &ar.HolisticBand, &ar.ScoringFormula, &ar.ScoringDetails, &ar.ScoreSpread, &ar.EnsembleDetails, &ar.PromptTemplateVersion,
+		&ar.AIProvider, &ar.ModelName, &ar.PromptHash, &ar.GenerationParams, &ar.PromptTokens, &ar.CandidatesTokens, &ar.TotalTokens, &ar.RawResponse, &ar.FromCache, &ar.GeneratedAt,
 	)
 
 	if err != nil {
@@ -83,7 +85,8 @@ func (s *AIResultService) GetAIResultByID(resultID string) (*models.AIResult, er
 // Mengembalikan objek AIResult atau error jika tidak ditemukan.
 func (s *AIResultService) GetAIResultBySubmissionID(submissionID string) (*models.AIResult, error) {
 	query := `
-		SELECT id, submission_id, skor_ai, umpan_balik_ai, logs_rag, generated_at
+		SELECT id, submission_id, skor_ai, umpan_balik_ai, logs_rag, rubric_scores::text, aspect_evidence::text, holistic_band::text, scoring_formula, scoring_details::text, score_spread, ensemble_details::text, prompt_template_version,
+		       ai_provider, model_name, prompt_hash, generation_params::text, COALESCE(prompt_tokens, 0), COALESCE(candidates_tokens, 0), COALESCE(total_tokens, 0), raw_response, COALESCE(from_cache, FALSE), generated_at
 		FROM ai_results
 		WHERE submission_id = $1
 	`
@@ -91,7 +94,8 @@ func (s *AIResultService) GetAIResultBySubmissionID(submissionID string) (*model
 	var ar models.AIResult
 	// Menjalankan query dan memindai hasilnya.
 	err := s.db.QueryRow(query, submissionID).Scan(
-		&ar.ID, &ar.SubmissionID, &ar.SkorAI, &ar.UmpanBalikAI, &ar.LogsRAG, &ar.GeneratedAt,
+		&ar.ID, &ar.SubmissionID, &ar.SkorAI, &ar.UmpanBalikAI, &ar.LogsRAG, &ar.RubricScores, &ar.AspectEvidence, &ar.HolisticBand, &ar.ScoringFormula, &ar.ScoringDetails, &ar.ScoreSpread, &ar.EnsembleDetails, &ar.PromptTemplateVersion,
+		&ar.AIProvider, &ar.ModelName, &ar.PromptHash, &ar.GenerationParams, &ar.PromptTokens, &ar.CandidatesTokens, &ar.TotalTokens, &ar.RawResponse, &ar.FromCache, &ar.GeneratedAt,
 	)
 
 	if err != nil {
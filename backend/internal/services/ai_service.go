@@ -19,7 +19,6 @@ import (
 	"time"
 
 	"github.com/google/generative-ai-go/genai" // Mengimpor klien Google Generative AI.
-	"google.golang.org/api/option"             // Mengimpor package option untuk konfigurasi klien Google API.
 )
 
 type aiProvider string
@@ -27,6 +26,7 @@ type aiProvider string
 const (
 	aiProviderGemini  aiProvider = "gemini"
 	aiProviderLiteLLM aiProvider = "litellm"
+	aiProviderFake    aiProvider = "fake"
 )
 
 type aiUsage struct {
@@ -38,6 +38,7 @@ type aiUsage struct {
 type aiRawResponse struct {
 	Text  string
 	Usage aiUsage
+	Model string // Model yang benar-benar dipanggil.
 }
 
 type liteLLMClient struct {
@@ -82,15 +83,21 @@ type openAIChatResponse struct {
 // AIService menangani logika untuk berinteraksi dengan model AI Gemini.
 type AIService struct {
 	provider        aiProvider
-	geminiClient    *genai.GenerativeModel // Klien model Gemini yang akan digunakan untuk generate konten.
-	liteLLMClient   *liteLLMClient
+	client          aiProviderClient // Client provider aktif (gemini, litellm, atau fake).
 	db              *sql.DB
 	modelName       string
 	dailyTokenLimit int64
-	aiLimiterMu     sync.Mutex
 	modelMu         sync.RWMutex
-	lastRequestAt   time.Time
-	aiMinInterval   time.Duration
+	limiter         *AIRateLimiter // Token bucket RPM/TPM/RPD bersama dengan antrean grading.
+	repairMaxAttempts int // Batas prompt perbaikan bila output AI melanggar rubrik.
+	promptTemplates   *PromptTemplateService // Sumber template prompt berversi (fallback ke bawaan).
+	settings          *SystemSettingService
+	cacheCfgMu        sync.Mutex
+	cacheCfg          GradingCacheConfig // Konfigurasi cache grading (TTL, batas entri), dibaca ulang tiap menit.
+	cacheCfgLoadedAt  time.Time
+	cacheWrites       uint64 // Counter atomik penulisan cache untuk memicu eviksi berkala.
+	quota             *AIQuotaService // Batas token harian global serta kuota per kelas/guru.
+	geminiAPIKey      string // Key Gemini terakhir dari UpdateAPIKey, dipakai saat berpindah kembali ke Gemini.
 }
 
 // NewAIService membuat instance baru dari AIService.
@@ -100,36 +107,30 @@ func NewAIService(db *sql.DB) (*AIService, error) {
 	if modelName == "" {
 		modelName = "gemini-2.5-flash"
 	}
-	dailyLimit := int64(0)
-	if value := strings.TrimSpace(os.Getenv("GEMINI_DAILY_TOKEN_LIMIT")); value != "" {
-		if parsed, parseErr := strconv.ParseInt(value, 10, 64); parseErr == nil && parsed > 0 {
-			dailyLimit = parsed
+	dailyLimit := DailyTokenLimitFromEnv()
+	repairAttempts := defaultAIRepairMaxAttempts
+	if value := strings.TrimSpace(os.Getenv("AI_REPAIR_MAX_ATTEMPTS")); value != "" {
+		if parsed, parseErr := strconv.Atoi(value); parseErr == nil && parsed >= 0 && parsed <= 5 {
+			repairAttempts = parsed
 		}
 	}
-	rpmLimit := int64(5)
-	if value := strings.TrimSpace(os.Getenv("GEMINI_LIMIT_RPM")); value != "" {
-		if parsed, parseErr := strconv.ParseInt(value, 10, 64); parseErr == nil && parsed > 0 {
-			rpmLimit = parsed
-		}
-	}
-	minInterval := time.Minute / time.Duration(rpmLimit)
 
-	service := &AIService{db: db, modelName: modelName, dailyTokenLimit: dailyLimit, aiMinInterval: minInterval}
+	limiter := SharedAIRateLimiter()
+	limiter.SeedDailyCount(db)
+	settings := NewSystemSettingService(db)
+	service := &AIService{db: db, modelName: modelName, dailyTokenLimit: dailyLimit, limiter: limiter, repairMaxAttempts: repairAttempts, promptTemplates: NewPromptTemplateService(db), settings: settings, quota: NewAIQuotaService(db, settings, dailyLimit)}
 	if err := service.RefreshFromEnv(); err != nil {
 		return nil, err
 	}
 	return service, nil
 }
 
-func buildGeminiModel(apiKey, modelName string) (*genai.GenerativeModel, error) {
-	ctx := context.Background()
-	client, err := genai.NewClient(ctx, option.WithAPIKey(apiKey))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create new genai client: %w", err)
+// SetPromptTemplateService memakai service template yang sama dengan handler admin
+// agar aktivasi versi baru langsung membersihkan cache template.
+func (s *AIService) SetPromptTemplateService(service *PromptTemplateService) {
+	if service != nil {
+		s.promptTemplates = service
 	}
-	model := client.GenerativeModel(modelName)
-	model.GenerationConfig.ResponseMIMEType = "application/json"
-	return model, nil
 }
 
 func (s *AIService) UpdateAPIKey(apiKey string) error {
@@ -137,13 +138,29 @@ func (s *AIService) UpdateAPIKey(apiKey string) error {
 	if apiKey == "" {
 		return fmt.Errorf("api key cannot be empty")
 	}
-	model, err := buildGeminiModel(apiKey, s.modelName)
+	s.modelMu.Lock()
+	s.geminiAPIKey = apiKey
+	modelName := s.modelName
+	provider := s.provider
+	s.modelMu.Unlock()
+
+	// Key Gemini hanya dipasang langsung bila provider aktif memang Gemini; untuk provider lain key
+	// disimpan dan baru dipakai saat RefreshFromEnv berpindah ke Gemini.
+	if provider != aiProviderGemini {
+		return nil
+	}
+	geminiClient, err := newGeminiProviderClient(apiKey, modelName)
 	if err != nil {
 		return err
 	}
-
 	s.modelMu.Lock()
-	s.geminiClient = model
+	if s.provider != aiProviderGemini {
+		// Provider berganti selagi client dibuat; client baru tidak dipakai.
+		s.modelMu.Unlock()
+		geminiClient.close()
+		return nil
+	}
+	s.client = geminiClient
 	s.modelMu.Unlock()
 	return nil
 }
@@ -153,13 +170,9 @@ func (s *AIService) RefreshFromEnv() error {
 	if providerRaw == "" {
 		providerRaw = string(aiProviderGemini)
 	}
-	var provider aiProvider
-	switch providerRaw {
-	case string(aiProviderGemini):
-		provider = aiProviderGemini
-	case string(aiProviderLiteLLM):
-		provider = aiProviderLiteLLM
-	default:
+	provider := aiProvider(providerRaw)
+	factory, ok := aiProviderFactories[provider]
+	if !ok {
 		return fmt.Errorf("unsupported AI_PROVIDER: %s", providerRaw)
 	}
 
@@ -168,44 +181,63 @@ func (s *AIService) RefreshFromEnv() error {
 		modelName = "gemini-2.5-flash"
 	}
 
-	var geminiModel *genai.GenerativeModel
-	var liteClient *liteLLMClient
-	switch provider {
-	case aiProviderGemini:
-		apiKey := strings.TrimSpace(os.Getenv("GEMINI_API_KEY"))
-		if apiKey == "" {
-			return fmt.Errorf("GEMINI_API_KEY environment variable not set")
-		}
-		model, err := buildGeminiModel(apiKey, modelName)
-		if err != nil {
-			return err
-		}
-		geminiModel = model
-	case aiProviderLiteLLM:
-		baseURL := strings.TrimSpace(os.Getenv("LITELLM_BASE_URL"))
-		apiKey := strings.TrimSpace(os.Getenv("LITELLM_API_KEY"))
-		if baseURL == "" {
-			return fmt.Errorf("LITELLM_BASE_URL environment variable not set")
-		}
-		if apiKey == "" {
-			return fmt.Errorf("LITELLM_API_KEY environment variable not set")
-		}
-		liteClient = &liteLLMClient{
-			baseURL:    baseURL,
-			apiKey:     apiKey,
-			httpClient: &http.Client{Timeout: 60 * time.Second},
-		}
+	s.modelMu.RLock()
+	geminiAPIKey := s.geminiAPIKey
+	s.modelMu.RUnlock()
+	var client aiProviderClient
+	var err error
+	if provider == aiProviderGemini && geminiAPIKey != "" {
+		client, err = newGeminiProviderClient(geminiAPIKey, modelName)
+	} else {
+		client, err = factory(modelName)
+	}
+	if err != nil {
+		return err
 	}
 
 	s.modelMu.Lock()
 	s.provider = provider
 	s.modelName = modelName
-	s.geminiClient = geminiModel
-	s.liteLLMClient = liteClient
+	s.client = client
 	s.modelMu.Unlock()
 	return nil
 }
 
+// QuotaService mengembalikan service kuota token yang dipakai AIService.
+func (s *AIService) QuotaService() *AIQuotaService {
+	return s.quota
+}
+
+// UsageScopeForClass mengembalikan atribusi kelas dan guru pemilik kelas untuk pencatatan dan kuota token.
+func (s *AIService) UsageScopeForClass(classID string) AIUsageScope {
+	return s.quota.ScopeForClass(classID)
+}
+
+// ProviderName mengembalikan nama provider AI yang sedang aktif.
+func (s *AIService) ProviderName() string {
+	s.modelMu.RLock()
+	defer s.modelMu.RUnlock()
+	return string(s.provider)
+}
+
+// generationParams mengembalikan parameter generasi provider aktif (kosong bila tidak dilaporkan).
+func (s *AIService) generationParams() map[string]interface{} {
+	s.modelMu.RLock()
+	client := s.client
+	s.modelMu.RUnlock()
+	if reporter, ok := client.(aiGenerationParamsReporter); ok {
+		return reporter.generationParams()
+	}
+	return nil
+}
+
+// hashPrompt menghasilkan SHA-256 prompt untuk mendeteksi perubahan prompt antarhasil. Nonce batas jawaban
+// dibuang dulu karena berubah di setiap request.
+func hashPrompt(prompt string) string {
+	sum := sha256.Sum256([]byte(stableEssayBoundaries(prompt)))
+	return hex.EncodeToString(sum[:])
+}
+
 func detectAIErrorType(err error) string {
 	if err == nil {
 		return ""
@@ -226,16 +258,22 @@ func detectAIErrorType(err error) string {
 }
 
 func (s *AIService) logAPIUsage(feature, status, errorType, errorMessage string, promptTokens, candidateTokens, totalTokens, responseTimeMs int64) {
+	s.logAPIUsageForModel(AIUsageScope{}, s.modelName, "", feature, status, errorType, errorMessage, promptTokens, candidateTokens, totalTokens, responseTimeMs)
+}
+
+// logAPIUsageForModel sama dengan logAPIUsage, tetapi mencatat model yang benar-benar dipanggil
+// (mis. saat ensemble menggilir beberapa model), versi template prompt, serta kelas/guru untuk kuota.
+func (s *AIService) logAPIUsageForModel(scope AIUsageScope, modelName, promptVersion, feature, status, errorType, errorMessage string, promptTokens, candidateTokens, totalTokens, responseTimeMs int64) {
 	if s.db == nil {
 		return
 	}
 	_, err := s.db.ExecContext(
 		context.Background(),
 		`INSERT INTO ai_api_usage_logs
-		 (feature, model_name, status, error_type, error_message, prompt_tokens, candidates_tokens, total_tokens, response_time_ms, created_at)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		 (feature, model_name, status, error_type, error_message, prompt_tokens, candidates_tokens, total_tokens, response_time_ms, created_at, prompt_template_version, class_id, teacher_id)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
 		feature,
-		s.modelName,
+		modelName,
 		status,
 		nullIfEmpty(errorType),
 		nullIfEmpty(errorMessage),
@@ -244,6 +282,9 @@ func (s *AIService) logAPIUsage(feature, status, errorType, errorMessage string,
 		totalTokens,
 		responseTimeMs,
 		time.Now(),
+		nullIfEmpty(promptVersion),
+		nullIfEmpty(scope.ClassID),
+		nullIfEmpty(scope.TeacherID),
 	)
 	if err != nil {
 		log.Printf("WARNING: Failed to log AI API usage: %v", err)
@@ -257,21 +298,22 @@ func nullIfEmpty(value string) interface{} {
 	return value
 }
 
-func (s *AIService) waitForAIRateSlot() {
-	s.aiLimiterMu.Lock()
-	defer s.aiLimiterMu.Unlock()
-	if s.aiMinInterval <= 0 {
-		return
-	}
-	now := time.Now()
-	nextAllowed := s.lastRequestAt.Add(s.aiMinInterval)
-	if now.Before(nextAllowed) {
-		time.Sleep(nextAllowed.Sub(now))
+// RateLimiter mengembalikan limiter bersama untuk semua panggilan provider AI.
+func (s *AIService) RateLimiter() *AIRateLimiter {
+	if s == nil {
+		return nil
 	}
-	s.lastRequestAt = time.Now()
+	return s.limiter
 }
 
+// generateContentWithRetry dipakai panggilan interaktif (generate soal/metadata, tes koneksi).
 func (s *AIService) generateContentWithRetry(prompt string) (*aiRawResponse, error) {
+	return s.generateContentWithModel(AIPriorityInteractive, prompt, "")
+}
+
+// generateContentWithModel memanggil provider aktif dengan model tertentu; string kosong berarti model aktif.
+// Setiap percobaan mengambil slot dari limiter sesuai prioritas, kecuali provider fake yang berjalan offline.
+func (s *AIService) generateContentWithModel(priority AIRequestPriority, prompt, modelOverride string) (*aiRawResponse, error) {
 	ctx := context.Background()
 	backoffs := []time.Duration{0, 2 * time.Second, 5 * time.Second}
 	var lastErr error
@@ -279,47 +321,33 @@ func (s *AIService) generateContentWithRetry(prompt string) (*aiRawResponse, err
 		if delay > 0 {
 			time.Sleep(delay)
 		}
-		s.waitForAIRateSlot()
 		s.modelMu.RLock()
 		provider := s.provider
 		modelName := s.modelName
-		gemini := s.geminiClient
-		lite := s.liteLLMClient
+		client := s.client
 		s.modelMu.RUnlock()
-
-		switch provider {
-		case aiProviderGemini:
-			if gemini == nil {
-				return nil, fmt.Errorf("AI model is unavailable")
-			}
-			resp, err := gemini.GenerateContent(ctx, genai.Text(prompt))
-			if err == nil {
-				text, parseErr := extractGeminiText(resp)
-				if parseErr != nil {
-					return nil, parseErr
-				}
-				return &aiRawResponse{
-					Text: text,
-					Usage: aiUsage{
-						PromptTokens:    int64(resp.UsageMetadata.PromptTokenCount),
-						CandidateTokens: int64(resp.UsageMetadata.CandidatesTokenCount),
-						TotalTokens:     int64(resp.UsageMetadata.TotalTokenCount),
-					},
-				}, nil
-			}
-			lastErr = err
-		case aiProviderLiteLLM:
-			if lite == nil {
-				return nil, fmt.Errorf("AI model is unavailable")
-			}
-			text, usage, err := lite.generate(ctx, modelName, prompt)
-			if err == nil {
-				return &aiRawResponse{Text: text, Usage: usage}, nil
+		if strings.TrimSpace(modelOverride) != "" {
+			modelName = strings.TrimSpace(modelOverride)
+		}
+		var reservation *AIRateReservation
+		if provider != aiProviderFake {
+			var limitErr error
+			reservation, limitErr = s.limiter.Acquire(priority, prompt)
+			if limitErr != nil {
+				return nil, limitErr
 			}
-			lastErr = err
-		default:
-			return nil, fmt.Errorf("unsupported AI provider")
 		}
+
+		if client == nil {
+			reservation.Settle(0, true)
+			return nil, fmt.Errorf("AI model is unavailable")
+		}
+		text, usage, err := client.generate(ctx, modelName, prompt)
+		reservation.Settle(usage.TotalTokens, err != nil)
+		if err == nil {
+			return &aiRawResponse{Text: text, Usage: usage, Model: modelName}, nil
+		}
+		lastErr = err
 	}
 	return nil, lastErr
 }
@@ -393,15 +421,26 @@ func (c *liteLLMClient) generate(ctx context.Context, modelName, prompt string)
 type AIAspectScore struct {
 	Aspek         string `json:"aspek"`          // Nama aspek yang dinilai.
 	SkorDiperoleh int    `json:"skor_diperoleh"` // Skor numerik yang diberikan AI untuk aspek ini.
+	Justifikasi   string   `json:"justifikasi,omitempty"` // Alasan singkat pemilihan skor.
+	Kutipan       []string `json:"kutipan,omitempty"`     // Kutipan verbatim dari jawaban siswa sebagai bukti.
 }
 
 // AIResponse merepresentasikan struktur respons JSON yang diharapkan dari model AI.
 type AIResponse struct {
 	SkorAspek           []AIAspectScore `json:"skor_aspek"`           // Daftar skor untuk setiap aspek.
 	FeedbackKeseluruhan string          `json:"feedback_keseluruhan"` // Umpan balik keseluruhan dari AI.
+	// Kontrak rubrik holistik: satu band beserta alasan dan kutipan, tanpa skor_aspek.
+	Band        *int     `json:"band,omitempty"`
+	Justifikasi string   `json:"justifikasi,omitempty"`
+	Kutipan     []string `json:"kutipan,omitempty"`
 }
 
+// gradeEssayCacheKey menyertakan provider, model, dan versi prompt agar hasil dari
+// model atau template lain tidak pernah dipakai ulang.
 type gradeEssayCacheKey struct {
+	Provider              string                `json:"provider"`
+	Model                 string                `json:"model"`
+	PromptTemplateVersion string                `json:"prompt_template_version"`
 	Question         string                `json:"question"`
 	Keywords         string                `json:"keywords"`
 	IdealAnswer      string                `json:"ideal_answer"`
@@ -410,6 +449,9 @@ type gradeEssayCacheKey struct {
 	GroundingContext string                `json:"grounding_context"`
 	GroundingSource  string                `json:"grounding_source"`
 	Rubric           []models.RubricAspect `json:"rubric"`
+	// Exemplars memuat sidik jari contoh guru (submission + skor) agar perubahan contoh
+	// atau skor review menghasilkan key baru, bukan memakai hasil dengan kalibrasi lama.
+	Exemplars []string `json:"exemplars,omitempty"`
 }
 
 // formatRubricForPrompt mengubah struktur rubrik menjadi format string yang mudah dibaca
@@ -426,12 +468,13 @@ func formatRubricForPrompt(structuredRubric []models.RubricAspect) string {
 	return rubricBuilder.String()
 }
 
-func buildGradeEssayRequestHash(req models.GradeEssayRequest, rubric []models.RubricAspect) (string, error) {
+func buildGradeEssayRequestHash(req models.GradeEssayRequest, rubric []models.RubricAspect, provider, modelName, promptVersion string) (string, error) {
 	normalized := make([]models.RubricAspect, 0, len(rubric))
 	for _, aspect := range rubric {
 		item := models.RubricAspect{
 			Aspek:    strings.TrimSpace(aspect.Aspek),
 			Kriteria: make([]models.RubricCriterion, 0, len(aspect.Kriteria)),
+			Bobot:    aspect.Bobot,
 		}
 		for _, criterion := range aspect.Kriteria {
 			item.Kriteria = append(item.Kriteria, models.RubricCriterion{
@@ -443,6 +486,9 @@ func buildGradeEssayRequestHash(req models.GradeEssayRequest, rubric []models.Ru
 	}
 
 	key := gradeEssayCacheKey{
+		Provider:              provider,
+		Model:                 modelName,
+		PromptTemplateVersion: promptVersion,
 		Question:         strings.TrimSpace(req.Question),
 		Keywords:         strings.TrimSpace(req.Keywords),
 		IdealAnswer:      strings.TrimSpace(req.IdealAnswer),
@@ -452,6 +498,14 @@ func buildGradeEssayRequestHash(req models.GradeEssayRequest, rubric []models.Ru
 		GroundingSource:  strings.TrimSpace(req.GroundingSource),
 		Rubric:           normalized,
 	}
+	for _, exemplar := range req.Exemplars {
+		aspects := make([]string, 0, len(exemplar.AspectScores))
+		for _, aspect := range exemplar.AspectScores {
+			aspects = append(aspects, fmt.Sprintf("%s=%d", strings.TrimSpace(aspect.Aspek), aspect.SkorDiperoleh))
+		}
+		contentHash := sha256.Sum256([]byte(exemplar.Essay + "\x00" + exemplar.Feedback))
+		key.Exemplars = append(key.Exemplars, fmt.Sprintf("%s|%g|%s|%s", exemplar.SubmissionID, exemplar.Score, strings.Join(aspects, ","), hex.EncodeToString(contentHash[:8])))
+	}
 
 	payload, err := json.Marshal(key)
 	if err != nil {
@@ -461,34 +515,61 @@ func buildGradeEssayRequestHash(req models.GradeEssayRequest, rubric []models.Ru
 	return hex.EncodeToString(hash[:]), nil
 }
 
-func (s *AIService) getGradeEssayCache(requestHash string) (*models.GradeEssayResponse, bool, error) {
+// getGradeEssayCache mengambil entri cache. Entri yang lebih tua dari TTL dianggap miss
+// (expired=true) dan akan ditimpa oleh hasil grading baru.
+func (s *AIService) getGradeEssayCache(requestHash string, ttl time.Duration) (response *models.GradeEssayResponse, hit bool, expired bool, err error) {
 	if s.db == nil || strings.TrimSpace(requestHash) == "" {
-		return nil, false, nil
+		return nil, false, false, nil
 	}
 
 	var (
 		score           string
 		feedback        string
 		aspectScoresRaw []byte
+		evidenceRaw     []byte
+		holisticRaw     []byte
+		provider        sql.NullString
+		modelName       sql.NullString
+		promptHash      sql.NullString
+		paramsRaw       []byte
+		rawResponse     sql.NullString
+		promptVersion   sql.NullString
+		createdAt       time.Time
 	)
-	err := s.db.QueryRowContext(
+	err = s.db.QueryRowContext(
 		context.Background(),
-		`SELECT score, feedback, aspect_scores
+		`SELECT score, feedback, aspect_scores, aspect_evidence, holistic_band, provider, model_name, prompt_hash, generation_params, raw_response, prompt_template_version, created_at
 		 FROM ai_grading_cache
 		 WHERE request_hash = $1`,
 		requestHash,
-	).Scan(&score, &feedback, &aspectScoresRaw)
+	).Scan(&score, &feedback, &aspectScoresRaw, &evidenceRaw, &holisticRaw, &provider, &modelName, &promptHash, &paramsRaw, &rawResponse, &promptVersion, &createdAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, false, nil
+			return nil, false, false, nil
 		}
-		return nil, false, err
+		return nil, false, false, err
+	}
+	if ttl > 0 && time.Since(createdAt) > ttl {
+		return nil, false, true, nil
 	}
 
 	aspectScores := make([]models.GradeEssayAspectScore, 0)
 	if len(aspectScoresRaw) > 0 {
 		if err := json.Unmarshal(aspectScoresRaw, &aspectScores); err != nil {
-			return nil, false, err
+			return nil, false, false, err
+		}
+	}
+	var aspectEvidence []models.AspectEvidence
+	if len(evidenceRaw) > 0 {
+		if err := json.Unmarshal(evidenceRaw, &aspectEvidence); err != nil {
+			log.Printf("WARNING: failed to decode cached aspect evidence: %v", err)
+		}
+	}
+	var holistic *models.HolisticBand
+	if len(holisticRaw) > 0 && string(holisticRaw) != "null" {
+		holistic = &models.HolisticBand{}
+		if err := json.Unmarshal(holisticRaw, holistic); err != nil {
+			return nil, false, false, err
 		}
 	}
 
@@ -504,14 +585,31 @@ func (s *AIService) getGradeEssayCache(requestHash string) (*models.GradeEssayRe
 		log.Printf("WARNING: failed to update ai_grading_cache hit counter: %v", updateErr)
 	}
 
+	// Hasil dari cache tidak memakai token; provenance tetap menunjuk model yang membuat hasil aslinya.
+	provenance := &models.GradingProvenance{
+		Provider:    provider.String,
+		Model:       modelName.String,
+		PromptHash:  promptHash.String,
+		FromCache:   true,
+		RawResponse: rawResponse.String,
+	}
+	if len(paramsRaw) > 0 {
+		_ = json.Unmarshal(paramsRaw, &provenance.GenerationParams)
+	}
 	return &models.GradeEssayResponse{
-		Score:        score,
-		Feedback:     feedback,
-		AspectScores: aspectScores,
-	}, true, nil
+		Score:                 score,
+		Feedback:              feedback,
+		AspectScores:          aspectScores,
+		AspectEvidence:        aspectEvidence,
+		Holistic:              holistic,
+		PromptTemplateVersion: promptVersion.String,
+		Provenance:            provenance,
+	}, true, false, nil
 }
 
-func (s *AIService) upsertGradeEssayCache(requestHash string, response *models.GradeEssayResponse) error {
+// upsertGradeEssayCache menyimpan hasil grading baru. Entri yang ditimpa (mis. kedaluwarsa
+// atau re-grade) dimulai ulang: created_at baru dan hit_count nol.
+func (s *AIService) upsertGradeEssayCache(requestHash, questionID string, response *models.GradeEssayResponse) error {
 	if s.db == nil || strings.TrimSpace(requestHash) == "" || response == nil {
 		return nil
 	}
@@ -520,21 +618,66 @@ func (s *AIService) upsertGradeEssayCache(requestHash string, response *models.G
 	if err != nil {
 		return err
 	}
+	var evidenceJSON interface{}
+	if len(response.AspectEvidence) > 0 {
+		payload, err := json.Marshal(response.AspectEvidence)
+		if err != nil {
+			return err
+		}
+		evidenceJSON = string(payload)
+	}
+	var holisticJSON interface{}
+	if response.Holistic != nil {
+		payload, err := json.Marshal(response.Holistic)
+		if err != nil {
+			return err
+		}
+		holisticJSON = string(payload)
+	}
+	provenance := response.Provenance
+	if provenance == nil {
+		provenance = &models.GradingProvenance{}
+	}
+	var paramsJSON interface{}
+	if len(provenance.GenerationParams) > 0 {
+		if payload, err := json.Marshal(provenance.GenerationParams); err == nil {
+			paramsJSON = string(payload)
+		}
+	}
 
 	_, err = s.db.ExecContext(
 		context.Background(),
-		`INSERT INTO ai_grading_cache (request_hash, score, feedback, aspect_scores, created_at, last_used_at, hit_count)
-		 VALUES ($1, $2, $3, $4::jsonb, NOW(), NOW(), 1)
+		`INSERT INTO ai_grading_cache (request_hash, score, feedback, aspect_scores, aspect_evidence, provider, model_name, prompt_hash, generation_params, raw_response, prompt_template_version, question_id, holistic_band, created_at, last_used_at, hit_count)
+		 VALUES ($1, $2, $3, $4::jsonb, $5::jsonb, $6, $7, $8, $9::jsonb, $10, $11, $12::uuid, $13::jsonb, NOW(), NOW(), 0)
 		 ON CONFLICT (request_hash) DO UPDATE
 		 SET score = EXCLUDED.score,
 		     feedback = EXCLUDED.feedback,
 		     aspect_scores = EXCLUDED.aspect_scores,
+		     aspect_evidence = EXCLUDED.aspect_evidence,
+		     holistic_band = EXCLUDED.holistic_band,
+		     provider = EXCLUDED.provider,
+		     model_name = EXCLUDED.model_name,
+		     prompt_hash = EXCLUDED.prompt_hash,
+		     generation_params = EXCLUDED.generation_params,
+		     raw_response = EXCLUDED.raw_response,
+		     prompt_template_version = EXCLUDED.prompt_template_version,
+		     question_id = COALESCE(EXCLUDED.question_id, ai_grading_cache.question_id),
+		     created_at = NOW(),
 		     last_used_at = NOW(),
-		     hit_count = ai_grading_cache.hit_count + 1`,
+		     hit_count = 0`,
 		requestHash,
 		response.Score,
 		response.Feedback,
 		string(aspectScoresJSON),
+		evidenceJSON,
+		nullIfEmpty(provenance.Provider),
+		nullIfEmpty(provenance.Model),
+		nullIfEmpty(provenance.PromptHash),
+		paramsJSON,
+		nullIfEmpty(provenance.RawResponse),
+		nullIfEmpty(response.PromptTemplateVersion),
+		nullIfEmpty(questionID),
+		holisticJSON,
 	)
 	return err
 }
@@ -557,55 +700,102 @@ func clampPromptText(value string, maxChars int) string {
 // GradeEssay membangun prompt, memanggil API Gemini, menghitung skor akhir, dan mem-parsing respons.
 // Ini adalah metode utama untuk penilaian esai menggunakan AI.
 func (s *AIService) GradeEssay(req models.GradeEssayRequest) (*models.GradeEssayResponse, error) {
-	startedAt := time.Now()
 	var structuredRubric []models.RubricAspect
 	// Mengubah JSON rubrik mentah dari request menjadi struktur data Go.
 	if err := json.Unmarshal(req.Rubric, &structuredRubric); err != nil {
 		return nil, fmt.Errorf("invalid rubric JSON format: %w", err)
 	}
-	formattedRubric := formatRubricForPrompt(structuredRubric) // Memformat rubrik untuk prompt.
+	holistic := isHolisticRubric(req.RubricType)
+	var formattedRubric string
+	if holistic {
+		// Rubrik holistik: satu skala band, dinilai dengan kontrak prompt terpisah.
+		if len(structuredRubric) != 1 {
+			return nil, fmt.Errorf("holistic rubric must contain exactly one band scale, got %d", len(structuredRubric))
+		}
+		formattedRubric = formatHolisticScaleForPrompt(structuredRubric)
+	} else {
+		formattedRubric = formatRubricForPrompt(structuredRubric) // Memformat rubrik untuk prompt.
+	}
 
-	requestHash, hashErr := buildGradeEssayRequestHash(req, structuredRubric)
-	if hashErr != nil {
-		log.Printf("WARNING: failed to build grade essay cache hash: %v", hashErr)
+	prompt, promptRef, err := s.buildPrompt(req, formattedRubric) // Membangun prompt lengkap dari template aktif.
+	if err != nil {
+		return nil, fmt.Errorf("failed to render grading prompt: %w", err)
+	}
+	scope := AIUsageScope{ClassID: req.ClassID, TeacherID: req.TeacherID}
+	// Mode ensemble sengaja melewati cache: tujuannya mengambil sampel baru dari model. Request dengan kurang dari
+	// MinEnsembleModels model dinilai dengan satu panggilan biasa.
+	if req.EnsembleSamples > 1 && len(req.EnsembleModels) >= MinEnsembleModels {
+		if err := s.quota.Check(scope, AIQuotaFeatureGrading); err != nil {
+			return nil, err
+		}
+		return s.gradeEssayEnsemble(req, structuredRubric, prompt, promptRef.String())
 	}
-	if requestHash != "" {
-		if cached, hit, cacheErr := s.getGradeEssayCache(requestHash); cacheErr != nil {
+
+	cacheCfg := s.gradingCacheConfig()
+	requestHash := ""
+	if cacheCfg.Enabled {
+		s.modelMu.RLock()
+		providerName, activeModel := string(s.provider), s.modelName
+		s.modelMu.RUnlock()
+		var hashErr error
+		requestHash, hashErr = buildGradeEssayRequestHash(req, structuredRubric, providerName, activeModel, promptRef.String())
+		if hashErr != nil {
+			log.Printf("WARNING: failed to build grade essay cache hash: %v", hashErr)
+		}
+	}
+	if requestHash != "" && !req.BypassCache {
+		if cached, hit, expired, cacheErr := s.getGradeEssayCache(requestHash, cacheCfg.ttl()); cacheErr != nil {
 			log.Printf("WARNING: failed to read grade essay cache: %v", cacheErr)
 		} else if hit {
 			log.Println("INFO: grade_essay cache hit")
+			recordGradingCacheEvent(s.db, "hits", 1)
+			// Skor akhir dihitung ulang dari skor aspek agar mengikuti bobot & normalisasi terkini.
+			if holistic && cached.Holistic != nil {
+				if finalScore, breakdown, calcErr := calculateHolisticScore(structuredRubric, cached.Holistic.Band); calcErr == nil {
+					breakdown.Holistic.Justifikasi = cached.Holistic.Justifikasi
+					breakdown.Holistic.Kutipan = cached.Holistic.Kutipan
+					breakdown.Holistic.Unverified = cached.Holistic.Unverified
+					cached.Score = fmt.Sprintf("%.0f", finalScore)
+					cached.ScoringFormula = scoringFormulaLabel(breakdown)
+					cached.ScoringBreakdown = breakdown
+					cached.Holistic = breakdown.Holistic
+				}
+			} else if len(cached.AspectScores) > 0 {
+				cachedAspects := make([]AIAspectScore, 0, len(cached.AspectScores))
+				for _, item := range cached.AspectScores {
+					cachedAspects = append(cachedAspects, AIAspectScore{Aspek: item.Aspek, SkorDiperoleh: item.SkorDiperoleh})
+				}
+				if finalScore, breakdown, calcErr := calculateFinalScore(structuredRubric, cachedAspects, req.ScoringNormalization); calcErr == nil {
+					cached.Score = fmt.Sprintf("%.0f", finalScore)
+					cached.ScoringFormula = scoringFormulaLabel(breakdown)
+					cached.ScoringBreakdown = breakdown
+				}
+			}
 			return cached, nil
+		} else {
+			recordGradingCacheEvent(s.db, "misses", 1)
+			if expired {
+				recordGradingCacheEvent(s.db, "expired", 1)
+			}
 		}
 	}
 
-	prompt := buildPrompt(req, formattedRubric) // Membangun prompt lengkap.
-	log.Println("--- SENDING PROMPT TO AI API ---")
-
-	log.Println("DEBUG: Calling s.client.GenerateContent now...")
-	// Memanggil Gemini API untuk generate konten berdasarkan prompt.
-	resp, err := s.generateContentWithRetry(prompt)
-
-	// Logging tambahan untuk membantu debug jika ada kegagalan.
-	if err != nil {
-		log.Printf("DEBUG: GenerateContent returned an error: %v", err)
-	} else {
-		log.Println("DEBUG: GenerateContent call successful, no error returned.")
+	// Kuota dicek setelah cache: hasil cache tidak memakai token.
+	if err := s.quota.Check(scope, AIQuotaFeatureGrading); err != nil {
+		return nil, err
 	}
 
-	if err != nil {
-		log.Printf("ERROR: AI API call failed: %v", err)
-		s.logAPIUsage("grade_essay", "error", detectAIErrorType(err), err.Error(), 0, 0, 0, time.Since(startedAt).Milliseconds())
-		return nil, fmt.Errorf("failed to generate content from AI service")
-	}
+	log.Println("--- SENDING PROMPT TO AI API ---")
 
-	aiResponse, err := parseAIResponse(resp.Text) // Mem-parsing respons mentah dari AI.
+	// Output AI divalidasi terhadap rubrik dan diperbaiki lewat re-prompt bila perlu.
+	graded, err := s.generateValidatedGrading(scope, gradingPriority(req), prompt, promptRef.String(), "", "grade_essay", structuredRubric)
 	if err != nil {
-		s.logAPIUsage("grade_essay", "error", "parse", err.Error(), resp.Usage.PromptTokens, resp.Usage.CandidateTokens, resp.Usage.TotalTokens, time.Since(startedAt).Milliseconds())
 		return nil, err
 	}
+	aiResponse := graded.response
 
-	// Menghitung skor akhir berdasarkan rubrik dan skor aspek dari AI.
-	finalScore, err := calculateFinalScore(structuredRubric, aiResponse.SkorAspek)
+	// Menghitung skor akhir berdasarkan rubrik dan skor aspek (atau band holistik) dari AI.
+	finalScore, breakdown, err := calculateGradingScore(req.RubricType, structuredRubric, aiResponse.SkorAspek, req.ScoringNormalization)
 	if err != nil {
 		return nil, err
 	}
@@ -623,14 +813,35 @@ func (s *AIService) GradeEssay(req models.GradeEssayRequest) (*models.GradeEssay
 		Score:        fmt.Sprintf("%.0f", finalScore), // Skor dibulatkan dan diformat sebagai string.
 		Feedback:     aiResponse.FeedbackKeseluruhan,
 		AspectScores: aspectScores,
+		AspectEvidence:   buildAspectEvidence(req.Essay, aiResponse.SkorAspek),
+		ScoringFormula:   scoringFormulaLabel(breakdown),
+		ScoringBreakdown: breakdown,
+		PromptTemplateVersion: promptRef.String(),
+		Provenance: &models.GradingProvenance{
+			Provider:         s.ProviderName(),
+			Model:            graded.model,
+			PromptHash:       hashPrompt(prompt),
+			GenerationParams: s.generationParams(),
+			PromptTokens:     graded.usage.PromptTokens,
+			CandidateTokens:  graded.usage.CandidateTokens,
+			TotalTokens:      graded.usage.TotalTokens,
+			RawResponse:      graded.rawText,
+		},
+	}
+	if holistic {
+		// Rubrik holistik tidak punya skor per aspek; band dan buktinya disimpan terpisah.
+		finalResponse.AspectScores = nil
+		finalResponse.AspectEvidence = nil
+		finalResponse.Holistic = attachHolisticEvidence(breakdown, req.Essay, aiResponse.SkorAspek)
 	}
 	if requestHash != "" {
-		if cacheErr := s.upsertGradeEssayCache(requestHash, finalResponse); cacheErr != nil {
+		if cacheErr := s.upsertGradeEssayCache(requestHash, req.QuestionID, finalResponse); cacheErr != nil {
 			log.Printf("WARNING: failed to upsert grade essay cache: %v", cacheErr)
+		} else {
+			recordGradingCacheEvent(s.db, "writes", 1)
+			s.maybeEvictGradingCache(cacheCfg)
 		}
 	}
-	s.logAPIUsage("grade_essay", "success", "", "", resp.Usage.PromptTokens, resp.Usage.CandidateTokens, resp.Usage.TotalTokens, time.Since(startedAt).Milliseconds())
-
 	return finalResponse, nil
 }
 
@@ -677,69 +888,36 @@ func assertValidJSON(value string) error {
 
 // buildPrompt menyusun prompt lengkap yang akan dikirim ke model AI Gemini.
 // Prompt ini mencakup pertanyaan esai, rubrik, jawaban ideal (jika ada), dan esai siswa.
-func buildPrompt(req models.GradeEssayRequest, formattedRubric string) string {
-	var promptBuilder bytes.Buffer
-
-	// Prompt deterministik + evidence-based untuk mengurangi variasi skor antarsesi.
-	promptBuilder.WriteString("You are a strict, deterministic academic grader.\n")
-	promptBuilder.WriteString("Grade ONLY using the effective rubric for this question and the student's essay text.\n")
-	promptBuilder.WriteString("Do not infer facts that are not explicitly present in the student's essay.\n")
-	promptBuilder.WriteString("If evidence is insufficient for an aspect, assign the lower score.\n\n")
-	promptBuilder.WriteString("SOURCE PRIORITY:\n")
-	promptBuilder.WriteString("1. EFFECTIVE RUBRIC FOR THIS QUESTION is the highest-priority scoring authority.\n")
-	promptBuilder.WriteString("2. IDEAL ANSWER is reference only; it must not override the rubric.\n")
-	promptBuilder.WriteString("3. GROUNDING CONTEXT is for topic/fact validation only; it must not override the rubric.\n")
-	promptBuilder.WriteString("4. KEYWORDS are for concept validation only and must not directly change score.\n\n")
-
-	promptBuilder.WriteString(fmt.Sprintf("ESSAY QUESTION:\n\"%s\"\n\n", req.Question))
-
-	if strings.TrimSpace(req.RubricMode) != "" {
-		promptBuilder.WriteString(fmt.Sprintf("RUBRIC MODE METADATA:\n%s\n\n", req.RubricMode))
+// gradingPromptData memetakan request grading ke placeholder template prompt.
+func gradingPromptData(req models.GradeEssayRequest, formattedRubric string) models.PromptTemplateData {
+	data := models.PromptTemplateData{
+		Question:    req.Question,
+		Rubric:      formattedRubric,
+		RubricMode:  strings.TrimSpace(req.RubricMode),
+		IdealAnswer: req.IdealAnswer,
+		Essay:       essayPromptBlock(req.Essay),
+		Keywords:    req.Keywords,
+		Exemplars:   formatExemplarsForPrompt(req.Exemplars),
 	}
-
-	promptBuilder.WriteString(fmt.Sprintf("GRADING RUBRIC:\n%s\n", formattedRubric))
-
-	if req.IdealAnswer != "" {
-		promptBuilder.WriteString(fmt.Sprintf("IDEAL ANSWER (Reference for reasoning):\n\"%s\"\n\n", req.IdealAnswer))
-	}
-
 	if strings.TrimSpace(req.GroundingContext) != "" {
-		source := strings.TrimSpace(req.GroundingSource)
-		if source == "" {
-			source = "grounding_context"
+		data.Grounding = req.GroundingContext
+		data.GroundingSource = strings.TrimSpace(req.GroundingSource)
+		if data.GroundingSource == "" {
+			data.GroundingSource = "grounding_context"
 		}
-		promptBuilder.WriteString(fmt.Sprintf("GROUNDING CONTEXT (%s):\n%s\n\n", source, req.GroundingContext))
 	}
+	return data
+}
 
-	promptBuilder.WriteString(fmt.Sprintf("STUDENT'S ESSAY TO GRADE:\n\"%s\"\n\n", req.Essay))
-
-	// Keyword hanya untuk validasi sesuai Hal. 53 PDF
-	if req.Keywords != "" {
-		promptBuilder.WriteString(fmt.Sprintf("KEYWORDS (Use ONLY for concept validation, NOT for scoring):\n\"%s\"\n\n", req.Keywords))
+// buildPrompt merender template grading yang aktif (atau bawaan) dan mengembalikan
+// versi template yang dipakai untuk dicatat bersama hasil penilaian.
+// Prompt deterministik + evidence-based untuk mengurangi variasi skor antarsesi.
+func (s *AIService) buildPrompt(req models.GradeEssayRequest, formattedRubric string) (string, PromptTemplateRef, error) {
+	name := PromptTemplateGradeEssay
+	if isHolisticRubric(req.RubricType) {
+		name = PromptTemplateGradeEssayHolistic
 	}
-
-	promptBuilder.WriteString("DETERMINISTIC SCORING PROCEDURE (MUST FOLLOW):\n")
-	promptBuilder.WriteString("1. Read the rubric aspects in order.\n")
-	promptBuilder.WriteString("2. For each aspect, choose exactly one integer score that exists in that aspect's rubric scale.\n")
-	promptBuilder.WriteString("3. Use the SAME aspect names as rubric; do not rename, merge, or split aspects.\n")
-	promptBuilder.WriteString("4. Score must be evidence-based from the student's essay text; if unclear, choose the lower plausible score.\n")
-	promptBuilder.WriteString("5. Keep grading conservative and stable. For identical input, produce identical scores.\n")
-	promptBuilder.WriteString("6. KEYWORDS are for validation only and MUST NOT directly increase/decrease score.\n")
-	promptBuilder.WriteString("7. If the ideal answer or grounding context conflicts with the rubric, follow the rubric.\n")
-	promptBuilder.WriteString("8. Give full score on an aspect only when the student's essay contains clear supporting evidence for that aspect.\n")
-	promptBuilder.WriteString("9. Do not punish harmless wording variation or small typos if the intended concept is correct.\n")
-	promptBuilder.WriteString("10. Feedback must be concise, specific, and tied to rubric weaknesses/strengths.\n\n")
-
-	promptBuilder.WriteString("OUTPUT RULES:\n")
-	promptBuilder.WriteString("1. Return ONLY one valid JSON object, no markdown, no extra text.\n")
-	promptBuilder.WriteString("2. JSON schema:\n")
-	promptBuilder.WriteString("{ \"skor_aspek\": [{\"aspek\": \"<exact_rubric_aspect_name>\", \"skor_diperoleh\": <int>}], \"feedback_keseluruhan\": \"...\" }\n")
-	promptBuilder.WriteString("3. skor_aspek must contain all rubric aspects exactly once.\n")
-	promptBuilder.WriteString("4. skor_diperoleh must be integer and within allowed score range of each aspect.\n")
-	promptBuilder.WriteString("5. feedback_keseluruhan max 120 words. and the target is a highschool student (siswa, not mahasiswa)\n")
-
-	return promptBuilder.String()
-
+	return s.promptTemplates.Render(name, gradingPromptData(req, formattedRubric))
 }
 
 // parseAIResponse mem-parsing respons dari model AI Gemini.
@@ -768,84 +946,51 @@ func (s *AIService) GenerateEssayQuestionFromMaterial(
 	targetLevel string,
 	teachingModuleContext string,
 	existingQuestionTexts []string,
+	scope AIUsageScope,
 ) (*models.AutoGeneratedEssayQuestion, error) {
 	startedAt := time.Now()
 	if strings.TrimSpace(materialContent) == "" {
 		return nil, fmt.Errorf("material content is empty")
 	}
+	if err := s.quota.Check(scope, AIQuotaFeatureGeneration); err != nil {
+		return nil, err
+	}
 	if rubricType != "holistik" && rubricType != "analitik" {
 		rubricType = "analitik"
 	}
 
-	var promptBuilder bytes.Buffer
-	promptBuilder.WriteString("You are an expert instructional designer for LMS essay assessments.\n")
-	promptBuilder.WriteString("Domain constraint: This system is ONLY for Sejarah (History), especially Indonesian history context.\n")
-	promptBuilder.WriteString("Target audience: Sekolah menengah kelas 10. Use very simple vocabulary, avoid multi-step reasoning, and keep questions short and straightforward.\n")
-	promptBuilder.WriteString("Difficulty level: easy. Question should target C1-C4 only, and never require deep analysis or synthesis.\n")
-	promptBuilder.WriteString("Generate exactly one essay question draft based only on the material.\n")
-	promptBuilder.WriteString("Return ONLY JSON. No markdown.\n\n")
-	promptBuilder.WriteString(fmt.Sprintf("MATERIAL TITLE:\n%s\n\n", materialTitle))
-	promptBuilder.WriteString(fmt.Sprintf("MATERIAL CONTENT:\n%s\n\n", materialContent))
-	if strings.TrimSpace(teachingModuleContext) != "" {
-		promptBuilder.WriteString("CLASS TEACHING MODULE CONTEXT (PDF extract; prioritize factual consistency):\n")
-		promptBuilder.WriteString(teachingModuleContext)
-		promptBuilder.WriteString("\n\n")
-	}
-	if len(existingQuestionTexts) > 0 {
-		promptBuilder.WriteString("EXISTING ESSAY QUESTIONS FOR THIS MATERIAL (DO NOT REPEAT OR REPHRASE THESE):\n")
-		seen := map[string]struct{}{}
-		added := 0
-		for _, text := range existingQuestionTexts {
-			trimmed := strings.TrimSpace(text)
-			if trimmed == "" {
-				continue
-			}
-			key := strings.ToLower(trimmed)
-			if _, exists := seen[key]; exists {
-				continue
-			}
-			seen[key] = struct{}{}
-			added++
-			promptBuilder.WriteString(fmt.Sprintf("%d. %s\n", added, clampPromptText(trimmed, 220)))
-			if added >= 8 {
-				break
-			}
+	existingQuestions := make([]string, 0, 8)
+	seen := map[string]struct{}{}
+	for _, text := range existingQuestionTexts {
+		trimmed := strings.TrimSpace(text)
+		if trimmed == "" {
+			continue
 		}
-		promptBuilder.WriteString("\n")
-	}
-	promptBuilder.WriteString(fmt.Sprintf("RUBRIC TYPE REQUESTED: %s\n\n", rubricType))
-	if strings.TrimSpace(targetLevel) != "" {
-		promptBuilder.WriteString(fmt.Sprintf("TARGET COGNITIVE LEVEL (MANDATORY): %s\n\n", strings.TrimSpace(targetLevel)))
-	}
-	promptBuilder.WriteString("Return this exact JSON schema:\n")
-	promptBuilder.WriteString("{\"teks_soal\":\"...\",\"level_kognitif\":\"C1|C2|C3|C4\",\"keywords\":[\"...\"],\"ideal_answer\":\"...\",\"weight\":10,\"rubric_type\":\"analitik|holistik\",\"rubrics\":[{\"nama_aspek\":\"...\",\"descriptors\":[{\"score\":0,\"description\":\"...\"},{\"score\":1,\"description\":\"...\"},{\"score\":2,\"description\":\"...\"},{\"score\":3,\"description\":\"...\"}]}]}\n")
-	promptBuilder.WriteString("Rules:\n")
-	promptBuilder.WriteString("1) Question must be answerable from material only.\n")
-	promptBuilder.WriteString("1a) If teaching module context exists, align terms/timeline with it, but never contradict material content.\n")
-	promptBuilder.WriteString("2) The question MUST be history subject, and must not switch to non-history domains.\n")
-	promptBuilder.WriteString("2a) It MUST be materially different from any existing essay question listed above. Do not reuse the same command verb, focus, wording, or expected answer target.\n")
-	promptBuilder.WriteString("2b) If existing questions already ask for definition or explanation of one topic, choose another fact, actor, chronology, cause, impact, or comparison that is still supported by the material.\n")
-	if strings.TrimSpace(targetLevel) != "" {
-		promptBuilder.WriteString(fmt.Sprintf("3) Cognitive level MUST be exactly %s.\n", strings.TrimSpace(targetLevel)))
-	} else {
-		promptBuilder.WriteString("3) Cognitive level target MUST be only C1-C4 (remember/understand/apply/analyze). Avoid C5-C6 complexity.\n")
-		promptBuilder.WriteString("3b) Choose one level randomly among C1/C2/C3/C4 and output it in level_kognitif.\n")
-	}
-	promptBuilder.WriteString("3c) If level_kognitif is C1, rubric_type SHOULD be holistik with one aspect based on keyword recall coverage.\n")
-	promptBuilder.WriteString("3a) Aim for vocabulary and structure that a tenth-grader can understand on first read. No compound conditions.\n")
-	promptBuilder.WriteString("4) Keep question short and simple: single prompt, max 30 words, no multi-part questions. ended with ? or ! mark\n")
-	promptBuilder.WriteString("4a) Assign weights according to cognitive level: 5 points for C1, 10 for C2, 15 for C3, 20 for C4.\n")
-	promptBuilder.WriteString("5) ideal_answer must be concise: 5-50 words, easy to understand, no long essay answer required.\n")
-	promptBuilder.WriteString("6) keywords must be concise and relevant (3-6 items).\n")
-	promptBuilder.WriteString("7) weight must be positive number.\n")
-	promptBuilder.WriteString("8) For holistik, rubrics must contain exactly 1 aspect.\n")
-	promptBuilder.WriteString("9) For analitik, rubrics must contain 2 - 5 aspects with clear simple names.\n")
-	promptBuilder.WriteString("10) Each descriptor description must be plain string, no object.\n")
-	promptBuilder.WriteString("11) If the material is not history-related, return an error JSON: {\"error\":\"MATERI_BUKAN_SEJARAH\"}\n")
-
-	resp, err := s.generateContentWithRetry(promptBuilder.String())
+		key := strings.ToLower(trimmed)
+		if _, exists := seen[key]; exists {
+			continue
+		}
+		seen[key] = struct{}{}
+		existingQuestions = append(existingQuestions, clampPromptText(trimmed, 220))
+		if len(existingQuestions) >= 8 {
+			break
+		}
+	}
+	prompt, promptRef, err := s.promptTemplates.Render(PromptTemplateGenerateQuestion, models.PromptTemplateData{
+		MaterialTitle:         materialTitle,
+		MaterialContent:       materialContent,
+		TeachingModuleContext: strings.TrimSpace(teachingModuleContext),
+		ExistingQuestions:     existingQuestions,
+		RubricType:            rubricType,
+		TargetLevel:           strings.TrimSpace(targetLevel),
+	})
 	if err != nil {
-		s.logAPIUsage("auto_generate_question", "error", detectAIErrorType(err), err.Error(), 0, 0, 0, time.Since(startedAt).Milliseconds())
+		return nil, fmt.Errorf("failed to render question generation prompt: %w", err)
+	}
+
+	resp, err := s.generateContentWithRetry(prompt)
+	if err != nil {
+		s.logAPIUsageForModel(scope, s.modelName, promptRef.String(), "auto_generate_question", "error", detectAIErrorType(err), err.Error(), 0, 0, 0, time.Since(startedAt).Milliseconds())
 		return nil, fmt.Errorf("failed to generate essay question draft: %w", err)
 	}
 
@@ -856,7 +1001,7 @@ func (s *AIService) GenerateEssayQuestionFromMaterial(
 	var draft models.AutoGeneratedEssayQuestion
 	if err := json.Unmarshal([]byte(resp.Text), &draft); err != nil {
 		log.Printf("ERROR: Failed to unmarshal generated question JSON: %v. Raw response: %s", err, resp.Text)
-		s.logAPIUsage("auto_generate_question", "error", "parse", err.Error(), resp.Usage.PromptTokens, resp.Usage.CandidateTokens, resp.Usage.TotalTokens, time.Since(startedAt).Milliseconds())
+		s.logAPIUsageForModel(scope, s.modelName, promptRef.String(), "auto_generate_question", "error", "parse", err.Error(), resp.Usage.PromptTokens, resp.Usage.CandidateTokens, resp.Usage.TotalTokens, time.Since(startedAt).Milliseconds())
 		return nil, fmt.Errorf("failed to parse generated question from AI")
 	}
 	if strings.TrimSpace(draft.TeksSoal) == "" && strings.Contains(strings.ToUpper(resp.Text), "MATERI_BUKAN_SEJARAH") {
@@ -975,7 +1120,7 @@ func (s *AIService) GenerateEssayQuestionFromMaterial(
 			},
 		}
 	}
-	s.logAPIUsage("auto_generate_question", "success", "", "", resp.Usage.PromptTokens, resp.Usage.CandidateTokens, resp.Usage.TotalTokens, time.Since(startedAt).Milliseconds())
+	s.logAPIUsageForModel(scope, s.modelName, promptRef.String(), "auto_generate_question", "success", "", "", resp.Usage.PromptTokens, resp.Usage.CandidateTokens, resp.Usage.TotalTokens, time.Since(startedAt).Milliseconds())
 
 	return &draft, nil
 }
@@ -988,11 +1133,15 @@ func (s *AIService) GenerateEssayQuestionMetadataFromMaterial(
 	rubricType string,
 	targetLevel string,
 	teachingModuleContext string,
+	scope AIUsageScope,
 ) (*models.AutoGeneratedEssayQuestion, error) {
 	startedAt := time.Now()
 	if strings.TrimSpace(materialContent) == "" {
 		return nil, fmt.Errorf("material content is empty")
 	}
+	if err := s.quota.Check(scope, AIQuotaFeatureGeneration); err != nil {
+		return nil, err
+	}
 	if strings.TrimSpace(questionText) == "" {
 		return nil, fmt.Errorf("question text is empty")
 	}
@@ -1000,48 +1149,21 @@ func (s *AIService) GenerateEssayQuestionMetadataFromMaterial(
 		rubricType = "analitik"
 	}
 
-	var promptBuilder bytes.Buffer
-	promptBuilder.WriteString("You are an expert instructional designer for LMS essay assessments.\n")
-	promptBuilder.WriteString("Domain constraint: This system is ONLY for Sejarah (History), especially Indonesian history context.\n")
-	promptBuilder.WriteString("Target audience: Sekolah menengah kelas 10. Use simple vocabulary.\n")
-	promptBuilder.WriteString("Task: Generate ONLY the rubric, keywords, ideal answer, level, and weight for the given essay question.\n")
-	promptBuilder.WriteString("Do NOT change the question text. teks_soal in output MUST be identical to the given question.\n")
-	promptBuilder.WriteString("Return ONLY JSON. No markdown.\n\n")
-	promptBuilder.WriteString(fmt.Sprintf("MATERIAL TITLE:\n%s\n\n", materialTitle))
-	promptBuilder.WriteString(fmt.Sprintf("MATERIAL CONTENT:\n%s\n\n", materialContent))
-	if strings.TrimSpace(teachingModuleContext) != "" {
-		promptBuilder.WriteString("CLASS TEACHING MODULE CONTEXT (PDF extract; prioritize factual consistency):\n")
-		promptBuilder.WriteString(teachingModuleContext)
-		promptBuilder.WriteString("\n\n")
-	}
-	promptBuilder.WriteString(fmt.Sprintf("EXISTING QUESTION (MUST KEEP EXACTLY):\n%s\n\n", strings.TrimSpace(questionText)))
-	promptBuilder.WriteString(fmt.Sprintf("RUBRIC TYPE REQUESTED: %s\n\n", rubricType))
-	if strings.TrimSpace(targetLevel) != "" {
-		promptBuilder.WriteString(fmt.Sprintf("TARGET COGNITIVE LEVEL (MANDATORY): %s\n\n", strings.TrimSpace(targetLevel)))
-	}
-	promptBuilder.WriteString("Return this exact JSON schema:\n")
-	promptBuilder.WriteString("{\"teks_soal\":\"...\",\"level_kognitif\":\"C1|C2|C3|C4\",\"keywords\":[\"...\"],\"ideal_answer\":\"...\",\"weight\":10,\"rubric_type\":\"analitik|holistik\",\"rubrics\":[{\"nama_aspek\":\"...\",\"descriptors\":[{\"score\":0,\"description\":\"...\"},{\"score\":1,\"description\":\"...\"},{\"score\":2,\"description\":\"...\"},{\"score\":3,\"description\":\"...\"}]}]}\n")
-	promptBuilder.WriteString("Rules:\n")
-	promptBuilder.WriteString("1) Question must be answerable from material only.\n")
-	promptBuilder.WriteString("2) The question MUST be history subject.\n")
-	if strings.TrimSpace(targetLevel) != "" {
-		promptBuilder.WriteString(fmt.Sprintf("3) Cognitive level MUST be exactly %s.\n", strings.TrimSpace(targetLevel)))
-	} else {
-		promptBuilder.WriteString("3) Cognitive level target MUST be only C1-C4. Avoid C5-C6 complexity.\n")
-		promptBuilder.WriteString("3b) Choose one level randomly among C1/C2/C3/C4 and output it in level_kognitif.\n")
-	}
-	promptBuilder.WriteString("3c) If level_kognitif is C1, rubric_type SHOULD be holistik with one aspect based on keyword recall coverage.\n")
-	promptBuilder.WriteString("4) ideal_answer must be concise: 5-60 words, easy to understand.\n")
-	promptBuilder.WriteString("5) keywords must be concise and relevant (3-6 items).\n")
-	promptBuilder.WriteString("6) For holistik, rubrics must contain exactly 1 aspect.\n")
-	promptBuilder.WriteString("7) For analitik, rubrics must contain 2 - 5 aspects with clear simple names.\n")
-	promptBuilder.WriteString("8) Each descriptor description must be plain string, no object.\n")
-	promptBuilder.WriteString("9) Assign weights by level: C1=5, C2=10, C3=15, C4=20.\n")
-	promptBuilder.WriteString("10) If the material is not history-related, return an error JSON: {\"error\":\"MATERI_BUKAN_SEJARAH\"}\n")
-
-	resp, err := s.generateContentWithRetry(promptBuilder.String())
+	prompt, promptRef, err := s.promptTemplates.Render(PromptTemplateGenerateQuestionMetadata, models.PromptTemplateData{
+		MaterialTitle:         materialTitle,
+		MaterialContent:       materialContent,
+		TeachingModuleContext: strings.TrimSpace(teachingModuleContext),
+		Question:              strings.TrimSpace(questionText),
+		RubricType:            rubricType,
+		TargetLevel:           strings.TrimSpace(targetLevel),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to render question metadata prompt: %w", err)
+	}
+
+	resp, err := s.generateContentWithRetry(prompt)
 	if err != nil {
-		s.logAPIUsage("auto_generate_metadata", "error", detectAIErrorType(err), err.Error(), 0, 0, 0, time.Since(startedAt).Milliseconds())
+		s.logAPIUsageForModel(scope, s.modelName, promptRef.String(), "auto_generate_metadata", "error", detectAIErrorType(err), err.Error(), 0, 0, 0, time.Since(startedAt).Milliseconds())
 		return nil, fmt.Errorf("failed to generate essay metadata: %w", err)
 	}
 
@@ -1052,7 +1174,7 @@ func (s *AIService) GenerateEssayQuestionMetadataFromMaterial(
 	var draft models.AutoGeneratedEssayQuestion
 	if err := json.Unmarshal([]byte(resp.Text), &draft); err != nil {
 		log.Printf("ERROR: Failed to unmarshal generated metadata JSON: %v. Raw response: %s", err, resp.Text)
-		s.logAPIUsage("auto_generate_metadata", "error", "parse", err.Error(), resp.Usage.PromptTokens, resp.Usage.CandidateTokens, resp.Usage.TotalTokens, time.Since(startedAt).Milliseconds())
+		s.logAPIUsageForModel(scope, s.modelName, promptRef.String(), "auto_generate_metadata", "error", "parse", err.Error(), resp.Usage.PromptTokens, resp.Usage.CandidateTokens, resp.Usage.TotalTokens, time.Since(startedAt).Milliseconds())
 		return nil, fmt.Errorf("failed to parse generated metadata from AI")
 	}
 	draft.TeksSoal = strings.TrimSpace(questionText)
@@ -1167,16 +1289,16 @@ func (s *AIService) GenerateEssayQuestionMetadataFromMaterial(
 			},
 		}
 	}
-	s.logAPIUsage("auto_generate_metadata", "success", "", "", resp.Usage.PromptTokens, resp.Usage.CandidateTokens, resp.Usage.TotalTokens, time.Since(startedAt).Milliseconds())
+	s.logAPIUsageForModel(scope, s.modelName, promptRef.String(), "auto_generate_metadata", "success", "", "", resp.Usage.PromptTokens, resp.Usage.CandidateTokens, resp.Usage.TotalTokens, time.Since(startedAt).Milliseconds())
 
 	return &draft, nil
 }
 
 // calculateFinalScore menghitung skor akhir esai berdasarkan rubrik terstruktur
-// dan skor aspek yang diberikan oleh AI.
-func calculateFinalScore(rubric []models.RubricAspect, aspectScores []AIAspectScore) (float64, error) {
-	var totalScoreObtained float64 = 0
-	var totalMaxScore float64 = 0
+// dan skor aspek yang diberikan oleh AI. Bobot aspek (Rubric.Bobot) ikut diperhitungkan
+// sesuai mode normalisasi; rinciannya dikembalikan agar bisa disimpan bersama ai_results.
+func calculateFinalScore(rubric []models.RubricAspect, aspectScores []AIAspectScore, normalization string) (float64, *models.ScoringBreakdown, error) {
+	normalization, _ = NormalizeScoringNormalization(normalization)
 
 	obtainedScoresMap := make(map[string]int)
 	for _, as := range aspectScores {
@@ -1187,6 +1309,14 @@ func calculateFinalScore(rubric []models.RubricAspect, aspectScores []AIAspectSc
 		obtainedScoresMap[key] = as.SkorDiperoleh
 	}
 
+	breakdown := &models.ScoringBreakdown{
+		Version:       scoringFormulaVersion,
+		Normalization: normalization,
+		Formula:       scoringFormulaDescription(normalization),
+		Aspects:       make([]models.ScoringAspectBreakdown, 0, len(rubric)),
+	}
+
+	var weightedObtained, weightedMax, weightSum, weightedRatio float64
 	for i, aspect := range rubric {
 		// M = Skor Maksimal Rubrik (Hal. 55 PDF)
 		maxScoreInAspect := 0
@@ -1195,9 +1325,12 @@ func calculateFinalScore(rubric []models.RubricAspect, aspectScores []AIAspectSc
 				maxScoreInAspect = criterion.Skor
 			}
 		}
-		totalMaxScore += float64(maxScoreInAspect)
+		weight := aspect.Bobot
+		if weight <= 0 {
+			weight = 1
+		}
 
-		// S = Total skor yang diperoleh (Hal. 55 PDF)
+		// S = Skor yang diperoleh (Hal. 55 PDF)
 		normalizedAspect := strings.ToLower(strings.TrimSpace(aspect.Aspek))
 		obtainedScore, ok := obtainedScoresMap[normalizedAspect]
 		if !ok && len(aspectScores) == len(rubric) {
@@ -1206,24 +1339,51 @@ func calculateFinalScore(rubric []models.RubricAspect, aspectScores []AIAspectSc
 			ok = true
 		}
 		if !ok {
-			continue
+			obtainedScore = 0
 		}
-
 		if obtainedScore < 0 {
 			obtainedScore = 0
 		} else if maxScoreInAspect > 0 && obtainedScore > maxScoreInAspect {
 			obtainedScore = maxScoreInAspect
 		}
-		totalScoreObtained += float64(obtainedScore)
+
+		ratio := 0.0
+		if maxScoreInAspect > 0 {
+			ratio = float64(obtainedScore) / float64(maxScoreInAspect)
+		}
+		weightedObtained += weight * float64(obtainedScore)
+		weightedMax += weight * float64(maxScoreInAspect)
+		if maxScoreInAspect > 0 {
+			weightSum += weight
+			weightedRatio += weight * ratio
+		}
+
+		breakdown.Aspects = append(breakdown.Aspects, models.ScoringAspectBreakdown{
+			Aspek:      aspect.Aspek,
+			Bobot:      weight,
+			Skor:       obtainedScore,
+			MaxScore:   maxScoreInAspect,
+			Normalized: ratio,
+			Matched:    ok,
+		})
 	}
 
-	if totalMaxScore == 0 {
-		return 0, fmt.Errorf("maximum possible score is zero")
+	if weightedMax == 0 {
+		return 0, nil, fmt.Errorf("maximum possible score is zero")
 	}
 
-	// Rumus sesuai Hal. 55: (S / M) * 100
-	// Kita hilangkan pembulatan kelipatan 5 agar presisi sesuai dokumen (e.g., 66.6)
-	finalScore := (totalScoreObtained / totalMaxScore) * 100
+	var finalScore float64
+	switch normalization {
+	case ScoringNormalizationPerAspectMax:
+		// Setiap aspek dinormalisasi ke skor maksimumnya sendiri, lalu dirata-rata berbobot.
+		finalScore = (weightedRatio / weightSum) * 100
+	default:
+		// Rumus Hal. 55: (S / M) * 100, dengan S dan M dikalikan bobot aspek.
+		// Jika semua bobot sama, hasilnya identik dengan rumus lama.
+		finalScore = (weightedObtained / weightedMax) * 100
+	}
 
-	return finalScore, nil
+	breakdown.RawScore = finalScore
+	breakdown.FinalScore = finalScore
+	return finalScore, breakdown, nil
 }
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"api-backend/internal/models"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	maxEvidenceQuotesPerAspect = 3
+	minEvidenceQuoteRunes      = 4
+	minEvidenceFragmentWords   = 3   // Potongan kutipan "..." minimal sekian kata agar tidak cocok secara kebetulan.
+	minEvidenceCoverage        = 0.8 // Porsi huruf kutipan yang harus berasal dari potongan yang cocok.
+	maxJustificationWords      = 60
+)
+
+var evidenceTextReplacer = strings.NewReplacer(
+	"“", "\"", "”", "\"", "‘", "'", "’", "'",
+	"–", "-", "—", "-", "…", "...",
+)
+
+// normalizeEvidenceText menyamakan huruf, tanda kutip, dan spasi agar perbedaan format kecil
+// (mis. baris baru atau kutip miring) tidak membuat kutipan asli dianggap karangan.
+func normalizeEvidenceText(value string) string {
+	value = evidenceTextReplacer.Replace(strings.ToLower(value))
+	return strings.Join(strings.Fields(value), " ")
+}
+
+// locateEvidenceQuote memeriksa apakah kutipan benar-benar ada di jawaban siswa.
+// Kutipan yang dipotong dengan "..." dianggap valid bila setiap potongannya cukup panjang, muncul berurutan,
+// dan bersama-sama mencakup sebagian besar kutipan; potongan pendek seperti "a ... di ... yang" ditolak.
+func locateEvidenceQuote(essay, normalizedEssay, quote string) string {
+	trimmed := strings.Trim(strings.TrimSpace(quote), "\"'“”‘’")
+	if utf8.RuneCountInString(strings.TrimSpace(trimmed)) < minEvidenceQuoteRunes {
+		return "not_found"
+	}
+	if strings.Contains(essay, trimmed) {
+		return "exact"
+	}
+
+	normalizedQuote := normalizeEvidenceText(trimmed)
+	fragments := strings.Split(normalizedQuote, "...")
+	pos := 0
+	matchedRunes := 0
+	for _, fragment := range fragments {
+		fragment = strings.Trim(fragment, " .,;:!?\"'")
+		if fragment == "" {
+			continue
+		}
+		if len(fragments) > 1 && (utf8.RuneCountInString(fragment) < minEvidenceQuoteRunes || len(strings.Fields(fragment)) < minEvidenceFragmentWords) {
+			return "not_found"
+		}
+		idx := strings.Index(normalizedEssay[pos:], fragment)
+		if idx < 0 {
+			return "not_found"
+		}
+		pos += idx + len(fragment)
+		matchedRunes += evidenceLetterCount(fragment)
+	}
+	total := evidenceLetterCount(normalizedQuote)
+	if matchedRunes == 0 || float64(matchedRunes) < minEvidenceCoverage*float64(total) {
+		return "not_found"
+	}
+	return "normalized"
+}
+
+// evidenceLetterCount menghitung huruf dan angka, tanpa spasi, tanda baca, maupun elipsis.
+func evidenceLetterCount(value string) int {
+	count := 0
+	for _, r := range value {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			count++
+		}
+	}
+	return count
+}
+
+// buildAspectEvidence memvalidasi kutipan AI terhadap teks jawaban siswa.
+// Kutipan yang tidak ditemukan tetap disimpan tetapi ditandai verified=false agar guru tahu
+// bagian mana yang kemungkinan dikarang model.
+func buildAspectEvidence(essay string, scores []AIAspectScore) []models.AspectEvidence {
+	normalizedEssay := normalizeEvidenceText(essay)
+	out := make([]models.AspectEvidence, 0, len(scores))
+	for _, item := range scores {
+		evidence := models.AspectEvidence{
+			Aspek:         item.Aspek,
+			SkorDiperoleh: item.SkorDiperoleh,
+			Justifikasi:   trimToWordLimit(item.Justifikasi, maxJustificationWords),
+			Kutipan:       make([]models.EvidenceQuote, 0, len(item.Kutipan)),
+		}
+		for _, quote := range item.Kutipan {
+			if strings.TrimSpace(quote) == "" {
+				continue
+			}
+			if len(evidence.Kutipan) >= maxEvidenceQuotesPerAspect {
+				break
+			}
+			match := locateEvidenceQuote(essay, normalizedEssay, quote)
+			verified := match != "not_found"
+			if !verified {
+				evidence.UnverifiedQuotes++
+			}
+			evidence.Kutipan = append(evidence.Kutipan, models.EvidenceQuote{
+				Text:     strings.TrimSpace(quote),
+				Verified: verified,
+				Match:    match,
+			})
+		}
+		out = append(out, evidence)
+	}
+	return out
+}
+
+// attachHolisticEvidence melengkapi band holistik pada breakdown dengan justifikasi dan kutipan
+// yang sudah divalidasi terhadap jawaban siswa.
+func attachHolisticEvidence(breakdown *models.ScoringBreakdown, essay string, scores []AIAspectScore) *models.HolisticBand {
+	if breakdown == nil || breakdown.Holistic == nil {
+		return nil
+	}
+	if evidence := buildAspectEvidence(essay, scores); len(evidence) > 0 {
+		breakdown.Holistic.Justifikasi = evidence[0].Justifikasi
+		breakdown.Holistic.Kutipan = evidence[0].Kutipan
+		breakdown.Holistic.Unverified = evidence[0].UnverifiedQuotes
+	}
+	return breakdown.Holistic
+}
@@ -4,10 +4,8 @@ import (
 	"api-backend/internal/models" // Mengimpor definisi model pengguna.
 	"database/sql"                // Mengimpor package database/sql untuk interaksi DB.
 	"encoding/json"
-	"fmt" // Mengimpor package fmt untuk format string dan error.
-	"log" // Mengimpor package log untuk logging.
-	"os"
-	"strconv"
+	"fmt"     // Mengimpor package fmt untuk format string dan error.
+	"log"     // Mengimpor package log untuk logging.
 	"strings" // Mengimpor package strings untuk membangun query dinamis.
 	"time"    // Mengimpor package time untuk timestamp.
 
@@ -1133,26 +1131,13 @@ func (s *AuthService) GetAdminAPIStatistics(days int) (*models.AdminAPIStatistic
 		RecentLogs:       []models.APIUsageRecentLog{},
 	}
 
-	dailyLimit := int64(0)
-	if value := strings.TrimSpace(os.Getenv("GEMINI_DAILY_TOKEN_LIMIT")); value != "" {
-		if parsed, parseErr := strconv.ParseInt(value, 10, 64); parseErr == nil && parsed > 0 {
-			dailyLimit = parsed
-		}
-	}
-	parseEnvInt := func(key string, fallback int64) int64 {
-		value := strings.TrimSpace(os.Getenv(key))
-		if value == "" {
-			return fallback
-		}
-		parsed, err := strconv.ParseInt(value, 10, 64)
-		if err != nil || parsed <= 0 {
-			return fallback
-		}
-		return parsed
-	}
-	resp.Summary.RPMLimit = parseEnvInt("GEMINI_LIMIT_RPM", 5)
-	resp.Summary.TPMLimit = parseEnvInt("GEMINI_LIMIT_TPM", 250000)
-	resp.Summary.RPDLimit = parseEnvInt("GEMINI_LIMIT_RPD", 20)
+	dailyLimit := DailyTokenLimitFromEnv()
+	// Batas yang ditampilkan diambil dari limiter yang sama dengan yang menegakkannya.
+	limiterStatus := SharedAIRateLimiter().Status()
+	resp.Summary.Limiter = &limiterStatus
+	resp.Summary.RPMLimit = limiterStatus.RPMLimit
+	resp.Summary.TPMLimit = limiterStatus.TPMLimit
+	resp.Summary.RPDLimit = limiterStatus.RPDLimit
 
 	if err := s.db.QueryRow(`
 		SELECT
@@ -1206,6 +1191,7 @@ func (s *AuthService) GetAdminAPIStatistics(days int) (*models.AdminAPIStatistic
 			remaining = 0
 		}
 		resp.Summary.TokensRemaining = remaining
+		resp.Summary.TokenBudgetExhausted = remaining == 0
 	}
 
 	rowsDaily, err := s.db.Query(`
@@ -1,9 +1,13 @@
 package services
 
 import (
+	"api-backend/internal/doctext"
 	"api-backend/internal/models"
 	"database/sql"
+	"errors"
 	"fmt"
+	"io/fs"
+	"log"
 	"time"
 )
 
@@ -22,6 +26,8 @@ func (s *ClassTeachingModuleService) CreateClassTeachingModule(req models.Create
 		FileURL:   req.FileURL,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
+
+		ExtractionStatus: models.TeachingModuleTextPending,
 	}
 	if req.UploadedBy != "" {
 		newModule.UploadedBy = &req.UploadedBy
@@ -45,12 +51,20 @@ func (s *ClassTeachingModuleService) CreateClassTeachingModule(req models.Create
 		return nil, fmt.Errorf("error inserting class teaching module: %w", err)
 	}
 
+	// Ekstraksi langsung saat upload agar permintaan generate soal pertama tidak menunggu parsing file.
+	if _, err := s.TeachingModuleText(newModule); err != nil {
+		log.Printf("WARNING: teaching module %s text not extracted on upload: %v", newModule.ID, err)
+	}
+
 	return newModule, nil
 }
 
 func (s *ClassTeachingModuleService) GetClassTeachingModulesByClassID(classID string) ([]models.ClassTeachingModule, error) {
 	query := `
-		SELECT id, class_id, uploaded_by, nama_modul, file_url, created_at, updated_at
+		SELECT id, class_id, uploaded_by, nama_modul, file_url,
+		       extracted_text, extracted_page_count, text_extracted_at, text_source_url,
+		       text_extraction_status, text_extraction_error,
+		       created_at, updated_at
 		FROM class_teaching_modules
 		WHERE class_id = $1
 		ORDER BY updated_at DESC, created_at DESC
@@ -71,6 +85,12 @@ func (s *ClassTeachingModuleService) GetClassTeachingModulesByClassID(classID st
 			&m.UploadedBy,
 			&m.NamaModul,
 			&m.FileURL,
+			&m.ExtractedText,
+			&m.ExtractedPageCount,
+			&m.TextExtractedAt,
+			&m.TextSourceURL,
+			&m.ExtractionStatus,
+			&m.ExtractionError,
 			&m.CreatedAt,
 			&m.UpdatedAt,
 		); err != nil {
@@ -87,7 +107,10 @@ func (s *ClassTeachingModuleService) GetClassTeachingModulesByClassID(classID st
 
 func (s *ClassTeachingModuleService) GetClassTeachingModuleByID(moduleID string) (*models.ClassTeachingModule, error) {
 	query := `
-		SELECT id, class_id, uploaded_by, nama_modul, file_url, created_at, updated_at
+		SELECT id, class_id, uploaded_by, nama_modul, file_url,
+		       extracted_text, extracted_page_count, text_extracted_at, text_source_url,
+		       text_extraction_status, text_extraction_error,
+		       created_at, updated_at
 		FROM class_teaching_modules
 		WHERE id = $1
 	`
@@ -101,6 +124,12 @@ func (s *ClassTeachingModuleService) GetClassTeachingModuleByID(moduleID string)
 		&m.UploadedBy,
 		&m.NamaModul,
 		&m.FileURL,
+		&m.ExtractedText,
+		&m.ExtractedPageCount,
+		&m.TextExtractedAt,
+		&m.TextSourceURL,
+		&m.ExtractionStatus,
+		&m.ExtractionError,
 		&m.CreatedAt,
 		&m.UpdatedAt,
 	); err != nil {
@@ -127,6 +156,95 @@ func (s *ClassTeachingModuleService) DeleteClassTeachingModule(moduleID string)
 	return nil
 }
 
+// TeachingModuleText mengembalikan teks modul (dengan penanda halaman/slide) dari cache kolom extracted_text.
+// Bila modul belum pernah diekstrak atau file-nya sudah diganti, file diekstrak lalu hasil dan statusnya disimpan.
+func (s *ClassTeachingModuleService) TeachingModuleText(m *models.ClassTeachingModule) (string, error) {
+	return loadTeachingModuleText(s.db, m)
+}
+
+// ReextractTeachingModuleText membuang cache lalu mengekstrak ulang file modul, mis. setelah extractor
+// diperbaiki atau file di server diganti. updated_at ikut diperbarui agar indeks grounding kelas dibangun ulang.
+func (s *ClassTeachingModuleService) ReextractTeachingModuleText(moduleID string) (*models.ClassTeachingModule, error) {
+	m, err := s.GetClassTeachingModuleByID(moduleID)
+	if err != nil {
+		return nil, err
+	}
+	m.TextExtractedAt = nil
+	if _, err := loadTeachingModuleText(s.db, m); err != nil {
+		log.Printf("INFO: teaching module %s re-extracted with status %s: %v", m.ID, m.ExtractionStatus, err)
+	}
+	if err := s.db.QueryRow(
+		"UPDATE class_teaching_modules SET updated_at = NOW() WHERE id = $1 RETURNING updated_at",
+		m.ID,
+	).Scan(&m.UpdatedAt); err != nil {
+		return nil, fmt.Errorf("error touching class teaching module %s: %w", m.ID, err)
+	}
+	return m, nil
+}
+
+func loadTeachingModuleText(db *sql.DB, m *models.ClassTeachingModule) (string, error) {
+	if m.TextExtractedAt != nil && m.TextSourceURL != nil && *m.TextSourceURL == m.FileURL {
+		if m.ExtractionStatus != models.TeachingModuleTextReady || m.ExtractedText == nil {
+			return "", fmt.Errorf("teaching module text is not available (%s)", m.ExtractionStatus)
+		}
+		return *m.ExtractedText, nil
+	}
+
+	// Semua hasil, termasuk gagal, dicache agar file yang sama tidak diproses berulang kali;
+	// guru bisa memicu ekstraksi ulang lewat ReextractTeachingModuleText.
+	text, units := "", 0
+	result, extractErr := ExtractTextFromUploadedFile(m.FileURL)
+	if result != nil {
+		text, units = result.Text, result.Units
+	}
+	status, reason := teachingModuleExtractionStatus(extractErr)
+	extractedAt := time.Now()
+	sourceURL := m.FileURL
+	if _, err := db.Exec(
+		`UPDATE class_teaching_modules
+		 SET extracted_text = $2, extracted_page_count = $3, text_extracted_at = $4, text_source_url = $5,
+		     text_extraction_status = $6, text_extraction_error = $7
+		 WHERE id = $1 AND file_url = $5`,
+		m.ID, text, units, extractedAt, sourceURL, status, reason,
+	); err != nil {
+		log.Printf("WARNING: failed caching extracted text for teaching module %s: %v", m.ID, err)
+	}
+	m.ExtractedText = &text
+	m.ExtractedPageCount = &units
+	m.TextExtractedAt = &extractedAt
+	m.TextSourceURL = &sourceURL
+	m.ExtractionStatus = status
+	m.ExtractionError = reason
+
+	if extractErr != nil {
+		return "", extractErr
+	}
+	return text, nil
+}
+
+// teachingModuleExtractionStatus menerjemahkan error ekstraksi menjadi status dan alasan yang ditampilkan ke guru.
+func teachingModuleExtractionStatus(err error) (string, *string) {
+	var status, reason string
+	var pathErr *fs.PathError
+	switch {
+	case err == nil:
+		return models.TeachingModuleTextReady, nil
+	case errors.Is(err, doctext.ErrUnsupportedFormat):
+		status, reason = models.TeachingModuleTextUnsupported, "Format file belum didukung. Gunakan PDF, DOCX, PPTX, ODT, ODP, TXT, Markdown, atau HTML."
+	case errors.Is(err, doctext.ErrNoText):
+		status, reason = models.TeachingModuleTextEmpty, "File tidak memuat teks yang bisa dibaca (misalnya PDF hasil scan tanpa OCR)."
+	case errors.Is(err, doctext.ErrEncrypted):
+		status, reason = models.TeachingModuleTextFailed, "File dilindungi password."
+	case errors.Is(err, doctext.ErrTooLarge):
+		status, reason = models.TeachingModuleTextFailed, "Isi file terlalu besar untuk dibaca."
+	case errors.As(err, &pathErr):
+		status, reason = models.TeachingModuleTextFailed, "File modul tidak ditemukan di server."
+	default:
+		status, reason = models.TeachingModuleTextFailed, "File rusak atau tidak bisa dibaca."
+	}
+	return status, &reason
+}
+
 func (s *ClassTeachingModuleService) CleanupUploadPathIfUnused(rawPath string) error {
 	helper := NewMaterialService(s.db)
 	return helper.DeleteUploadPathIfUnused(rawPath)
@@ -0,0 +1,311 @@
+package services
+
+import (
+	"api-backend/internal/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultDriftWindowDays       = 30
+	DefaultDriftMinSamples       = 10
+	DefaultDriftWarningMeanDiff  = 8.0
+	DefaultDriftCriticalMeanDiff = 15.0
+	DefaultDriftWarningQWK       = 0.6
+	DefaultDriftCriticalQWK      = 0.4
+
+	driftWindowDaysSettingKey       = "drift_window_days"
+	driftMinSamplesSettingKey       = "drift_min_samples"
+	driftWarningMeanDiffSettingKey  = "drift_warning_mean_diff"
+	driftCriticalMeanDiffSettingKey = "drift_critical_mean_diff"
+
+	driftDrillDownLimit = 5
+)
+
+// DriftMonitorService memantau selisih skor AI terhadap skor revisi guru (drift) dalam jendela bergulir.
+type DriftMonitorService struct {
+	db       *sql.DB
+	settings *SystemSettingService
+}
+
+func NewDriftMonitorService(db *sql.DB, settings *SystemSettingService) *DriftMonitorService {
+	return &DriftMonitorService{db: db, settings: settings}
+}
+
+// Thresholds membaca batas drift dari system_settings; key yang belum diset memakai default.
+func (s *DriftMonitorService) Thresholds() models.DriftThresholds {
+	cfg := models.DriftThresholds{
+		WindowDays:       DefaultDriftWindowDays,
+		MinSamples:       DefaultDriftMinSamples,
+		WarningMeanDiff:  DefaultDriftWarningMeanDiff,
+		CriticalMeanDiff: DefaultDriftCriticalMeanDiff,
+		WarningQWK:       DefaultDriftWarningQWK,
+		CriticalQWK:      DefaultDriftCriticalQWK,
+	}
+	if s.settings == nil {
+		return cfg
+	}
+	read := func(key string) string {
+		value, err := s.settings.GetSetting(key)
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(value)
+	}
+	if parsed, err := strconv.Atoi(read(driftWindowDaysSettingKey)); err == nil && parsed > 0 {
+		cfg.WindowDays = parsed
+	}
+	if parsed, err := strconv.Atoi(read(driftMinSamplesSettingKey)); err == nil && parsed > 0 {
+		cfg.MinSamples = parsed
+	}
+	if parsed, err := strconv.ParseFloat(read(driftWarningMeanDiffSettingKey), 64); err == nil && parsed > 0 {
+		cfg.WarningMeanDiff = parsed
+	}
+	if parsed, err := strconv.ParseFloat(read(driftCriticalMeanDiffSettingKey), 64); err == nil && parsed > 0 {
+		cfg.CriticalMeanDiff = parsed
+	}
+	if cfg.CriticalMeanDiff < cfg.WarningMeanDiff {
+		cfg.CriticalMeanDiff = cfg.WarningMeanDiff
+	}
+	return cfg
+}
+
+type driftSample struct {
+	QuestionID   string
+	QuestionText string
+	TeacherID    string
+	TeacherName  string
+	Model        string
+	AI           float64
+	Teacher      float64
+}
+
+// BuildReport menghitung drift per soal, guru (pereview), dan model untuk review dalam windowDays terakhir.
+// windowDays <= 0 memakai setting drift_window_days.
+func (s *DriftMonitorService) BuildReport(windowDays int) (*models.DriftReport, error) {
+	thresholds := s.Thresholds()
+	if windowDays > 0 {
+		thresholds.WindowDays = windowDays
+	}
+
+	rows, err := s.db.QueryContext(
+		context.Background(),
+		`SELECT eq.id, eq.teks_soal, tr.teacher_id, COALESCE(u.nama_lengkap, ''),
+		        COALESCE(NULLIF(ar.model_name, ''), 'unknown'), ar.skor_ai, tr.revised_score
+		 FROM teacher_reviews tr
+		 JOIN essay_submissions es ON es.id = tr.submission_id
+		 JOIN essay_questions eq ON eq.id = es.soal_id
+		 JOIN ai_results ar ON ar.submission_id = es.id
+		 LEFT JOIN users u ON u.id = tr.teacher_id
+		 WHERE ar.skor_ai IS NOT NULL
+		   AND tr.updated_at >= NOW() - make_interval(days => $1)`,
+		thresholds.WindowDays,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query drift samples: %w", err)
+	}
+	defer rows.Close()
+
+	samples := make([]driftSample, 0)
+	for rows.Next() {
+		var item driftSample
+		if err := rows.Scan(&item.QuestionID, &item.QuestionText, &item.TeacherID, &item.TeacherName, &item.Model, &item.AI, &item.Teacher); err != nil {
+			return nil, fmt.Errorf("failed to scan drift sample: %w", err)
+		}
+		samples = append(samples, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate drift samples: %w", err)
+	}
+
+	report := &models.DriftReport{
+		Thresholds:        thresholds,
+		PairedSubmissions: len(samples),
+		Overall:           buildDriftGroup("overall", "all", "Semua", samples, thresholds),
+		GeneratedAt:       time.Now(),
+	}
+	report.ByQuestion = groupDrift("question", samples, thresholds, func(item driftSample) (string, string) {
+		return item.QuestionID, item.QuestionText
+	})
+	report.ByTeacher = groupDrift("teacher", samples, thresholds, func(item driftSample) (string, string) {
+		return item.TeacherID, item.TeacherName
+	})
+	report.ByModel = groupDrift("model", samples, thresholds, func(item driftSample) (string, string) {
+		return item.Model, item.Model
+	})
+	return report, nil
+}
+
+// FlaggedGroups mengembalikan grup ber-level warning/critical, critical lebih dulu.
+func FlaggedGroups(groups []models.DriftGroup) []models.DriftGroup {
+	out := make([]models.DriftGroup, 0)
+	for _, group := range groups {
+		if group.Level == "warning" || group.Level == "critical" {
+			out = append(out, group)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Level != out[j].Level {
+			return out[i].Level == "critical"
+		}
+		return math.Abs(out[i].MeanSignedDiff) > math.Abs(out[j].MeanSignedDiff)
+	})
+	return out
+}
+
+func groupDrift(dimension string, samples []driftSample, thresholds models.DriftThresholds, keyFn func(driftSample) (string, string)) []models.DriftGroup {
+	order := make([]string, 0)
+	labels := map[string]string{}
+	buckets := map[string][]driftSample{}
+	for _, item := range samples {
+		key, label := keyFn(item)
+		if _, ok := buckets[key]; !ok {
+			order = append(order, key)
+			labels[key] = label
+		}
+		buckets[key] = append(buckets[key], item)
+	}
+
+	groups := make([]models.DriftGroup, 0, len(order))
+	for _, key := range order {
+		group := buildDriftGroup(dimension, key, labels[key], buckets[key], thresholds)
+		// Drill-down guru/model ke soal dengan selisih terbesar agar admin tahu sumber drift.
+		if dimension != "question" && (group.Level == "warning" || group.Level == "critical") {
+			group.Questions = driftQuestionBreakdown(buckets[key])
+		}
+		groups = append(groups, group)
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		if rank := driftLevelRank(groups[i].Level) - driftLevelRank(groups[j].Level); rank != 0 {
+			return rank > 0
+		}
+		return math.Abs(groups[i].MeanSignedDiff) > math.Abs(groups[j].MeanSignedDiff)
+	})
+	return groups
+}
+
+func driftLevelRank(level string) int {
+	switch level {
+	case "critical":
+		return 3
+	case "warning":
+		return 2
+	case "ok":
+		return 1
+	default:
+		return 0
+	}
+}
+
+func driftQuestionBreakdown(samples []driftSample) []models.DriftQuestionRef {
+	order := make([]string, 0)
+	texts := map[string]string{}
+	buckets := map[string][]agreementPair{}
+	for _, item := range samples {
+		if _, ok := buckets[item.QuestionID]; !ok {
+			order = append(order, item.QuestionID)
+			texts[item.QuestionID] = item.QuestionText
+		}
+		buckets[item.QuestionID] = append(buckets[item.QuestionID], agreementPair{Teacher: item.Teacher, AI: item.AI})
+	}
+	refs := make([]models.DriftQuestionRef, 0, len(order))
+	for _, questionID := range order {
+		pairs := buckets[questionID]
+		metrics := computeAgreementMetrics(pairs, DefaultAgreementScoreBinSize)
+		refs = append(refs, models.DriftQuestionRef{
+			QuestionID:     questionID,
+			QuestionText:   texts[questionID],
+			N:              len(pairs),
+			MeanSignedDiff: roundTo(meanSignedDiff(pairs), 2),
+			QWK:            metrics.QWK,
+		})
+	}
+	sort.SliceStable(refs, func(i, j int) bool {
+		return math.Abs(refs[i].MeanSignedDiff) > math.Abs(refs[j].MeanSignedDiff)
+	})
+	if len(refs) > driftDrillDownLimit {
+		refs = refs[:driftDrillDownLimit]
+	}
+	return refs
+}
+
+func meanSignedDiff(pairs []agreementPair) float64 {
+	if len(pairs) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, p := range pairs {
+		sum += p.AI - p.Teacher
+	}
+	return sum / float64(len(pairs))
+}
+
+func buildDriftGroup(dimension, key, label string, samples []driftSample, thresholds models.DriftThresholds) models.DriftGroup {
+	pairs := make([]agreementPair, 0, len(samples))
+	for _, item := range samples {
+		pairs = append(pairs, agreementPair{Teacher: item.Teacher, AI: item.AI})
+	}
+	metrics := computeAgreementMetrics(pairs, DefaultAgreementScoreBinSize)
+	group := models.DriftGroup{
+		Dimension:      dimension,
+		Key:            key,
+		Label:          label,
+		N:              len(pairs),
+		MeanSignedDiff: roundTo(meanSignedDiff(pairs), 2),
+		QWK:            metrics.QWK,
+	}
+	if metrics.MAE != nil {
+		group.MAE = roundTo(*metrics.MAE, 2)
+	}
+	if group.MeanSignedDiff > 0 {
+		group.Direction = "over"
+	} else if group.MeanSignedDiff < 0 {
+		group.Direction = "under"
+	}
+	group.Level, group.Reasons = classifyDrift(group, thresholds)
+	return group
+}
+
+// classifyDrift menentukan level drift. Grup dengan sampel di bawah MinSamples tidak dinilai.
+func classifyDrift(group models.DriftGroup, thresholds models.DriftThresholds) (string, []string) {
+	if group.N < thresholds.MinSamples {
+		return "insufficient_data", nil
+	}
+	level := "ok"
+	reasons := make([]string, 0, 2)
+	absDiff := math.Abs(group.MeanSignedDiff)
+	direction := "lebih tinggi"
+	if group.MeanSignedDiff < 0 {
+		direction = "lebih rendah"
+	}
+	if absDiff >= thresholds.CriticalMeanDiff {
+		level = "critical"
+		reasons = append(reasons, fmt.Sprintf("AI rata-rata %.1f poin %s dari guru (batas kritis %.1f)", absDiff, direction, thresholds.CriticalMeanDiff))
+	} else if absDiff >= thresholds.WarningMeanDiff {
+		level = "warning"
+		reasons = append(reasons, fmt.Sprintf("AI rata-rata %.1f poin %s dari guru (batas %.1f)", absDiff, direction, thresholds.WarningMeanDiff))
+	}
+	if group.QWK != nil {
+		if *group.QWK < thresholds.CriticalQWK {
+			level = "critical"
+			reasons = append(reasons, fmt.Sprintf("QWK %.2f di bawah batas kritis %.2f", *group.QWK, thresholds.CriticalQWK))
+		} else if *group.QWK < thresholds.WarningQWK {
+			if level == "ok" {
+				level = "warning"
+			}
+			reasons = append(reasons, fmt.Sprintf("QWK %.2f di bawah batas %.2f", *group.QWK, thresholds.WarningQWK))
+		}
+	}
+	return level, reasons
+}
+
+func roundTo(value float64, places int) float64 {
+	factor := math.Pow(10, float64(places))
+	return math.Round(value*factor) / factor
+}
@@ -0,0 +1,298 @@
+package services
+
+import (
+	"api-backend/internal/models"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	// MaxEnsembleSamples membatasi jumlah panggilan model per esai.
+	MaxEnsembleSamples = 7
+	// DefaultEnsembleSamples dipakai bila ensemble aktif tetapi jumlah sampel belum diatur.
+	DefaultEnsembleSamples = 3
+	// DefaultEnsembleSpreadThreshold adalah selisih skor akhir (skala 0-100) antarsampel
+	// yang masih dianggap wajar sebelum submission ditandai perlu review guru.
+	DefaultEnsembleSpreadThreshold = 15.0
+	// MinEnsembleModels adalah jumlah model berbeda minimum agar ensemble berarti; sampel dari model yang sama
+	// dengan prompt yang sama hampir identik sehingga median dan sebarannya tidak memberi informasi.
+	MinEnsembleModels = 2
+
+	ensembleEnabledSettingKey         = "ensemble_enabled"
+	ensembleSamplesSettingKey         = "ensemble_samples"
+	ensembleModelsSettingKey          = "ensemble_models"
+	ensembleSpreadThresholdSettingKey = "ensemble_spread_threshold"
+)
+
+// EnsembleConfig adalah konfigurasi global ensemble dari system_settings.
+type EnsembleConfig struct {
+	Enabled         bool
+	Samples         int
+	Models          []string
+	SpreadThreshold float64
+}
+
+// ParseEnsembleModels memecah daftar model yang dipisah koma/baris baru dan membuang duplikat.
+func ParseEnsembleModels(raw string) []string {
+	seen := map[string]struct{}{}
+	out := make([]string, 0)
+	for _, item := range strings.FieldsFunc(raw, func(r rune) bool { return r == ',' || r == '\n' || r == ';' }) {
+		name := strings.TrimSpace(item)
+		if name == "" {
+			continue
+		}
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		out = append(out, name)
+	}
+	return out
+}
+
+// resolveEnsembleSettings menggabungkan setting global dengan override per soal.
+// Override ensemble_samples pada soal selalu menang (<= 1 mematikan ensemble untuk soal tersebut).
+// Ensemble tidak dijalankan bila ensemble_models berisi kurang dari MinEnsembleModels model.
+func resolveEnsembleSettings(global EnsembleConfig, question *models.EssayQuestion) (int, []string, float64) {
+	if len(global.Models) < MinEnsembleModels {
+		return 0, nil, 0
+	}
+	samples := 0
+	if global.Enabled {
+		samples = global.Samples
+	}
+	threshold := global.SpreadThreshold
+	if question != nil {
+		if question.EnsembleSamples != nil {
+			samples = *question.EnsembleSamples
+		}
+		if question.EnsembleSpreadThreshold != nil {
+			threshold = *question.EnsembleSpreadThreshold
+		}
+	}
+	if samples > MaxEnsembleSamples {
+		samples = MaxEnsembleSamples
+	}
+	if samples <= 1 {
+		return 0, nil, 0
+	}
+	if threshold <= 0 {
+		threshold = DefaultEnsembleSpreadThreshold
+	}
+	return samples, global.Models, threshold
+}
+
+// ensembleModelPlan menentukan model untuk tiap sampel dengan menggilir daftar model.
+func ensembleModelPlan(samples int, modelNames []string) []string {
+	plan := make([]string, samples)
+	for i := range plan {
+		plan[i] = modelNames[i%len(modelNames)]
+	}
+	return plan
+}
+
+// lowerMedianInt mengambil median bawah agar hasilnya tetap salah satu skor rubrik yang valid
+// dan konsisten dengan aturan "pilih skor lebih rendah bila ragu".
+func lowerMedianInt(values []int) int {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]int(nil), values...)
+	sort.Ints(sorted)
+	return sorted[(len(sorted)-1)/2]
+}
+
+type ensembleSampleResult struct {
+	model     string
+	rawText   string
+	score     float64
+	feedback  string
+	aspects   []AIAspectScore
+	breakdown *models.ScoringBreakdown
+}
+
+// gradeEssayEnsemble memanggil model sebanyak req.EnsembleSamples kali, mengambil median
+// per aspek, lalu mencatat sebaran skor antarsampel.
+func (s *AIService) gradeEssayEnsemble(req models.GradeEssayRequest, rubric []models.RubricAspect, prompt, promptVersion string) (*models.GradeEssayResponse, error) {
+	scope := AIUsageScope{ClassID: req.ClassID, TeacherID: req.TeacherID}
+	plan := ensembleModelPlan(req.EnsembleSamples, req.EnsembleModels)
+	results := make([]ensembleSampleResult, 0, len(plan))
+	var lastErr error
+	var totalUsage aiUsage
+	for _, modelName := range plan {
+		graded, err := s.generateValidatedGrading(scope, gradingPriority(req), prompt, promptVersion, modelName, "grade_essay_ensemble", rubric)
+		if err != nil {
+			log.Printf("WARNING: ensemble sample on model %s failed: %v", modelName, err)
+			lastErr = err
+			continue
+		}
+		totalUsage.PromptTokens += graded.usage.PromptTokens
+		totalUsage.CandidateTokens += graded.usage.CandidateTokens
+		totalUsage.TotalTokens += graded.usage.TotalTokens
+		aiResponse := graded.response
+		score, breakdown, err := calculateGradingScore(req.RubricType, rubric, aiResponse.SkorAspek, req.ScoringNormalization)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, ensembleSampleResult{
+			model:     graded.model,
+			rawText:   graded.rawText,
+			score:     score,
+			feedback:  aiResponse.FeedbackKeseluruhan,
+			aspects:   aiResponse.SkorAspek,
+			breakdown: breakdown,
+		})
+	}
+	if len(results) == 0 {
+		if lastErr != nil {
+			log.Printf("ERROR: all ensemble samples failed: %v", lastErr)
+			return nil, lastErr
+		}
+		return nil, fmt.Errorf("failed to generate content from AI service")
+	}
+
+	summary := &models.EnsembleSummary{
+		RequestedSamples: len(plan),
+		Samples:          make([]models.EnsembleSample, 0, len(results)),
+		FailedSamples:    len(plan) - len(results),
+		Aspects:          make([]models.EnsembleAspectSpread, 0, len(rubric)),
+		Threshold:        req.EnsembleSpreadThreshold,
+	}
+	if summary.Threshold <= 0 {
+		summary.Threshold = DefaultEnsembleSpreadThreshold
+	}
+
+	medianAspects := make([]AIAspectScore, 0, len(rubric))
+	for i, aspect := range rubric {
+		scores := make([]int, 0, len(results))
+		for _, result := range results {
+			sampled := scoredAspects(result.breakdown)
+			if i < len(sampled) && sampled[i].Matched {
+				scores = append(scores, sampled[i].Skor)
+			}
+		}
+		spread := models.EnsembleAspectSpread{Aspek: aspect.Aspek, Scores: scores}
+		if len(scores) > 0 {
+			spread.Median = lowerMedianInt(scores)
+			spread.Min, spread.Max = scores[0], scores[0]
+			for _, v := range scores {
+				if v < spread.Min {
+					spread.Min = v
+				}
+				if v > spread.Max {
+					spread.Max = v
+				}
+			}
+			spread.Spread = spread.Max - spread.Min
+			medianAspects = append(medianAspects, AIAspectScore{Aspek: aspect.Aspek, SkorDiperoleh: spread.Median})
+		}
+		if spread.Spread > summary.MaxAspectSpread {
+			summary.MaxAspectSpread = spread.Spread
+		}
+		summary.Aspects = append(summary.Aspects, spread)
+	}
+
+	finalScore, breakdown, err := calculateGradingScore(req.RubricType, rubric, medianAspects, req.ScoringNormalization)
+	if err != nil {
+		return nil, err
+	}
+
+	minScore, maxScore := results[0].score, results[0].score
+	representative := results[0]
+	closest := math.Abs(results[0].score - finalScore)
+	for _, result := range results {
+		minScore = math.Min(minScore, result.score)
+		maxScore = math.Max(maxScore, result.score)
+		// Feedback dan bukti diambil dari sampel yang skornya paling dekat dengan hasil median.
+		if diff := math.Abs(result.score - finalScore); diff < closest {
+			closest = diff
+			representative = result
+		}
+		sampleAspects := make([]models.GradeEssayAspectScore, 0, len(result.breakdown.Aspects))
+		for _, item := range scoredAspects(result.breakdown) {
+			sampleAspects = append(sampleAspects, models.GradeEssayAspectScore{Aspek: item.Aspek, SkorDiperoleh: item.Skor})
+		}
+		summary.Samples = append(summary.Samples, models.EnsembleSample{
+			Model:        result.model,
+			Score:        math.Round(result.score),
+			AspectScores: sampleAspects,
+		})
+	}
+	summary.ScoreSpread = math.Round(maxScore) - math.Round(minScore)
+
+	switch {
+	case len(results) < 2:
+		summary.NeedsReview = true
+		summary.ReviewReason = fmt.Sprintf("Hanya %d dari %d sampel AI yang berhasil; konsistensi nilai tidak dapat diukur.", len(results), len(plan))
+	case summary.ScoreSpread > summary.Threshold:
+		summary.NeedsReview = true
+		summary.ReviewReason = fmt.Sprintf("Selisih skor antarsampel AI %.0f poin melebihi batas %.0f poin.", summary.ScoreSpread, summary.Threshold)
+	}
+
+	// Output mentah semua sampel disimpan agar hasil median dapat ditelusuri ulang.
+	rawSamples := make([]map[string]string, 0, len(results))
+	usedModels := make([]string, 0, len(results))
+	seenModels := map[string]struct{}{}
+	for _, result := range results {
+		rawSamples = append(rawSamples, map[string]string{"model": result.model, "raw_response": result.rawText})
+		if _, ok := seenModels[result.model]; !ok {
+			seenModels[result.model] = struct{}{}
+			usedModels = append(usedModels, result.model)
+		}
+	}
+	rawJSON, _ := json.Marshal(rawSamples)
+
+	aspectScores := make([]models.GradeEssayAspectScore, 0, len(medianAspects))
+	for _, item := range medianAspects {
+		aspectScores = append(aspectScores, models.GradeEssayAspectScore{Aspek: item.Aspek, SkorDiperoleh: item.SkorDiperoleh})
+	}
+	response := &models.GradeEssayResponse{
+		Score:                 fmt.Sprintf("%.0f", finalScore),
+		Feedback:              representative.feedback,
+		AspectScores:          aspectScores,
+		AspectEvidence:        buildAspectEvidence(req.Essay, representative.aspects),
+		ScoringFormula:        scoringFormulaLabel(breakdown),
+		ScoringBreakdown:      breakdown,
+		Ensemble:              summary,
+		PromptTemplateVersion: promptVersion,
+		Provenance: &models.GradingProvenance{
+			Provider:         s.ProviderName(),
+			Model:            strings.Join(usedModels, ","),
+			PromptHash:       hashPrompt(prompt),
+			GenerationParams: s.generationParams(),
+			PromptTokens:     totalUsage.PromptTokens,
+			CandidateTokens:  totalUsage.CandidateTokens,
+			TotalTokens:      totalUsage.TotalTokens,
+			RawResponse:      string(rawJSON),
+		},
+	}
+	if isHolisticRubric(req.RubricType) {
+		// Median dihitung pada band; bukti diambil dari sampel representatif.
+		response.AspectScores = nil
+		response.AspectEvidence = nil
+		response.Holistic = attachHolisticEvidence(breakdown, req.Essay, representative.aspects)
+	}
+	return response, nil
+}
+
+// scoredAspects mengembalikan skor per aspek pada breakdown. Rubrik holistik diwakili satu entri
+// band agar sebaran antarsampel ensemble dapat dihitung dengan cara yang sama.
+func scoredAspects(breakdown *models.ScoringBreakdown) []models.ScoringAspectBreakdown {
+	if breakdown == nil {
+		return nil
+	}
+	if breakdown.Holistic != nil {
+		return []models.ScoringAspectBreakdown{{
+			Aspek:    breakdown.Holistic.Skala,
+			Bobot:    1,
+			Skor:     breakdown.Holistic.Band,
+			MaxScore: breakdown.Holistic.MaxBand,
+			Matched:  true,
+		}}
+	}
+	return breakdown.Aspects
+}
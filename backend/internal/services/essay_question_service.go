@@ -6,6 +6,7 @@ import (
 	"crypto/rand"                 // Mengimpor package crypto/rand untuk UUID generation
 	"database/sql"                // Mengimpor package database/sql untuk interaksi dengan database.
 	"encoding/json"               // Mengimpor package encoding/json untuk bekerja dengan JSON.
+	"errors"                      // Mengimpor package errors untuk sentinel error validasi rubrik.
 	"fmt"                         // Mengimpor package fmt untuk format string dan error.
 	"strings"                     // Mengimpor package strings untuk manipulasi string (membangun query update).
 	"time"                        // Mengimpor package time untuk timestamp.
@@ -20,6 +21,77 @@ func sanitizeRoundScoreStep(step *float64) float64 {
 	return *step
 }
 
+// sanitizeEnsembleSamples mengembalikan nil (ikut setting global) untuk nilai negatif
+// dan membatasi jumlah sampel agar biaya token tetap terkendali.
+func sanitizeEnsembleSamples(samples *int) interface{} {
+	if samples == nil || *samples < 0 {
+		return nil
+	}
+	if *samples > MaxEnsembleSamples {
+		return MaxEnsembleSamples
+	}
+	return *samples
+}
+
+func sanitizeEnsembleSpreadThreshold(threshold *float64) interface{} {
+	if threshold == nil || *threshold < 0 {
+		return nil
+	}
+	if *threshold > 100 {
+		return 100.0
+	}
+	return *threshold
+}
+
+// ErrInvalidQuestionRubric menandai tipe rubrik atau skala holistik yang tidak valid (HTTP 400).
+var ErrInvalidQuestionRubric = errors.New("invalid question rubric")
+
+// resolveQuestionRubricType memvalidasi tipe rubrik soal. Rubrik holistik wajib tepat satu skala band,
+// dan skor_band (bila diisi) hanya boleh untuk band yang punya deskriptor dengan nilai 0-100.
+func resolveQuestionRubricType(value string, rubrics json.RawMessage) (string, error) {
+	rubricType, ok := NormalizeRubricType(value)
+	if !ok {
+		return "", fmt.Errorf("%w: rubric_type must be analitik or holistik", ErrInvalidQuestionRubric)
+	}
+	if rubricType != models.RubricTypeHolistik {
+		return rubricType, nil
+	}
+	var scales []models.Rubric
+	if len(rubrics) > 0 && string(rubrics) != "null" {
+		if err := json.Unmarshal(rubrics, &scales); err != nil {
+			return "", fmt.Errorf("%w: rubrics format is invalid", ErrInvalidQuestionRubric)
+		}
+	}
+	if len(scales) == 0 {
+		// Soal boleh disimpan tanpa rubrik dulu; grading akan menolak sampai rubrik diisi.
+		return rubricType, nil
+	}
+	if len(scales) != 1 {
+		return "", fmt.Errorf("%w: holistik rubric must contain exactly one band scale", ErrInvalidQuestionRubric)
+	}
+	for band, score := range scales[0].SkorBand {
+		if _, exists := scales[0].Descriptors[band]; !exists {
+			return "", fmt.Errorf("%w: skor_band refers to band %d which has no descriptor", ErrInvalidQuestionRubric, band)
+		}
+		if score < 0 || score > 100 {
+			return "", fmt.Errorf("%w: skor_band for band %d must be between 0 and 100", ErrInvalidQuestionRubric, band)
+		}
+	}
+	return rubricType, nil
+}
+
+// parseHolisticBand mendekode ai_results.holistic_band; nil bila kosong atau rusak.
+func parseHolisticBand(raw sql.NullString) *models.HolisticBand {
+	if !raw.Valid || strings.TrimSpace(raw.String) == "" || raw.String == "null" {
+		return nil
+	}
+	var band models.HolisticBand
+	if err := json.Unmarshal([]byte(raw.String), &band); err != nil {
+		return nil
+	}
+	return &band
+}
+
 // EssayQuestionService menyediakan metode untuk manajemen pertanyaan esai.
 type EssayQuestionService struct {
 	db *sql.DB // Koneksi database yang digunakan oleh layanan ini.
@@ -51,15 +123,19 @@ func (s *EssayQuestionService) CreateEssayQuestion(req *models.CreateEssayQuesti
 	} else {
 		rubricsToStore = req.Rubrics
 	}
+	rubricType, err := resolveQuestionRubricType(req.RubricType, rubricsToStore)
+	if err != nil {
+		return nil, err
+	}
 
 	query := `
-		INSERT INTO essay_questions (id, material_id, teks_soal, keywords, ideal_answer, weight, round_score_to_5, round_score_step, rubrics, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
-		RETURNING id, material_id, teks_soal, keywords, ideal_answer, weight, round_score_to_5, round_score_step, rubrics, created_at, updated_at
+		INSERT INTO essay_questions (id, material_id, teks_soal, keywords, ideal_answer, weight, round_score_to_5, round_score_step, rubrics, rubric_type, ensemble_samples, ensemble_spread_threshold, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		RETURNING id, material_id, teks_soal, keywords, ideal_answer, weight, round_score_to_5, round_score_step, rubrics, rubric_type, ensemble_samples, ensemble_spread_threshold, created_at, updated_at
 	`
 	var createdQuestion models.EssayQuestion
 	var scannedKeywords pq.StringArray
-	err := s.db.QueryRowContext(
+	err = s.db.QueryRowContext(
 		context.Background(),
 		query,
 		newID,
@@ -71,6 +147,9 @@ func (s *EssayQuestionService) CreateEssayQuestion(req *models.CreateEssayQuesti
 		req.RoundScoreTo5,
 		sanitizeRoundScoreStep(req.RoundScoreStep),
 		rubricsToStore, // Use rubricsToStore here
+		rubricType,
+		sanitizeEnsembleSamples(req.EnsembleSamples),
+		sanitizeEnsembleSpreadThreshold(req.EnsembleSpreadThreshold),
 		now,
 		now,
 	).Scan(
@@ -83,6 +162,9 @@ func (s *EssayQuestionService) CreateEssayQuestion(req *models.CreateEssayQuesti
 		&createdQuestion.RoundScoreTo5,
 		&createdQuestion.RoundScoreStep,
 		&createdQuestion.Rubrics, // Use createdQuestion.Rubrics (plural)
+		&createdQuestion.RubricType,
+		&createdQuestion.EnsembleSamples,
+		&createdQuestion.EnsembleSpreadThreshold,
 		&createdQuestion.CreatedAt,
 		&createdQuestion.UpdatedAt,
 	)
@@ -101,7 +183,7 @@ func (s *EssayQuestionService) CreateEssayQuestion(req *models.CreateEssayQuesti
 // GetEssayQuestionsByMaterialID mengambil semua pertanyaan esai yang terkait dengan materi tertentu.
 func (s *EssayQuestionService) GetEssayQuestionsByMaterialID(materialID string) ([]models.EssayQuestion, error) {
 	query := `
-		SELECT id, material_id, teks_soal, keywords, ideal_answer, weight, round_score_to_5, round_score_step, rubrics, created_at, updated_at
+		SELECT id, material_id, teks_soal, keywords, ideal_answer, weight, round_score_to_5, round_score_step, rubrics, rubric_type, ensemble_samples, ensemble_spread_threshold, created_at, updated_at
 		FROM essay_questions
 		WHERE material_id = $1
 		ORDER BY created_at ASC, id ASC
@@ -116,7 +198,7 @@ func (s *EssayQuestionService) GetEssayQuestionsByMaterialID(materialID string)
 	for rows.Next() {
 		var q models.EssayQuestion
 		var keywords pq.StringArray
-		if err := rows.Scan(&q.ID, &q.MaterialID, &q.TeksSoal, &keywords, &q.IdealAnswer, &q.Weight, &q.RoundScoreTo5, &q.RoundScoreStep, &q.Rubrics, &q.CreatedAt, &q.UpdatedAt); err != nil {
+		if err := rows.Scan(&q.ID, &q.MaterialID, &q.TeksSoal, &keywords, &q.IdealAnswer, &q.Weight, &q.RoundScoreTo5, &q.RoundScoreStep, &q.Rubrics, &q.RubricType, &q.EnsembleSamples, &q.EnsembleSpreadThreshold, &q.CreatedAt, &q.UpdatedAt); err != nil {
 			return nil, fmt.Errorf("error scanning essay question row: %w", err)
 		}
 		if len(keywords) > 0 {
@@ -139,12 +221,12 @@ func (s *EssayQuestionService) GetEssayQuestionsByMaterialID(materialID string)
 func (s *EssayQuestionService) GetEssayQuestionsByMaterialIDForStudent(materialID, studentID string) ([]models.EssayQuestion, error) {
 	query := `
 		SELECT 
-			eq.id, eq.material_id, eq.teks_soal, eq.keywords, eq.ideal_answer, eq.weight, eq.round_score_to_5, eq.round_score_step, eq.rubrics, eq.created_at, eq.updated_at,
+			eq.id, eq.material_id, eq.teks_soal, eq.keywords, eq.ideal_answer, eq.weight, eq.round_score_to_5, eq.round_score_step, eq.rubrics, eq.rubric_type, eq.ensemble_samples, eq.ensemble_spread_threshold, eq.created_at, eq.updated_at,
 			es.id as submission_id, es.attempt_count as submission_attempt_count, es.submitted_at as submission_submitted_at,
 			es.teks_jawaban as student_essay_text, es.ai_grading_status, es.ai_grading_error,
 			ar.skor_ai, ar.umpan_balik_ai,
 			tr.revised_score, tr.teacher_feedback,
-			ar.logs_rag
+			ar.rubric_scores::text, ar.holistic_band::text
 		FROM essay_questions eq
 		LEFT JOIN essay_submissions es ON eq.id = es.soal_id AND es.siswa_id = $2
 		LEFT JOIN ai_results ar ON es.id = ar.submission_id
@@ -162,11 +244,12 @@ func (s *EssayQuestionService) GetEssayQuestionsByMaterialIDForStudent(materialI
 	for rows.Next() {
 		var q models.EssayQuestion
 		var keywords pq.StringArray
-		var logsRAG sql.NullString
+		var rubricScores sql.NullString
+		var holisticBand sql.NullString
 
 		if err := rows.Scan(
-			&q.ID, &q.MaterialID, &q.TeksSoal, &keywords, &q.IdealAnswer, &q.Weight, &q.RoundScoreTo5, &q.RoundScoreStep, &q.Rubrics, &q.CreatedAt, &q.UpdatedAt,
-			&q.SubmissionID, &q.SubmissionAttemptCount, &q.SubmissionSubmittedAt, &q.StudentEssayText, &q.AIGradingStatus, &q.AIGradingError, &q.SkorAI, &q.UmpanBalikAI, &q.RevisedScore, &q.TeacherFeedback, &logsRAG,
+			&q.ID, &q.MaterialID, &q.TeksSoal, &keywords, &q.IdealAnswer, &q.Weight, &q.RoundScoreTo5, &q.RoundScoreStep, &q.Rubrics, &q.RubricType, &q.EnsembleSamples, &q.EnsembleSpreadThreshold, &q.CreatedAt, &q.UpdatedAt,
+			&q.SubmissionID, &q.SubmissionAttemptCount, &q.SubmissionSubmittedAt, &q.StudentEssayText, &q.AIGradingStatus, &q.AIGradingError, &q.SkorAI, &q.UmpanBalikAI, &q.RevisedScore, &q.TeacherFeedback, &rubricScores, &holisticBand,
 		); err != nil {
 			return nil, fmt.Errorf("error scanning essay question row for student: %w", err)
 		}
@@ -174,12 +257,13 @@ func (s *EssayQuestionService) GetEssayQuestionsByMaterialIDForStudent(materialI
 			joined := strings.Join(keywords, ", ")
 			q.Keywords = &joined
 		}
-		if logsRAG.Valid && strings.TrimSpace(logsRAG.String) != "" {
+		if rubricScores.Valid && strings.TrimSpace(rubricScores.String) != "" {
 			var parsedScores []models.GradeEssayAspectScore
-			if unmarshalErr := json.Unmarshal([]byte(logsRAG.String), &parsedScores); unmarshalErr == nil {
+			if unmarshalErr := json.Unmarshal([]byte(rubricScores.String), &parsedScores); unmarshalErr == nil {
 				q.RubricScores = parsedScores
 			}
 		}
+		q.HolisticBand = parseHolisticBand(holisticBand)
 		questions = append(questions, q)
 	}
 	if err = rows.Err(); err != nil {
@@ -224,6 +308,16 @@ func (s *EssayQuestionService) UpdateEssayQuestion(questionID string, req *model
 		args = append(args, sanitizeRoundScoreStep(req.RoundScoreStep))
 		argId++
 	}
+	if req.EnsembleSamples != nil {
+		updates = append(updates, fmt.Sprintf("ensemble_samples = $%d", argId))
+		args = append(args, sanitizeEnsembleSamples(req.EnsembleSamples))
+		argId++
+	}
+	if req.EnsembleSpreadThreshold != nil {
+		updates = append(updates, fmt.Sprintf("ensemble_spread_threshold = $%d", argId))
+		args = append(args, sanitizeEnsembleSpreadThreshold(req.EnsembleSpreadThreshold))
+		argId++
+	}
 	if req.Keywords != nil {
 		updates = append(updates, fmt.Sprintf("keywords = $%d", argId))
 		// Menggunakan pq.Array untuk menyimpan slice string sebagai array di PostgreSQL.
@@ -235,6 +329,29 @@ func (s *EssayQuestionService) UpdateEssayQuestion(questionID string, req *model
 		args = append(args, *req.Rubrics)
 		argId++
 	}
+	if req.RubricType != nil || req.Rubrics != nil {
+		// Tipe rubrik divalidasi bersama rubrik efektif (baru atau yang sudah tersimpan).
+		existing, err := s.GetEssayQuestionByID(questionID)
+		if err != nil {
+			return nil, err
+		}
+		rubricType, rubrics := existing.RubricType, existing.Rubrics
+		if req.RubricType != nil {
+			rubricType = *req.RubricType
+		}
+		if req.Rubrics != nil {
+			rubrics = *req.Rubrics
+		}
+		resolved, err := resolveQuestionRubricType(rubricType, rubrics)
+		if err != nil {
+			return nil, err
+		}
+		if req.RubricType != nil {
+			updates = append(updates, fmt.Sprintf("rubric_type = $%d", argId))
+			args = append(args, resolved)
+			argId++
+		}
+	}
 
 	if len(updates) == 0 {
 		return nil, fmt.Errorf("no fields to update") // Jika tidak ada field yang perlu diupdate.
@@ -261,14 +378,14 @@ func (s *EssayQuestionService) UpdateEssayQuestion(questionID string, req *model
 // GetEssayQuestionByID mengambil satu pertanyaan esai berdasarkan ID-nya.
 func (s *EssayQuestionService) GetEssayQuestionByID(questionID string) (*models.EssayQuestion, error) {
 	query := `
-		SELECT id, material_id, teks_soal, keywords, ideal_answer, weight, round_score_to_5, round_score_step, rubrics, created_at, updated_at
+		SELECT id, material_id, teks_soal, keywords, ideal_answer, weight, round_score_to_5, round_score_step, rubrics, rubric_type, ensemble_samples, ensemble_spread_threshold, created_at, updated_at
 		FROM essay_questions
 		WHERE id = $1
 	`
 	var q models.EssayQuestion
 	var keywords pq.StringArray
 	err := s.db.QueryRowContext(context.Background(), query, questionID).Scan(
-		&q.ID, &q.MaterialID, &q.TeksSoal, &keywords, &q.IdealAnswer, &q.Weight, &q.RoundScoreTo5, &q.RoundScoreStep, &q.Rubrics, &q.CreatedAt, &q.UpdatedAt,
+		&q.ID, &q.MaterialID, &q.TeksSoal, &keywords, &q.IdealAnswer, &q.Weight, &q.RoundScoreTo5, &q.RoundScoreStep, &q.Rubrics, &q.RubricType, &q.EnsembleSamples, &q.EnsembleSpreadThreshold, &q.CreatedAt, &q.UpdatedAt,
 	)
 	if len(keywords) > 0 {
 		joined := strings.Join(keywords, ", ")
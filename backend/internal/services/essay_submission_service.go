@@ -1,6 +1,7 @@
 package services
 
 import (
+	"api-backend/internal/indotext"
 	"api-backend/internal/models" // Mengimpor definisi model yang diperlukan (EssaySubmission, GradeEssayRequest, dll.).
 	"context"                     // Mengimpor package context untuk mengelola batas waktu dan pembatalan operasi DB.
 	"database/sql"                // Mengimpor package database/sql untuk interaksi dengan database.
@@ -43,28 +44,53 @@ type EssaySubmissionService struct {
 	aiService            *AIService            // Referensi ke AIService untuk penilaian AI.
 	essayQuestionService *EssayQuestionService // Referensi ke EssayQuestionService untuk mengambil detail pertanyaan.
 	settingService       *SystemSettingService
-	jobQueue             chan essayGradingJob
-	limiterMu            sync.Mutex
-	lastAIRequestAt      time.Time
-	aiMinInterval        time.Duration
-	cancelMu             sync.RWMutex
-	cancelledJobs        map[string]struct{}
+	similarityService    *SimilarityService // Deteksi jawaban mirip antarsiswa dan terhadap materi.
+	workerID             string             // Identitas worker di grading_jobs.locked_by (host:pid:boot id).
+	visibilityTimeout    time.Duration      // Lama job dipegang worker sebelum boleh diambil worker lain.
+	queueWake            chan struct{}
+	workerCount          int // Jumlah worker grading di proses ini (AI_GRADING_WORKERS).
+	queueCfgMu           sync.Mutex
+	queueCfg             GradingQueueConfig
+	queueCfgLoadedAt     time.Time
+	snapshotMu           sync.Mutex
+	snapshot             *gradingQueueSnapshot // Potret antrean untuk posisi dan ETA siswa.
+	groundingIndex       *GroundingIndex       // Indeks BM25 per kelas untuk konteks grading.
 }
 
 type essayGradingJob struct {
+	JobID         string // ID grading_jobs; kosong untuk grading instan di luar antrean.
 	SubmissionID  string
 	QuestionID    string
 	StudentID     string
 	TeksJawaban   string
 	ScoringMethod string
+	BypassCache   bool // true untuk re-grade paksa agar tidak memakai hasil cache model lama.
+	Interactive   bool // true untuk grading instan yang ditunggu siswa; job antrean memakai prioritas bulk di limiter.
+	// RegradeBatchID diisi untuk re-grade soal setelah rubrik berubah: hasil baru selalu menimpa (mengabaikan
+	// attempt_scoring best) dan skor baru dicatat di question_regrade_items.
+	RegradeBatchID string
+	// ForceReplace diisi untuk re-grade per model: hasil baru selalu menimpa agar ai_results berpindah ke model
+	// baru. Hasil lama diarsipkan ke ai_result_history sebelum ditimpa.
+	ForceReplace bool
+	// LeaseID adalah token klaim grading_jobs; hasil hanya ditulis selama klaim ini masih berlaku.
+	LeaseID string
+}
+
+// requeueOptions mengatur job yang dibuat requeueSubmissions.
+type requeueOptions struct {
+	BypassCache    bool
+	RegradeBatchID string
+	ForceReplace   bool
 }
 
 type groundingCandidate struct {
 	Label string
 	Text  string
-	Score int
 }
 
+// groundingMaxPassages adalah jumlah potongan hasil retrieval yang dimasukkan ke prompt grading.
+const groundingMaxPassages = 4
+
 var ErrAttemptLimitReached = errors.New("attempt limit reached")
 var ErrSectionCardUnread = errors.New("section card not read")
 
@@ -256,12 +282,6 @@ func (s *EssaySubmissionService) shouldRoundScore(questionID string) (bool, floa
 // NewEssaySubmissionService membuat instance baru dari EssaySubmissionService.
 // Menerima koneksi database dan referensi ke AIService serta EssayQuestionService.
 func NewEssaySubmissionService(db *sql.DB, ai *AIService, eqs *EssayQuestionService, settings *SystemSettingService) *EssaySubmissionService {
-	rpmLimit := 5
-	if value := strings.TrimSpace(os.Getenv("GEMINI_LIMIT_RPM")); value != "" {
-		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
-			rpmLimit = parsed
-		}
-	}
 	workers := 1
 	if value := strings.TrimSpace(os.Getenv("AI_GRADING_WORKERS")); value != "" {
 		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
@@ -274,95 +294,25 @@ func NewEssaySubmissionService(db *sql.DB, ai *AIService, eqs *EssayQuestionServ
 		aiService:            ai,
 		essayQuestionService: eqs,
 		settingService:       settings,
-		jobQueue:             make(chan essayGradingJob, 500),
-		aiMinInterval:        time.Minute / time.Duration(rpmLimit),
-		cancelledJobs:        make(map[string]struct{}),
+		similarityService:    NewSimilarityService(db, settings),
+		groundingIndex:       NewGroundingIndex(db),
+		workerID:             gradingWorkerID(),
+		visibilityTimeout:    gradingVisibilityTimeoutFromEnv(),
+		queueWake:            make(chan struct{}, 1),
+		workerCount:          workers,
 	}
 	for i := 0; i < workers; i++ {
 		go svc.runGradingWorker()
 	}
 	go svc.recoverPendingQueue()
+	go svc.runDeferredGradingResumer()
 	return svc
 }
 
-func (s *EssaySubmissionService) recoverPendingQueue() {
-	if s.aiService == nil {
-		log.Printf("WARNING: skipping queue recovery because AI service is unavailable")
-		return
-	}
-
-	rows, err := s.db.QueryContext(
-		context.Background(),
-		`SELECT id, soal_id, siswa_id, teks_jawaban, ai_grading_status
-		 FROM essay_submissions
-		 WHERE submission_type = 'essay'
-		   AND ai_grading_status IN ('queued', 'processing')
-		 ORDER BY submitted_at ASC`,
-	)
-	if err != nil {
-		log.Printf("WARNING: failed to scan pending grading queue on startup: %v", err)
-		return
-	}
-	defer rows.Close()
-
-	recovered := 0
-	failed := 0
-	for rows.Next() {
-		var (
-			job    essayGradingJob
-			status string
-		)
-		if err := rows.Scan(&job.SubmissionID, &job.QuestionID, &job.StudentID, &job.TeksJawaban, &status); err != nil {
-			log.Printf("WARNING: failed to scan pending queue item during startup recovery: %v", err)
-			failed++
-			continue
-		}
-
-		config, cfgErr := s.loadQuizAttemptConfig(job.QuestionID)
-		if cfgErr != nil {
-			log.Printf("WARNING: failed to load attempt config for recovered submission %s: %v", job.SubmissionID, cfgErr)
-			_ = s.updateSubmissionGradingStatus(job.SubmissionID, "failed", "Gagal memulihkan job AI saat startup.", nil)
-			failed++
-			continue
-		}
-		job.ScoringMethod = config.AttemptScoring
-
-		if _, err := s.db.ExecContext(
-			context.Background(),
-			`UPDATE essay_submissions
-			 SET ai_grading_status = 'queued',
-			     ai_grading_error = NULL,
-			     ai_graded_at = NULL
-			 WHERE id = $1`,
-			job.SubmissionID,
-		); err != nil {
-			log.Printf("WARNING: failed to reset recovered submission %s to queued: %v", job.SubmissionID, err)
-			failed++
-			continue
-		}
-		s.clearStopRequest(job.SubmissionID)
-
-		if err := s.enqueueGradingJob(job); err != nil {
-			log.Printf("WARNING: failed to requeue recovered submission %s: %v", job.SubmissionID, err)
-			_ = s.updateSubmissionGradingStatus(job.SubmissionID, "failed", "Recovery queue penuh saat startup.", nil)
-			failed++
-			continue
-		}
-
-		recovered++
-	}
-	if err := rows.Err(); err != nil {
-		log.Printf("WARNING: queue recovery iteration failed: %v", err)
-	}
-	if recovered > 0 || failed > 0 {
-		log.Printf("Queue recovery completed: recovered=%d failed=%d", recovered, failed)
-	}
-}
-
 // CreateEssaySubmission membuat submission esai baru di database dan secara otomatis
 // memicu penilaian AI untuk esai tersebut.
 // Mengembalikan objek EssaySubmission yang baru dibuat dan respons penilaian dari AI.
-func (s *EssaySubmissionService) buildGradeRequest(questionID, teksJawaban string) (*models.GradeEssayRequest, error) {
+func (s *EssaySubmissionService) buildGradeRequest(questionID, submissionID, teksJawaban string) (*models.GradeEssayRequest, error) {
 	// Mengambil detail pertanyaan esai yang terkait untuk digunakan dalam penilaian AI.
 	question, err := s.essayQuestionService.GetEssayQuestionByID(questionID)
 	if err != nil {
@@ -387,24 +337,69 @@ func (s *EssaySubmissionService) buildGradeRequest(questionID, teksJawaban strin
 		IdealAnswer: idealAnswer,
 		Keywords:    keywords,
 		RubricMode:  "effective_question_rubric",
+		RubricType:  question.RubricType,
+		QuestionID:  questionID,
+	}
+	if s.aiService != nil {
+		scope := s.aiService.QuotaService().ScopeForQuestion(questionID)
+		gradeReq.ClassID, gradeReq.TeacherID = scope.ClassID, scope.TeacherID
 	}
 
-	groundingContext, groundingSource, groundingErr := s.buildQuestionGroundingContext(question, teksJawaban)
+	groundingContext, groundingSource, groundingLog, groundingErr := s.buildQuestionGroundingContext(question, teksJawaban)
 	if groundingErr != nil {
 		log.Printf("WARNING: failed to build grounding context for question %s: %v", questionID, groundingErr)
 	} else {
 		gradeReq.GroundingContext = groundingContext
 		gradeReq.GroundingSource = groundingSource
+		gradeReq.GroundingLog = groundingLog
 	}
 
 	// Menangani rubrics (plural) dari question.Rubrics (json.RawMessage)
 	// dan mengubahnya menjadi format yang diharapkan oleh AIService.
-	if len(question.Rubrics) == 0 || string(question.Rubrics) == "null" {
+	transformedRubricAspects, err := questionRubricAspects(question.Rubrics, questionID)
+	if err != nil {
+		return nil, err
+	}
+
+	transformedRubricJSON, err := json.Marshal(transformedRubricAspects)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal transformed rubric for question %s: %w", questionID, err)
+	}
+	gradeReq.Rubric = transformedRubricJSON
+	if s.settingService != nil {
+		if normalization, settingErr := s.settingService.GetScoringNormalization(); settingErr == nil {
+			gradeReq.ScoringNormalization = normalization
+		} else {
+			log.Printf("WARNING: failed to load scoring normalization setting: %v", settingErr)
+		}
+		if ensembleCfg, settingErr := s.settingService.GetEnsembleConfig(); settingErr == nil {
+			gradeReq.EnsembleSamples, gradeReq.EnsembleModels, gradeReq.EnsembleSpreadThreshold = resolveEnsembleSettings(ensembleCfg, question)
+		} else {
+			log.Printf("WARNING: failed to load ensemble grading setting: %v", settingErr)
+		}
+		if exemplarCount, settingErr := s.settingService.GetFewShotExemplarCount(); settingErr != nil {
+			log.Printf("WARNING: failed to load few-shot exemplar setting: %v", settingErr)
+		} else if exemplarCount > 0 {
+			exemplars, exemplarErr := NewQuestionExemplarService(s.db).SelectForGrading(questionID, submissionID, exemplarCount)
+			if exemplarErr != nil {
+				log.Printf("WARNING: failed to load exemplars for question %s: %v", questionID, exemplarErr)
+			} else {
+				gradeReq.Exemplars = exemplars
+			}
+		}
+	}
+	return gradeReq, nil
+}
+
+// questionRubricAspects mengubah essay_questions.rubrics menjadi rubrik terstruktur (aspek, kriteria urut skor, bobot)
+// yang dipakai AIService dan rescoring offline.
+func questionRubricAspects(raw json.RawMessage, questionID string) ([]models.RubricAspect, error) {
+	if len(raw) == 0 || string(raw) == "null" {
 		return nil, fmt.Errorf("rubrics are missing for question %s", questionID)
 	}
 
 	var rubricsFromQuestion []models.Rubric
-	if err := json.Unmarshal(question.Rubrics, &rubricsFromQuestion); err != nil {
+	if err := json.Unmarshal(raw, &rubricsFromQuestion); err != nil {
 		return nil, fmt.Errorf("invalid rubrics format for question %s: %w", questionID, err)
 	}
 	if len(rubricsFromQuestion) == 0 {
@@ -417,7 +412,7 @@ func (s *EssaySubmissionService) buildGradeRequest(questionID, teksJawaban strin
 			continue
 		}
 
-		aspect := models.RubricAspect{Aspek: rubric.NamaAspek}
+		aspect := models.RubricAspect{Aspek: rubric.NamaAspek, Bobot: rubric.Bobot, SkorBand: rubric.SkorBand}
 
 		scores := make([]int, 0, len(rubric.Descriptors))
 		for score := range rubric.Descriptors {
@@ -437,38 +432,26 @@ func (s *EssaySubmissionService) buildGradeRequest(questionID, teksJawaban strin
 	if len(transformedRubricAspects) == 0 {
 		return nil, fmt.Errorf("rubrics have no usable descriptors for question %s", questionID)
 	}
-
-	transformedRubricJSON, err := json.Marshal(transformedRubricAspects)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal transformed rubric for question %s: %w", questionID, err)
-	}
-	gradeReq.Rubric = transformedRubricJSON
-	return gradeReq, nil
+	return transformedRubricAspects, nil
 }
 
-func (s *EssaySubmissionService) buildQuestionGroundingContext(question *models.EssayQuestion, studentAnswer string) (string, string, error) {
-	if question == nil || strings.TrimSpace(question.MaterialID) == "" {
-		return "", "", nil
+// buildQuestionGroundingContext mengambil potongan teratas dari indeks BM25 kelas (materi, section card, modul ajar)
+// untuk soal dan jawaban ini. Log sumber dikembalikan agar dicatat di ai_results.logs_rag.
+func (s *EssaySubmissionService) buildQuestionGroundingContext(question *models.EssayQuestion, studentAnswer string) (string, string, *models.GroundingLog, error) {
+	if question == nil || strings.TrimSpace(question.MaterialID) == "" || s.groundingIndex == nil {
+		return "", "", nil, nil
 	}
 
-	var (
-		classID  string
-		title    string
-		body     sql.NullString
-		outcomes sql.NullString
-		keywords []string
-	)
+	var classID string
 	if err := s.db.QueryRowContext(
 		context.Background(),
-		`SELECT class_id, judul, isi_materi, capaian_pembelajaran, kata_kunci
-		 FROM materials
-		 WHERE id = $1`,
+		"SELECT class_id FROM materials WHERE id = $1",
 		question.MaterialID,
-	).Scan(&classID, &title, &body, &outcomes, pq.Array(&keywords)); err != nil {
+	).Scan(&classID); err != nil {
 		if err == sql.ErrNoRows {
-			return "", "", nil
+			return "", "", nil, nil
 		}
-		return "", "", err
+		return "", "", nil, err
 	}
 
 	queryText := strings.Join([]string{
@@ -478,77 +461,19 @@ func (s *EssaySubmissionService) buildQuestionGroundingContext(question *models.
 		stringOrEmpty(question.Keywords),
 	}, " ")
 
-	candidates := make([]groundingCandidate, 0, 8)
-	if trimmed := strings.TrimSpace(title); trimmed != "" || (body.Valid && strings.TrimSpace(body.String) != "") {
-		textParts := make([]string, 0, 4)
-		if trimmed != "" {
-			textParts = append(textParts, fmt.Sprintf("Judul materi: %s", trimmed))
-		}
-		if outcomes.Valid && strings.TrimSpace(outcomes.String) != "" {
-			textParts = append(textParts, fmt.Sprintf("Capaian pembelajaran: %s", trimToWordLimit(outcomes.String, 60)))
-		}
-		if len(keywords) > 0 {
-			cleanKeywords := make([]string, 0, len(keywords))
-			for _, kw := range keywords {
-				if item := strings.TrimSpace(kw); item != "" {
-					cleanKeywords = append(cleanKeywords, item)
-				}
-			}
-			if len(cleanKeywords) > 0 {
-				textParts = append(textParts, fmt.Sprintf("Kata kunci materi: %s", strings.Join(cleanKeywords, ", ")))
-			}
-		}
-		if body.Valid && strings.TrimSpace(body.String) != "" {
-			textParts = append(textParts, fmt.Sprintf("Ringkasan materi umum: %s", trimToWordLimit(extractRelevantMaterialText(body.String), 180)))
-		}
-		text := strings.TrimSpace(strings.Join(textParts, "\n"))
-		if text != "" {
-			candidates = append(candidates, groundingCandidate{
-				Label: "material_summary",
-				Text:  text,
-				Score: scoreGroundingCandidate(queryText, text) + 1,
-			})
-		}
-	}
-
-	for _, item := range extractSectionCardCandidates(body.String) {
-		candidates = append(candidates, groundingCandidate{
-			Label: item.Label,
-			Text:  item.Text,
-			Score: scoreGroundingCandidate(queryText, item.Text) + 2,
-		})
+	retrieval, err := s.groundingIndex.Search(classID, question.MaterialID, queryText, groundingMaxPassages)
+	if err != nil {
+		return "", "", nil, err
 	}
-
-	_ = classID
-
-	sort.SliceStable(candidates, func(i, j int) bool {
-		if candidates[i].Score == candidates[j].Score {
-			return len(candidates[i].Text) < len(candidates[j].Text)
-		}
-		return candidates[i].Score > candidates[j].Score
-	})
-
-	parts := make([]string, 0, 4)
-	added := 0
-	for _, candidate := range candidates {
-		if strings.TrimSpace(candidate.Text) == "" {
-			continue
-		}
-		if candidate.Score <= 0 && added >= 2 {
-			continue
-		}
-		parts = append(parts, fmt.Sprintf("[%s]\n%s", candidate.Label, trimToWordLimit(candidate.Text, 180)))
-		added++
-		if added >= 4 {
-			break
-		}
+	if retrieval == nil || len(retrieval.Passages) == 0 {
+		return "", "", nil, nil
 	}
 
-	if len(parts) == 0 {
-		return "", "", nil
+	parts := make([]string, 0, len(retrieval.Passages))
+	for _, passage := range retrieval.Passages {
+		parts = append(parts, fmt.Sprintf("[%s]\n%s", passage.Source, trimToWordLimit(passage.Text, 180)))
 	}
-
-	return strings.Join(parts, "\n\n"), "light_rag_material_and_module_context", nil
+	return strings.Join(parts, "\n\n"), "bm25_class_index", retrieval, nil
 }
 
 func stringOrEmpty(value *string) string {
@@ -639,22 +564,19 @@ func extractSectionCardCandidates(raw string) []groundingCandidate {
 	return out
 }
 
-func splitGroundingChunks(value string, maxChars int, maxChunks int) []string {
-	trimmed := compactGroundingSpaces(value)
-	if trimmed == "" || maxChars <= 0 || maxChunks <= 0 {
+// splitGroundingChunks memecah teks menjadi jendela maxWords kata agar potongan tidak terpotong di tengah kata.
+func splitGroundingChunks(value string, maxWords int, maxChunks int) []string {
+	words := strings.Fields(value)
+	if len(words) == 0 || maxWords <= 0 || maxChunks <= 0 {
 		return nil
 	}
-	runes := []rune(trimmed)
 	chunks := make([]string, 0, maxChunks)
-	for start := 0; start < len(runes) && len(chunks) < maxChunks; start += maxChars {
-		end := start + maxChars
-		if end > len(runes) {
-			end = len(runes)
-		}
-		chunk := strings.TrimSpace(string(runes[start:end]))
-		if chunk != "" {
-			chunks = append(chunks, chunk)
+	for start := 0; start < len(words) && len(chunks) < maxChunks; start += maxWords {
+		end := start + maxWords
+		if end > len(words) {
+			end = len(words)
 		}
+		chunks = append(chunks, strings.Join(words[start:end], " "))
 	}
 	return chunks
 }
@@ -663,50 +585,19 @@ func compactGroundingSpaces(value string) string {
 	return strings.Join(strings.Fields(strings.TrimSpace(value)), " ")
 }
 
-func scoreGroundingCandidate(queryText, candidateText string) int {
-	queryTokens := groundingTokens(queryText)
-	if len(queryTokens) == 0 {
-		return 0
-	}
-	candidateTokens := groundingTokens(candidateText)
-	if len(candidateTokens) == 0 {
-		return 0
-	}
-
-	score := 0
-	for token := range queryTokens {
-		if _, ok := candidateTokens[token]; ok {
-			score++
-			if len(token) >= 8 {
-				score++
-			}
-		}
-	}
-	return score
+// groundingTokens memecah teks menjadi term indeks ter-stem (urut, dengan duplikat untuk frekuensi term BM25)
+// agar "menghasilkan" pada jawaban cocok dengan "hasil" pada materi.
+func groundingTokens(value string) []string {
+	return indotext.Terms(value)
 }
 
-func groundingTokens(value string) map[string]struct{} {
-	parts := strings.Fields(strings.ToLower(value))
-	out := make(map[string]struct{}, len(parts))
-	for _, part := range parts {
-		token := strings.Trim(part, ".,:;!?()[]{}\"'`/\\|+-_=*&^%$#@~")
-		if len(token) < 3 {
-			continue
-		}
-		if _, isStop := groundingStopWords[token]; isStop {
-			continue
-		}
-		out[token] = struct{}{}
-	}
-	return out
-}
-
-var groundingStopWords = map[string]struct{}{
-	"dan": {}, "yang": {}, "untuk": {}, "atau": {}, "dari": {}, "pada": {}, "dengan": {}, "dalam": {}, "karena": {}, "adalah": {},
-	"the": {}, "and": {}, "for": {}, "with": {}, "this": {}, "that": {}, "are": {}, "was": {}, "were": {}, "from": {},
+func (s *EssaySubmissionService) CreateEssaySubmission(questionID, studentID, teksJawaban string) (*models.EssaySubmission, *models.GradeEssayResponse, error) {
+	return s.createEssaySubmission(questionID, studentID, teksJawaban, nil)
 }
 
-func (s *EssaySubmissionService) CreateEssaySubmission(questionID, studentID, teksJawaban string) (*models.EssaySubmission, *models.GradeEssayResponse, error) {
+// createEssaySubmission menyimpan attempt baru dan mengantrekan penilaian AI. attachmentUploadID menautkan
+// attempt ke file jawaban yang diunggah; nil berarti jawaban diketik langsung (lampiran lama dilepas).
+func (s *EssaySubmissionService) createEssaySubmission(questionID, studentID, teksJawaban string, attachmentUploadID *string) (*models.EssaySubmission, *models.GradeEssayResponse, error) {
 	isTaskSubmission, err := s.isTaskQuestion(questionID)
 	if err != nil {
 		return nil, nil, err
@@ -752,8 +643,8 @@ func (s *EssaySubmissionService) CreateEssaySubmission(questionID, studentID, te
 	case getExistingErr == sql.ErrNoRows:
 		err = s.db.QueryRowContext(
 			context.Background(),
-			`INSERT INTO essay_submissions (soal_id, siswa_id, submission_type, teks_jawaban, submitted_at, ai_grading_status, attempt_count)
-			 VALUES ($1, $2, $3, $4, $5, $6, 1)
+			`INSERT INTO essay_submissions (soal_id, siswa_id, submission_type, teks_jawaban, submitted_at, ai_grading_status, attempt_count, attachment_upload_id)
+			 VALUES ($1, $2, $3, $4, $5, $6, 1, $7)
 			 RETURNING id`,
 			newSubmission.QuestionID,
 			newSubmission.StudentID,
@@ -761,6 +652,7 @@ func (s *EssaySubmissionService) CreateEssaySubmission(questionID, studentID, te
 			newSubmission.TeksJawaban,
 			newSubmission.SubmittedAt,
 			newSubmission.AIGradingStatus,
+			attachmentUploadID,
 		).Scan(&newSubmission.ID)
 		if err != nil {
 			return nil, nil, fmt.Errorf("error inserting new essay submission: %w", err)
@@ -789,13 +681,17 @@ func (s *EssaySubmissionService) CreateEssaySubmission(questionID, studentID, te
 			     ai_grading_status = $4,
 			     ai_grading_error = NULL,
 			     ai_graded_at = NULL,
-			     attempt_count = COALESCE(attempt_count, 1) + 1
+			     needs_teacher_review = FALSE,
+			     needs_review_reason = NULL,
+			     attempt_count = COALESCE(attempt_count, 1) + 1,
+			     attachment_upload_id = $6
 			 WHERE id = $5`,
 			newSubmission.SubmissionType,
 			newSubmission.TeksJawaban,
 			newSubmission.SubmittedAt,
 			newSubmission.AIGradingStatus,
 			newSubmission.ID,
+			attachmentUploadID,
 		); err != nil {
 			return nil, nil, fmt.Errorf("error updating essay submission attempt: %w", err)
 		}
@@ -810,6 +706,7 @@ func (s *EssaySubmissionService) CreateEssaySubmission(questionID, studentID, te
 			}
 		}
 	}
+	s.similarityService.ScheduleCheck(newSubmission.ID)
 	if isTaskSubmission {
 		return newSubmission, nil, nil
 	}
@@ -827,6 +724,7 @@ func (s *EssaySubmissionService) CreateEssaySubmission(questionID, studentID, te
 		ScoringMethod: config.AttemptScoring,
 	}
 	if s.shouldUseInstantGrading() {
+		job.Interactive = true
 		if gradeResp, gradeErr := s.gradeJob(job); gradeErr != nil {
 			log.Printf("WARNING: instant AI grading failed for submission %s: %v", newSubmission.ID, gradeErr)
 			return newSubmission, nil, nil
@@ -834,57 +732,17 @@ func (s *EssaySubmissionService) CreateEssaySubmission(questionID, studentID, te
 			return newSubmission, gradeResp, nil
 		}
 	}
-	select {
-	case s.jobQueue <- job:
-	default:
-		_ = s.updateSubmissionGradingStatus(newSubmission.ID, "failed", "Grading queue penuh. Coba lagi beberapa saat.", nil)
-		return newSubmission, nil, fmt.Errorf("grading queue is full")
+	if err := s.enqueueGradingJob(job); err != nil {
+		msg := "Gagal memasukkan job ke antrean grading."
+		if errors.Is(err, ErrGradingQueueFull) {
+			msg = "Grading queue penuh. Coba lagi beberapa saat."
+		}
+		_ = s.updateSubmissionGradingStatus(newSubmission.ID, "failed", msg, nil)
+		return newSubmission, nil, err
 	}
 	return newSubmission, nil, nil
 }
 
-func (s *EssaySubmissionService) markStopRequested(submissionID string) {
-	if strings.TrimSpace(submissionID) == "" {
-		return
-	}
-	s.cancelMu.Lock()
-	defer s.cancelMu.Unlock()
-	s.cancelledJobs[submissionID] = struct{}{}
-}
-
-func (s *EssaySubmissionService) clearStopRequest(submissionID string) {
-	if strings.TrimSpace(submissionID) == "" {
-		return
-	}
-	s.cancelMu.Lock()
-	defer s.cancelMu.Unlock()
-	delete(s.cancelledJobs, submissionID)
-}
-
-func (s *EssaySubmissionService) isStopRequested(submissionID string) bool {
-	if strings.TrimSpace(submissionID) == "" {
-		return false
-	}
-	s.cancelMu.RLock()
-	defer s.cancelMu.RUnlock()
-	_, ok := s.cancelledJobs[submissionID]
-	return ok
-}
-
-func (s *EssaySubmissionService) waitRateSlot() {
-	s.limiterMu.Lock()
-	defer s.limiterMu.Unlock()
-	if s.aiMinInterval <= 0 {
-		return
-	}
-	now := time.Now()
-	nextAllowed := s.lastAIRequestAt.Add(s.aiMinInterval)
-	if now.Before(nextAllowed) {
-		time.Sleep(nextAllowed.Sub(now))
-	}
-	s.lastAIRequestAt = time.Now()
-}
-
 func (s *EssaySubmissionService) shouldUseInstantGrading() bool {
 	if s.settingService == nil {
 		return false
@@ -899,45 +757,69 @@ func (s *EssaySubmissionService) shouldUseInstantGrading() bool {
 
 func (s *EssaySubmissionService) gradeJob(job essayGradingJob) (*models.GradeEssayResponse, error) {
 	if s.isStopRequested(job.SubmissionID) {
-		_ = s.updateSubmissionGradingStatus(job.SubmissionID, "failed", "Dihentikan admin sebelum grading dimulai.", nil)
-		return nil, errors.New("grading stopped by admin")
+		_, _ = s.updateJobGradingStatus(job, "failed", "Dihentikan admin sebelum grading dimulai.", nil)
+		return nil, errGradingStopped
 	}
-	if err := s.updateSubmissionGradingStatus(job.SubmissionID, "processing", "", nil); err != nil {
+	if _, err := s.updateJobGradingStatus(job, "processing", "", nil); err != nil {
 		log.Printf("WARNING: failed to set processing status for %s: %v", job.SubmissionID, err)
 	}
 
-	gradeReq, err := s.buildGradeRequest(job.QuestionID, job.TeksJawaban)
+	// Jawaban yang berisi instruksi ke model diperiksa sebelum prompt dibuat.
+	injectionMatches, held := s.screenPromptInjection(job)
+	// Sidik jari kemiripan hanya dihitung ulang bila teks berubah sejak pemeriksaan terakhir.
+	s.similarityService.ScheduleCheck(job.SubmissionID)
+	if held {
+		return nil, nil
+	}
+
+	gradeReq, err := s.buildGradeRequest(job.QuestionID, job.SubmissionID, job.TeksJawaban)
 	if err != nil {
-		_ = s.updateSubmissionGradingStatus(job.SubmissionID, "failed", err.Error(), nil)
+		_, _ = s.updateJobGradingStatus(job, "failed", err.Error(), nil)
 		return nil, err
 	}
+	gradeReq.BypassCache = job.BypassCache
+	gradeReq.Bulk = !job.Interactive
 
 	if s.aiService == nil {
 		msg := "AI service is unavailable"
-		_ = s.updateSubmissionGradingStatus(job.SubmissionID, "failed", msg, nil)
+		_, _ = s.updateJobGradingStatus(job, "failed", msg, nil)
 		return nil, errors.New(msg)
 	}
 
 	var gradeResp *models.GradeEssayResponse
 	var lastErr error
 	backoffs := []time.Duration{0, 2 * time.Second, 5 * time.Second}
+	if job.JobID != "" {
+		// Job antrean dicoba sekali per klaim; retry berikutnya dijadwalkan lewat grading_jobs dengan backoff.
+		backoffs = backoffs[:1]
+	}
 	for _, delay := range backoffs {
 		if s.isStopRequested(job.SubmissionID) {
-			_ = s.updateSubmissionGradingStatus(job.SubmissionID, "failed", "Dihentikan admin saat menunggu proses AI.", nil)
-			return nil, errors.New("grading stopped by admin")
+			_, _ = s.updateJobGradingStatus(job, "failed", "Dihentikan admin saat menunggu proses AI.", nil)
+			return nil, errGradingStopped
 		}
 		if delay > 0 {
 			time.Sleep(delay)
 		}
-		s.waitRateSlot()
+		// Jeda RPM/TPM diatur limiter bersama di AIService.
 		gradeResp, lastErr = s.aiService.GradeEssay(*gradeReq)
 		if lastErr == nil {
 			break
 		}
+		// Output yang tetap melanggar rubrik setelah re-prompt perbaikan tidak diulang lagi di sini.
+		var validationErr *AIOutputValidationError
+		if errors.As(lastErr, &validationErr) {
+			break
+		}
+		// Kuota habis tidak pulih dalam hitungan detik; ditangani sesuai setting di bawah.
+		var quotaErr *AIQuotaExceededError
+		if errors.As(lastErr, &quotaErr) {
+			return s.handleGradingQuotaExceeded(job, quotaErr)
+		}
 	}
 	if s.isStopRequested(job.SubmissionID) {
-		_ = s.updateSubmissionGradingStatus(job.SubmissionID, "failed", "Dihentikan admin saat proses grading berjalan.", nil)
-		return nil, errors.New("grading stopped by admin")
+		_, _ = s.updateJobGradingStatus(job, "failed", "Dihentikan admin saat proses grading berjalan.", nil)
+		return nil, errGradingStopped
 	}
 
 	if lastErr != nil || gradeResp == nil {
@@ -945,7 +827,7 @@ func (s *EssaySubmissionService) gradeJob(job essayGradingJob) (*models.GradeEss
 		if lastErr != nil {
 			errMsg = lastErr.Error()
 		}
-		_ = s.updateSubmissionGradingStatus(job.SubmissionID, "failed", errMsg, nil)
+		_, _ = s.updateJobGradingStatus(job, "failed", errMsg, nil)
 		return nil, errors.New(errMsg)
 	}
 
@@ -956,82 +838,276 @@ func (s *EssaySubmissionService) gradeJob(job essayGradingJob) (*models.GradeEss
 	if shouldRound, roundStep := s.shouldRoundScore(job.QuestionID); shouldRound {
 		skorAI = roundToNearestStep(skorAI, roundStep)
 		gradeResp.Score = strconv.FormatFloat(skorAI, 'f', -1, 64)
+		if gradeResp.ScoringBreakdown != nil {
+			step := roundStep
+			gradeResp.ScoringBreakdown.RoundingStep = &step
+		}
 	}
 	feedbackAI := gradeResp.Feedback
 	var logsRAG *string
 	var rubricScores *string
+	var scoringFormula *string
+	var scoringDetails *string
+	if gradeResp.ScoringBreakdown != nil {
+		gradeResp.ScoringBreakdown.FinalScore = skorAI
+		gradeResp.ScoringFormula = scoringFormulaLabel(gradeResp.ScoringBreakdown)
+		if detailsJSON, marshalErr := json.Marshal(gradeResp.ScoringBreakdown); marshalErr == nil {
+			text := string(detailsJSON)
+			scoringDetails = &text
+		}
+	}
+	if strings.TrimSpace(gradeResp.ScoringFormula) != "" {
+		formula := gradeResp.ScoringFormula
+		scoringFormula = &formula
+	}
 	if len(gradeResp.AspectScores) > 0 {
 		if aspectJSON, marshalErr := json.Marshal(gradeResp.AspectScores); marshalErr == nil {
 			text := string(aspectJSON)
-			logsRAG = &text
 			rubricScores = &text
 		}
 	}
+	// logs_rag hanya memuat jejak retrieval (sumber potongan grounding), bukan salinan skor aspek.
+	if gradeReq.GroundingLog != nil {
+		if logJSON, marshalErr := json.Marshal(gradeReq.GroundingLog); marshalErr == nil {
+			text := string(logJSON)
+			logsRAG = &text
+		}
+	}
+	var aspectEvidence *string
+	if len(gradeResp.AspectEvidence) > 0 {
+		if evidenceJSON, marshalErr := json.Marshal(gradeResp.AspectEvidence); marshalErr == nil {
+			text := string(evidenceJSON)
+			aspectEvidence = &text
+		}
+	}
+	// Rubrik holistik hanya menyimpan band terpilih; rubric_scores dibiarkan kosong.
+	var holisticBand *string
+	if gradeResp.Holistic != nil {
+		if bandJSON, marshalErr := json.Marshal(gradeResp.Holistic); marshalErr == nil {
+			text := string(bandJSON)
+			holisticBand = &text
+		}
+	}
+	var scoreSpread *float64
+	var ensembleDetails *string
+	needsReview := false
+	needsReviewReason := ""
+	if gradeResp.Ensemble != nil {
+		spread := gradeResp.Ensemble.ScoreSpread
+		scoreSpread = &spread
+		if detailsJSON, marshalErr := json.Marshal(gradeResp.Ensemble); marshalErr == nil {
+			text := string(detailsJSON)
+			ensembleDetails = &text
+		}
+		needsReview = gradeResp.Ensemble.NeedsReview
+		needsReviewReason = gradeResp.Ensemble.ReviewReason
+	}
+	if len(injectionMatches) > 0 {
+		needsReview = true
+		if needsReviewReason != "" {
+			needsReviewReason += "; "
+		}
+		needsReviewReason += promptInjectionReviewReason(injectionMatches, false)
+	}
+	provenance := gradeResp.Provenance
+	if provenance == nil {
+		provenance = &models.GradingProvenance{}
+	}
+	var generationParams *string
+	if len(provenance.GenerationParams) > 0 {
+		if paramsJSON, marshalErr := json.Marshal(provenance.GenerationParams); marshalErr == nil {
+			text := string(paramsJSON)
+			generationParams = &text
+		}
+	}
 
-	if strings.TrimSpace(job.ScoringMethod) == "best" {
+	// Penulisan hasil dilakukan dalam satu transaksi yang mengunci submission dan job, sehingga worker yang
+	// attempt-nya sudah digantikan tidak bisa menimpa hasil attempt yang lebih baru.
+	tx, err := s.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		_, _ = s.updateJobGradingStatus(job, "failed", err.Error(), nil)
+		return gradeResp, err
+	}
+	defer tx.Rollback()
+	failWrite := func(writeErr error) {
+		// Rollback dulu agar update status tidak menunggu kunci baris milik transaksi ini.
+		_ = tx.Rollback()
+		_, _ = s.updateJobGradingStatus(job, "failed", writeErr.Error(), nil)
+	}
+	if err := lockGradingResultWrite(tx, job); err != nil {
+		if errors.Is(err, errGradingSuperseded) {
+			return gradeResp, err
+		}
+		failWrite(err)
+		return gradeResp, err
+	}
+
+	if strings.TrimSpace(job.ScoringMethod) == "best" && job.RegradeBatchID == "" && !job.ForceReplace {
 		var prevScore sql.NullFloat64
-		if err := s.db.QueryRowContext(
+		if err := tx.QueryRowContext(
 			context.Background(),
 			"SELECT skor_ai FROM ai_results WHERE submission_id = $1",
 			job.SubmissionID,
 		).Scan(&prevScore); err != nil && err != sql.ErrNoRows {
-			_ = s.updateSubmissionGradingStatus(job.SubmissionID, "failed", err.Error(), nil)
+			failWrite(err)
 			return gradeResp, err
 		}
 		if prevScore.Valid && prevScore.Float64 >= skorAI {
 			gradedAt := time.Now()
-			_ = s.updateSubmissionGradingStatus(job.SubmissionID, "completed", "", &gradedAt)
+			if err := markSubmissionGradedTx(tx, job.SubmissionID, gradedAt); err != nil {
+				failWrite(err)
+				return gradeResp, err
+			}
+			if err := tx.Commit(); err != nil {
+				failWrite(err)
+				return gradeResp, err
+			}
 			return gradeResp, nil
 		}
 	}
 
-	if _, insertErr := s.db.ExecContext(
+	if job.ForceReplace {
+		if _, archiveErr := tx.ExecContext(
+			context.Background(),
+			`INSERT INTO ai_result_history (
+				submission_id, skor_ai, umpan_balik_ai, logs_rag, rubric_scores, aspect_evidence,
+				scoring_formula, scoring_details, score_spread, ensemble_details, prompt_template_version,
+				ai_provider, model_name, prompt_hash, generated_at, holistic_band
+			 )
+			 SELECT submission_id, skor_ai, umpan_balik_ai, logs_rag, rubric_scores, aspect_evidence,
+			        scoring_formula, scoring_details, score_spread, ensemble_details, prompt_template_version,
+			        ai_provider, model_name, prompt_hash, generated_at, holistic_band
+			 FROM ai_results
+			 WHERE submission_id = $1`,
+			job.SubmissionID,
+		); archiveErr != nil {
+			failWrite(archiveErr)
+			return gradeResp, fmt.Errorf("failed to archive previous AI result: %w", archiveErr)
+		}
+	}
+
+	if _, insertErr := tx.ExecContext(
 		context.Background(),
-		`INSERT INTO ai_results (submission_id, skor_ai, umpan_balik_ai, logs_rag, rubric_scores, generated_at)
-			 VALUES ($1, $2, $3, $4, $5, $6)
+		`INSERT INTO ai_results (submission_id, skor_ai, umpan_balik_ai, logs_rag, rubric_scores, aspect_evidence, scoring_formula, scoring_details, score_spread, ensemble_details, generated_at, prompt_template_version,
+			     ai_provider, model_name, prompt_hash, generation_params, prompt_tokens, candidates_tokens, total_tokens, raw_response, from_cache, holistic_band)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22)
 			 ON CONFLICT (submission_id) DO UPDATE
 			 SET skor_ai = EXCLUDED.skor_ai,
 			     umpan_balik_ai = EXCLUDED.umpan_balik_ai,
 			     logs_rag = EXCLUDED.logs_rag,
 			     rubric_scores = EXCLUDED.rubric_scores,
-			     generated_at = EXCLUDED.generated_at`,
+			     aspect_evidence = EXCLUDED.aspect_evidence,
+			     scoring_formula = EXCLUDED.scoring_formula,
+			     scoring_details = EXCLUDED.scoring_details,
+			     score_spread = EXCLUDED.score_spread,
+			     ensemble_details = EXCLUDED.ensemble_details,
+			     generated_at = EXCLUDED.generated_at,
+			     prompt_template_version = EXCLUDED.prompt_template_version,
+			     ai_provider = EXCLUDED.ai_provider,
+			     model_name = EXCLUDED.model_name,
+			     prompt_hash = EXCLUDED.prompt_hash,
+			     generation_params = EXCLUDED.generation_params,
+			     prompt_tokens = EXCLUDED.prompt_tokens,
+			     candidates_tokens = EXCLUDED.candidates_tokens,
+			     total_tokens = EXCLUDED.total_tokens,
+			     raw_response = EXCLUDED.raw_response,
+			     from_cache = EXCLUDED.from_cache,
+			     holistic_band = EXCLUDED.holistic_band`,
 		job.SubmissionID,
 		skorAI,
 		feedbackAI,
 		logsRAG,
 		rubricScores,
+		aspectEvidence,
+		scoringFormula,
+		scoringDetails,
+		scoreSpread,
+		ensembleDetails,
 		time.Now(),
+		nullIfEmpty(gradeResp.PromptTemplateVersion),
+		nullIfEmpty(provenance.Provider),
+		nullIfEmpty(provenance.Model),
+		nullIfEmpty(provenance.PromptHash),
+		generationParams,
+		provenance.PromptTokens,
+		provenance.CandidateTokens,
+		provenance.TotalTokens,
+		nullIfEmpty(provenance.RawResponse),
+		provenance.FromCache,
+		holisticBand,
 	); insertErr != nil {
-		_ = s.updateSubmissionGradingStatus(job.SubmissionID, "failed", insertErr.Error(), nil)
+		failWrite(insertErr)
 		return gradeResp, insertErr
 	}
-
 	gradedAt := time.Now()
-	_ = s.updateSubmissionGradingStatus(job.SubmissionID, "completed", "", &gradedAt)
+	if err := markSubmissionGradedTx(tx, job.SubmissionID, gradedAt); err != nil {
+		failWrite(err)
+		return gradeResp, err
+	}
+	if err := tx.Commit(); err != nil {
+		failWrite(err)
+		return gradeResp, err
+	}
+	if err := s.setSubmissionReviewFlag(job.SubmissionID, needsReview, needsReviewReason); err != nil {
+		log.Printf("WARNING: failed to update review flag for %s: %v", job.SubmissionID, err)
+	}
+	if job.RegradeBatchID != "" {
+		s.recordRegradeResult(job.RegradeBatchID, job.SubmissionID, skorAI)
+	}
 	s.clearStopRequest(job.SubmissionID)
 
 	return gradeResp, nil
 }
 
-func (s *EssaySubmissionService) runGradingWorker() {
-	for job := range s.jobQueue {
-		if s.isStopRequested(job.SubmissionID) {
-			_ = s.updateSubmissionGradingStatus(job.SubmissionID, "failed", "Dihentikan admin sebelum diproses worker.", nil)
-			continue
-		}
-		if _, err := s.gradeJob(job); err != nil {
-			log.Printf("WARNING: AI grading worker failed for submission %s: %v", job.SubmissionID, err)
+// lockGradingResultWrite mengunci baris submission (dan job antrean bila ada) sebelum ai_results ditulis.
+// errGradingSuperseded dikembalikan bila teks jawaban sudah berubah karena siswa mengirim ulang, atau klaim
+// job sudah tidak berlaku karena job di-enqueue ulang selama worker ini menunggu AI.
+func lockGradingResultWrite(tx *sql.Tx, job essayGradingJob) error {
+	var currentText string
+	if err := tx.QueryRowContext(
+		context.Background(),
+		`SELECT teks_jawaban FROM essay_submissions WHERE id = $1 FOR UPDATE`,
+		job.SubmissionID,
+	).Scan(&currentText); err != nil {
+		if err == sql.ErrNoRows {
+			return errGradingSuperseded
 		}
+		return err
 	}
-}
-
-func (s *EssaySubmissionService) enqueueGradingJob(job essayGradingJob) error {
-	select {
-	case s.jobQueue <- job:
+	if currentText != job.TeksJawaban {
+		return errGradingSuperseded
+	}
+	if job.JobID == "" {
 		return nil
-	default:
-		return errors.New("grading queue is full")
 	}
+	var jobID string
+	err := tx.QueryRowContext(
+		context.Background(),
+		`SELECT id FROM grading_jobs
+		 WHERE id = $1 AND lease_id = NULLIF($2, '')::uuid AND status = 'processing'
+		 FOR UPDATE`,
+		job.JobID,
+		job.LeaseID,
+	).Scan(&jobID)
+	if err == sql.ErrNoRows {
+		return errGradingSuperseded
+	}
+	return err
+}
+
+// markSubmissionGradedTx menandai submission selesai dinilai di dalam transaksi penulisan hasil.
+func markSubmissionGradedTx(tx *sql.Tx, submissionID string, gradedAt time.Time) error {
+	_, err := tx.ExecContext(
+		context.Background(),
+		`UPDATE essay_submissions
+		 SET ai_grading_status = 'completed',
+		     ai_grading_error = NULL,
+		     ai_graded_at = $2
+		 WHERE id = $1`,
+		submissionID,
+		gradedAt,
+	)
+	return err
 }
 
 func (s *EssaySubmissionService) updateSubmissionGradingStatus(submissionID, status, errMsg string, gradedAt *time.Time) error {
@@ -1050,10 +1126,63 @@ func (s *EssaySubmissionService) updateSubmissionGradingStatus(submissionID, sta
 	return err
 }
 
+// updateJobGradingStatus seperti updateSubmissionGradingStatus, tetapi hanya menulis selama attempt job masih
+// berlaku (lihat gradingAttemptGuard). applied=false berarti attempt ini sudah digantikan dan status dibiarkan.
+func (s *EssaySubmissionService) updateJobGradingStatus(job essayGradingJob, status, errMsg string, gradedAt *time.Time) (bool, error) {
+	res, err := s.db.ExecContext(
+		context.Background(),
+		`UPDATE essay_submissions es
+		 SET ai_grading_status = $1,
+		     ai_grading_error = NULLIF($2, ''),
+		     ai_graded_at = $3
+		 WHERE es.id = $4
+		   AND `+gradingAttemptGuard(5),
+		status,
+		errMsg,
+		gradedAt,
+		job.SubmissionID,
+		job.TeksJawaban,
+		job.JobID,
+		job.LeaseID,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, _ := res.RowsAffected()
+	return affected > 0, nil
+}
+
+// gradingAttemptGuard adalah syarat WHERE (alias es untuk essay_submissions) agar worker yang attempt-nya
+// sudah digantikan tidak menimpa status attempt baru: teks jawaban masih sama dan, untuk job antrean, klaim
+// job masih berlaku. Placeholder dimulai dari $n: teks jawaban, id job (kosong untuk grading instan), lease id.
+func gradingAttemptGuard(n int) string {
+	return fmt.Sprintf(`es.teks_jawaban = $%d
+		   AND ($%d = '' OR EXISTS (
+			SELECT 1 FROM grading_jobs gj
+			WHERE gj.id::text = $%d AND gj.lease_id = NULLIF($%d, '')::uuid AND gj.status = 'processing'
+		   ))`, n, n+1, n+1, n+2)
+}
+
+// setSubmissionReviewFlag menandai (atau membersihkan) status "perlu review guru" pada submission.
+func (s *EssaySubmissionService) setSubmissionReviewFlag(submissionID string, needsReview bool, reason string) error {
+	_, err := s.db.ExecContext(
+		context.Background(),
+		`UPDATE essay_submissions
+		 SET needs_teacher_review = $1,
+		     needs_review_reason = NULLIF($2, '')
+		 WHERE id = $3`,
+		needsReview,
+		reason,
+		submissionID,
+	)
+	return err
+}
+
 // GetEssaySubmissionByID mengambil satu submission esai berdasarkan ID-nya.
 func (s *EssaySubmissionService) GetEssaySubmissionByID(submissionID string) (*models.EssaySubmission, error) {
 	query := `
-		SELECT id, soal_id, siswa_id, submission_type, COALESCE(attempt_count, 1), teks_jawaban, submitted_at, ai_grading_status, ai_grading_error, ai_graded_at
+		SELECT id, soal_id, siswa_id, submission_type, COALESCE(attempt_count, 1), teks_jawaban, submitted_at, ai_grading_status, ai_grading_error, ai_graded_at,
+			COALESCE(needs_teacher_review, FALSE), needs_review_reason
 		FROM essay_submissions
 		WHERE id = $1
 	`
@@ -1061,6 +1190,7 @@ func (s *EssaySubmissionService) GetEssaySubmissionByID(submissionID string) (*m
 	var es models.EssaySubmission
 	err := s.db.QueryRow(query, submissionID).Scan(
 		&es.ID, &es.QuestionID, &es.StudentID, &es.SubmissionType, &es.AttemptCount, &es.TeksJawaban, &es.SubmittedAt, &es.AIGradingStatus, &es.AIGradingError, &es.AIGradedAt,
+		&es.NeedsReview, &es.NeedsReviewNote,
 	)
 
 	if err != nil {
@@ -1069,6 +1199,8 @@ func (s *EssaySubmissionService) GetEssaySubmissionByID(submissionID string) (*m
 		}
 		return nil, fmt.Errorf("error querying essay submission %s: %w", submissionID, err)
 	}
+	s.attachQueueETA([]*models.EssaySubmission{&es})
+	s.attachSubmissionAttachments([]*models.EssaySubmission{&es})
 
 	return &es, nil
 }
@@ -1081,7 +1213,9 @@ func (s *EssaySubmissionService) GetEssaySubmissionsByQuestionID(questionID stri
             u.nama_lengkap AS student_name, u.email AS student_email,
             ar.skor_ai, ar.umpan_balik_ai,
             tr.id AS review_id, tr.revised_score, tr.teacher_feedback,
-			ar.logs_rag
+			ar.rubric_scores::text, ar.holistic_band::text,
+			COALESCE(es.needs_teacher_review, FALSE), es.needs_review_reason, ar.score_spread,
+			COALESCE(tr.needs_recheck, FALSE)
 		FROM essay_submissions es
         JOIN users u ON u.id = es.siswa_id
         LEFT JOIN ai_results ar ON es.id = ar.submission_id
@@ -1104,13 +1238,16 @@ func (s *EssaySubmissionService) GetEssaySubmissionsByQuestionID(questionID stri
 		var reviewID sql.NullString
 		var revisedScoreDB sql.NullFloat64   // Use a distinct name for scanning
 		var teacherFeedbackDB sql.NullString // Use a distinct name for scanning
-		var logsRAG sql.NullString
+		var rubricScores sql.NullString
+		var holisticBand sql.NullString
 		if err := rows.Scan(
 			&es.ID, &es.QuestionID, &es.StudentID, &es.SubmissionType, &es.AttemptCount, &es.TeksJawaban, &es.SubmittedAt,
 			&es.AIGradingStatus, &es.AIGradingError, &es.AIGradedAt,
 			&es.StudentName, &es.StudentEmail,
 			&skorAI, &umpanBalikAI,
-			&reviewID, &revisedScoreDB, &teacherFeedbackDB, &logsRAG,
+			&reviewID, &revisedScoreDB, &teacherFeedbackDB, &rubricScores, &holisticBand,
+			&es.NeedsReview, &es.NeedsReviewNote, &es.ScoreSpread,
+			&es.ReviewRecheck,
 		); err != nil {
 			return nil, fmt.Errorf("error scanning essay submission row: %w", err)
 		}
@@ -1130,12 +1267,13 @@ func (s *EssaySubmissionService) GetEssaySubmissionsByQuestionID(questionID stri
 		if teacherFeedbackDB.Valid {
 			es.TeacherFeedback = &teacherFeedbackDB.String
 		}
-		if logsRAG.Valid && strings.TrimSpace(logsRAG.String) != "" {
+		if rubricScores.Valid && strings.TrimSpace(rubricScores.String) != "" {
 			var parsedScores []models.GradeEssayAspectScore
-			if unmarshalErr := json.Unmarshal([]byte(logsRAG.String), &parsedScores); unmarshalErr == nil {
+			if unmarshalErr := json.Unmarshal([]byte(rubricScores.String), &parsedScores); unmarshalErr == nil {
 				es.RubricScores = parsedScores
 			}
 		}
+		es.HolisticBand = parseHolisticBand(holisticBand)
 		submissions = append(submissions, es)
 	}
 	if err := rows.Err(); err != nil {
@@ -1145,6 +1283,11 @@ func (s *EssaySubmissionService) GetEssaySubmissionsByQuestionID(questionID stri
 	if submissions == nil {
 		submissions = []models.EssaySubmission{}
 	}
+	refs := make([]*models.EssaySubmission, 0, len(submissions))
+	for i := range submissions {
+		refs = append(refs, &submissions[i])
+	}
+	s.attachSubmissionAttachments(refs)
 
 	return submissions, nil
 }
@@ -1178,8 +1321,9 @@ func (s *EssaySubmissionService) ListMaterialStudentSubmissionSummaries(material
 				u.email AS student_email,
 				COUNT(es.id)::int AS total_submissions,
 				SUM(CASE WHEN tr.revised_score IS NOT NULL OR COALESCE(TRIM(tr.teacher_feedback), '') <> '' THEN 1 ELSE 0 END)::int AS reviewed_submissions,
+				SUM(CASE WHEN es.needs_teacher_review AND NOT (tr.revised_score IS NOT NULL OR COALESCE(TRIM(tr.teacher_feedback), '') <> '') THEN 1 ELSE 0 END)::int AS needs_review_submissions,
 				MAX(es.submitted_at) AS latest_submitted_at,
-				AVG(COALESCE(tr.revised_score, ar.skor_ai)) AS average_final_score
+				` + weightedFinalScoreAvgSQL + ` AS average_final_score
 			FROM essay_submissions es
 			JOIN essay_questions eq ON eq.id = es.soal_id
 			JOIN materials m ON m.id = eq.material_id
@@ -1212,6 +1356,8 @@ func (s *EssaySubmissionService) ListMaterialStudentSubmissionSummaries(material
 		orderBy = "(total_submissions - reviewed_submissions) DESC, latest_submitted_at DESC"
 	case "pending_asc":
 		orderBy = "(total_submissions - reviewed_submissions) ASC, latest_submitted_at DESC"
+	case "needs_review_desc":
+		orderBy = "needs_review_submissions DESC, latest_submitted_at DESC"
 	}
 
 	offset := (page - 1) * size
@@ -1223,6 +1369,7 @@ func (s *EssaySubmissionService) ListMaterialStudentSubmissionSummaries(material
 			total_submissions,
 			reviewed_submissions,
 			(total_submissions - reviewed_submissions) AS pending_submissions,
+			needs_review_submissions,
 			average_final_score,
 			latest_submitted_at
 		FROM grouped
@@ -1255,6 +1402,7 @@ func (s *EssaySubmissionService) ListMaterialStudentSubmissionSummaries(material
 			&item.TotalSubmissions,
 			&item.ReviewedSubmissions,
 			&item.PendingSubmissions,
+			&item.NeedsReviewSubmissions,
 			&avgScore,
 			&latestSubmitted,
 		); err != nil {
@@ -1458,6 +1606,8 @@ func (s *EssaySubmissionService) ListClassStudentSubmissionSummaries(classID, te
 			whereClauses = append(whereClauses, reviewedExpr)
 		} else if reviewStatus == "pending" {
 			whereClauses = append(whereClauses, "NOT "+reviewedExpr)
+		} else if reviewStatus == "needs_review" {
+			whereClauses = append(whereClauses, "es.needs_teacher_review AND NOT "+reviewedExpr)
 		}
 	}
 
@@ -1469,8 +1619,9 @@ func (s *EssaySubmissionService) ListClassStudentSubmissionSummaries(classID, te
 				u.email AS student_email,
 				COUNT(es.id)::int AS total_submissions,
 				SUM(CASE WHEN tr.revised_score IS NOT NULL OR COALESCE(TRIM(tr.teacher_feedback), '') <> '' THEN 1 ELSE 0 END)::int AS reviewed_submissions,
+				SUM(CASE WHEN es.needs_teacher_review AND NOT (tr.revised_score IS NOT NULL OR COALESCE(TRIM(tr.teacher_feedback), '') <> '') THEN 1 ELSE 0 END)::int AS needs_review_submissions,
 				MAX(es.submitted_at) AS latest_submitted_at,
-				AVG(COALESCE(tr.revised_score, ar.skor_ai)) AS average_final_score
+				` + weightedFinalScoreAvgSQL + ` AS average_final_score
 			FROM essay_submissions es
 			JOIN essay_questions eq ON eq.id = es.soal_id
 			JOIN materials m ON m.id = eq.material_id
@@ -1503,6 +1654,8 @@ func (s *EssaySubmissionService) ListClassStudentSubmissionSummaries(classID, te
 		orderBy = "(total_submissions - reviewed_submissions) DESC, latest_submitted_at DESC"
 	case "pending_asc":
 		orderBy = "(total_submissions - reviewed_submissions) ASC, latest_submitted_at DESC"
+	case "needs_review_desc":
+		orderBy = "needs_review_submissions DESC, latest_submitted_at DESC"
 	}
 
 	offset := (page - 1) * size
@@ -1514,6 +1667,7 @@ func (s *EssaySubmissionService) ListClassStudentSubmissionSummaries(classID, te
 			total_submissions,
 			reviewed_submissions,
 			(total_submissions - reviewed_submissions) AS pending_submissions,
+			needs_review_submissions,
 			average_final_score,
 			latest_submitted_at
 		FROM grouped
@@ -1546,6 +1700,7 @@ func (s *EssaySubmissionService) ListClassStudentSubmissionSummaries(classID, te
 			&item.TotalSubmissions,
 			&item.ReviewedSubmissions,
 			&item.PendingSubmissions,
+			&item.NeedsReviewSubmissions,
 			&avgScore,
 			&latestSubmitted,
 		); err != nil {
@@ -1612,6 +1767,8 @@ func (s *EssaySubmissionService) ListClassStudentSubmissionSummariesAll(classID,
 			whereClauses = append(whereClauses, reviewedExpr)
 		} else if reviewStatus == "pending" {
 			whereClauses = append(whereClauses, "NOT "+reviewedExpr)
+		} else if reviewStatus == "needs_review" {
+			whereClauses = append(whereClauses, "es.needs_teacher_review AND NOT "+reviewedExpr)
 		}
 	}
 
@@ -1623,8 +1780,9 @@ func (s *EssaySubmissionService) ListClassStudentSubmissionSummariesAll(classID,
 				u.email AS student_email,
 				COUNT(es.id)::int AS total_submissions,
 				SUM(CASE WHEN tr.revised_score IS NOT NULL OR COALESCE(TRIM(tr.teacher_feedback), '') <> '' THEN 1 ELSE 0 END)::int AS reviewed_submissions,
+				SUM(CASE WHEN es.needs_teacher_review AND NOT (tr.revised_score IS NOT NULL OR COALESCE(TRIM(tr.teacher_feedback), '') <> '') THEN 1 ELSE 0 END)::int AS needs_review_submissions,
 				MAX(es.submitted_at) AS latest_submitted_at,
-				AVG(COALESCE(tr.revised_score, ar.skor_ai)) AS average_final_score
+				` + weightedFinalScoreAvgSQL + ` AS average_final_score
 			FROM essay_submissions es
 			JOIN essay_questions eq ON eq.id = es.soal_id
 			JOIN materials m ON m.id = eq.material_id
@@ -1645,6 +1803,8 @@ func (s *EssaySubmissionService) ListClassStudentSubmissionSummariesAll(classID,
 		orderBy = "(total_submissions - reviewed_submissions) DESC, latest_submitted_at DESC"
 	case "pending_asc":
 		orderBy = "(total_submissions - reviewed_submissions) ASC, latest_submitted_at DESC"
+	case "needs_review_desc":
+		orderBy = "needs_review_submissions DESC, latest_submitted_at DESC"
 	}
 
 	dataQuery := baseCTE + fmt.Sprintf(`
@@ -1655,6 +1815,7 @@ func (s *EssaySubmissionService) ListClassStudentSubmissionSummariesAll(classID,
 			total_submissions,
 			reviewed_submissions,
 			(total_submissions - reviewed_submissions) AS pending_submissions,
+			needs_review_submissions,
 			average_final_score,
 			latest_submitted_at
 		FROM grouped
@@ -1679,6 +1840,7 @@ func (s *EssaySubmissionService) ListClassStudentSubmissionSummariesAll(classID,
 			&item.TotalSubmissions,
 			&item.ReviewedSubmissions,
 			&item.PendingSubmissions,
+			&item.NeedsReviewSubmissions,
 			&avgScore,
 			&latestSubmitted,
 		); err != nil {
@@ -1756,7 +1918,27 @@ func (s *EssaySubmissionService) BuildSectionCardIndexForClass(classID, teacherI
 func (s *EssaySubmissionService) ListClassQWKExportRows(classID, teacherID, materialID string, questionIDs []string, studentID, aiStatus, reviewStatus string, from, to *time.Time, query string, sectionIndex map[string]SectionCardInfo, includeRubricScores bool) ([]models.QWKExportRow, error) {
 	whereClauses := []string{"c.id = $1", "c.teacher_id = $2", "es.submission_type = 'essay'"}
 	args := []interface{}{classID, teacherID}
-	argPos := 3
+	return s.listQWKExportRows(whereClauses, args, materialID, questionIDs, studentID, aiStatus, reviewStatus, from, to, query, sectionIndex, includeRubricScores)
+}
+
+// ListAdminQWKExportRows sama seperti ListClassQWKExportRows tetapi lintas kelas/guru (superadmin).
+// classID dan teacherID opsional untuk mempersempit cakupan.
+func (s *EssaySubmissionService) ListAdminQWKExportRows(classID, teacherID, materialID string, questionIDs []string, studentID, aiStatus, reviewStatus string, from, to *time.Time, query string, includeRubricScores bool) ([]models.QWKExportRow, error) {
+	whereClauses := []string{"es.submission_type = 'essay'"}
+	args := []interface{}{}
+	if strings.TrimSpace(classID) != "" {
+		args = append(args, classID)
+		whereClauses = append(whereClauses, fmt.Sprintf("c.id = $%d", len(args)))
+	}
+	if strings.TrimSpace(teacherID) != "" {
+		args = append(args, teacherID)
+		whereClauses = append(whereClauses, fmt.Sprintf("c.teacher_id = $%d", len(args)))
+	}
+	return s.listQWKExportRows(whereClauses, args, materialID, questionIDs, studentID, aiStatus, reviewStatus, from, to, query, nil, includeRubricScores)
+}
+
+func (s *EssaySubmissionService) listQWKExportRows(whereClauses []string, args []interface{}, materialID string, questionIDs []string, studentID, aiStatus, reviewStatus string, from, to *time.Time, query string, sectionIndex map[string]SectionCardInfo, includeRubricScores bool) ([]models.QWKExportRow, error) {
+	argPos := len(args) + 1
 
 	if strings.TrimSpace(materialID) != "" {
 		whereClauses = append(whereClauses, fmt.Sprintf("m.id = $%d", argPos))
@@ -1799,6 +1981,8 @@ func (s *EssaySubmissionService) ListClassQWKExportRows(classID, teacherID, mate
 			whereClauses = append(whereClauses, reviewedExpr)
 		} else if reviewStatus == "pending" {
 			whereClauses = append(whereClauses, "NOT "+reviewedExpr)
+		} else if reviewStatus == "needs_review" {
+			whereClauses = append(whereClauses, "es.needs_teacher_review AND NOT "+reviewedExpr)
 		}
 	}
 
@@ -1818,7 +2002,8 @@ func (s *EssaySubmissionService) ListClassQWKExportRows(classID, teacherID, mate
 			ar.skor_ai,
 			tr.revised_score,
 			COALESCE(es.ai_grading_status, '') AS ai_status,
-			ar.rubric_scores::text AS rubric_scores
+			COALESCE(ar.rubric_scores::text, ar.holistic_band::text) AS rubric_scores,
+			tr.aspect_scores::text AS teacher_aspect_scores
 		FROM essay_submissions es
 		JOIN essay_questions eq ON eq.id = es.soal_id
 		JOIN materials m ON m.id = eq.material_id
@@ -1843,6 +2028,7 @@ func (s *EssaySubmissionService) ListClassQWKExportRows(classID, teacherID, mate
 		var revisedScore sql.NullFloat64
 		var aiStatus sql.NullString
 		var rubricScores sql.NullString
+		var teacherAspectScores sql.NullString
 		if err := rows.Scan(
 			&item.ClassID,
 			&item.ClassName,
@@ -1859,6 +2045,7 @@ func (s *EssaySubmissionService) ListClassQWKExportRows(classID, teacherID, mate
 			&revisedScore,
 			&aiStatus,
 			&rubricScores,
+			&teacherAspectScores,
 		); err != nil {
 			return nil, fmt.Errorf("failed to scan qwk export row: %w", err)
 		}
@@ -1875,6 +2062,10 @@ func (s *EssaySubmissionService) ListClassQWKExportRows(classID, teacherID, mate
 			text := rubricScores.String
 			item.RubricScores = &text
 		}
+		if includeRubricScores && teacherAspectScores.Valid {
+			text := teacherAspectScores.String
+			item.TeacherAspectScores = &text
+		}
 		if info, ok := sectionIndex[item.QuestionID]; ok {
 			item.SectionCardID = info.ID
 			item.SectionTitle = info.Title
@@ -1928,6 +2119,8 @@ func (s *EssaySubmissionService) ListClassQuestionExportRows(classID, teacherID,
 			whereClauses = append(whereClauses, reviewedExpr)
 		} else if reviewStatus == "pending" {
 			whereClauses = append(whereClauses, "NOT "+reviewedExpr)
+		} else if reviewStatus == "needs_review" {
+			whereClauses = append(whereClauses, "es.needs_teacher_review AND NOT "+reviewedExpr)
 		}
 	}
 
@@ -2278,7 +2471,7 @@ func (s *EssaySubmissionService) ListClassRubricScoreMap(classID, teacherID, mat
 			WHERE ` + strings.Join(whereClauses, " AND ") + `
 			ORDER BY es.soal_id, es.siswa_id, reviewed DESC, es.submitted_at DESC
 		)
-		SELECT latest.soal_id, latest.siswa_id, COALESCE(ar.rubric_scores::text, ar.logs_rag::text)
+		SELECT latest.soal_id, latest.siswa_id, ar.rubric_scores::text, ar.holistic_band::text
 		FROM latest
 		LEFT JOIN ai_results ar ON ar.submission_id = latest.id
 	`
@@ -2294,9 +2487,18 @@ func (s *EssaySubmissionService) ListClassRubricScoreMap(classID, teacherID, mat
 		var questionID string
 		var studentID string
 		var rubricScores sql.NullString
-		if err := rows.Scan(&questionID, &studentID, &rubricScores); err != nil {
+		var holisticBand sql.NullString
+		if err := rows.Scan(&questionID, &studentID, &rubricScores, &holisticBand); err != nil {
 			return nil, fmt.Errorf("failed to scan rubric scores: %w", err)
 		}
+		// Soal holistik dilaporkan sebagai band pada skalanya, bukan skor per aspek.
+		if band := parseHolisticBand(holisticBand); band != nil {
+			if _, ok := out[studentID]; !ok {
+				out[studentID] = map[string]map[string]int{}
+			}
+			out[studentID][questionID] = map[string]int{strings.TrimSpace(band.Skala): band.Band}
+			continue
+		}
 		if !rubricScores.Valid || strings.TrimSpace(rubricScores.String) == "" {
 			continue
 		}
@@ -2368,6 +2570,8 @@ func (s *EssaySubmissionService) GetClassScoreDistribution(classID, teacherID, m
 			whereClauses = append(whereClauses, reviewedExpr)
 		} else if reviewStatus == "pending" {
 			whereClauses = append(whereClauses, "NOT "+reviewedExpr)
+		} else if reviewStatus == "needs_review" {
+			whereClauses = append(whereClauses, "es.needs_teacher_review AND NOT "+reviewedExpr)
 		}
 	}
 
@@ -2460,7 +2664,8 @@ func (s *EssaySubmissionService) GetMaterialSubmissionsByStudent(materialID, tea
 			u.nama_lengkap AS student_name, u.email AS student_email,
 			ar.skor_ai, ar.umpan_balik_ai,
 			tr.id AS review_id, tr.revised_score, tr.teacher_feedback,
-			ar.logs_rag
+			ar.rubric_scores::text, ar.holistic_band::text,
+			COALESCE(es.needs_teacher_review, FALSE), es.needs_review_reason, ar.score_spread
 		FROM essay_submissions es
 		JOIN essay_questions eq ON eq.id = es.soal_id
 		JOIN materials m ON m.id = eq.material_id
@@ -2485,13 +2690,15 @@ func (s *EssaySubmissionService) GetMaterialSubmissionsByStudent(materialID, tea
 		var reviewID sql.NullString
 		var revisedScoreDB sql.NullFloat64
 		var teacherFeedbackDB sql.NullString
-		var logsRAG sql.NullString
+		var rubricScores sql.NullString
+		var holisticBand sql.NullString
 		if err := rows.Scan(
 			&es.ID, &es.QuestionID, &es.StudentID, &es.SubmissionType, &es.AttemptCount, &es.TeksJawaban, &es.SubmittedAt,
 			&es.AIGradingStatus, &es.AIGradingError, &es.AIGradedAt,
 			&es.StudentName, &es.StudentEmail,
 			&skorAI, &umpanBalikAI,
-			&reviewID, &revisedScoreDB, &teacherFeedbackDB, &logsRAG,
+			&reviewID, &revisedScoreDB, &teacherFeedbackDB, &rubricScores, &holisticBand,
+			&es.NeedsReview, &es.NeedsReviewNote, &es.ScoreSpread,
 		); err != nil {
 			return nil, fmt.Errorf("failed to scan student material submission: %w", err)
 		}
@@ -2510,17 +2717,23 @@ func (s *EssaySubmissionService) GetMaterialSubmissionsByStudent(materialID, tea
 		if teacherFeedbackDB.Valid {
 			es.TeacherFeedback = &teacherFeedbackDB.String
 		}
-		if logsRAG.Valid && strings.TrimSpace(logsRAG.String) != "" {
+		if rubricScores.Valid && strings.TrimSpace(rubricScores.String) != "" {
 			var parsedScores []models.GradeEssayAspectScore
-			if unmarshalErr := json.Unmarshal([]byte(logsRAG.String), &parsedScores); unmarshalErr == nil {
+			if unmarshalErr := json.Unmarshal([]byte(rubricScores.String), &parsedScores); unmarshalErr == nil {
 				es.RubricScores = parsedScores
 			}
 		}
+		es.HolisticBand = parseHolisticBand(holisticBand)
 		result = append(result, es)
 	}
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("failed during student material submissions iteration: %w", err)
 	}
+	refs := make([]*models.EssaySubmission, 0, len(result))
+	for i := range result {
+		refs = append(refs, &result[i])
+	}
+	s.attachSubmissionAttachments(refs)
 	return result, nil
 }
 
@@ -2555,6 +2768,12 @@ func (s *EssaySubmissionService) GetEssaySubmissionsByStudentID(studentID string
 	if submissions == nil {
 		submissions = []models.EssaySubmission{}
 	}
+	refs := make([]*models.EssaySubmission, 0, len(submissions))
+	for i := range submissions {
+		refs = append(refs, &submissions[i])
+	}
+	s.attachQueueETA(refs)
+	s.attachSubmissionAttachments(refs)
 
 	return submissions, nil
 }
@@ -2606,12 +2825,23 @@ func (s *EssaySubmissionService) GetAdminQueueSummary() (*models.AdminQueueSumma
 			COALESCE(SUM(CASE WHEN ai_grading_status = 'processing' THEN 1 ELSE 0 END), 0) AS processing,
 			COALESCE(SUM(CASE WHEN ai_grading_status = 'completed' THEN 1 ELSE 0 END), 0) AS completed,
 			COALESCE(SUM(CASE WHEN ai_grading_status = 'failed' THEN 1 ELSE 0 END), 0) AS failed,
+			COALESCE(SUM(CASE WHEN ai_grading_status = 'held' THEN 1 ELSE 0 END), 0) AS held,
+			COALESCE(SUM(CASE WHEN ai_grading_status = 'deferred' THEN 1 ELSE 0 END), 0) AS deferred,
+			COALESCE(SUM(CASE WHEN injection_flagged THEN 1 ELSE 0 END), 0) AS injection_flagged,
 			COUNT(*) AS total
 		FROM essay_submissions
 		WHERE submission_type = 'essay'
-	`).Scan(&summary.Queued, &summary.Processing, &summary.Completed, &summary.Failed, &summary.Total); err != nil {
+	`).Scan(&summary.Queued, &summary.Processing, &summary.Completed, &summary.Failed, &summary.Held, &summary.Deferred, &summary.InjectionFlagged, &summary.Total); err != nil {
 		return nil, fmt.Errorf("failed to load queue summary: %w", err)
 	}
+	if err := s.db.QueryRow(`
+		SELECT
+			COALESCE(SUM(CASE WHEN status = 'queued' AND attempts > 0 THEN 1 ELSE 0 END), 0) AS retry_scheduled,
+			COALESCE(SUM(CASE WHEN status = 'dead' THEN 1 ELSE 0 END), 0) AS dead_letter
+		FROM grading_jobs
+	`).Scan(&summary.RetryScheduled, &summary.DeadLetter); err != nil {
+		return nil, fmt.Errorf("failed to load grading job summary: %w", err)
+	}
 	return summary, nil
 }
 
@@ -2630,7 +2860,10 @@ func (s *EssaySubmissionService) ListAdminQueueJobs(status, classID string, from
 	args := []interface{}{}
 	argPos := 1
 
-	if trimmed := strings.TrimSpace(status); trimmed != "" {
+	if trimmed := strings.TrimSpace(status); trimmed == "dead" {
+		// Dead letter adalah status job, bukan status submission (submission-nya failed).
+		clauses = append(clauses, "gj.status = 'dead'")
+	} else if trimmed != "" {
 		clauses = append(clauses, fmt.Sprintf("es.ai_grading_status = $%d", argPos))
 		args = append(args, trimmed)
 		argPos++
@@ -2658,6 +2891,7 @@ func (s *EssaySubmissionService) ListAdminQueueJobs(status, classID string, from
 		JOIN classes c ON c.id = m.class_id
 		JOIN users u ON u.id = es.siswa_id
 		LEFT JOIN ai_results ar ON ar.submission_id = es.id
+		LEFT JOIN grading_jobs gj ON gj.submission_id = es.id
 	`
 	clauses = append(clauses, "es.submission_type = 'essay'")
 	whereSQL := ""
@@ -2688,7 +2922,11 @@ func (s *EssaySubmissionService) ListAdminQueueJobs(status, classID string, from
 			es.submitted_at,
 			es.ai_graded_at,
 			ar.skor_ai,
-			ar.umpan_balik_ai
+			ar.umpan_balik_ai,
+			gj.status,
+			gj.attempts,
+			gj.max_attempts,
+			CASE WHEN gj.status = 'queued' THEN gj.available_at END
 	` + baseFrom + whereSQL + fmt.Sprintf(`
 		ORDER BY es.submitted_at DESC
 		LIMIT $%d OFFSET $%d
@@ -2713,6 +2951,9 @@ func (s *EssaySubmissionService) ListAdminQueueJobs(status, classID string, from
 		var score sql.NullFloat64
 		var feedback sql.NullString
 		var gradingErr sql.NullString
+		var jobStatus sql.NullString
+		var attempts, maxAttempts sql.NullInt64
+		var nextAttemptAt sql.NullTime
 		if err := rows.Scan(
 			&item.SubmissionID,
 			&item.QuestionID,
@@ -2729,9 +2970,22 @@ func (s *EssaySubmissionService) ListAdminQueueJobs(status, classID string, from
 			&item.AIGradedAt,
 			&score,
 			&feedback,
+			&jobStatus,
+			&attempts,
+			&maxAttempts,
+			&nextAttemptAt,
 		); err != nil {
 			return nil, fmt.Errorf("failed to scan queue job row: %w", err)
 		}
+		if jobStatus.Valid {
+			item.JobStatus = &jobStatus.String
+			attemptCount, maxCount := int(attempts.Int64), int(maxAttempts.Int64)
+			item.Attempts = &attemptCount
+			item.MaxAttempts = &maxCount
+		}
+		if nextAttemptAt.Valid {
+			item.NextAttemptAt = &nextAttemptAt.Time
+		}
 		if score.Valid {
 			item.Score = &score.Float64
 		}
@@ -2757,6 +3011,13 @@ func (s *EssaySubmissionService) ListAdminQueueJobs(status, classID string, from
 }
 
 func (s *EssaySubmissionService) RetryQueueSubmissions(submissionIDs []string) (*models.RetryQueueResponse, error) {
+	return s.requeueSubmissions(submissionIDs, requeueOptions{})
+}
+
+// requeueSubmissions mengembalikan submission ke antrean grading. bypassCache dipakai saat
+// re-grade paksa agar hasil cache dari model sebelumnya tidak dipakai ulang; regradeBatchID
+// (opsional) menautkan job ke batch re-grade soal agar skor baru tercatat di laporan diff.
+func (s *EssaySubmissionService) requeueSubmissions(submissionIDs []string, opts requeueOptions) (*models.RetryQueueResponse, error) {
 	resp := &models.RetryQueueResponse{
 		Details: []models.RetryQueueItemResult{},
 	}
@@ -2829,7 +3090,14 @@ func (s *EssaySubmissionService) RetryQueueSubmissions(submissionIDs []string) (
 		}
 		s.clearStopRequest(submissionID)
 
+		job.BypassCache = opts.BypassCache
+		job.RegradeBatchID = opts.RegradeBatchID
+		job.ForceReplace = opts.ForceReplace
 		if err := s.enqueueGradingJob(job); err != nil {
+			if !errors.Is(err, ErrGradingQueueFull) {
+				_ = s.updateSubmissionGradingStatus(submissionID, "failed", "Gagal memasukkan job ke antrean grading.", nil)
+				return nil, err
+			}
 			_ = s.updateSubmissionGradingStatus(submissionID, "failed", "Grading queue penuh. Coba lagi beberapa saat.", nil)
 			resp.Skipped++
 			resp.Details = append(resp.Details, models.RetryQueueItemResult{
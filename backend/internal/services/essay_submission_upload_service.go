@@ -0,0 +1,422 @@
+package services
+
+import (
+	"api-backend/internal/doctext"
+	"api-backend/internal/models"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/lib/pq"
+)
+
+// submissionFilesDir menyimpan file jawaban siswa. Sengaja terpisah dari folder uploads yang disajikan
+// publik, sehingga file hanya bisa diunduh lewat endpoint yang memeriksa hak akses.
+const submissionFilesDir = "submission_files"
+
+// submissionUploadFormats adalah format file jawaban yang diterima untuk submission esai.
+var submissionUploadFormats = map[string]bool{"docx": true, "pdf": true, "txt": true}
+
+var (
+	ErrSubmissionUploadFormat      = errors.New("submission upload format not supported")
+	ErrSubmissionUploadEncrypted   = errors.New("submission upload is password protected")
+	ErrSubmissionUploadNoText      = errors.New("submission upload has no readable text")
+	ErrSubmissionUploadUnreadable  = errors.New("submission upload could not be read")
+	ErrSubmissionUploadNotFound    = errors.New("submission upload not found")
+	ErrSubmissionUploadConfirmed   = errors.New("submission upload already confirmed")
+	ErrSubmissionUploadEmptyText   = errors.New("submission text is empty")
+	ErrSubmissionAttachmentDenied  = errors.New("not allowed to access submission attachment")
+	ErrSubmissionAttachmentMissing = errors.New("submission attachment not found")
+)
+
+// CreateSubmissionUploadDraft mengekstrak teks dari file jawaban siswa, menyimpan file beserta teksnya sebagai
+// draft, dan mengembalikannya agar siswa bisa memeriksa teks sebelum dinilai. Draft lama untuk soal yang sama
+// dibuang karena hanya draft terakhir yang bisa dikonfirmasi.
+func (s *EssaySubmissionService) CreateSubmissionUploadDraft(questionID, studentID, originalName string, data []byte) (*models.EssaySubmissionUpload, error) {
+	var questionExists bool
+	if err := s.db.QueryRowContext(context.Background(), "SELECT EXISTS(SELECT 1 FROM essay_questions WHERE id = $1)", questionID).Scan(&questionExists); err != nil {
+		return nil, fmt.Errorf("failed to check essay question: %w", err)
+	}
+	if !questionExists {
+		return nil, fmt.Errorf("essay question not found")
+	}
+	if err := s.ensureSectionCardRead(questionID, studentID); err != nil {
+		return nil, err
+	}
+
+	ext := strings.ToLower(filepath.Ext(originalName))
+	if ext != ".docx" && ext != ".pdf" && ext != ".txt" {
+		return nil, ErrSubmissionUploadFormat
+	}
+	result, err := doctext.Extract(originalName, data)
+	switch {
+	case errors.Is(err, doctext.ErrEncrypted):
+		return nil, ErrSubmissionUploadEncrypted
+	case errors.Is(err, doctext.ErrNoText):
+		return nil, ErrSubmissionUploadNoText
+	case errors.Is(err, doctext.ErrUnsupportedFormat):
+		return nil, ErrSubmissionUploadFormat
+	case err != nil:
+		log.Printf("WARNING: failed to extract submission upload %q: %v", originalName, err)
+		return nil, ErrSubmissionUploadUnreadable
+	}
+	if !submissionUploadFormats[result.Format] {
+		return nil, ErrSubmissionUploadFormat
+	}
+	text := normalizeSubmissionText(result.Format, result.Text)
+	if text == "" {
+		return nil, ErrSubmissionUploadNoText
+	}
+
+	fileName, err := saveSubmissionFile(ext, data)
+	if err != nil {
+		return nil, err
+	}
+	s.discardSubmissionUploadDrafts(questionID, studentID)
+
+	upload := &models.EssaySubmissionUpload{
+		QuestionID:       questionID,
+		StudentID:        studentID,
+		FilePath:         fileName,
+		OriginalFileName: filepath.Base(originalName),
+		FileFormat:       result.Format,
+		FileSize:         int64(len(data)),
+		ExtractedText:    text,
+		PageCount:        result.Units,
+		Status:           models.SubmissionUploadDraft,
+	}
+	if err := s.db.QueryRowContext(
+		context.Background(),
+		`INSERT INTO essay_submission_uploads (soal_id, siswa_id, file_path, original_file_name, file_format, file_size, extracted_text, page_count, status)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		 RETURNING id, created_at`,
+		upload.QuestionID,
+		upload.StudentID,
+		upload.FilePath,
+		upload.OriginalFileName,
+		upload.FileFormat,
+		upload.FileSize,
+		upload.ExtractedText,
+		upload.PageCount,
+		upload.Status,
+	).Scan(&upload.ID, &upload.CreatedAt); err != nil {
+		removeSubmissionFile(fileName)
+		return nil, fmt.Errorf("error inserting submission upload: %w", err)
+	}
+	return upload, nil
+}
+
+// ConfirmSubmissionUpload menjadikan draft upload sebagai attempt baru. Teks yang dikirim siswa (bila ada)
+// menggantikan hasil ekstraksi; setelah itu alurnya sama dengan CreateEssaySubmission, termasuk antrean AI.
+func (s *EssaySubmissionService) ConfirmSubmissionUpload(uploadID, studentID string, editedText *string) (*models.EssaySubmission, *models.GradeEssayResponse, error) {
+	var upload models.EssaySubmissionUpload
+	err := s.db.QueryRowContext(
+		context.Background(),
+		`SELECT id, soal_id, siswa_id, extracted_text, status
+		 FROM essay_submission_uploads
+		 WHERE id = $1 AND siswa_id = $2`,
+		uploadID,
+		studentID,
+	).Scan(&upload.ID, &upload.QuestionID, &upload.StudentID, &upload.ExtractedText, &upload.Status)
+	if err == sql.ErrNoRows {
+		return nil, nil, ErrSubmissionUploadNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("error loading submission upload %s: %w", uploadID, err)
+	}
+	if upload.Status != models.SubmissionUploadDraft {
+		return nil, nil, ErrSubmissionUploadConfirmed
+	}
+
+	text := upload.ExtractedText
+	if editedText != nil {
+		text = strings.TrimSpace(strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(*editedText))
+	}
+	if text == "" {
+		return nil, nil, ErrSubmissionUploadEmptyText
+	}
+
+	// Draft diklaim lebih dulu agar konfirmasi ganda (klik dua kali) tidak membuat dua attempt.
+	res, err := s.db.ExecContext(
+		context.Background(),
+		`UPDATE essay_submission_uploads
+		 SET status = $1, extracted_text = $2, confirmed_at = NOW()
+		 WHERE id = $3 AND status = $4`,
+		models.SubmissionUploadConfirmed,
+		text,
+		upload.ID,
+		models.SubmissionUploadDraft,
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error confirming submission upload %s: %w", uploadID, err)
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return nil, nil, ErrSubmissionUploadConfirmed
+	}
+
+	submission, gradeResp, err := s.createEssaySubmission(upload.QuestionID, studentID, text, &upload.ID)
+	if submission == nil {
+		if _, revertErr := s.db.ExecContext(
+			context.Background(),
+			`UPDATE essay_submission_uploads SET status = $1, extracted_text = $2, confirmed_at = NULL WHERE id = $3`,
+			models.SubmissionUploadDraft,
+			upload.ExtractedText,
+			upload.ID,
+		); revertErr != nil {
+			log.Printf("WARNING: failed to revert submission upload %s to draft: %v", upload.ID, revertErr)
+		}
+		return nil, nil, err
+	}
+	if _, linkErr := s.db.ExecContext(
+		context.Background(),
+		"UPDATE essay_submission_uploads SET submission_id = $1 WHERE id = $2",
+		submission.ID,
+		upload.ID,
+	); linkErr != nil {
+		log.Printf("WARNING: failed to link submission upload %s to submission %s: %v", upload.ID, submission.ID, linkErr)
+	}
+	s.attachSubmissionAttachments([]*models.EssaySubmission{submission})
+	return submission, gradeResp, err
+}
+
+// GetSubmissionAttachmentFile mengembalikan lampiran attempt terakhir sebuah submission beserta path lokalnya.
+// Lampiran hanya bisa diakses siswa pemiliknya, guru pemilik kelas, dan superadmin.
+func (s *EssaySubmissionService) GetSubmissionAttachmentFile(submissionID, userID, userRole string) (*models.EssaySubmissionUpload, string, error) {
+	var upload models.EssaySubmissionUpload
+	var teacherID sql.NullString
+	err := s.db.QueryRowContext(
+		context.Background(),
+		`SELECT u.id, u.soal_id, u.siswa_id, u.file_path, u.original_file_name, u.file_format, u.file_size, u.created_at, c.teacher_id
+		 FROM essay_submissions es
+		 JOIN essay_submission_uploads u ON u.id = es.attachment_upload_id
+		 JOIN essay_questions eq ON eq.id = es.soal_id
+		 LEFT JOIN materials m ON m.id = eq.material_id
+		 LEFT JOIN classes c ON c.id = m.class_id
+		 WHERE es.id = $1`,
+		submissionID,
+	).Scan(&upload.ID, &upload.QuestionID, &upload.StudentID, &upload.FilePath, &upload.OriginalFileName, &upload.FileFormat, &upload.FileSize, &upload.CreatedAt, &teacherID)
+	if err == sql.ErrNoRows {
+		return nil, "", ErrSubmissionAttachmentMissing
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("error loading attachment for submission %s: %w", submissionID, err)
+	}
+
+	switch userRole {
+	case "superadmin":
+	case "teacher":
+		if !teacherID.Valid || teacherID.String != userID {
+			return nil, "", ErrSubmissionAttachmentDenied
+		}
+	default:
+		if upload.StudentID != userID {
+			return nil, "", ErrSubmissionAttachmentDenied
+		}
+	}
+
+	path, err := submissionFilePath(upload.FilePath)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := os.Stat(path); err != nil {
+		log.Printf("WARNING: attachment file for submission %s is missing: %v", submissionID, err)
+		return nil, "", ErrSubmissionAttachmentMissing
+	}
+	return &upload, path, nil
+}
+
+// attachSubmissionAttachments mengisi ringkasan lampiran untuk submission yang attempt terakhirnya diunggah
+// sebagai file.
+func (s *EssaySubmissionService) attachSubmissionAttachments(items []*models.EssaySubmission) {
+	ids := make([]string, 0, len(items))
+	byID := make(map[string][]*models.EssaySubmission, len(items))
+	for _, item := range items {
+		if item == nil || item.ID == "" {
+			continue
+		}
+		if _, seen := byID[item.ID]; !seen {
+			ids = append(ids, item.ID)
+		}
+		byID[item.ID] = append(byID[item.ID], item)
+	}
+	if len(ids) == 0 {
+		return
+	}
+
+	rows, err := s.db.QueryContext(
+		context.Background(),
+		`SELECT es.id, u.id, u.original_file_name, u.file_format, u.file_size, u.created_at
+		 FROM essay_submissions es
+		 JOIN essay_submission_uploads u ON u.id = es.attachment_upload_id
+		 WHERE es.id = ANY($1)`,
+		pq.Array(ids),
+	)
+	if err != nil {
+		log.Printf("WARNING: failed to load submission attachments: %v", err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var submissionID string
+		var attachment models.SubmissionAttachment
+		if err := rows.Scan(&submissionID, &attachment.UploadID, &attachment.FileName, &attachment.FileFormat, &attachment.FileSize, &attachment.UploadedAt); err != nil {
+			log.Printf("WARNING: failed to scan submission attachment: %v", err)
+			return
+		}
+		attachment.DownloadURL = "/api/submissions/" + submissionID + "/attachment"
+		for _, item := range byID[submissionID] {
+			copied := attachment
+			item.Attachment = &copied
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("WARNING: failed to iterate submission attachments: %v", err)
+	}
+}
+
+// discardSubmissionUploadDrafts menghapus draft yang belum dikonfirmasi beserta filenya.
+func (s *EssaySubmissionService) discardSubmissionUploadDrafts(questionID, studentID string) {
+	rows, err := s.db.QueryContext(
+		context.Background(),
+		`DELETE FROM essay_submission_uploads
+		 WHERE soal_id = $1 AND siswa_id = $2 AND status = $3
+		 RETURNING file_path`,
+		questionID,
+		studentID,
+		models.SubmissionUploadDraft,
+	)
+	if err != nil {
+		log.Printf("WARNING: failed to discard old submission upload drafts: %v", err)
+		return
+	}
+	var files []string
+	for rows.Next() {
+		var fileName string
+		if rows.Scan(&fileName) == nil {
+			files = append(files, fileName)
+		}
+	}
+	rows.Close()
+	for _, fileName := range files {
+		removeSubmissionFile(fileName)
+	}
+}
+
+func saveSubmissionFile(ext string, data []byte) (string, error) {
+	if err := os.MkdirAll(submissionFilesDir, 0o750); err != nil {
+		return "", fmt.Errorf("failed to prepare submission files directory: %w", err)
+	}
+	randBytes := make([]byte, 16)
+	if _, err := rand.Read(randBytes); err != nil {
+		return "", fmt.Errorf("failed to generate submission file name: %w", err)
+	}
+	fileName := fmt.Sprintf("%x%s", randBytes, ext)
+	if err := os.WriteFile(filepath.Join(submissionFilesDir, fileName), data, 0o640); err != nil {
+		return "", fmt.Errorf("failed to store submission file: %w", err)
+	}
+	return fileName, nil
+}
+
+func removeSubmissionFile(fileName string) {
+	path, err := submissionFilePath(fileName)
+	if err != nil {
+		return
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		log.Printf("WARNING: failed to remove submission file %s: %v", fileName, err)
+	}
+}
+
+// submissionFilePath memetakan nama file tersimpan ke path lokal dan menolak nama yang keluar dari foldernya.
+func submissionFilePath(fileName string) (string, error) {
+	if fileName == "" || strings.Contains(fileName, "..") || strings.ContainsAny(fileName, `/\`) {
+		return "", fmt.Errorf("invalid submission file path")
+	}
+	return filepath.Join(submissionFilesDir, fileName), nil
+}
+
+var pageMarkerLine = regexp.MustCompile(`^\[Halaman \d+\]$`)
+
+// normalizeSubmissionText merapikan teks hasil ekstraksi menjadi jawaban esai. Penanda halaman PDF dibuang,
+// dan baris PDF yang terpotong karena lebar halaman disambung kembali menjadi paragraf. DOCX/TXT sudah
+// memisahkan paragraf per baris sehingga cukup dipadatkan.
+func normalizeSubmissionText(format, text string) string {
+	lines := strings.Split(text, "\n")
+	kept := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if format == "pdf" && pageMarkerLine.MatchString(strings.TrimSpace(line)) {
+			continue
+		}
+		kept = append(kept, strings.TrimSpace(line))
+	}
+	if format != "pdf" {
+		return collapseBlankLines(kept)
+	}
+
+	var paragraphs []string
+	var current string
+	flush := func() {
+		if current != "" {
+			paragraphs = append(paragraphs, current)
+			current = ""
+		}
+	}
+	for _, line := range kept {
+		switch {
+		case line == "":
+			flush()
+		case current == "":
+			current = line
+		case startsListItem(line) || strings.HasSuffix(current, ":"):
+			paragraphs = append(paragraphs, current)
+			current = line
+		default:
+			current = joinWrappedLine(current, line)
+		}
+	}
+	flush()
+	return strings.Join(paragraphs, "\n\n")
+}
+
+// joinWrappedLine menyambung baris yang terpotong. Tanda hubung di akhir baris dibuang untuk pemenggalan
+// kata ("mengha-" + "silkan"), tetapi dipertahankan untuk kata ulang ("anak-" + "anak").
+func joinWrappedLine(prev, next string) string {
+	if !strings.HasSuffix(prev, "-") || len(prev) < 2 {
+		return prev + " " + next
+	}
+	head := strings.TrimSuffix(prev, "-")
+	lastWord := head[strings.LastIndexFunc(head, unicode.IsSpace)+1:]
+	firstRune := []rune(next)[0]
+	if lastWord == "" || !unicode.IsLetter(firstRune) || !unicode.IsLower(firstRune) {
+		return prev + next
+	}
+	if strings.HasPrefix(strings.ToLower(next), strings.ToLower(lastWord)) {
+		return prev + next
+	}
+	return head + next
+}
+
+var listItemPrefix = regexp.MustCompile(`^([-•*–]\s|\d{1,2}[.)]\s|[a-zA-Z][.)]\s)`)
+
+func startsListItem(line string) bool {
+	return listItemPrefix.MatchString(line)
+}
+
+func collapseBlankLines(lines []string) string {
+	out := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if line == "" && (len(out) == 0 || out[len(out)-1] == "") {
+			continue
+		}
+		out = append(out, line)
+	}
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
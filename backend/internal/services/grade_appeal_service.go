@@ -316,6 +316,8 @@ func (s *GradeAppealService) ReviewAppeal(appealID string, teacherID string, req
 			 SET teacher_id = EXCLUDED.teacher_id,
 			     revised_score = EXCLUDED.revised_score,
 			     teacher_feedback = COALESCE(EXCLUDED.teacher_feedback, teacher_reviews.teacher_feedback),
+			     needs_recheck = FALSE,
+			     recheck_reason = NULL,
 			     updated_at = NOW()`,
 			submissionID,
 			teacherID,
@@ -0,0 +1,297 @@
+package services
+
+import (
+	"api-backend/internal/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultGradingCacheTTLHours adalah umur maksimum entri cache grading (30 hari).
+	DefaultGradingCacheTTLHours = 720
+	// DefaultGradingCacheMaxEntries membatasi jumlah entri; entri paling lama tidak dipakai dibuang lebih dulu.
+	DefaultGradingCacheMaxEntries = 20000
+
+	gradingCacheEnabledSettingKey    = "grading_cache_enabled"
+	gradingCacheTTLHoursSettingKey   = "grading_cache_ttl_hours"
+	gradingCacheMaxEntriesSettingKey = "grading_cache_max_entries"
+
+	gradingCacheConfigRefresh = time.Minute
+	// gradingCacheEvictEvery menjalankan eviksi setiap N penulisan agar tidak membebani setiap grading.
+	gradingCacheEvictEvery = 25
+)
+
+// GradingCacheConfig adalah konfigurasi cache grading dari system_settings.
+// TTLHours 0 berarti entri tidak kedaluwarsa; MaxEntries 0 berarti tanpa batas jumlah.
+type GradingCacheConfig struct {
+	Enabled    bool `json:"enabled"`
+	TTLHours   int  `json:"ttl_hours"`
+	MaxEntries int  `json:"max_entries"`
+}
+
+func (c GradingCacheConfig) ttl() time.Duration {
+	return time.Duration(c.TTLHours) * time.Hour
+}
+
+func defaultGradingCacheConfig() GradingCacheConfig {
+	return GradingCacheConfig{Enabled: true, TTLHours: DefaultGradingCacheTTLHours, MaxEntries: DefaultGradingCacheMaxEntries}
+}
+
+// gradingCacheConfig membaca konfigurasi cache (disimpan sementara satu menit).
+func (s *AIService) gradingCacheConfig() GradingCacheConfig {
+	if s.settings == nil || s.db == nil {
+		return defaultGradingCacheConfig()
+	}
+	s.cacheCfgMu.Lock()
+	defer s.cacheCfgMu.Unlock()
+	if !s.cacheCfgLoadedAt.IsZero() && time.Since(s.cacheCfgLoadedAt) < gradingCacheConfigRefresh {
+		return s.cacheCfg
+	}
+	cfg, err := s.settings.GetGradingCacheConfig()
+	if err != nil {
+		log.Printf("WARNING: failed to load grading cache config: %v", err)
+		if s.cacheCfgLoadedAt.IsZero() {
+			cfg = defaultGradingCacheConfig()
+		} else {
+			cfg = s.cacheCfg
+		}
+	}
+	s.cacheCfg = cfg
+	s.cacheCfgLoadedAt = time.Now()
+	return cfg
+}
+
+// recordGradingCacheEvent menambah counter harian: hits, misses, expired, writes, atau evictions.
+func recordGradingCacheEvent(db *sql.DB, column string, count int64) {
+	if db == nil || count <= 0 {
+		return
+	}
+	switch column {
+	case "hits", "misses", "expired", "writes", "evictions":
+	default:
+		return
+	}
+	query := fmt.Sprintf(
+		`INSERT INTO ai_grading_cache_stats (day, %[1]s) VALUES (CURRENT_DATE, $1)
+		 ON CONFLICT (day) DO UPDATE SET %[1]s = ai_grading_cache_stats.%[1]s + EXCLUDED.%[1]s`,
+		column,
+	)
+	if _, err := db.ExecContext(context.Background(), query, count); err != nil {
+		log.Printf("WARNING: failed to record grading cache %s: %v", column, err)
+	}
+}
+
+// maybeEvictGradingCache membuang entri kedaluwarsa dan entri berlebih berdasarkan last_used_at.
+func (s *AIService) maybeEvictGradingCache(cfg GradingCacheConfig) {
+	if s.db == nil || atomic.AddUint64(&s.cacheWrites, 1)%gradingCacheEvictEvery != 0 {
+		return
+	}
+	evicted, err := evictGradingCache(s.db, cfg)
+	if err != nil {
+		log.Printf("WARNING: failed to evict grading cache: %v", err)
+		return
+	}
+	recordGradingCacheEvent(s.db, "evictions", evicted)
+}
+
+func evictGradingCache(db *sql.DB, cfg GradingCacheConfig) (int64, error) {
+	var total int64
+	if cfg.TTLHours > 0 {
+		res, err := db.ExecContext(
+			context.Background(),
+			`DELETE FROM ai_grading_cache WHERE created_at < NOW() - make_interval(hours => $1)`,
+			cfg.TTLHours,
+		)
+		if err != nil {
+			return total, err
+		}
+		affected, _ := res.RowsAffected()
+		total += affected
+	}
+	if cfg.MaxEntries > 0 {
+		res, err := db.ExecContext(
+			context.Background(),
+			`DELETE FROM ai_grading_cache
+			 WHERE request_hash IN (
+			     SELECT request_hash FROM ai_grading_cache
+			     ORDER BY last_used_at DESC
+			     OFFSET $1
+			 )`,
+			cfg.MaxEntries,
+		)
+		if err != nil {
+			return total, err
+		}
+		affected, _ := res.RowsAffected()
+		total += affected
+	}
+	return total, nil
+}
+
+// GradingCacheService menyediakan statistik dan pembersihan cache grading untuk superadmin.
+type GradingCacheService struct {
+	db       *sql.DB
+	settings *SystemSettingService
+}
+
+func NewGradingCacheService(db *sql.DB, settings *SystemSettingService) *GradingCacheService {
+	return &GradingCacheService{db: db, settings: settings}
+}
+
+func (s *GradingCacheService) config() GradingCacheConfig {
+	if s.settings == nil {
+		return defaultGradingCacheConfig()
+	}
+	cfg, err := s.settings.GetGradingCacheConfig()
+	if err != nil {
+		return defaultGradingCacheConfig()
+	}
+	return cfg
+}
+
+// Stats merangkum isi cache dan hit/miss selama `days` hari terakhir.
+func (s *GradingCacheService) Stats(days int) (*models.GradingCacheStats, error) {
+	if days <= 0 {
+		days = 30
+	}
+	if days > 365 {
+		days = 365
+	}
+	cfg := s.config()
+	stats := &models.GradingCacheStats{
+		Enabled:    cfg.Enabled,
+		TTLHours:   cfg.TTLHours,
+		MaxEntries: cfg.MaxEntries,
+		Days:       days,
+		Daily:      []models.GradingCacheDailyStat{},
+		ByModel:    []models.GradingCacheModelStat{},
+	}
+
+	var oldest, newest sql.NullTime
+	if err := s.db.QueryRowContext(
+		context.Background(),
+		`SELECT COUNT(*),
+		        COALESCE(SUM(hit_count), 0),
+		        COUNT(*) FILTER (WHERE $1 > 0 AND created_at < NOW() - make_interval(hours => $1)),
+		        MIN(created_at),
+		        MAX(created_at),
+		        COALESCE(pg_total_relation_size('ai_grading_cache'), 0)`,
+		cfg.TTLHours,
+	).Scan(&stats.Entries, &stats.EntryHits, &stats.ExpiredEntries, &oldest, &newest, &stats.TableBytes); err != nil {
+		return nil, fmt.Errorf("failed to summarize grading cache: %w", err)
+	}
+	if oldest.Valid {
+		stats.OldestEntryAt = &oldest.Time
+	}
+	if newest.Valid {
+		stats.NewestEntryAt = &newest.Time
+	}
+
+	rows, err := s.db.QueryContext(
+		context.Background(),
+		`SELECT day, hits, misses, expired, writes, evictions
+		 FROM ai_grading_cache_stats
+		 WHERE day > CURRENT_DATE - $1::int
+		 ORDER BY day ASC`,
+		days,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load grading cache stats: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item models.GradingCacheDailyStat
+		var day time.Time
+		if err := rows.Scan(&day, &item.Hits, &item.Misses, &item.Expired, &item.Writes, &item.Evictions); err != nil {
+			return nil, fmt.Errorf("failed to scan grading cache stats: %w", err)
+		}
+		item.Day = day.Format("2006-01-02")
+		stats.Hits += item.Hits
+		stats.Misses += item.Misses
+		stats.Expired += item.Expired
+		stats.Writes += item.Writes
+		stats.Evictions += item.Evictions
+		stats.Daily = append(stats.Daily, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate grading cache stats: %w", err)
+	}
+	// Entri kedaluwarsa dihitung sebagai miss karena model tetap dipanggil.
+	if lookups := stats.Hits + stats.Misses; lookups > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(lookups)
+	}
+
+	modelRows, err := s.db.QueryContext(
+		context.Background(),
+		`SELECT COALESCE(provider, ''), COALESCE(model_name, ''), COALESCE(prompt_template_version, ''), COUNT(*), COALESCE(SUM(hit_count), 0)
+		 FROM ai_grading_cache
+		 GROUP BY 1, 2, 3
+		 ORDER BY COUNT(*) DESC`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load grading cache models: %w", err)
+	}
+	defer modelRows.Close()
+	for modelRows.Next() {
+		var item models.GradingCacheModelStat
+		if err := modelRows.Scan(&item.Provider, &item.Model, &item.PromptTemplateVersion, &item.Entries, &item.Hits); err != nil {
+			return nil, fmt.Errorf("failed to scan grading cache models: %w", err)
+		}
+		stats.ByModel = append(stats.ByModel, item)
+	}
+	if err := modelRows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate grading cache models: %w", err)
+	}
+	return stats, nil
+}
+
+// Purge menghapus entri cache sesuai filter. Minimal satu filter wajib diisi kecuali All=true.
+func (s *GradingCacheService) Purge(filter models.GradingCachePurgeRequest) (int64, error) {
+	clauses := []string{}
+	args := []interface{}{}
+	argPos := 1
+	if questionID := strings.TrimSpace(filter.QuestionID); questionID != "" {
+		clauses = append(clauses, fmt.Sprintf("question_id = $%d", argPos))
+		args = append(args, questionID)
+		argPos++
+	}
+	if modelName := strings.TrimSpace(filter.ModelName); modelName != "" {
+		clauses = append(clauses, fmt.Sprintf("model_name = $%d", argPos))
+		args = append(args, modelName)
+		argPos++
+	}
+	if filter.OlderThanDays > 0 {
+		clauses = append(clauses, fmt.Sprintf("created_at < NOW() - make_interval(days => $%d)", argPos))
+		args = append(args, filter.OlderThanDays)
+		argPos++
+	}
+	if filter.ExpiredOnly {
+		cfg := s.config()
+		if cfg.TTLHours <= 0 {
+			return 0, nil
+		}
+		clauses = append(clauses, fmt.Sprintf("created_at < NOW() - make_interval(hours => $%d)", argPos))
+		args = append(args, cfg.TTLHours)
+		argPos++
+	}
+	if len(clauses) == 0 && !filter.All {
+		return 0, fmt.Errorf("at least one filter (question_id, model_name, older_than_days, expired_only) or all=true is required")
+	}
+
+	query := "DELETE FROM ai_grading_cache"
+	if len(clauses) > 0 {
+		query += " WHERE " + strings.Join(clauses, " AND ")
+	}
+	res, err := s.db.ExecContext(context.Background(), query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge grading cache: %w", err)
+	}
+	affected, _ := res.RowsAffected()
+	recordGradingCacheEvent(s.db, "evictions", affected)
+	return affected, nil
+}
@@ -0,0 +1,587 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultGradingRetryMaxAttempts adalah jumlah retry (di luar percobaan pertama) bila ai_retry_max_attempts belum diset.
+	DefaultGradingRetryMaxAttempts = 2
+	// DefaultGradingQueueMaxSize adalah batas job queued bila queue_max_size belum diset.
+	DefaultGradingQueueMaxSize = 1000
+
+	aiRetryEnabledSettingKey     = "ai_retry_enabled"
+	aiRetryMaxAttemptsSettingKey = "ai_retry_max_attempts"
+	queueMaxSizeSettingKey       = "queue_max_size"
+
+	gradingJobPollInterval          = 2 * time.Second
+	gradingJobBaseBackoff           = 15 * time.Second
+	gradingJobMaxBackoff            = 15 * time.Minute
+	defaultGradingVisibilityTimeout = 10 * time.Minute
+)
+
+// ErrGradingQueueFull dikembalikan saat jumlah job queued sudah mencapai queue_max_size.
+var ErrGradingQueueFull = errors.New("grading queue is full")
+
+var errGradingStopped = errors.New("grading stopped by admin")
+
+// errGradingSuperseded menandai hasil grading yang dibuang karena submission sudah dikirim ulang atau job
+// sudah di-enqueue ulang selama worker menunggu AI.
+var errGradingSuperseded = errors.New("grading superseded by a newer attempt")
+
+// GradingQueueConfig adalah konfigurasi antrean grading dari system_settings.
+type GradingQueueConfig struct {
+	RetryEnabled     bool
+	RetryMaxAttempts int
+	MaxSize          int
+	Paused           bool   // true bila admin menjeda klaim job baru.
+	Fairness         string // fifo | class | teacher
+}
+
+func defaultGradingQueueConfig() GradingQueueConfig {
+	return GradingQueueConfig{
+		RetryEnabled:     true,
+		RetryMaxAttempts: DefaultGradingRetryMaxAttempts,
+		MaxSize:          DefaultGradingQueueMaxSize,
+		Fairness:         GradingQueueFairnessClass,
+	}
+}
+
+// MaxAttempts adalah total percobaan per job: 1 bila retry nonaktif, selain itu 1 + RetryMaxAttempts.
+func (c GradingQueueConfig) MaxAttempts() int {
+	if !c.RetryEnabled || c.RetryMaxAttempts <= 0 {
+		return 1
+	}
+	return 1 + c.RetryMaxAttempts
+}
+
+// claimedGradingJob adalah job yang sedang dipegang worker ini sampai locked_until.
+type claimedGradingJob struct {
+	ID             string
+	SubmissionID   string
+	BypassCache    bool
+	Attempts       int
+	MaxAttempts    int
+	StopRequested  bool
+	RegradeBatchID string
+	ForceReplace   bool
+	LeaseID        string // Token klaim; berubah setiap job diklaim dan dihapus saat job di-enqueue ulang.
+}
+
+// gradingWorkerID mengidentifikasi proses di grading_jobs.locked_by untuk diagnosis. Boot id acak membuat
+// proses di container berbeda (yang semuanya pid 1) tetap bisa dibedakan.
+func gradingWorkerID() string {
+	host, err := os.Hostname()
+	if err != nil || strings.TrimSpace(host) == "" {
+		host = "backend"
+	}
+	bootID := make([]byte, 4)
+	if _, err := rand.Read(bootID); err != nil {
+		return fmt.Sprintf("%s:%d:%x", host, os.Getpid(), time.Now().UnixNano())
+	}
+	return fmt.Sprintf("%s:%d:%s", host, os.Getpid(), hex.EncodeToString(bootID))
+}
+
+func gradingVisibilityTimeoutFromEnv() time.Duration {
+	if value := strings.TrimSpace(os.Getenv("AI_GRADING_VISIBILITY_TIMEOUT_SECONDS")); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed >= 30 {
+			return time.Duration(parsed) * time.Second
+		}
+	}
+	return defaultGradingVisibilityTimeout
+}
+
+// gradingRetryBackoff menghitung jeda eksponensial sebelum percobaan berikutnya (15 dtk, 30 dtk, 1 mnt, ... maks 15 mnt).
+func gradingRetryBackoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	delay := gradingJobBaseBackoff
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= gradingJobMaxBackoff {
+			return gradingJobMaxBackoff
+		}
+	}
+	return delay
+}
+
+// isRetryableGradingError menentukan apakah job layak dicoba ulang. Output yang tetap melanggar rubrik,
+// kuota yang habis, dan stop admin tidak akan berubah dengan mengulang.
+func isRetryableGradingError(err error) bool {
+	if err == nil || errors.Is(err, errGradingStopped) {
+		return false
+	}
+	var validationErr *AIOutputValidationError
+	if errors.As(err, &validationErr) {
+		return false
+	}
+	var quotaErr *AIQuotaExceededError
+	return !errors.As(err, &quotaErr)
+}
+
+func (s *EssaySubmissionService) queueConfig() GradingQueueConfig {
+	if s.settingService == nil {
+		return defaultGradingQueueConfig()
+	}
+	cfg, err := s.settingService.GetGradingQueueConfig()
+	if err != nil {
+		log.Printf("WARNING: failed to read grading queue settings: %v", err)
+	}
+	return cfg
+}
+
+func (s *EssaySubmissionService) wakeGradingWorkers() {
+	select {
+	case s.queueWake <- struct{}{}:
+	default:
+	}
+}
+
+// enqueueGradingJob memasukkan (atau mengulang) job untuk submission. Job lama milik submission yang sama
+// direset: percobaan kembali 0 dan permintaan stop dihapus; prioritas yang sudah diatur admin dipertahankan.
+// lease_id ikut dihapus sehingga worker yang masih memproses klaim lama tidak bisa menulis hasil maupun
+// menutup job baru ini.
+func (s *EssaySubmissionService) enqueueGradingJob(job essayGradingJob) error {
+	cfg := s.queueConfig()
+	var queued int
+	if err := s.db.QueryRowContext(
+		context.Background(),
+		`SELECT COUNT(*) FROM grading_jobs WHERE status = 'queued' AND submission_id <> $1`,
+		job.SubmissionID,
+	).Scan(&queued); err != nil {
+		return fmt.Errorf("failed to count grading queue: %w", err)
+	}
+	if cfg.MaxSize > 0 && queued >= cfg.MaxSize {
+		return ErrGradingQueueFull
+	}
+
+	if _, err := s.db.ExecContext(
+		context.Background(),
+		`INSERT INTO grading_jobs (submission_id, status, bypass_cache, attempts, max_attempts, available_at, class_id, teacher_id, regrade_batch_id, force_replace)
+		 SELECT es.id, 'queued', $2, 0, $3, NOW(), c.id, c.teacher_id, NULLIF($4, '')::uuid, $5
+		 FROM essay_submissions es
+		 LEFT JOIN essay_questions eq ON eq.id = es.soal_id
+		 LEFT JOIN materials m ON m.id = eq.material_id
+		 LEFT JOIN classes c ON c.id = m.class_id
+		 WHERE es.id = $1
+		 ON CONFLICT (submission_id) DO UPDATE
+		 SET status = 'queued',
+		     bypass_cache = EXCLUDED.bypass_cache,
+		     class_id = EXCLUDED.class_id,
+		     teacher_id = EXCLUDED.teacher_id,
+		     regrade_batch_id = EXCLUDED.regrade_batch_id,
+		     force_replace = EXCLUDED.force_replace,
+		     attempts = 0,
+		     max_attempts = EXCLUDED.max_attempts,
+		     available_at = NOW(),
+		     locked_by = NULL,
+		     locked_until = NULL,
+		     lease_id = NULL,
+		     stop_requested = FALSE,
+		     last_error = NULL,
+		     finished_at = NULL,
+		     updated_at = NOW()`,
+		job.SubmissionID,
+		job.BypassCache,
+		cfg.MaxAttempts(),
+		job.RegradeBatchID,
+		job.ForceReplace,
+	); err != nil {
+		return fmt.Errorf("failed to enqueue grading job: %w", err)
+	}
+	s.wakeGradingWorkers()
+	return nil
+}
+
+func (s *EssaySubmissionService) markStopRequested(submissionID string) {
+	if strings.TrimSpace(submissionID) == "" {
+		return
+	}
+	// Job yang belum diklaim langsung dibatalkan; job yang sedang diproses berhenti di titik cek berikutnya.
+	// Grading instan tidak punya baris job, jadi baris cancelled dibuat agar permintaan stop tetap terbaca.
+	if _, err := s.db.ExecContext(
+		context.Background(),
+		`INSERT INTO grading_jobs (submission_id, status, stop_requested, finished_at)
+		 VALUES ($1, 'cancelled', TRUE, NOW())
+		 ON CONFLICT (submission_id) DO UPDATE
+		 SET stop_requested = TRUE,
+		     status = CASE WHEN grading_jobs.status = 'queued' THEN 'cancelled' ELSE grading_jobs.status END,
+		     finished_at = CASE WHEN grading_jobs.status = 'queued' THEN NOW() ELSE grading_jobs.finished_at END,
+		     updated_at = NOW()`,
+		submissionID,
+	); err != nil {
+		log.Printf("WARNING: failed to mark stop request for %s: %v", submissionID, err)
+	}
+}
+
+func (s *EssaySubmissionService) clearStopRequest(submissionID string) {
+	if strings.TrimSpace(submissionID) == "" {
+		return
+	}
+	if _, err := s.db.ExecContext(
+		context.Background(),
+		`UPDATE grading_jobs SET stop_requested = FALSE, updated_at = NOW() WHERE submission_id = $1 AND stop_requested`,
+		submissionID,
+	); err != nil {
+		log.Printf("WARNING: failed to clear stop request for %s: %v", submissionID, err)
+	}
+}
+
+func (s *EssaySubmissionService) isStopRequested(submissionID string) bool {
+	if strings.TrimSpace(submissionID) == "" {
+		return false
+	}
+	var stop bool
+	if err := s.db.QueryRowContext(
+		context.Background(),
+		`SELECT stop_requested FROM grading_jobs WHERE submission_id = $1`,
+		submissionID,
+	).Scan(&stop); err != nil {
+		return false
+	}
+	return stop
+}
+
+func (s *EssaySubmissionService) runGradingWorker() {
+	for {
+		cfg := s.cachedQueueConfig()
+		if cfg.Paused {
+			select {
+			case <-s.queueWake:
+			case <-time.After(gradingJobPollInterval):
+			}
+			continue
+		}
+		claimed, err := s.claimGradingJob(cfg.Fairness)
+		if err != nil {
+			log.Printf("WARNING: failed to claim grading job: %v", err)
+		}
+		if claimed == nil {
+			select {
+			case <-s.queueWake:
+			case <-time.After(gradingJobPollInterval):
+			}
+			continue
+		}
+		s.processGradingJob(claimed)
+	}
+}
+
+// claimGradingJob mengambil satu job yang siap: queued dan sudah lewat available_at, atau processing
+// yang locked_until-nya habis (worker sebelumnya mati). SKIP LOCKED mencegah dua worker mengambil job yang sama.
+// Urutan: prioritas tertinggi dulu, lalu kelompok (kelas/guru sesuai fairness) yang paling lama tidak
+// mendapat giliran, lalu yang paling lama menunggu. Kelas yang sudah mencapai max_concurrency dilewati;
+// batas ini best-effort karena dua worker bisa membaca hitungan yang sama pada saat bersamaan.
+func (s *EssaySubmissionService) claimGradingJob(fairness string) (*claimedGradingJob, error) {
+	lastTurn := "NULL::timestamptz"
+	if fairness != GradingQueueFairnessFIFO {
+		lastTurn = fmt.Sprintf(`(
+				SELECT MAX(p.claimed_at)
+				FROM grading_jobs p
+				WHERE p.claimed_at >= NOW() - INTERVAL '1 day'
+				  AND %s = %s
+			)`, gradingFairnessKey(fairness, "p"), gradingFairnessKey(fairness, "cand"))
+	}
+	var job claimedGradingJob
+	err := s.db.QueryRowContext(
+		context.Background(),
+		fmt.Sprintf(`UPDATE grading_jobs gj
+		 SET status = 'processing',
+		     attempts = gj.attempts + 1,
+		     locked_by = $1,
+		     locked_until = NOW() + make_interval(secs => $2),
+		     lease_id = gen_random_uuid(),
+		     claimed_at = NOW(),
+		     updated_at = NOW()
+		 WHERE gj.id = (
+			SELECT cand.id
+			FROM grading_jobs cand
+			LEFT JOIN grading_queue_class_limits l ON l.class_id = cand.class_id
+			WHERE ((cand.status = 'queued' AND cand.available_at <= NOW())
+			   OR (cand.status = 'processing' AND cand.locked_until < NOW()))
+			  AND (
+				l.class_id IS NULL
+				OR cand.status = 'processing'
+				OR (
+					SELECT COUNT(*)
+					FROM grading_jobs r
+					WHERE r.class_id = cand.class_id
+					  AND r.status = 'processing'
+					  AND r.locked_until >= NOW()
+				) < l.max_concurrency
+			  )
+			ORDER BY cand.priority DESC, %s ASC NULLS FIRST, cand.available_at ASC, cand.created_at ASC
+			FOR UPDATE OF cand SKIP LOCKED
+			LIMIT 1
+		 )
+		 RETURNING gj.id, gj.submission_id, gj.bypass_cache, gj.attempts, gj.max_attempts, gj.stop_requested, COALESCE(gj.regrade_batch_id::text, ''), gj.force_replace, gj.lease_id::text`, lastTurn),
+		s.workerID,
+		int64(s.visibilityTimeout/time.Second),
+	).Scan(&job.ID, &job.SubmissionID, &job.BypassCache, &job.Attempts, &job.MaxAttempts, &job.StopRequested, &job.RegradeBatchID, &job.ForceReplace, &job.LeaseID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (s *EssaySubmissionService) processGradingJob(claimed *claimedGradingJob) {
+	if claimed.Attempts > claimed.MaxAttempts {
+		// Percobaan terakhir terputus (worker mati) dan visibility timeout habis.
+		s.deadLetterGradingJob(claimed, "worker berhenti saat memproses job")
+		return
+	}
+	if claimed.StopRequested {
+		if s.finishGradingJob(claimed, "cancelled", "") {
+			_ = s.updateSubmissionGradingStatus(claimed.SubmissionID, "failed", "Dihentikan admin sebelum diproses worker.", nil)
+		}
+		return
+	}
+
+	job := essayGradingJob{JobID: claimed.ID, SubmissionID: claimed.SubmissionID, BypassCache: claimed.BypassCache, RegradeBatchID: claimed.RegradeBatchID, ForceReplace: claimed.ForceReplace, LeaseID: claimed.LeaseID}
+	var submissionType string
+	if err := s.db.QueryRowContext(
+		context.Background(),
+		`SELECT soal_id, siswa_id, teks_jawaban, submission_type FROM essay_submissions WHERE id = $1`,
+		claimed.SubmissionID,
+	).Scan(&job.QuestionID, &job.StudentID, &job.TeksJawaban, &submissionType); err != nil {
+		if err == sql.ErrNoRows {
+			s.finishGradingJob(claimed, "failed", "submission not found")
+			return
+		}
+		s.retryOrDeadLetterGradingJob(claimed, err)
+		return
+	}
+	if submissionType != "essay" {
+		s.finishGradingJob(claimed, "failed", "task submissions are not graded by the AI queue")
+		return
+	}
+	config, err := s.loadQuizAttemptConfig(job.QuestionID)
+	if err != nil {
+		s.retryOrDeadLetterGradingJob(claimed, err)
+		return
+	}
+	job.ScoringMethod = config.AttemptScoring
+
+	stopHeartbeat := s.startGradingJobHeartbeat(claimed)
+	_, gradeErr := s.gradeJob(job)
+	stopHeartbeat()
+
+	var deferred *gradingDeferredError
+
+	switch {
+	case gradeErr == nil:
+		s.finishGradingJob(claimed, "done", "")
+	case errors.Is(gradeErr, errGradingStopped):
+		s.finishGradingJob(claimed, "cancelled", gradeErr.Error())
+	case errors.As(gradeErr, &deferred):
+		s.deferGradingJob(claimed, deferred.Until)
+	case errors.Is(gradeErr, errGradingSuperseded):
+		// Job sudah menjadi milik attempt yang lebih baru; status job dan submission dibiarkan apa adanya.
+		log.Printf("INFO: discarded stale grading result for submission %s (job %s)", claimed.SubmissionID, claimed.ID)
+	case !isRetryableGradingError(gradeErr):
+		log.Printf("WARNING: AI grading worker failed for submission %s: %v", claimed.SubmissionID, gradeErr)
+		s.finishGradingJob(claimed, "failed", gradeErr.Error())
+	default:
+		log.Printf("WARNING: AI grading attempt %d/%d failed for submission %s: %v", claimed.Attempts, claimed.MaxAttempts, claimed.SubmissionID, gradeErr)
+		s.retryOrDeadLetterGradingJob(claimed, gradeErr)
+	}
+}
+
+// startGradingJobHeartbeat memperpanjang locked_until selama job diproses agar job yang lama
+// menunggu limiter tidak diambil worker lain.
+func (s *EssaySubmissionService) startGradingJobHeartbeat(claimed *claimedGradingJob) func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(s.visibilityTimeout / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if _, err := s.db.ExecContext(
+					context.Background(),
+					`UPDATE grading_jobs
+					 SET locked_until = NOW() + make_interval(secs => $3)
+					 WHERE id = $1 AND lease_id = $2::uuid AND status = 'processing'`,
+					claimed.ID,
+					claimed.LeaseID,
+					int64(s.visibilityTimeout/time.Second),
+				); err != nil {
+					log.Printf("WARNING: failed to extend grading job lease %s: %v", claimed.ID, err)
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// finishGradingJob menutup job hanya bila klaim ini masih berlaku; job yang sudah di-enqueue ulang
+// (mis. retry admin atau submit ulang) tidak ditimpa. Nilai kembalian false berarti klaim sudah tidak berlaku.
+func (s *EssaySubmissionService) finishGradingJob(claimed *claimedGradingJob, status, lastErr string) bool {
+	res, err := s.db.ExecContext(
+		context.Background(),
+		`UPDATE grading_jobs
+		 SET status = $3,
+		     last_error = NULLIF($4, ''),
+		     locked_by = NULL,
+		     locked_until = NULL,
+		     lease_id = NULL,
+		     finished_at = NOW(),
+		     updated_at = NOW()
+		 WHERE id = $1 AND lease_id = $2::uuid AND status = 'processing'`,
+		claimed.ID,
+		claimed.LeaseID,
+		status,
+		lastErr,
+	)
+	if err != nil {
+		log.Printf("WARNING: failed to finish grading job %s: %v", claimed.ID, err)
+		return false
+	}
+	affected, _ := res.RowsAffected()
+	return affected > 0
+}
+
+func (s *EssaySubmissionService) retryOrDeadLetterGradingJob(claimed *claimedGradingJob, cause error) {
+	if claimed.Attempts >= claimed.MaxAttempts {
+		s.deadLetterGradingJob(claimed, cause.Error())
+		return
+	}
+	delay := gradingRetryBackoff(claimed.Attempts)
+	res, err := s.db.ExecContext(
+		context.Background(),
+		`UPDATE grading_jobs
+		 SET status = 'queued',
+		     available_at = NOW() + make_interval(secs => $3),
+		     last_error = $4,
+		     locked_by = NULL,
+		     locked_until = NULL,
+		     lease_id = NULL,
+		     updated_at = NOW()
+		 WHERE id = $1 AND lease_id = $2::uuid AND status = 'processing'`,
+		claimed.ID,
+		claimed.LeaseID,
+		int64(delay/time.Second),
+		cause.Error(),
+	)
+	if err != nil {
+		log.Printf("WARNING: failed to reschedule grading job %s: %v", claimed.ID, err)
+		return
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return
+	}
+	msg := fmt.Sprintf("Percobaan %d/%d gagal: %s. Dicoba lagi sekitar %s.",
+		claimed.Attempts, claimed.MaxAttempts, cause.Error(), time.Now().Add(delay).In(aiQuotaLocation).Format("15:04:05"))
+	_ = s.updateSubmissionGradingStatus(claimed.SubmissionID, "queued", msg, nil)
+}
+
+// deferGradingJob mengembalikan job ke queued sampai kuota reset tanpa menghitung percobaan. Baris job tidak
+// dibuat ulang, sehingga bypass_cache, force_replace, dan regrade_batch_id tetap berlaku saat job diklaim lagi.
+func (s *EssaySubmissionService) deferGradingJob(claimed *claimedGradingJob, until time.Time) {
+	if _, err := s.db.ExecContext(
+		context.Background(),
+		`UPDATE grading_jobs
+		 SET status = 'queued',
+		     attempts = GREATEST(attempts - 1, 0),
+		     available_at = GREATEST($3, NOW()),
+		     last_error = 'Kuota token AI habis',
+		     locked_by = NULL,
+		     locked_until = NULL,
+		     lease_id = NULL,
+		     updated_at = NOW()
+		 WHERE id = $1 AND lease_id = $2::uuid AND status = 'processing'`,
+		claimed.ID,
+		claimed.LeaseID,
+		until,
+	); err != nil {
+		log.Printf("WARNING: failed to defer grading job %s: %v", claimed.ID, err)
+	}
+}
+
+// deadLetterGradingJob memindahkan job ke status dead setelah percobaan habis. Submission ditandai failed
+// dan bisa dimasukkan ulang lewat RetryQueueSubmissions.
+func (s *EssaySubmissionService) deadLetterGradingJob(claimed *claimedGradingJob, reason string) {
+	log.Printf("WARNING: grading job %s for submission %s moved to dead letter after %d attempt(s): %s", claimed.ID, claimed.SubmissionID, claimed.MaxAttempts, reason)
+	if !s.finishGradingJob(claimed, "dead", reason) {
+		return
+	}
+	_ = s.updateSubmissionGradingStatus(claimed.SubmissionID, "failed", fmt.Sprintf("Gagal setelah %d percobaan: %s", claimed.MaxAttempts, reason), nil)
+}
+
+// recoverPendingQueue dijalankan saat startup. Job processing milik proses yang sudah mati tidak dilepas di
+// sini karena tidak bisa dibedakan dari proses lain yang masih hidup; job itu diklaim ulang setelah
+// locked_until habis. Submission queued/processing yang belum punya job aktif (mis. dari antrean in-memory
+// lama) dimasukkan ke tabel job.
+func (s *EssaySubmissionService) recoverPendingQueue() {
+	if s.aiService == nil {
+		log.Printf("WARNING: skipping queue recovery because AI service is unavailable")
+		return
+	}
+
+	orphaned, err := s.db.ExecContext(
+		context.Background(),
+		`INSERT INTO grading_jobs (submission_id, status, attempts, max_attempts, available_at, class_id, teacher_id)
+		 SELECT es.id, 'queued', 0, $1, NOW(), c.id, c.teacher_id
+		 FROM essay_submissions es
+		 LEFT JOIN grading_jobs gj ON gj.submission_id = es.id
+		 LEFT JOIN essay_questions eq ON eq.id = es.soal_id
+		 LEFT JOIN materials m ON m.id = eq.material_id
+		 LEFT JOIN classes c ON c.id = m.class_id
+		 WHERE es.submission_type = 'essay'
+		   AND es.ai_grading_status IN ('queued', 'processing')
+		   AND (gj.id IS NULL OR gj.status NOT IN ('queued', 'processing'))
+		 ON CONFLICT (submission_id) DO UPDATE
+		 SET status = 'queued',
+		     class_id = EXCLUDED.class_id,
+		     teacher_id = EXCLUDED.teacher_id,
+		     attempts = 0,
+		     max_attempts = EXCLUDED.max_attempts,
+		     available_at = NOW(),
+		     locked_by = NULL,
+		     locked_until = NULL,
+		     lease_id = NULL,
+		     stop_requested = FALSE,
+		     last_error = NULL,
+		     finished_at = NULL,
+		     updated_at = NOW()`,
+		s.queueConfig().MaxAttempts(),
+	)
+	if err != nil {
+		log.Printf("WARNING: failed to recover pending submissions into grading queue: %v", err)
+		return
+	}
+	orphanedCount, _ := orphaned.RowsAffected()
+
+	if _, err := s.db.ExecContext(
+		context.Background(),
+		`UPDATE essay_submissions es
+		 SET ai_grading_status = 'queued'
+		 FROM grading_jobs gj
+		 WHERE gj.submission_id = es.id
+		   AND gj.status = 'queued'
+		   AND es.ai_grading_status = 'processing'`,
+	); err != nil {
+		log.Printf("WARNING: failed to reset recovered submissions to queued: %v", err)
+	}
+
+	if orphanedCount > 0 {
+		log.Printf("Queue recovery completed: recovered=%d", orphanedCount)
+		s.wakeGradingWorkers()
+	}
+}
@@ -0,0 +1,491 @@
+package services
+
+import (
+	"api-backend/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// GradingQueueFairnessFIFO memproses job sesuai urutan masuk.
+	GradingQueueFairnessFIFO = "fifo"
+	// GradingQueueFairnessClass menggilir kelas (round-robin) agar satu kelas tidak memonopoli antrean.
+	GradingQueueFairnessClass = "class"
+	// GradingQueueFairnessTeacher menggilir guru pemilik kelas.
+	GradingQueueFairnessTeacher = "teacher"
+
+	gradingQueuePausedSettingKey   = "grading_queue_paused"
+	gradingQueueFairnessSettingKey = "grading_queue_fairness"
+
+	// MaxGradingQueuePriority membatasi nilai prioritas job (negatif = diturunkan).
+	MaxGradingQueuePriority = 100
+
+	gradingQueueConfigRefresh   = 5 * time.Second
+	gradingQueueSnapshotRefresh = 15 * time.Second
+	gradingQueueThroughputSpan  = 30 * time.Minute
+)
+
+var (
+	ErrQueueClassLimitNotFound = errors.New("class limit not found")
+	ErrQueueClassNotFound      = errors.New("class not found")
+)
+
+// NormalizeGradingQueueFairness memvalidasi mode penjadwalan. String kosong dianggap class.
+func NormalizeGradingQueueFairness(value string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", GradingQueueFairnessClass:
+		return GradingQueueFairnessClass, true
+	case GradingQueueFairnessTeacher:
+		return GradingQueueFairnessTeacher, true
+	case GradingQueueFairnessFIFO:
+		return GradingQueueFairnessFIFO, true
+	default:
+		return "", false
+	}
+}
+
+// gradingFairnessKey mengembalikan ekspresi SQL pengelompokan job untuk round-robin.
+func gradingFairnessKey(mode, alias string) string {
+	switch mode {
+	case GradingQueueFairnessTeacher:
+		return "COALESCE(" + alias + ".teacher_id::text, '')"
+	case GradingQueueFairnessFIFO:
+		return "''"
+	default:
+		return "COALESCE(" + alias + ".class_id::text, '')"
+	}
+}
+
+// cachedQueueConfig dibaca ulang tiap beberapa detik agar loop worker tidak membaca system_settings terus-menerus.
+func (s *EssaySubmissionService) cachedQueueConfig() GradingQueueConfig {
+	s.queueCfgMu.Lock()
+	defer s.queueCfgMu.Unlock()
+	if !s.queueCfgLoadedAt.IsZero() && time.Since(s.queueCfgLoadedAt) < gradingQueueConfigRefresh {
+		return s.queueCfg
+	}
+	s.queueCfg = s.queueConfig()
+	s.queueCfgLoadedAt = time.Now()
+	return s.queueCfg
+}
+
+func (s *EssaySubmissionService) invalidateQueueConfig() {
+	s.queueCfgMu.Lock()
+	s.queueCfgLoadedAt = time.Time{}
+	s.queueCfgMu.Unlock()
+	s.snapshotMu.Lock()
+	s.snapshot = nil
+	s.snapshotMu.Unlock()
+}
+
+// SetGradingQueuePaused menjeda atau melanjutkan klaim job baru di semua replika.
+// Job yang sedang diproses tetap diselesaikan.
+func (s *EssaySubmissionService) SetGradingQueuePaused(paused bool) error {
+	if s.settingService == nil {
+		return fmt.Errorf("settings service is unavailable")
+	}
+	value := "false"
+	if paused {
+		value = "true"
+	}
+	if err := s.settingService.SetSetting(gradingQueuePausedSettingKey, value); err != nil {
+		return fmt.Errorf("failed to update queue pause state: %w", err)
+	}
+	s.invalidateQueueConfig()
+	if !paused {
+		s.wakeGradingWorkers()
+	}
+	return nil
+}
+
+// SetQueuePriority mengubah prioritas job yang masih queued.
+func (s *EssaySubmissionService) SetQueuePriority(submissionIDs []string, priority int) (*models.SetQueuePriorityResponse, error) {
+	if priority < -MaxGradingQueuePriority || priority > MaxGradingQueuePriority {
+		return nil, fmt.Errorf("priority must be between %d and %d", -MaxGradingQueuePriority, MaxGradingQueuePriority)
+	}
+	ids := make([]string, 0, len(submissionIDs))
+	seen := map[string]struct{}{}
+	for _, id := range submissionIDs {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		ids = append(ids, id)
+	}
+	res, err := s.db.ExecContext(
+		context.Background(),
+		`UPDATE grading_jobs
+		 SET priority = $2, updated_at = NOW()
+		 WHERE submission_id::text = ANY($1) AND status = 'queued'`,
+		pq.Array(ids),
+		priority,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update queue priority: %w", err)
+	}
+	updated, _ := res.RowsAffected()
+	s.invalidateQueueConfig()
+	return &models.SetQueuePriorityResponse{Updated: int(updated), Skipped: len(ids) - int(updated)}, nil
+}
+
+// ListQueueClassLimits mengembalikan batas konkurensi per kelas.
+func (s *EssaySubmissionService) ListQueueClassLimits() ([]models.GradingQueueClassLimit, error) {
+	rows, err := s.db.QueryContext(
+		context.Background(),
+		`SELECT l.class_id, c.class_name, l.max_concurrency, l.updated_by, l.updated_at
+		 FROM grading_queue_class_limits l
+		 JOIN classes c ON c.id = l.class_id
+		 ORDER BY c.class_name ASC`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list queue class limits: %w", err)
+	}
+	defer rows.Close()
+	items := []models.GradingQueueClassLimit{}
+	for rows.Next() {
+		var item models.GradingQueueClassLimit
+		var updatedBy sql.NullString
+		if err := rows.Scan(&item.ClassID, &item.ClassName, &item.MaxConcurrency, &updatedBy, &item.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan queue class limit: %w", err)
+		}
+		if updatedBy.Valid {
+			item.UpdatedBy = &updatedBy.String
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// UpsertQueueClassLimit menetapkan batas job yang boleh diproses bersamaan untuk satu kelas.
+func (s *EssaySubmissionService) UpsertQueueClassLimit(req models.UpsertGradingQueueClassLimitRequest, actorID string) (*models.GradingQueueClassLimit, error) {
+	classID := strings.TrimSpace(req.ClassID)
+	if classID == "" {
+		return nil, fmt.Errorf("class_id is required")
+	}
+	if req.MaxConcurrency < 1 || req.MaxConcurrency > 100 {
+		return nil, fmt.Errorf("max_concurrency must be between 1 and 100")
+	}
+	var item models.GradingQueueClassLimit
+	var updatedBy sql.NullString
+	err := s.db.QueryRowContext(
+		context.Background(),
+		`WITH upserted AS (
+			INSERT INTO grading_queue_class_limits (class_id, max_concurrency, updated_by, updated_at)
+			SELECT c.id, $2, NULLIF($3, '')::uuid, NOW()
+			FROM classes c
+			WHERE c.id = $1
+			ON CONFLICT (class_id) DO UPDATE
+			SET max_concurrency = EXCLUDED.max_concurrency,
+			    updated_by = EXCLUDED.updated_by,
+			    updated_at = NOW()
+			RETURNING class_id, max_concurrency, updated_by, updated_at
+		 )
+		 SELECT u.class_id, c.class_name, u.max_concurrency, u.updated_by, u.updated_at
+		 FROM upserted u
+		 JOIN classes c ON c.id = u.class_id`,
+		classID,
+		req.MaxConcurrency,
+		actorID,
+	).Scan(&item.ClassID, &item.ClassName, &item.MaxConcurrency, &updatedBy, &item.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrQueueClassNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to save queue class limit: %w", err)
+	}
+	if updatedBy.Valid {
+		item.UpdatedBy = &updatedBy.String
+	}
+	return &item, nil
+}
+
+// DeleteQueueClassLimit menghapus batas konkurensi kelas.
+func (s *EssaySubmissionService) DeleteQueueClassLimit(classID string) error {
+	res, err := s.db.ExecContext(context.Background(), `DELETE FROM grading_queue_class_limits WHERE class_id = $1`, classID)
+	if err != nil {
+		return fmt.Errorf("failed to delete queue class limit: %w", err)
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return ErrQueueClassLimitNotFound
+	}
+	s.wakeGradingWorkers()
+	return nil
+}
+
+// queueSnapshotJob adalah job queued dalam snapshot untuk menghitung posisi dan ETA.
+type queueSnapshotJob struct {
+	SubmissionID string
+	ClassID      string
+	ClassName    string
+	Group        string
+	Priority     int
+	AvailableAt  time.Time
+}
+
+// gradingQueueSnapshot adalah potret antrean yang di-cache singkat agar polling status siswa tidak membebani DB.
+type gradingQueueSnapshot struct {
+	loadedAt      time.Time
+	paused        bool
+	fairness      string
+	ratePerMinute float64
+	avgSeconds    float64
+	processing    map[string]int64 // per class_id
+	jobs          []queueSnapshotJob
+	index         map[string]int
+}
+
+func (s *EssaySubmissionService) queueSnapshot() (*gradingQueueSnapshot, error) {
+	s.snapshotMu.Lock()
+	defer s.snapshotMu.Unlock()
+	if s.snapshot != nil && time.Since(s.snapshot.loadedAt) < gradingQueueSnapshotRefresh {
+		return s.snapshot, nil
+	}
+	snap, err := s.loadQueueSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	s.snapshot = snap
+	return snap, nil
+}
+
+func (s *EssaySubmissionService) loadQueueSnapshot() (*gradingQueueSnapshot, error) {
+	cfg := s.cachedQueueConfig()
+	snap := &gradingQueueSnapshot{
+		loadedAt:   time.Now(),
+		paused:     cfg.Paused,
+		fairness:   cfg.Fairness,
+		processing: map[string]int64{},
+		index:      map[string]int{},
+	}
+
+	rows, err := s.db.QueryContext(
+		context.Background(),
+		fmt.Sprintf(`SELECT gj.submission_id, COALESCE(gj.class_id::text, ''), COALESCE(c.class_name, ''), %s, gj.priority, gj.available_at
+		 FROM grading_jobs gj
+		 LEFT JOIN classes c ON c.id = gj.class_id
+		 WHERE gj.status = 'queued'
+		 ORDER BY gj.priority DESC, gj.available_at ASC, gj.created_at ASC`, gradingFairnessKey(cfg.Fairness, "gj")),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load queued jobs: %w", err)
+	}
+	for rows.Next() {
+		var job queueSnapshotJob
+		if err := rows.Scan(&job.SubmissionID, &job.ClassID, &job.ClassName, &job.Group, &job.Priority, &job.AvailableAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan queued job: %w", err)
+		}
+		snap.index[job.SubmissionID] = len(snap.jobs)
+		snap.jobs = append(snap.jobs, job)
+	}
+	rows.Close()
+
+	processingRows, err := s.db.QueryContext(
+		context.Background(),
+		`SELECT COALESCE(class_id::text, ''), COUNT(*) FROM grading_jobs WHERE status = 'processing' GROUP BY 1`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load processing jobs: %w", err)
+	}
+	for processingRows.Next() {
+		var classID string
+		var count int64
+		if err := processingRows.Scan(&classID, &count); err != nil {
+			processingRows.Close()
+			return nil, fmt.Errorf("failed to scan processing jobs: %w", err)
+		}
+		snap.processing[classID] = count
+	}
+	processingRows.Close()
+
+	// Throughput dari job yang selesai dalam 30 menit terakhir; bila terlalu sedikit, pakai rata-rata durasi
+	// 24 jam terakhir dikali jumlah worker, dan terakhir batas RPM limiter.
+	var finished int64
+	var avgRecent, avgDay sql.NullFloat64
+	if err := s.db.QueryRowContext(
+		context.Background(),
+		`SELECT
+			COUNT(*) FILTER (WHERE finished_at >= NOW() - make_interval(secs => $1)),
+			AVG(EXTRACT(EPOCH FROM finished_at - claimed_at)) FILTER (WHERE finished_at >= NOW() - make_interval(secs => $1)),
+			AVG(EXTRACT(EPOCH FROM finished_at - claimed_at))
+		 FROM grading_jobs
+		 WHERE status IN ('done', 'failed', 'dead')
+		   AND claimed_at IS NOT NULL
+		   AND finished_at >= NOW() - INTERVAL '1 day'`,
+		int64(gradingQueueThroughputSpan/time.Second),
+	).Scan(&finished, &avgRecent, &avgDay); err != nil {
+		return nil, fmt.Errorf("failed to load queue throughput: %w", err)
+	}
+	switch {
+	case finished >= 3:
+		snap.ratePerMinute = float64(finished) / gradingQueueThroughputSpan.Minutes()
+		snap.avgSeconds = avgRecent.Float64
+	case avgDay.Valid && avgDay.Float64 > 0:
+		snap.avgSeconds = avgDay.Float64
+		snap.ratePerMinute = float64(s.workerCount) * 60 / avgDay.Float64
+	}
+	if rpm := float64(SharedAIRateLimiter().Status().RPMLimit); rpm > 0 && (snap.ratePerMinute <= 0 || snap.ratePerMinute > rpm) {
+		snap.ratePerMinute = rpm
+	}
+	if snap.ratePerMinute <= 0 {
+		snap.ratePerMinute = 1
+	}
+	return snap, nil
+}
+
+// jobsAhead memperkirakan jumlah job yang diproses sebelum job ke-idx. Prioritas lebih tinggi selalu lebih
+// dulu; pada prioritas yang sama, mode round-robin memberi tiap kelompok lain paling banyak k giliran
+// sebelum job ke-k milik kelompok job ini.
+func (snap *gradingQueueSnapshot) jobsAhead(idx int) int {
+	target := snap.jobs[idx]
+	rank := 0
+	for i := 0; i <= idx; i++ {
+		job := snap.jobs[i]
+		if job.Priority == target.Priority && job.Group == target.Group {
+			rank++
+		}
+	}
+	ahead := rank - 1
+	otherGroups := map[string]int{}
+	for i, job := range snap.jobs {
+		if i == idx {
+			continue
+		}
+		switch {
+		case job.Priority > target.Priority:
+			ahead++
+		case job.Priority < target.Priority || job.Group == target.Group:
+		case snap.fairness == GradingQueueFairnessFIFO:
+			if i < idx {
+				ahead++
+			}
+		default:
+			if otherGroups[job.Group] < rank {
+				otherGroups[job.Group]++
+				ahead++
+			}
+		}
+	}
+	return ahead
+}
+
+// estimateAt mengubah jumlah job di depan menjadi perkiraan waktu selesai.
+func (snap *gradingQueueSnapshot) estimateAt(idx int, now time.Time) *time.Time {
+	if snap.paused {
+		return nil
+	}
+	minutes := float64(snap.jobsAhead(idx)+1) / snap.ratePerMinute
+	if snap.avgSeconds > 0 {
+		minutes += snap.avgSeconds / 60
+	}
+	eta := now.Add(time.Duration(math.Ceil(minutes*60)) * time.Second)
+	if available := snap.jobs[idx].AvailableAt; available.After(eta) {
+		eta = available
+	}
+	return &eta
+}
+
+// attachQueueETA mengisi posisi antrean dan perkiraan selesai untuk submission yang masih queued.
+func (s *EssaySubmissionService) attachQueueETA(items []*models.EssaySubmission) {
+	needed := false
+	for _, item := range items {
+		if item != nil && item.AIGradingStatus == "queued" {
+			needed = true
+			break
+		}
+	}
+	if !needed {
+		return
+	}
+	snap, err := s.queueSnapshot()
+	if err != nil {
+		log.Printf("WARNING: failed to estimate grading queue ETA: %v", err)
+		return
+	}
+	now := time.Now()
+	for _, item := range items {
+		if item == nil || item.AIGradingStatus != "queued" {
+			continue
+		}
+		idx, ok := snap.index[item.ID]
+		if !ok {
+			continue
+		}
+		ahead := snap.jobsAhead(idx)
+		item.QueuePosition = &ahead
+		item.EstimatedAt = snap.estimateAt(idx, now)
+		item.QueuePaused = snap.paused
+	}
+}
+
+// GetGradingQueueControlStatus merangkum status pause, mode penjadwalan, throughput, dan ETA per kelas.
+func (s *EssaySubmissionService) GetGradingQueueControlStatus() (*models.GradingQueueControlStatus, error) {
+	s.invalidateQueueConfig()
+	snap, err := s.queueSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	limits, err := s.ListQueueClassLimits()
+	if err != nil {
+		return nil, err
+	}
+	limitByClass := map[string]int{}
+	for _, limit := range limits {
+		limitByClass[limit.ClassID] = limit.MaxConcurrency
+	}
+
+	status := &models.GradingQueueControlStatus{
+		Paused:               snap.paused,
+		Fairness:             snap.fairness,
+		LocalWorkers:         s.workerCount,
+		Queued:               int64(len(snap.jobs)),
+		ThroughputPerMinute:  math.Round(snap.ratePerMinute*100) / 100,
+		AvgProcessingSeconds: math.Round(snap.avgSeconds*10) / 10,
+		Classes:              []models.GradingQueueClassStatus{},
+	}
+	now := time.Now()
+	classIdx := map[string]int{}
+	lastJob := map[string]int{}
+	for i, job := range snap.jobs {
+		if _, ok := classIdx[job.ClassID]; !ok {
+			classIdx[job.ClassID] = len(status.Classes)
+			status.Classes = append(status.Classes, models.GradingQueueClassStatus{ClassID: job.ClassID, ClassName: job.ClassName})
+		}
+		status.Classes[classIdx[job.ClassID]].Queued++
+		lastJob[job.ClassID] = i
+	}
+	for classID, count := range snap.processing {
+		status.Processing += count
+		if _, ok := classIdx[classID]; !ok {
+			classIdx[classID] = len(status.Classes)
+			status.Classes = append(status.Classes, models.GradingQueueClassStatus{ClassID: classID})
+		}
+		status.Classes[classIdx[classID]].Processing = count
+	}
+	for i := range status.Classes {
+		item := &status.Classes[i]
+		if limit, ok := limitByClass[item.ClassID]; ok {
+			value := limit
+			item.MaxConcurrency = &value
+		}
+		if idx, ok := lastJob[item.ClassID]; ok {
+			item.EstimatedDrainAt = snap.estimateAt(idx, now)
+			if item.EstimatedDrainAt != nil && (status.EstimatedDrainAt == nil || item.EstimatedDrainAt.After(*status.EstimatedDrainAt)) {
+				drain := *item.EstimatedDrainAt
+				status.EstimatedDrainAt = &drain
+			}
+		}
+	}
+	return status, nil
+}
@@ -0,0 +1,320 @@
+package services
+
+import (
+	"api-backend/internal/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/lib/pq"
+)
+
+const (
+	groundingRetrieverBM25 = "bm25"
+	// Parameter BM25 standar (Robertson): k1 mengatur saturasi frekuensi term, b normalisasi panjang potongan.
+	groundingBM25K1 = 1.2
+	groundingBM25B  = 0.75
+	// groundingChunkWords adalah panjang satu potongan teks di indeks (jendela kata tanpa tumpang tindih).
+	groundingChunkWords = 150
+	// groundingMaxChunksPerSource membatasi potongan dari satu materi/modul agar modul tebal tidak mendominasi indeks.
+	groundingMaxChunksPerSource = 40
+	// groundingOwnMaterialBoost mengutamakan potongan dari materi soal itu sendiri dibanding materi lain di kelas.
+	groundingOwnMaterialBoost = 1.25
+	// groundingMinPassages: bila hanya sedikit potongan yang cocok, materi soal tetap disertakan sebagai konteks dasar.
+	groundingMinPassages = 2
+)
+
+type groundingChunk struct {
+	passage models.GroundingPassage
+	terms   map[string]int
+	length  int
+}
+
+type classGroundingIndex struct {
+	fingerprint string
+	chunks      []groundingChunk
+	docFreq     map[string]int
+	avgLength   float64
+}
+
+// GroundingIndex menyimpan indeks BM25 per kelas di memori atas materi, section card, dan teks modul ajar.
+// Indeks dibangun ulang saat sidik jari isi kelas (jumlah dan updated_at materi/modul) berubah.
+type GroundingIndex struct {
+	db      *sql.DB
+	mu      sync.Mutex
+	classes map[string]*classGroundingIndex
+}
+
+func NewGroundingIndex(db *sql.DB) *GroundingIndex {
+	return &GroundingIndex{db: db, classes: map[string]*classGroundingIndex{}}
+}
+
+// Search mengambil potongan teratas untuk query dari indeks kelas. Potongan dari materialID diberi boost.
+func (g *GroundingIndex) Search(classID, materialID, queryText string, limit int) (*models.GroundingLog, error) {
+	index, err := g.indexFor(classID)
+	if err != nil {
+		return nil, err
+	}
+	result := &models.GroundingLog{
+		Retriever:        groundingRetrieverBM25,
+		IndexFingerprint: index.fingerprint,
+		IndexedChunks:    len(index.chunks),
+		Passages:         []models.GroundingPassage{},
+	}
+	if len(index.chunks) == 0 || limit <= 0 {
+		return result, nil
+	}
+
+	queryTerms := map[string]struct{}{}
+	for _, term := range groundingTokens(queryText) {
+		queryTerms[term] = struct{}{}
+	}
+
+	type scored struct {
+		idx   int
+		score float64
+	}
+	ranked := make([]scored, 0, len(index.chunks))
+	total := float64(len(index.chunks))
+	for i, chunk := range index.chunks {
+		score := 0.0
+		for term := range queryTerms {
+			tf := float64(chunk.terms[term])
+			if tf == 0 {
+				continue
+			}
+			df := float64(index.docFreq[term])
+			idf := math.Log(1 + (total-df+0.5)/(df+0.5))
+			norm := 1 - groundingBM25B + groundingBM25B*float64(chunk.length)/index.avgLength
+			score += idf * tf * (groundingBM25K1 + 1) / (tf + groundingBM25K1*norm)
+		}
+		if score > 0 && materialID != "" && chunk.passage.MaterialID == materialID {
+			score *= groundingOwnMaterialBoost
+		}
+		ranked = append(ranked, scored{idx: i, score: score})
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].score > ranked[j].score
+	})
+
+	picked := map[int]struct{}{}
+	for _, item := range ranked {
+		if item.score <= 0 || len(result.Passages) >= limit {
+			break
+		}
+		passage := index.chunks[item.idx].passage
+		passage.Score = math.Round(item.score*1000) / 1000
+		result.Passages = append(result.Passages, passage)
+		picked[item.idx] = struct{}{}
+	}
+	// Jawaban yang nyaris tidak beririsan dengan materi tetap dinilai dengan konteks materi soalnya sendiri.
+	for i, chunk := range index.chunks {
+		if len(result.Passages) >= groundingMinPassages || len(result.Passages) >= limit {
+			break
+		}
+		if _, ok := picked[i]; ok || materialID == "" || chunk.passage.MaterialID != materialID {
+			continue
+		}
+		result.Passages = append(result.Passages, chunk.passage)
+	}
+	return result, nil
+}
+
+func (g *GroundingIndex) indexFor(classID string) (*classGroundingIndex, error) {
+	fingerprint, err := g.classFingerprint(classID)
+	if err != nil {
+		return nil, err
+	}
+	g.mu.Lock()
+	cached := g.classes[classID]
+	g.mu.Unlock()
+	if cached != nil && cached.fingerprint == fingerprint {
+		return cached, nil
+	}
+
+	index, err := g.build(classID, fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	g.mu.Lock()
+	g.classes[classID] = index
+	g.mu.Unlock()
+	return index, nil
+}
+
+func (g *GroundingIndex) classFingerprint(classID string) (string, error) {
+	var materials, modules string
+	if err := g.db.QueryRowContext(
+		context.Background(),
+		`SELECT
+			(SELECT COUNT(1)::text || '@' || COALESCE(MAX(COALESCE(updated_at, created_at))::text, '') FROM materials WHERE class_id = $1),
+			(SELECT COUNT(1)::text || '@' || COALESCE(MAX(COALESCE(updated_at, created_at))::text, '') FROM class_teaching_modules WHERE class_id = $1)`,
+		classID,
+	).Scan(&materials, &modules); err != nil {
+		return "", fmt.Errorf("failed to load grounding index fingerprint: %w", err)
+	}
+	return "materials:" + materials + ";modules:" + modules, nil
+}
+
+func (g *GroundingIndex) build(classID, fingerprint string) (*classGroundingIndex, error) {
+	index := &classGroundingIndex{fingerprint: fingerprint, docFreq: map[string]int{}}
+
+	rows, err := g.db.QueryContext(
+		context.Background(),
+		`SELECT id, judul, isi_materi, capaian_pembelajaran, kata_kunci
+		 FROM materials
+		 WHERE class_id = $1
+		 ORDER BY created_at ASC, id ASC`,
+		classID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load materials for grounding index: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			materialID string
+			title      string
+			body       sql.NullString
+			outcomes   sql.NullString
+			keywords   []string
+		)
+		if err := rows.Scan(&materialID, &title, &body, &outcomes, pq.Array(&keywords)); err != nil {
+			return nil, fmt.Errorf("failed to scan material for grounding index: %w", err)
+		}
+		index.addMaterial(materialID, title, body.String, outcomes.String, keywords)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate materials for grounding index: %w", err)
+	}
+
+	moduleRows, err := g.db.QueryContext(
+		context.Background(),
+		`SELECT id, nama_modul, file_url, extracted_text, text_extracted_at, text_source_url, text_extraction_status
+		 FROM class_teaching_modules
+		 WHERE class_id = $1
+		 ORDER BY created_at ASC, id ASC`,
+		classID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load teaching modules for grounding index: %w", err)
+	}
+	defer moduleRows.Close()
+	modules := []models.ClassTeachingModule{}
+	for moduleRows.Next() {
+		var module models.ClassTeachingModule
+		if err := moduleRows.Scan(&module.ID, &module.NamaModul, &module.FileURL, &module.ExtractedText, &module.TextExtractedAt, &module.TextSourceURL, &module.ExtractionStatus); err != nil {
+			return nil, fmt.Errorf("failed to scan teaching module for grounding index: %w", err)
+		}
+		modules = append(modules, module)
+	}
+	if err := moduleRows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate teaching modules for grounding index: %w", err)
+	}
+	moduleRows.Close()
+	// Teks modul diambil setelah rows ditutup karena ekstraksi pertama menulis cache ke tabel yang sama.
+	for i := range modules {
+		text, extractErr := loadTeachingModuleText(g.db, &modules[i])
+		if extractErr != nil {
+			log.Printf("WARNING: teaching module %s skipped from grounding index: %v", modules[i].ID, extractErr)
+			continue
+		}
+		index.addChunks(models.GroundingPassage{
+			Source:     groundingSourceLabel("modul", modules[i].NamaModul, "bagian"),
+			SourceType: "teaching_module",
+			ModuleID:   modules[i].ID,
+		}, text)
+	}
+
+	totalLength := 0
+	for _, chunk := range index.chunks {
+		totalLength += chunk.length
+		for term := range chunk.terms {
+			index.docFreq[term]++
+		}
+	}
+	if len(index.chunks) > 0 {
+		index.avgLength = float64(totalLength) / float64(len(index.chunks))
+	}
+	if index.avgLength <= 0 {
+		index.avgLength = 1
+	}
+	return index, nil
+}
+
+func (index *classGroundingIndex) addMaterial(materialID, title, body, outcomes string, keywords []string) {
+	title = strings.TrimSpace(title)
+	summaryParts := make([]string, 0, 3)
+	if title != "" {
+		summaryParts = append(summaryParts, fmt.Sprintf("Judul materi: %s", title))
+	}
+	if trimmed := strings.TrimSpace(outcomes); trimmed != "" {
+		summaryParts = append(summaryParts, fmt.Sprintf("Capaian pembelajaran: %s", trimToWordLimit(trimmed, 60)))
+	}
+	cleanKeywords := make([]string, 0, len(keywords))
+	for _, kw := range keywords {
+		if item := strings.TrimSpace(kw); item != "" {
+			cleanKeywords = append(cleanKeywords, item)
+		}
+	}
+	if len(cleanKeywords) > 0 {
+		summaryParts = append(summaryParts, fmt.Sprintf("Kata kunci materi: %s", strings.Join(cleanKeywords, ", ")))
+	}
+	if len(summaryParts) > 0 {
+		index.addChunks(models.GroundingPassage{
+			Source:     groundingSourceLabel("materi", title, "ringkasan"),
+			SourceType: "material_summary",
+			MaterialID: materialID,
+		}, strings.Join(summaryParts, "\n"))
+	}
+
+	cards := extractSectionCardCandidates(body)
+	for _, card := range cards {
+		index.addChunks(models.GroundingPassage{
+			Source:     groundingSourceLabel("materi", title, card.Label),
+			SourceType: "section_card",
+			MaterialID: materialID,
+		}, card.Text)
+	}
+	if len(cards) == 0 {
+		index.addChunks(models.GroundingPassage{
+			Source:     groundingSourceLabel("materi", title, "bagian"),
+			SourceType: "material_text",
+			MaterialID: materialID,
+		}, extractRelevantMaterialText(body))
+	}
+}
+
+// addChunks memecah teks satu sumber menjadi potongan; label diberi nomor urut bila lebih dari satu potongan.
+func (index *classGroundingIndex) addChunks(base models.GroundingPassage, text string) {
+	chunks := splitGroundingChunks(text, groundingChunkWords, groundingMaxChunksPerSource)
+	for i, chunkText := range chunks {
+		terms := groundingTokens(chunkText)
+		if len(terms) == 0 {
+			continue
+		}
+		passage := base
+		passage.Text = chunkText
+		if len(chunks) > 1 || strings.HasSuffix(base.Source, "/bagian") {
+			passage.Source = fmt.Sprintf("%s_%d", base.Source, i+1)
+		}
+		termFreq := make(map[string]int, len(terms))
+		for _, term := range terms {
+			termFreq[term]++
+		}
+		index.chunks = append(index.chunks, groundingChunk{passage: passage, terms: termFreq, length: len(terms)})
+	}
+}
+
+func groundingSourceLabel(kind, name, part string) string {
+	name = compactGroundingSpaces(strings.ReplaceAll(name, "/", "-"))
+	if name == "" {
+		name = "tanpa_judul"
+	}
+	return kind + "/" + name + "/" + part
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"api-backend/internal/models"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	defaultRegradeLimit = 200
+	maxRegradeLimit     = 1000
+)
+
+// RegradeSubmissionsByModel memilih submission essay yang nilainya dihasilkan oleh modelName
+// dan/atau sebelum gradedBefore, lalu memasukkannya kembali ke antrean dengan cache dilewati.
+// Hasil terlama diproses lebih dulu sehingga migrasi model dapat dilakukan bertahap per batch.
+// Job selalu menimpa ai_results meski soal memakai nilai terbaik; hasil lama diarsipkan ke ai_result_history.
+func (s *EssaySubmissionService) RegradeSubmissionsByModel(modelName string, gradedBefore *time.Time, includeUnknownModel bool, classID string, limit int, dryRun bool) (*models.RegradeByModelResponse, error) {
+	modelName = strings.TrimSpace(modelName)
+	classID = strings.TrimSpace(classID)
+	if modelName == "" && gradedBefore == nil {
+		return nil, fmt.Errorf("model_name or graded_before is required")
+	}
+	if limit <= 0 {
+		limit = defaultRegradeLimit
+	}
+	if limit > maxRegradeLimit {
+		limit = maxRegradeLimit
+	}
+
+	clauses := []string{"es.submission_type = 'essay'", "es.ai_grading_status = 'completed'"}
+	args := []interface{}{}
+	argPos := 1
+	if modelName != "" {
+		// Ensemble menyimpan beberapa model dipisah koma; cocokkan per elemen.
+		modelClause := fmt.Sprintf("$%d = ANY(string_to_array(COALESCE(ar.model_name, ''), ','))", argPos)
+		if includeUnknownModel {
+			modelClause = "(" + modelClause + " OR ar.model_name IS NULL)"
+		}
+		clauses = append(clauses, modelClause)
+		args = append(args, modelName)
+		argPos++
+	} else if !includeUnknownModel {
+		clauses = append(clauses, "ar.model_name IS NOT NULL")
+	}
+	if gradedBefore != nil {
+		clauses = append(clauses, fmt.Sprintf("ar.generated_at < $%d", argPos))
+		args = append(args, *gradedBefore)
+		argPos++
+	}
+	if classID != "" {
+		clauses = append(clauses, fmt.Sprintf("m.class_id = $%d", argPos))
+		args = append(args, classID)
+		argPos++
+	}
+	args = append(args, limit)
+
+	query := fmt.Sprintf(`
+		SELECT es.id, ar.model_name, ar.generated_at
+		FROM essay_submissions es
+		JOIN ai_results ar ON ar.submission_id = es.id
+		JOIN essay_questions eq ON eq.id = es.soal_id
+		JOIN materials m ON m.id = eq.material_id
+		WHERE %s
+		ORDER BY ar.generated_at ASC
+		LIMIT $%d`, strings.Join(clauses, " AND "), argPos)
+
+	rows, err := s.db.QueryContext(context.Background(), query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select submissions for regrade: %w", err)
+	}
+	defer rows.Close()
+
+	resp := &models.RegradeByModelResponse{DryRun: dryRun, Candidates: []models.RegradeByModelCandidate{}}
+	ids := make([]string, 0)
+	for rows.Next() {
+		var item models.RegradeByModelCandidate
+		if err := rows.Scan(&item.SubmissionID, &item.ModelName, &item.GeneratedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan regrade candidate: %w", err)
+		}
+		resp.Candidates = append(resp.Candidates, item)
+		ids = append(ids, item.SubmissionID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate regrade candidates: %w", err)
+	}
+	resp.Matched = len(ids)
+	if dryRun || len(ids) == 0 {
+		return resp, nil
+	}
+
+	result, err := s.requeueSubmissions(ids, requeueOptions{BypassCache: true, ForceReplace: true})
+	if err != nil {
+		return nil, err
+	}
+	resp.Result = result
+	return resp, nil
+}
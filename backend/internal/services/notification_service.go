@@ -892,5 +892,30 @@ func (s *NotificationService) buildSuperadminSeeds(_ string) ([]notificationSeed
 		})
 	}
 
+	if driftReport, err := NewDriftMonitorService(s.db, NewSystemSettingService(s.db)).BuildReport(0); err == nil {
+		critical := 0
+		for _, groups := range [][]models.DriftGroup{driftReport.ByQuestion, driftReport.ByTeacher, driftReport.ByModel} {
+			for _, group := range FlaggedGroups(groups) {
+				if group.Level == "critical" {
+					critical++
+				}
+			}
+		}
+		if critical > 0 {
+			seeds = append(seeds, notificationSeed{
+				ExternalKey: "superadmin-anomaly-ai-drift-critical",
+				Category:    "anomaly_alert",
+				Title:       "Drift skor AI vs guru",
+				Message:     fmt.Sprintf("%d soal/guru/model menunjukkan selisih skor AI vs guru di atas batas kritis.", critical),
+				Href:        stringPtr("/dashboard/superadmin/monitoring"),
+				EventAt:     time.Now(),
+				Payload: map[string]interface{}{
+					"critical_groups": critical,
+					"window_days":     driftReport.Thresholds.WindowDays,
+				},
+			})
+		}
+	}
+
 	return seeds, nil
 }
@@ -0,0 +1,345 @@
+package services
+
+import (
+	"api-backend/internal/models"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Aksi bila jawaban siswa terdeteksi mengandung prompt injection.
+const (
+	PromptInjectionActionFlag = "flag" // Tetap dinilai AI, ditandai perlu review guru.
+	PromptInjectionActionHold = "hold" // Tidak dikirim ke AI; menunggu penilaian guru (status held).
+	PromptInjectionActionOff  = "off"  // Deteksi dimatikan.
+
+	promptInjectionActionSettingKey = "prompt_injection_action"
+	promptInjectionExcerptRunes     = 80
+)
+
+// promptInjectionRule adalah satu pola teks yang menyerupai instruksi ke model, bukan jawaban.
+type promptInjectionRule struct {
+	ID       string
+	Severity string
+	Pattern  *regexp.Regexp
+}
+
+var promptInjectionRules = []promptInjectionRule{
+	{
+		ID:       "instruction_override",
+		Severity: "high",
+		Pattern:  regexp.MustCompile(`(?i)\b(ignore|disregard|forget|override|abaikan|lupakan|hiraukan|acuhkan)\b[^.\n]{0,60}\b(instructions?|rules?|rubrics?|prompts?|previous|above|system|instruksi|aturan|perintah|rubrik|sebelumnya|di ?atas)\b`),
+	},
+	{
+		ID:       "score_manipulation",
+		Severity: "high",
+		Pattern:  regexp.MustCompile(`(?i)\b(give|award|assign|berikan|beri|kasih)\b[^.\n]{0,40}\b(full (marks?|scores?|points?)|max(imum)? (scores?|marks?|points?)|perfect scores?|(nilai|skor) (penuh|sempurna|maksimal|maksimum|tertinggi|100)|100 ?(points?|poin|%))`),
+	},
+	{
+		ID:       "role_marker",
+		Severity: "high",
+		Pattern:  regexp.MustCompile(`(?im)(^\s*(system|assistant|developer|sistem|asisten)\s*:|<\|?(im_start|im_end|system|endoftext)\|?>|\[/?INST\]|<</?SYS>>|###\s*(instruction|system|response)\b)`),
+	},
+	{
+		ID:       "output_schema",
+		Severity: "high",
+		// "kutipan:" dan "justifikasi:" lazim di esai biasa, jadi hanya cocok sebagai kunci JSON berkutip.
+		Pattern: regexp.MustCompile(`(?i)("(justifikasi|kutipan)"|"?\b(skor_aspek|skor_diperoleh|feedback_keseluruhan)\b"?)\s*:`),
+	},
+	{
+		ID:       "prompt_marker",
+		Severity: "high",
+		Pattern:  regexp.MustCompile(`(?i)\b(grading rubric|output rules|student'?s essay to grade|deterministic scoring procedure|source priority|calibration examples)\s*:`),
+	},
+	{
+		ID:       "grader_address",
+		Severity: "medium",
+		Pattern:  regexp.MustCompile(`(?i)\b(you are|you're|as an ai|act as|kamu adalah|anda adalah|bertindaklah sebagai)\b[^.\n]{0,30}\b(grader|assistant|model|ai|penilai|asisten|korektor)\b`),
+	},
+	{
+		ID:       "json_fragment",
+		Severity: "medium",
+		Pattern:  regexp.MustCompile(`\{\s*"[A-Za-z_]+"\s*:`),
+	},
+}
+
+// DetectPromptInjection menjalankan semua aturan terhadap teks jawaban siswa.
+// Satu aturan hanya dilaporkan sekali (kecocokan pertama).
+func DetectPromptInjection(text string) []models.PromptInjectionMatch {
+	if strings.TrimSpace(text) == "" {
+		return nil
+	}
+	matches := make([]models.PromptInjectionMatch, 0)
+	for _, rule := range promptInjectionRules {
+		loc := rule.Pattern.FindStringIndex(text)
+		if loc == nil {
+			continue
+		}
+		matches = append(matches, models.PromptInjectionMatch{
+			Rule:     rule.ID,
+			Severity: rule.Severity,
+			Excerpt:  clampPromptText(text[loc[0]:loc[1]], promptInjectionExcerptRunes),
+		})
+	}
+	return matches
+}
+
+// hasHighSeverityInjection bernilai true bila ada aturan high, atau minimal dua aturan medium.
+func hasHighSeverityInjection(matches []models.PromptInjectionMatch) bool {
+	medium := 0
+	for _, match := range matches {
+		if match.Severity == "high" {
+			return true
+		}
+		medium++
+	}
+	return medium >= 2
+}
+
+func promptInjectionRuleIDs(matches []models.PromptInjectionMatch) []string {
+	ids := make([]string, 0, len(matches))
+	for _, match := range matches {
+		ids = append(ids, match.Rule)
+	}
+	return ids
+}
+
+// essayBoundaryPattern mengenali penanda batas blok jawaban, termasuk tiruan dengan nonce karangan siswa.
+var essayBoundaryPattern = regexp.MustCompile(`(?i)<<<\s*(ESSAY|END)-[^<>\n]{0,64}>>>`)
+
+// essayPromptBlock membungkus jawaban siswa dengan penanda batas yang memuat nonce acak per request, sehingga
+// siswa tidak bisa menutup blok jawaban lebih awal lalu menulis instruksi di luarnya. Isi jawaban tidak diubah
+// selain penanda batas tiruan yang dibuang.
+func essayPromptBlock(essay string) string {
+	nonce := newEssayBoundaryNonce()
+	return "<<<ESSAY-" + nonce + ">>>\n" + sanitizeEssayForPrompt(essay) + "\n<<<END-" + nonce + ">>>"
+}
+
+// stableEssayBoundaries mengganti penanda batas bernonce dengan penanda tetap (<<<ESSAY>>>, <<<END>>>).
+// prompt_hash dan seed provider fake dihitung dari hasil ini agar prompt yang sama tetap menghasilkan nilai yang sama.
+func stableEssayBoundaries(prompt string) string {
+	return essayBoundaryPattern.ReplaceAllString(prompt, "<<<${1}>>>")
+}
+
+func newEssayBoundaryNonce() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		// crypto/rand praktis tidak pernah gagal; waktu nano tetap tidak bisa ditebak siswa saat menulis jawaban.
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(buf)
+}
+
+// sanitizeEssayForPrompt membuang karakter kontrol dan penanda batas tiruan, lalu meringkas baris kosong
+// beruntun. Tanda kutip dan teks lain dibiarkan apa adanya agar yang dinilai sama dengan yang ditulis siswa.
+func sanitizeEssayForPrompt(essay string) string {
+	cleaned := strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return r
+		}
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, strings.ReplaceAll(essay, "\r\n", "\n"))
+	cleaned = essayBoundaryPattern.ReplaceAllString(cleaned, "")
+	for strings.Contains(cleaned, "\n\n\n") {
+		cleaned = strings.ReplaceAll(cleaned, "\n\n\n", "\n\n")
+	}
+	return cleaned
+}
+
+// GetPromptInjectionAction membaca aksi deteksi prompt injection, default flag.
+func (s *SystemSettingService) GetPromptInjectionAction() (string, error) {
+	value, err := s.GetSetting(promptInjectionActionSettingKey)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return PromptInjectionActionFlag, nil
+		}
+		return "", err
+	}
+	normalized, _ := NormalizePromptInjectionAction(value)
+	return normalized, nil
+}
+
+// NormalizePromptInjectionAction memvalidasi nilai setting; nilai tidak dikenal menjadi flag.
+func NormalizePromptInjectionAction(value string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case PromptInjectionActionFlag:
+		return PromptInjectionActionFlag, true
+	case PromptInjectionActionHold:
+		return PromptInjectionActionHold, true
+	case PromptInjectionActionOff:
+		return PromptInjectionActionOff, true
+	default:
+		return PromptInjectionActionFlag, false
+	}
+}
+
+// recordPromptInjectionCheck menyimpan hasil deteksi terakhir pada submission.
+func (s *EssaySubmissionService) recordPromptInjectionCheck(submissionID string, matches []models.PromptInjectionMatch) error {
+	var rulesJSON interface{}
+	if len(matches) > 0 {
+		payload, err := json.Marshal(matches)
+		if err != nil {
+			return err
+		}
+		rulesJSON = string(payload)
+	}
+	_, err := s.db.ExecContext(
+		context.Background(),
+		`UPDATE essay_submissions
+		 SET injection_flagged = $1,
+		     injection_rules = $2::jsonb,
+		     injection_checked_at = NOW()
+		 WHERE id = $3`,
+		len(matches) > 0,
+		rulesJSON,
+		submissionID,
+	)
+	return err
+}
+
+// GetPromptInjectionSummary merekap submission yang diperiksa/ditandai dalam `days` hari terakhir.
+func (s *EssaySubmissionService) GetPromptInjectionSummary(days int) (*models.PromptInjectionSummary, error) {
+	if days <= 0 {
+		days = 7
+	}
+	summary := &models.PromptInjectionSummary{
+		Days:   days,
+		Action: PromptInjectionActionFlag,
+		ByRule: []models.PromptInjectionRuleCount{},
+		Recent: []models.PromptInjectionFlaggedSubmission{},
+	}
+	if s.settingService != nil {
+		if action, err := s.settingService.GetPromptInjectionAction(); err == nil {
+			summary.Action = action
+		}
+	}
+
+	if err := s.db.QueryRowContext(
+		context.Background(),
+		`SELECT COUNT(*),
+		        COUNT(*) FILTER (WHERE injection_flagged),
+		        COUNT(*) FILTER (WHERE injection_flagged AND ai_grading_status = 'held')
+		 FROM essay_submissions
+		 WHERE injection_checked_at >= NOW() - make_interval(days => $1)`,
+		days,
+	).Scan(&summary.Checked, &summary.Flagged, &summary.Held); err != nil {
+		return nil, fmt.Errorf("failed to count prompt injection checks: %w", err)
+	}
+
+	ruleRows, err := s.db.QueryContext(
+		context.Background(),
+		`SELECT match->>'rule', COUNT(*)
+		 FROM essay_submissions es
+		 CROSS JOIN LATERAL jsonb_array_elements(es.injection_rules) AS match
+		 WHERE es.injection_flagged
+		   AND es.injection_checked_at >= NOW() - make_interval(days => $1)
+		 GROUP BY 1
+		 ORDER BY 2 DESC`,
+		days,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count prompt injection rules: %w", err)
+	}
+	defer ruleRows.Close()
+	for ruleRows.Next() {
+		var item models.PromptInjectionRuleCount
+		if err := ruleRows.Scan(&item.Rule, &item.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan prompt injection rule count: %w", err)
+		}
+		summary.ByRule = append(summary.ByRule, item)
+	}
+	if err := ruleRows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate prompt injection rule counts: %w", err)
+	}
+
+	recentRows, err := s.db.QueryContext(
+		context.Background(),
+		`SELECT es.id, es.soal_id, es.siswa_id, COALESCE(u.nama_lengkap, ''), COALESCE(c.class_name, ''),
+		        es.ai_grading_status, COALESCE(es.injection_rules::text, '[]'), es.injection_checked_at
+		 FROM essay_submissions es
+		 LEFT JOIN users u ON u.id = es.siswa_id
+		 LEFT JOIN essay_questions eq ON eq.id = es.soal_id
+		 LEFT JOIN materials m ON m.id = eq.material_id
+		 LEFT JOIN classes c ON c.id = m.class_id
+		 WHERE es.injection_flagged
+		   AND es.injection_checked_at >= NOW() - make_interval(days => $1)
+		 ORDER BY es.injection_checked_at DESC
+		 LIMIT 20`,
+		days,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load flagged submissions: %w", err)
+	}
+	defer recentRows.Close()
+	for recentRows.Next() {
+		var (
+			item     models.PromptInjectionFlaggedSubmission
+			rulesRaw string
+		)
+		if err := recentRows.Scan(&item.SubmissionID, &item.QuestionID, &item.StudentID, &item.StudentName, &item.ClassName, &item.Status, &rulesRaw, &item.CheckedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan flagged submission: %w", err)
+		}
+		_ = json.Unmarshal([]byte(rulesRaw), &item.Matches)
+		summary.Recent = append(summary.Recent, item)
+	}
+	if err := recentRows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate flagged submissions: %w", err)
+	}
+	return summary, nil
+}
+
+// promptInjectionReviewReason menyusun alasan review guru dari aturan yang cocok.
+func promptInjectionReviewReason(matches []models.PromptInjectionMatch, held bool) string {
+	prefix := "Terdeteksi pola prompt injection"
+	if held {
+		prefix = "Ditahan untuk penilaian guru: terdeteksi pola prompt injection"
+	}
+	return fmt.Sprintf("%s (%s)", prefix, strings.Join(promptInjectionRuleIDs(matches), ", "))
+}
+
+// screenPromptInjection memeriksa jawaban sebelum dikirim ke AI dan menyimpan hasilnya.
+// held=true berarti submission ditahan untuk penilaian guru dan tidak boleh dinilai AI.
+func (s *EssaySubmissionService) screenPromptInjection(job essayGradingJob) (matches []models.PromptInjectionMatch, held bool) {
+	action := PromptInjectionActionFlag
+	if s.settingService != nil {
+		if configured, err := s.settingService.GetPromptInjectionAction(); err == nil {
+			action = configured
+		} else {
+			log.Printf("WARNING: failed to load prompt injection setting: %v", err)
+		}
+	}
+	if action == PromptInjectionActionOff {
+		return nil, false
+	}
+
+	matches = DetectPromptInjection(job.TeksJawaban)
+	if err := s.recordPromptInjectionCheck(job.SubmissionID, matches); err != nil {
+		log.Printf("WARNING: failed to record prompt injection check for %s: %v", job.SubmissionID, err)
+	}
+	if len(matches) == 0 {
+		return nil, false
+	}
+	log.Printf("WARNING: prompt injection rules matched for submission %s: %s", job.SubmissionID, strings.Join(promptInjectionRuleIDs(matches), ", "))
+	if action != PromptInjectionActionHold || !hasHighSeverityInjection(matches) {
+		return matches, false
+	}
+
+	if applied, _ := s.updateJobGradingStatus(job, "held", "Jawaban ditahan untuk dinilai guru (terdeteksi instruksi ke AI).", nil); !applied {
+		return matches, true
+	}
+	if err := s.setSubmissionReviewFlag(job.SubmissionID, true, promptInjectionReviewReason(matches, true)); err != nil {
+		log.Printf("WARNING: failed to flag held submission %s: %v", job.SubmissionID, err)
+	}
+	return matches, true
+}
@@ -0,0 +1,557 @@
+package services
+
+import (
+	"api-backend/internal/models"
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+const (
+	maxPromptTemplateChars  = 30000
+	promptTemplateCacheTTL  = 30 * time.Second
+	promptTemplateBuiltinID = "builtin"
+)
+
+var (
+	ErrPromptTemplateNotFound = errors.New("prompt template not found")
+	ErrPromptTemplateLocked   = errors.New("prompt template has been activated before and can no longer be edited; create a new version instead")
+	ErrPromptTemplateActive   = errors.New("active prompt template cannot be deleted; activate another version first")
+	ErrInvalidPromptTemplate  = errors.New("invalid prompt template")
+)
+
+// PromptTemplateRef menandai template yang dipakai untuk satu panggilan AI.
+// Version 0 berarti template bawaan (builtin) di kode.
+type PromptTemplateRef struct {
+	Name    string
+	Version int
+}
+
+// String menghasilkan label yang disimpan di ai_results dan ai_api_usage_logs, mis. grade_essay@v3.
+func (r PromptTemplateRef) String() string {
+	if strings.TrimSpace(r.Name) == "" {
+		return ""
+	}
+	if r.Version <= 0 {
+		return r.Name + "@" + promptTemplateBuiltinID
+	}
+	return fmt.Sprintf("%s@v%d", r.Name, r.Version)
+}
+
+type activePromptTemplate struct {
+	ref      PromptTemplateRef
+	tmpl     *template.Template
+	loadedAt time.Time
+}
+
+// PromptTemplateService mengelola template prompt berversi di tabel prompt_templates.
+type PromptTemplateService struct {
+	db     *sql.DB
+	mu     sync.RWMutex
+	active map[string]activePromptTemplate
+}
+
+func NewPromptTemplateService(db *sql.DB) *PromptTemplateService {
+	return &PromptTemplateService{db: db, active: map[string]activePromptTemplate{}}
+}
+
+// IsKnownPromptTemplateName memeriksa apakah nama template dikenali sistem.
+func IsKnownPromptTemplateName(name string) bool {
+	_, ok := promptTemplateSpecs[name]
+	return ok
+}
+
+func parsePromptTemplate(name, content string) (*template.Template, error) {
+	return template.New(name).Funcs(promptTemplateFuncs).Option("missingkey=error").Parse(content)
+}
+
+func samplePromptTemplateData() models.PromptTemplateData {
+	return models.PromptTemplateData{
+		Question:              "Jelaskan latar belakang terjadinya Sumpah Pemuda tahun 1928!",
+		Rubric:                "Aspek: Pemahaman Konsep\n- Skor 0: Tidak menjawab\n- Skor 1: Menyebut satu faktor\n- Skor 2: Menjelaskan faktor dengan tepat\n\n",
+		RubricMode:            "effective_question_rubric",
+		IdealAnswer:           "Sumpah Pemuda lahir dari kesadaran pemuda untuk bersatu melawan penjajahan.",
+		Grounding:             "Kongres Pemuda II diselenggarakan pada 27-28 Oktober 1928 di Batavia.",
+		GroundingSource:       "material_content",
+		Essay:                 "Sumpah Pemuda terjadi karena para pemuda ingin bersatu sebagai satu bangsa.",
+		Keywords:              "persatuan, Kongres Pemuda II, 1928",
+		Exemplars:             "Example 1 (teacher final score: 67/100)\nAnswer: \"Para pemuda ingin bersatu.\"\nTeacher aspect scores: Pemahaman Konsep = 1\n\n",
+		MaterialTitle:         "Pergerakan Nasional Indonesia",
+		MaterialContent:       "Pergerakan nasional ditandai berdirinya organisasi modern seperti Budi Utomo dan Kongres Pemuda.",
+		TeachingModuleContext: "Modul ajar: Bab 3 Pergerakan Nasional.",
+		ExistingQuestions:     []string{"Sebutkan organisasi pergerakan nasional pertama di Indonesia!"},
+		RubricType:            "analitik",
+		TargetLevel:           "C2",
+	}
+}
+
+// requiredSampleValue mengembalikan nilai contoh untuk placeholder wajib agar validasi bisa
+// memastikan placeholder tersebut benar-benar dirender, bukan sekadar ditulis di komentar.
+func requiredSampleValue(data models.PromptTemplateData, placeholder string) string {
+	switch placeholder {
+	case ".Essay":
+		return data.Essay
+	case ".Rubric":
+		return data.Rubric
+	case ".Question":
+		return data.Question
+	case ".MaterialContent":
+		return data.MaterialContent
+	}
+	return ""
+}
+
+// ValidatePromptTemplateContent mem-parse dan merender template dengan data contoh,
+// lalu memastikan semua placeholder wajib muncul di hasil render.
+func ValidatePromptTemplateContent(name, content string) error {
+	spec, ok := promptTemplateSpecs[name]
+	if !ok {
+		return fmt.Errorf("%w: unknown template name %q", ErrInvalidPromptTemplate, name)
+	}
+	if strings.TrimSpace(content) == "" {
+		return fmt.Errorf("%w: content is empty", ErrInvalidPromptTemplate)
+	}
+	if len(content) > maxPromptTemplateChars {
+		return fmt.Errorf("%w: content exceeds %d characters", ErrInvalidPromptTemplate, maxPromptTemplateChars)
+	}
+	tmpl, err := parsePromptTemplate(name, content)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPromptTemplate, err)
+	}
+	sample := samplePromptTemplateData()
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, sample); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPromptTemplate, err)
+	}
+	rendered := out.String()
+	for _, placeholder := range spec.required {
+		if !strings.Contains(rendered, strings.TrimSpace(requiredSampleValue(sample, placeholder))) {
+			return fmt.Errorf("%w: placeholder {{%s}} is required", ErrInvalidPromptTemplate, placeholder)
+		}
+	}
+	return nil
+}
+
+func (s *PromptTemplateService) invalidate(name string) {
+	s.mu.Lock()
+	delete(s.active, name)
+	s.mu.Unlock()
+}
+
+// loadActive mengambil template aktif untuk name (cache 30 detik). Bila tidak ada versi aktif
+// atau database tidak tersedia, template bawaan yang dipakai.
+func (s *PromptTemplateService) loadActive(name string) activePromptTemplate {
+	builtin := activePromptTemplate{ref: PromptTemplateRef{Name: name}, tmpl: builtinPromptTemplates[name]}
+	if s == nil || s.db == nil {
+		return builtin
+	}
+
+	s.mu.RLock()
+	cached, ok := s.active[name]
+	s.mu.RUnlock()
+	if ok && time.Since(cached.loadedAt) < promptTemplateCacheTTL {
+		return cached
+	}
+
+	var (
+		version int
+		content string
+	)
+	err := s.db.QueryRowContext(
+		context.Background(),
+		`SELECT version, content FROM prompt_templates WHERE name = $1 AND is_active = TRUE LIMIT 1`,
+		name,
+	).Scan(&version, &content)
+	entry := builtin
+	switch {
+	case err == sql.ErrNoRows:
+	case err != nil:
+		log.Printf("WARNING: failed to load active prompt template %s: %v", name, err)
+		if ok {
+			return cached
+		}
+		return builtin
+	default:
+		tmpl, parseErr := parsePromptTemplate(name, content)
+		if parseErr != nil {
+			log.Printf("WARNING: active prompt template %s v%d is invalid, using builtin: %v", name, version, parseErr)
+		} else {
+			entry = activePromptTemplate{ref: PromptTemplateRef{Name: name, Version: version}, tmpl: tmpl}
+		}
+	}
+	entry.loadedAt = time.Now()
+
+	s.mu.Lock()
+	s.active[name] = entry
+	s.mu.Unlock()
+	return entry
+}
+
+// Render merender template aktif untuk name. Bila template aktif gagal dirender,
+// template bawaan dipakai agar grading tidak berhenti karena kesalahan konfigurasi.
+func (s *PromptTemplateService) Render(name string, data models.PromptTemplateData) (string, PromptTemplateRef, error) {
+	entry := s.loadActive(name)
+	if entry.tmpl == nil {
+		return "", PromptTemplateRef{}, fmt.Errorf("%w: unknown template name %q", ErrInvalidPromptTemplate, name)
+	}
+	var out bytes.Buffer
+	if err := entry.tmpl.Execute(&out, data); err != nil {
+		if entry.ref.Version == 0 {
+			return "", entry.ref, err
+		}
+		log.Printf("WARNING: failed to render prompt template %s, using builtin: %v", entry.ref.String(), err)
+		out.Reset()
+		ref := PromptTemplateRef{Name: name}
+		if err := builtinPromptTemplates[name].Execute(&out, data); err != nil {
+			return "", ref, err
+		}
+		return out.String(), ref, nil
+	}
+	return out.String(), entry.ref, nil
+}
+
+const promptTemplateColumns = `id, name, version, content, description, is_active, created_by::text, created_at, updated_at, activated_at`
+
+func scanPromptTemplate(scanner interface{ Scan(...interface{}) error }) (*models.PromptTemplate, error) {
+	var (
+		item        models.PromptTemplate
+		description sql.NullString
+		createdBy   sql.NullString
+		activatedAt sql.NullTime
+	)
+	if err := scanner.Scan(
+		&item.ID,
+		&item.Name,
+		&item.Version,
+		&item.Content,
+		&description,
+		&item.IsActive,
+		&createdBy,
+		&item.CreatedAt,
+		&item.UpdatedAt,
+		&activatedAt,
+	); err != nil {
+		return nil, err
+	}
+	if description.Valid {
+		item.Description = &description.String
+	}
+	if createdBy.Valid {
+		item.CreatedBy = &createdBy.String
+	}
+	if activatedAt.Valid {
+		t := activatedAt.Time
+		item.ActivatedAt = &t
+	}
+	return &item, nil
+}
+
+// ListSummaries mengembalikan daftar jenis template beserta versi yang sedang aktif.
+func (s *PromptTemplateService) ListSummaries() ([]models.PromptTemplateSummary, error) {
+	counts := map[string]int{}
+	rows, err := s.db.QueryContext(context.Background(), `SELECT name, COUNT(*) FROM prompt_templates GROUP BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("error counting prompt templates: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		var count int
+		if err := rows.Scan(&name, &count); err != nil {
+			return nil, fmt.Errorf("error scanning prompt template count: %w", err)
+		}
+		counts[name] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating prompt template counts: %w", err)
+	}
+
+	names := make([]string, 0, len(promptTemplateSpecs))
+	for name := range promptTemplateSpecs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	out := make([]models.PromptTemplateSummary, 0, len(names))
+	for _, name := range names {
+		spec := promptTemplateSpecs[name]
+		out = append(out, models.PromptTemplateSummary{
+			Name:           name,
+			ActiveVersion:  s.loadActive(name).ref.String(),
+			Placeholders:   spec.placeholders,
+			Required:       spec.required,
+			BuiltinContent: spec.builtin,
+			VersionCount:   counts[name],
+		})
+	}
+	return out, nil
+}
+
+// List mengembalikan semua versi template, terbaru lebih dulu. name kosong berarti semua jenis.
+func (s *PromptTemplateService) List(name string) ([]models.PromptTemplate, error) {
+	query := `SELECT ` + promptTemplateColumns + ` FROM prompt_templates`
+	args := []interface{}{}
+	if strings.TrimSpace(name) != "" {
+		query += ` WHERE name = $1`
+		args = append(args, strings.TrimSpace(name))
+	}
+	query += ` ORDER BY name ASC, version DESC`
+
+	rows, err := s.db.QueryContext(context.Background(), query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying prompt templates: %w", err)
+	}
+	defer rows.Close()
+
+	items := []models.PromptTemplate{}
+	for rows.Next() {
+		item, err := scanPromptTemplate(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning prompt template: %w", err)
+		}
+		items = append(items, *item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating prompt templates: %w", err)
+	}
+	return items, nil
+}
+
+func (s *PromptTemplateService) GetByID(id string) (*models.PromptTemplate, error) {
+	item, err := scanPromptTemplate(s.db.QueryRowContext(
+		context.Background(),
+		`SELECT `+promptTemplateColumns+` FROM prompt_templates WHERE id = $1`,
+		id,
+	))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrPromptTemplateNotFound
+		}
+		return nil, fmt.Errorf("error fetching prompt template: %w", err)
+	}
+	return item, nil
+}
+
+// Create menyimpan versi baru (nonaktif) dengan nomor versi berikutnya untuk name tersebut.
+func (s *PromptTemplateService) Create(userID string, req models.CreatePromptTemplateRequest) (*models.PromptTemplate, error) {
+	name := strings.TrimSpace(req.Name)
+	if err := ValidatePromptTemplateContent(name, req.Content); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Kunci per nama agar dua superadmin tidak mendapat nomor versi yang sama.
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext('prompt_templates:' || $1))`, name); err != nil {
+		return nil, fmt.Errorf("error locking prompt template versions: %w", err)
+	}
+	var nextVersion int
+	if err := tx.QueryRow(`SELECT COALESCE(MAX(version), 0) + 1 FROM prompt_templates WHERE name = $1`, name).Scan(&nextVersion); err != nil {
+		return nil, fmt.Errorf("error computing prompt template version: %w", err)
+	}
+
+	now := time.Now()
+	created, err := scanPromptTemplate(tx.QueryRow(
+		`INSERT INTO prompt_templates (name, version, content, description, is_active, created_by, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, FALSE, NULLIF($5, '')::uuid, $6, $6)
+		 RETURNING `+promptTemplateColumns,
+		name,
+		nextVersion,
+		req.Content,
+		nullableTrimmedString(req.Description),
+		strings.TrimSpace(userID),
+		now,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("error creating prompt template: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing prompt template: %w", err)
+	}
+	return created, nil
+}
+
+// Update mengubah konten/deskripsi versi yang belum pernah diaktifkan.
+// Deskripsi tetap boleh diubah setelah aktif karena tidak memengaruhi prompt.
+func (s *PromptTemplateService) Update(id string, req models.UpdatePromptTemplateRequest) (*models.PromptTemplate, error) {
+	current, err := s.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if req.Content != nil {
+		if current.ActivatedAt != nil {
+			return nil, ErrPromptTemplateLocked
+		}
+		if err := ValidatePromptTemplateContent(current.Name, *req.Content); err != nil {
+			return nil, err
+		}
+	}
+	if req.Content == nil && req.Description == nil {
+		return nil, fmt.Errorf("no fields to update")
+	}
+
+	content := current.Content
+	if req.Content != nil {
+		content = *req.Content
+	}
+	description := current.Description
+	if req.Description != nil {
+		description = req.Description
+	}
+	updated, err := scanPromptTemplate(s.db.QueryRowContext(
+		context.Background(),
+		`UPDATE prompt_templates
+		 SET content = $1, description = $2, updated_at = NOW()
+		 WHERE id = $3
+		 RETURNING `+promptTemplateColumns,
+		content,
+		nullableTrimmedString(description),
+		id,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("error updating prompt template: %w", err)
+	}
+	return updated, nil
+}
+
+func (s *PromptTemplateService) Delete(id string) error {
+	current, err := s.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if current.IsActive {
+		return ErrPromptTemplateActive
+	}
+	if _, err := s.db.ExecContext(context.Background(), `DELETE FROM prompt_templates WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("error deleting prompt template: %w", err)
+	}
+	return nil
+}
+
+// Activate menjadikan versi id sebagai satu-satunya versi aktif untuk namanya.
+func (s *PromptTemplateService) Activate(id string) (*models.PromptTemplate, error) {
+	current, err := s.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidatePromptTemplateContent(current.Name, current.Content); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(
+		`UPDATE prompt_templates SET is_active = FALSE, updated_at = NOW() WHERE name = $1 AND is_active = TRUE AND id <> $2`,
+		current.Name, id,
+	); err != nil {
+		return nil, fmt.Errorf("error deactivating previous prompt template: %w", err)
+	}
+	activated, err := scanPromptTemplate(tx.QueryRow(
+		`UPDATE prompt_templates
+		 SET is_active = TRUE, activated_at = COALESCE(activated_at, NOW()), updated_at = NOW()
+		 WHERE id = $1
+		 RETURNING `+promptTemplateColumns,
+		id,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("error activating prompt template: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing prompt template activation: %w", err)
+	}
+	s.invalidate(current.Name)
+	return activated, nil
+}
+
+// ResetToBuiltin menonaktifkan semua versi untuk name sehingga template bawaan dipakai lagi.
+func (s *PromptTemplateService) ResetToBuiltin(name string) error {
+	if !IsKnownPromptTemplateName(name) {
+		return fmt.Errorf("%w: unknown template name %q", ErrInvalidPromptTemplate, name)
+	}
+	if _, err := s.db.ExecContext(
+		context.Background(),
+		`UPDATE prompt_templates SET is_active = FALSE, updated_at = NOW() WHERE name = $1 AND is_active = TRUE`,
+		name,
+	); err != nil {
+		return fmt.Errorf("error resetting prompt template: %w", err)
+	}
+	s.invalidate(name)
+	return nil
+}
+
+// Preview merender template tanpa memanggil model. Urutan sumber konten: Content, TemplateID,
+// lalu versi aktif saat ini.
+func (s *PromptTemplateService) Preview(req models.PreviewPromptTemplateRequest) (*models.PromptTemplatePreview, error) {
+	name := strings.TrimSpace(req.Name)
+	data := samplePromptTemplateData()
+	if req.Data != nil {
+		data = *req.Data
+	}
+
+	var (
+		content string
+		ref     PromptTemplateRef
+	)
+	switch {
+	case strings.TrimSpace(req.Content) != "":
+		content = req.Content
+		ref = PromptTemplateRef{Name: name, Version: -1}
+	case strings.TrimSpace(req.TemplateID) != "":
+		item, err := s.GetByID(strings.TrimSpace(req.TemplateID))
+		if err != nil {
+			return nil, err
+		}
+		name = item.Name
+		content = item.Content
+		ref = PromptTemplateRef{Name: item.Name, Version: item.Version}
+	default:
+		if !IsKnownPromptTemplateName(name) {
+			return nil, fmt.Errorf("%w: unknown template name %q", ErrInvalidPromptTemplate, name)
+		}
+		rendered, activeRef, err := s.Render(name, data)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPromptTemplate, err)
+		}
+		return &models.PromptTemplatePreview{Name: name, Version: activeRef.String(), Rendered: rendered, CharCount: len(rendered)}, nil
+	}
+
+	if err := ValidatePromptTemplateContent(name, content); err != nil {
+		return nil, err
+	}
+	tmpl, err := parsePromptTemplate(name, content)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPromptTemplate, err)
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPromptTemplate, err)
+	}
+	version := ref.String()
+	if ref.Version < 0 {
+		version = name + "@draft"
+	}
+	return &models.PromptTemplatePreview{Name: name, Version: version, Rendered: out.String(), CharCount: out.Len()}, nil
+}
+
+func nullableTrimmedString(value *string) interface{} {
+	if value == nil {
+		return nil
+	}
+	return nullIfEmpty(*value)
+}
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"api-backend/internal/indotext"
 	"api-backend/internal/models"
 	"context"
 	"database/sql"
@@ -130,7 +131,13 @@ func (s *QuestionBankService) ListQuestionBankEntries(_ string, _ string, classI
 		args = append(args, pq.Array(*tags))
 		argPos++
 	}
-	if q != nil && strings.TrimSpace(*q) != "" {
+	// Pencarian teks dicocokkan per term ter-stem setelah query SQL agar "fotosintesis tumbuhan" menemukan
+	// soal berisi "tumbuh-tumbuhan berfotosintesis"; query yang seluruhnya stopword memakai LIKE biasa.
+	var searchTerms []string
+	if q != nil {
+		searchTerms = indotext.Terms(*q)
+	}
+	if q != nil && strings.TrimSpace(*q) != "" && len(searchTerms) == 0 {
 		clauses = append(clauses, fmt.Sprintf("(LOWER(qb.teks_soal) LIKE LOWER($%d) OR LOWER(COALESCE(m.judul, '')) LIKE LOWER($%d) OR LOWER(COALESCE(c.class_name, '')) LIKE LOWER($%d) OR LOWER(COALESCE(qb.subject, '')) LIKE LOWER($%d))", argPos, argPos, argPos, argPos))
 		args = append(args, "%"+strings.TrimSpace(*q)+"%")
 		argPos++
@@ -182,6 +189,9 @@ func (s *QuestionBankService) ListQuestionBankEntries(_ string, _ string, classI
 			emptyRubrics, _ := json.Marshal([]interface{}{})
 			item.Rubrics = emptyRubrics
 		}
+		if len(searchTerms) > 0 && !questionBankEntryMatches(item, searchTerms) {
+			continue
+		}
 		entries = append(entries, item)
 	}
 	if err := rows.Err(); err != nil {
@@ -191,6 +201,34 @@ func (s *QuestionBankService) ListQuestionBankEntries(_ string, _ string, classI
 	return entries, nil
 }
 
+// questionBankEntryMatches memeriksa apakah semua term query muncul pada soal, judul materi, kelas, mapel,
+// kata kunci, atau tag. Term query juga cocok sebagai awalan term entri agar kata yang belum selesai diketik tetap ditemukan.
+func questionBankEntryMatches(item models.QuestionBankEntry, searchTerms []string) bool {
+	fields := []string{item.TeksSoal, item.MaterialTitle, item.ClassName, item.Subject}
+	fields = append(fields, item.Keywords...)
+	fields = append(fields, item.Tags...)
+	entryTerms := map[string]struct{}{}
+	for _, term := range indotext.Terms(strings.Join(fields, " ")) {
+		entryTerms[term] = struct{}{}
+	}
+	for _, want := range searchTerms {
+		if _, ok := entryTerms[want]; ok {
+			continue
+		}
+		found := false
+		for term := range entryTerms {
+			if strings.HasPrefix(term, want) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
 func (s *QuestionBankService) UpdateQuestionBankEntry(entryID string, req models.UpdateQuestionBankEntryRequest, userID, userRole string) (*models.QuestionBankEntry, error) {
 	updates := []string{}
 	args := []interface{}{}
@@ -0,0 +1,321 @@
+package services
+
+import (
+	"api-backend/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	// DefaultFewShotExemplarCount adalah jumlah maksimum contoh guru (K) per prompt grading.
+	DefaultFewShotExemplarCount = 3
+	// MaxFewShotExemplarCount membatasi K agar prompt tidak terlalu panjang.
+	MaxFewShotExemplarCount = 6
+	// MaxPinnedExemplarsPerQuestion membatasi jumlah contoh yang bisa dipasang per soal.
+	MaxPinnedExemplarsPerQuestion = 20
+
+	fewShotExemplarCountSettingKey = "few_shot_exemplar_count"
+	exemplarEssayMaxChars          = 1200
+	exemplarFeedbackMaxChars       = 300
+)
+
+var (
+	ErrExemplarNotFound       = errors.New("exemplar not found")
+	ErrExemplarNotReviewed    = errors.New("submission has no teacher review")
+	ErrExemplarWrongQuestion  = errors.New("submission does not belong to this question")
+	ErrExemplarLimitReached   = fmt.Errorf("a question can have at most %d exemplars", MaxPinnedExemplarsPerQuestion)
+	ErrExemplarQuestionAccess = errors.New("question not found or unauthorized")
+)
+
+// QuestionExemplarService mengelola contoh jawaban bernilai guru yang dipakai sebagai kalibrasi grading.
+type QuestionExemplarService struct {
+	db *sql.DB
+}
+
+func NewQuestionExemplarService(db *sql.DB) *QuestionExemplarService {
+	return &QuestionExemplarService{db: db}
+}
+
+// EnsureQuestionAccess memastikan guru boleh mengelola contoh jawaban (exemplar) untuk soal ini.
+func (s *QuestionExemplarService) EnsureQuestionAccess(questionID, userID, role string) error {
+	owned, err := teacherOwnsQuestion(s.db, questionID, userID, role)
+	if err != nil {
+		return err
+	}
+	if !owned {
+		return ErrExemplarQuestionAccess
+	}
+	return nil
+}
+
+const questionExemplarSelect = `
+	SELECT qe.id, qe.question_id, qe.submission_id, qe.pinned_by, qe.note, qe.created_at,
+	       es.teks_jawaban, tr.revised_score, tr.aspect_scores, tr.teacher_feedback
+	FROM question_exemplars qe
+	JOIN essay_submissions es ON es.id = qe.submission_id
+	JOIN teacher_reviews tr ON tr.submission_id = qe.submission_id`
+
+func scanQuestionExemplar(scanner interface{ Scan(...interface{}) error }) (*models.QuestionExemplar, error) {
+	var (
+		item         models.QuestionExemplar
+		pinnedBy     sql.NullString
+		note         sql.NullString
+		aspectScores sql.NullString
+		feedback     sql.NullString
+	)
+	if err := scanner.Scan(
+		&item.ID, &item.QuestionID, &item.SubmissionID, &pinnedBy, &note, &item.CreatedAt,
+		&item.EssayText, &item.RevisedScore, &aspectScores, &feedback,
+	); err != nil {
+		return nil, err
+	}
+	if pinnedBy.Valid {
+		item.PinnedBy = &pinnedBy.String
+	}
+	if note.Valid {
+		item.Note = &note.String
+	}
+	if feedback.Valid {
+		item.TeacherFeedback = &feedback.String
+	}
+	item.AspectScores = unmarshalTeacherAspectScores(aspectScores)
+	return &item, nil
+}
+
+// List mengembalikan contoh yang dipasang pada soal, diurutkan dari skor terendah.
+// Contoh yang review gurunya sudah dihapus otomatis tidak ikut (JOIN teacher_reviews).
+func (s *QuestionExemplarService) List(questionID string) ([]models.QuestionExemplar, error) {
+	rows, err := s.db.QueryContext(
+		context.Background(),
+		questionExemplarSelect+`
+		 WHERE qe.question_id = $1
+		 ORDER BY tr.revised_score ASC, qe.created_at DESC`,
+		questionID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load exemplars: %w", err)
+	}
+	defer rows.Close()
+
+	items := []models.QuestionExemplar{}
+	for rows.Next() {
+		item, err := scanQuestionExemplar(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan exemplar: %w", err)
+		}
+		items = append(items, *item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate exemplars: %w", err)
+	}
+	return items, nil
+}
+
+// Pin memasang submission yang sudah direview guru sebagai contoh kalibrasi untuk soalnya.
+// Memasang ulang submission yang sama hanya memperbarui catatan.
+func (s *QuestionExemplarService) Pin(questionID, teacherID string, req models.PinQuestionExemplarRequest) (*models.QuestionExemplar, error) {
+	submissionID := strings.TrimSpace(req.SubmissionID)
+	var (
+		soalID      string
+		hasReview   bool
+		pinnedCount int
+	)
+	err := s.db.QueryRowContext(
+		context.Background(),
+		`SELECT es.soal_id,
+		        EXISTS (SELECT 1 FROM teacher_reviews tr WHERE tr.submission_id = es.id),
+		        (SELECT COUNT(*) FROM question_exemplars qe WHERE qe.question_id = $2 AND qe.submission_id <> es.id)
+		 FROM essay_submissions es
+		 WHERE es.id = $1`,
+		submissionID, questionID,
+	).Scan(&soalID, &hasReview, &pinnedCount)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrExemplarNotFound
+		}
+		return nil, fmt.Errorf("failed to load submission: %w", err)
+	}
+	if soalID != questionID {
+		return nil, ErrExemplarWrongQuestion
+	}
+	if !hasReview {
+		return nil, ErrExemplarNotReviewed
+	}
+	if pinnedCount >= MaxPinnedExemplarsPerQuestion {
+		return nil, ErrExemplarLimitReached
+	}
+
+	var exemplarID string
+	err = s.db.QueryRowContext(
+		context.Background(),
+		`INSERT INTO question_exemplars (question_id, submission_id, pinned_by, note)
+		 VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (question_id, submission_id) DO UPDATE
+		 SET note = EXCLUDED.note
+		 RETURNING id`,
+		questionID, submissionID, nullIfEmpty(teacherID), nullableTrimmedString(req.Note),
+	).Scan(&exemplarID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pin exemplar: %w", err)
+	}
+
+	item, err := scanQuestionExemplar(s.db.QueryRowContext(
+		context.Background(),
+		questionExemplarSelect+` WHERE qe.id = $1`,
+		exemplarID,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load exemplar: %w", err)
+	}
+	return item, nil
+}
+
+// Unpin melepas contoh dari soal.
+func (s *QuestionExemplarService) Unpin(questionID, exemplarID string) error {
+	res, err := s.db.ExecContext(
+		context.Background(),
+		`DELETE FROM question_exemplars WHERE id = $1 AND question_id = $2`,
+		exemplarID, questionID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to unpin exemplar: %w", err)
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return ErrExemplarNotFound
+	}
+	return nil
+}
+
+// SelectForGrading memilih hingga k contoh untuk prompt grading. Submission yang sedang dinilai
+// tidak pernah dipakai sebagai contoh untuk dirinya sendiri.
+func (s *QuestionExemplarService) SelectForGrading(questionID, excludeSubmissionID string, k int) ([]models.GradingExemplar, error) {
+	if k <= 0 || strings.TrimSpace(questionID) == "" {
+		return nil, nil
+	}
+	items, err := s.List(questionID)
+	if err != nil {
+		return nil, err
+	}
+	candidates := make([]models.QuestionExemplar, 0, len(items))
+	for _, item := range items {
+		if item.SubmissionID == excludeSubmissionID || strings.TrimSpace(item.EssayText) == "" {
+			continue
+		}
+		candidates = append(candidates, item)
+	}
+
+	selected := selectExemplarsByBand(candidates, k)
+	out := make([]models.GradingExemplar, 0, len(selected))
+	for _, item := range selected {
+		exemplar := models.GradingExemplar{
+			SubmissionID: item.SubmissionID,
+			Essay:        clampPromptText(item.EssayText, exemplarEssayMaxChars),
+			Score:        item.RevisedScore,
+			AspectScores: item.AspectScores,
+		}
+		if item.TeacherFeedback != nil {
+			exemplar.Feedback = clampPromptText(*item.TeacherFeedback, exemplarFeedbackMaxChars)
+		}
+		out = append(out, exemplar)
+	}
+	return out, nil
+}
+
+// selectExemplarsByBand membagi skala 0-100 menjadi k pita yang sama lebar dan mengambil satu
+// contoh terdekat dengan titik tengah tiap pita. Pita kosong diisi contoh tersisa yang paling jauh
+// dari skor yang sudah terpilih, sehingga contoh tetap menyebar di sepanjang skala.
+// Hasil diurutkan dari skor terendah.
+func selectExemplarsByBand(items []models.QuestionExemplar, k int) []models.QuestionExemplar {
+	if k <= 0 || len(items) == 0 {
+		return nil
+	}
+	if len(items) <= k {
+		out := append([]models.QuestionExemplar(nil), items...)
+		sort.SliceStable(out, func(i, j int) bool { return out[i].RevisedScore < out[j].RevisedScore })
+		return out
+	}
+
+	used := make([]bool, len(items))
+	selected := make([]int, 0, k)
+	bandWidth := 100.0 / float64(k)
+	for band := 0; band < k; band++ {
+		low := float64(band) * bandWidth
+		high := low + bandWidth
+		center := low + bandWidth/2
+		best := -1
+		for i, item := range items {
+			if used[i] {
+				continue
+			}
+			score := math.Max(0, math.Min(100, item.RevisedScore))
+			inBand := score >= low && (score < high || (band == k-1 && score <= high))
+			if !inBand {
+				continue
+			}
+			if best < 0 || math.Abs(score-center) < math.Abs(items[best].RevisedScore-center) {
+				best = i
+			}
+		}
+		if best >= 0 {
+			used[best] = true
+			selected = append(selected, best)
+		}
+	}
+
+	for len(selected) < k {
+		best, bestDistance := -1, -1.0
+		for i, item := range items {
+			if used[i] {
+				continue
+			}
+			distance := math.Inf(1)
+			for _, idx := range selected {
+				distance = math.Min(distance, math.Abs(item.RevisedScore-items[idx].RevisedScore))
+			}
+			if distance > bestDistance {
+				best, bestDistance = i, distance
+			}
+		}
+		if best < 0 {
+			break
+		}
+		used[best] = true
+		selected = append(selected, best)
+	}
+
+	out := make([]models.QuestionExemplar, 0, len(selected))
+	for _, idx := range selected {
+		out = append(out, items[idx])
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].RevisedScore < out[j].RevisedScore })
+	return out
+}
+
+// formatExemplarsForPrompt menyusun blok contoh kalibrasi untuk prompt grading.
+func formatExemplarsForPrompt(exemplars []models.GradingExemplar) string {
+	if len(exemplars) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	for i, exemplar := range exemplars {
+		fmt.Fprintf(&b, "Example %d (teacher final score: %.0f/100)\n", i+1, exemplar.Score)
+		fmt.Fprintf(&b, "Answer: %q\n", exemplar.Essay)
+		if len(exemplar.AspectScores) > 0 {
+			parts := make([]string, 0, len(exemplar.AspectScores))
+			for _, aspect := range exemplar.AspectScores {
+				parts = append(parts, fmt.Sprintf("%s = %d", aspect.Aspek, aspect.SkorDiperoleh))
+			}
+			fmt.Fprintf(&b, "Teacher aspect scores: %s\n", strings.Join(parts, "; "))
+		}
+		if exemplar.Feedback != "" {
+			fmt.Fprintf(&b, "Teacher note: %s\n", exemplar.Feedback)
+		}
+		b.WriteString("\n")
+	}
+	return strings.TrimRight(b.String(), "\n") + "\n"
+}
@@ -0,0 +1,524 @@
+package services
+
+import (
+	"api-backend/internal/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// RegradeReviewPolicyKeep mempertahankan review guru sebagai nilai akhir tanpa tanda.
+	RegradeReviewPolicyKeep = "keep"
+	// RegradeReviewPolicyFlag mempertahankan review guru tetapi menandainya untuk dicek ulang.
+	RegradeReviewPolicyFlag = "flag"
+
+	regradeRecheckReason = "Soal dinilai ulang AI setelah rubrik/jawaban ideal diubah; cek kembali nilai guru."
+)
+
+var (
+	ErrRegradeQuestionAccess   = errors.New("question not found or unauthorized")
+	ErrRegradeSubmissionAccess = errors.New("submission not found or unauthorized")
+	ErrRegradeBatchNotFound    = errors.New("regrade batch not found")
+)
+
+// NormalizeRegradeReviewPolicy memvalidasi perlakuan review guru saat re-grade. String kosong dianggap flag.
+func NormalizeRegradeReviewPolicy(value string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", RegradeReviewPolicyFlag:
+		return RegradeReviewPolicyFlag, true
+	case RegradeReviewPolicyKeep:
+		return RegradeReviewPolicyKeep, true
+	default:
+		return "", false
+	}
+}
+
+// EnsureRegradeQuestionAccess memastikan guru boleh menilai ulang soal ini.
+func (s *EssaySubmissionService) EnsureRegradeQuestionAccess(questionID, userID, role string) error {
+	owned, err := teacherOwnsQuestion(s.db, questionID, userID, role)
+	if err != nil {
+		return err
+	}
+	if !owned {
+		return ErrRegradeQuestionAccess
+	}
+	return nil
+}
+
+// EnsureRegradeSubmissionAccess memastikan submission berada di kelas guru.
+func (s *EssaySubmissionService) EnsureRegradeSubmissionAccess(submissionID, userID, role string) error {
+	owned, err := teacherOwnsSubmission(s.db, submissionID, userID, role)
+	if err != nil {
+		return err
+	}
+	if !owned {
+		return ErrRegradeSubmissionAccess
+	}
+	return nil
+}
+
+type regradeCandidate struct {
+	item   models.QuestionRegradeDiffItem
+	reason string // kosong bila boleh dimasukkan ke antrean
+}
+
+// RegradeQuestion memasukkan ulang semua submission soal ke antrean setelah rubrik, jawaban ideal, atau
+// kata kunci berubah. ai_results lama diarsipkan ke ai_result_history sebelum ditimpa, dan review guru
+// tidak dihapus: policy keep membiarkannya, flag menandainya needs_recheck. dry_run hanya menampilkan kandidat.
+func (s *EssaySubmissionService) RegradeQuestion(questionID, actorID string, req models.QuestionRegradeRequest) (*models.QuestionRegradeReport, error) {
+	policy, ok := NormalizeRegradeReviewPolicy(req.ReviewPolicy)
+	if !ok {
+		return nil, fmt.Errorf("review_policy must be keep or flag")
+	}
+	if s.aiService == nil {
+		return nil, fmt.Errorf("AI service is unavailable")
+	}
+
+	candidates, err := s.loadRegradeCandidates(questionID)
+	if err != nil {
+		return nil, err
+	}
+	if req.DryRun {
+		report := &models.QuestionRegradeReport{DryRun: true, Items: []models.QuestionRegradeDiffItem{}}
+		for _, candidate := range candidates {
+			item := candidate.item
+			if candidate.reason != "" {
+				item.Status = "skipped"
+				item.Message = &candidate.reason
+			} else if item.TeacherScore != nil {
+				item.ReviewAction = "kept"
+				if policy == RegradeReviewPolicyFlag {
+					item.ReviewAction = "flagged"
+				}
+			}
+			report.Items = append(report.Items, item)
+		}
+		return report, nil
+	}
+
+	eligible := make([]string, 0, len(candidates))
+	for _, candidate := range candidates {
+		if candidate.reason == "" {
+			eligible = append(eligible, candidate.item.SubmissionID)
+		}
+	}
+
+	tx, err := s.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start regrade transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var batchID string
+	if err := tx.QueryRowContext(
+		context.Background(),
+		`INSERT INTO question_regrade_batches (question_id, requested_by, review_policy, reason, total)
+		 VALUES ($1, NULLIF($2, '')::uuid, $3, NULLIF($4, ''), $5)
+		 RETURNING id`,
+		questionID,
+		actorID,
+		policy,
+		strings.TrimSpace(req.Reason),
+		len(candidates),
+	).Scan(&batchID); err != nil {
+		return nil, fmt.Errorf("failed to create regrade batch: %w", err)
+	}
+
+	// Arsipkan hasil AI saat ini dan catat skor lama per siswa dalam satu statement.
+	if _, err := tx.ExecContext(
+		context.Background(),
+		`WITH hist AS (
+			INSERT INTO ai_result_history (
+				submission_id, regrade_batch_id, skor_ai, umpan_balik_ai, logs_rag, rubric_scores, aspect_evidence,
+				scoring_formula, scoring_details, score_spread, ensemble_details, prompt_template_version,
+				ai_provider, model_name, prompt_hash, generated_at, holistic_band
+			)
+			SELECT submission_id, $1, skor_ai, umpan_balik_ai, logs_rag, rubric_scores, aspect_evidence,
+			       scoring_formula, scoring_details, score_spread, ensemble_details, prompt_template_version,
+			       ai_provider, model_name, prompt_hash, generated_at, holistic_band
+			FROM ai_results
+			WHERE submission_id::text = ANY($2)
+			RETURNING id, submission_id, skor_ai
+		 )
+		 INSERT INTO question_regrade_items (batch_id, submission_id, history_id, old_score, teacher_score)
+		 SELECT $1, es.id, h.id, h.skor_ai, tr.revised_score
+		 FROM essay_submissions es
+		 LEFT JOIN hist h ON h.submission_id = es.id
+		 LEFT JOIN teacher_reviews tr ON tr.submission_id = es.id
+		 WHERE es.id::text = ANY($2)`,
+		batchID,
+		pq.Array(eligible),
+	); err != nil {
+		return nil, fmt.Errorf("failed to archive previous AI results: %w", err)
+	}
+
+	skipped := 0
+	for _, candidate := range candidates {
+		if candidate.reason == "" {
+			continue
+		}
+		skipped++
+		if _, err := tx.ExecContext(
+			context.Background(),
+			`INSERT INTO question_regrade_items (batch_id, submission_id, old_score, teacher_score, status, message)
+			 VALUES ($1, $2, $3, $4, 'skipped', $5)`,
+			batchID,
+			candidate.item.SubmissionID,
+			candidate.item.OldScore,
+			candidate.item.TeacherScore,
+			candidate.reason,
+		); err != nil {
+			return nil, fmt.Errorf("failed to record skipped submission: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit regrade batch: %w", err)
+	}
+
+	accepted := []string{}
+	if len(eligible) > 0 {
+		result, err := s.requeueSubmissions(eligible, requeueOptions{BypassCache: true, RegradeBatchID: batchID})
+		if err != nil {
+			return nil, err
+		}
+		for _, detail := range result.Details {
+			if detail.Status == "queued" {
+				accepted = append(accepted, detail.SubmissionID)
+				continue
+			}
+			skipped++
+			if _, err := s.db.ExecContext(
+				context.Background(),
+				`UPDATE question_regrade_items SET status = 'skipped', message = $3 WHERE batch_id = $1 AND submission_id = $2`,
+				batchID, detail.SubmissionID, detail.Message,
+			); err != nil {
+				log.Printf("WARNING: failed to mark regrade item %s skipped: %v", detail.SubmissionID, err)
+			}
+		}
+	}
+
+	flagged, err := s.applyRegradeReviewPolicy(batchID, accepted, policy)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.db.ExecContext(
+		context.Background(),
+		`UPDATE question_regrade_batches SET accepted = $2, skipped = $3, flagged_reviews = $4 WHERE id = $1`,
+		batchID, len(accepted), skipped, flagged,
+	); err != nil {
+		return nil, fmt.Errorf("failed to update regrade batch counters: %w", err)
+	}
+
+	return s.GetQuestionRegradeReport(questionID, batchID)
+}
+
+func (s *EssaySubmissionService) loadRegradeCandidates(questionID string) ([]regradeCandidate, error) {
+	rows, err := s.db.QueryContext(
+		context.Background(),
+		`SELECT es.id, es.siswa_id, COALESCE(u.nama_lengkap, ''), es.ai_grading_status, ar.skor_ai, tr.revised_score
+		 FROM essay_submissions es
+		 JOIN users u ON u.id = es.siswa_id
+		 LEFT JOIN ai_results ar ON ar.submission_id = es.id
+		 LEFT JOIN teacher_reviews tr ON tr.submission_id = es.id
+		 WHERE es.soal_id = $1 AND es.submission_type = 'essay'
+		 ORDER BY u.nama_lengkap ASC, es.submitted_at ASC`,
+		questionID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load submissions for regrade: %w", err)
+	}
+	defer rows.Close()
+
+	candidates := []regradeCandidate{}
+	for rows.Next() {
+		var candidate regradeCandidate
+		var status string
+		var oldScore, teacherScore sql.NullFloat64
+		if err := rows.Scan(&candidate.item.SubmissionID, &candidate.item.StudentID, &candidate.item.StudentName, &status, &oldScore, &teacherScore); err != nil {
+			return nil, fmt.Errorf("failed to scan regrade candidate: %w", err)
+		}
+		candidate.item.Status = "queued"
+		candidate.item.ReviewAction = "none"
+		if oldScore.Valid {
+			candidate.item.OldScore = &oldScore.Float64
+		}
+		if teacherScore.Valid {
+			candidate.item.TeacherScore = &teacherScore.Float64
+		}
+		switch status {
+		case "processing":
+			candidate.reason = "Submission sedang diproses"
+		case "held":
+			candidate.reason = "Submission ditahan untuk dinilai guru"
+		}
+		candidates = append(candidates, candidate)
+	}
+	return candidates, rows.Err()
+}
+
+// applyRegradeReviewPolicy mencatat perlakuan review guru untuk submission yang masuk antrean.
+// Mengembalikan jumlah review yang ditandai needs_recheck.
+func (s *EssaySubmissionService) applyRegradeReviewPolicy(batchID string, submissionIDs []string, policy string) (int, error) {
+	if len(submissionIDs) == 0 {
+		return 0, nil
+	}
+	action := "kept"
+	if policy == RegradeReviewPolicyFlag {
+		action = "flagged"
+		if _, err := s.db.ExecContext(
+			context.Background(),
+			`UPDATE teacher_reviews
+			 SET needs_recheck = TRUE,
+			     recheck_reason = $2
+			 WHERE submission_id::text = ANY($1)`,
+			pq.Array(submissionIDs),
+			regradeRecheckReason,
+		); err != nil {
+			return 0, fmt.Errorf("failed to flag teacher reviews: %w", err)
+		}
+	}
+	res, err := s.db.ExecContext(
+		context.Background(),
+		`UPDATE question_regrade_items
+		 SET review_action = $3
+		 WHERE batch_id = $1 AND submission_id::text = ANY($2) AND teacher_score IS NOT NULL`,
+		batchID,
+		pq.Array(submissionIDs),
+		action,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record review policy: %w", err)
+	}
+	if policy != RegradeReviewPolicyFlag {
+		return 0, nil
+	}
+	affected, _ := res.RowsAffected()
+	return int(affected), nil
+}
+
+// recordRegradeResult menyimpan skor baru hasil job re-grade ke laporan batch.
+func (s *EssaySubmissionService) recordRegradeResult(batchID, submissionID string, score float64) {
+	if _, err := s.db.ExecContext(
+		context.Background(),
+		`UPDATE question_regrade_items
+		 SET status = 'completed', new_score = $3, regraded_at = NOW()
+		 WHERE batch_id = $1 AND submission_id = $2`,
+		batchID, submissionID, score,
+	); err != nil {
+		log.Printf("WARNING: failed to record regrade result for %s: %v", submissionID, err)
+	}
+}
+
+const regradeBatchSelect = `
+	SELECT b.id, b.question_id, b.requested_by, b.review_policy, b.reason, b.total, b.accepted, b.skipped, b.flagged_reviews, b.created_at,
+	       COUNT(i.submission_id) FILTER (WHERE i.status = 'completed'),
+	       COUNT(i.submission_id) FILTER (WHERE i.status = 'queued' AND es.ai_grading_status = 'failed'),
+	       COUNT(i.submission_id) FILTER (WHERE i.status = 'queued' AND es.ai_grading_status <> 'failed')
+	FROM question_regrade_batches b
+	LEFT JOIN question_regrade_items i ON i.batch_id = b.id
+	LEFT JOIN essay_submissions es ON es.id = i.submission_id`
+
+func scanRegradeBatch(scanner interface{ Scan(...interface{}) error }) (*models.QuestionRegradeBatch, error) {
+	var batch models.QuestionRegradeBatch
+	var requestedBy, reason sql.NullString
+	if err := scanner.Scan(
+		&batch.ID, &batch.QuestionID, &requestedBy, &batch.ReviewPolicy, &reason, &batch.Total, &batch.Accepted, &batch.Skipped, &batch.FlaggedReviews, &batch.CreatedAt,
+		&batch.Completed, &batch.Failed, &batch.Pending,
+	); err != nil {
+		return nil, err
+	}
+	if requestedBy.Valid {
+		batch.RequestedBy = &requestedBy.String
+	}
+	if reason.Valid {
+		batch.Reason = &reason.String
+	}
+	return &batch, nil
+}
+
+// ListQuestionRegradeBatches mengembalikan riwayat re-grade soal, terbaru lebih dulu.
+func (s *EssaySubmissionService) ListQuestionRegradeBatches(questionID string) ([]models.QuestionRegradeBatch, error) {
+	rows, err := s.db.QueryContext(
+		context.Background(),
+		regradeBatchSelect+`
+		 WHERE b.question_id = $1
+		 GROUP BY b.id
+		 ORDER BY b.created_at DESC`,
+		questionID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list regrade batches: %w", err)
+	}
+	defer rows.Close()
+	items := []models.QuestionRegradeBatch{}
+	for rows.Next() {
+		batch, err := scanRegradeBatch(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan regrade batch: %w", err)
+		}
+		items = append(items, *batch)
+	}
+	return items, rows.Err()
+}
+
+// GetQuestionRegradeReport mengembalikan diff skor lama vs baru per siswa untuk satu batch.
+func (s *EssaySubmissionService) GetQuestionRegradeReport(questionID, batchID string) (*models.QuestionRegradeReport, error) {
+	batch, err := scanRegradeBatch(s.db.QueryRowContext(
+		context.Background(),
+		regradeBatchSelect+`
+		 WHERE b.id = $1 AND b.question_id = $2
+		 GROUP BY b.id`,
+		batchID,
+		questionID,
+	))
+	if err == sql.ErrNoRows {
+		return nil, ErrRegradeBatchNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load regrade batch: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(
+		context.Background(),
+		`SELECT i.submission_id, es.siswa_id, COALESCE(u.nama_lengkap, ''), i.status, i.message, es.ai_grading_status, es.ai_grading_error,
+		        i.old_score, i.new_score, i.teacher_score, i.review_action, COALESCE(tr.needs_recheck, FALSE), i.regraded_at
+		 FROM question_regrade_items i
+		 JOIN essay_submissions es ON es.id = i.submission_id
+		 JOIN users u ON u.id = es.siswa_id
+		 LEFT JOIN teacher_reviews tr ON tr.submission_id = i.submission_id
+		 WHERE i.batch_id = $1
+		 ORDER BY u.nama_lengkap ASC`,
+		batchID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load regrade items: %w", err)
+	}
+	defer rows.Close()
+
+	report := &models.QuestionRegradeReport{Batch: batch, Items: []models.QuestionRegradeDiffItem{}}
+	var sumDelta, sumAbs float64
+	compared := 0
+	for rows.Next() {
+		var item models.QuestionRegradeDiffItem
+		var message, gradingError sql.NullString
+		var gradingStatus string
+		var oldScore, newScore, teacherScore sql.NullFloat64
+		var regradedAt sql.NullTime
+		if err := rows.Scan(
+			&item.SubmissionID, &item.StudentID, &item.StudentName, &item.Status, &message, &gradingStatus, &gradingError,
+			&oldScore, &newScore, &teacherScore, &item.ReviewAction, &item.ReviewNeedsRecheck, &regradedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan regrade item: %w", err)
+		}
+		if item.Status == "queued" && gradingStatus == "failed" {
+			item.Status = "failed"
+			if gradingError.Valid {
+				message = gradingError
+			}
+		}
+		if message.Valid {
+			item.Message = &message.String
+		}
+		if oldScore.Valid {
+			item.OldScore = &oldScore.Float64
+		}
+		if newScore.Valid {
+			item.NewScore = &newScore.Float64
+		}
+		if teacherScore.Valid {
+			item.TeacherScore = &teacherScore.Float64
+		}
+		if regradedAt.Valid {
+			item.RegradedAt = &regradedAt.Time
+		}
+		if oldScore.Valid && newScore.Valid {
+			delta := math.Round((newScore.Float64-oldScore.Float64)*100) / 100
+			item.Delta = &delta
+			compared++
+			sumDelta += delta
+			sumAbs += math.Abs(delta)
+			switch {
+			case delta > 0:
+				report.Summary.Increased++
+			case delta < 0:
+				report.Summary.Decreased++
+			default:
+				report.Summary.Unchanged++
+			}
+			if math.Abs(delta) > report.Summary.MaxAbsDelta {
+				report.Summary.MaxAbsDelta = math.Abs(delta)
+			}
+		}
+		report.Items = append(report.Items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate regrade items: %w", err)
+	}
+	if compared > 0 {
+		report.Summary.MeanDelta = math.Round(sumDelta/float64(compared)*100) / 100
+		report.Summary.MeanAbsDelta = math.Round(sumAbs/float64(compared)*100) / 100
+	}
+	return report, nil
+}
+
+// GetAIResultHistory mengembalikan versi ai_results sebelumnya untuk satu submission, terbaru lebih dulu.
+func (s *EssaySubmissionService) GetAIResultHistory(submissionID string) ([]models.AIResultHistoryEntry, error) {
+	rows, err := s.db.QueryContext(
+		context.Background(),
+		`SELECT id, submission_id, regrade_batch_id, skor_ai, umpan_balik_ai, rubric_scores::text, scoring_formula,
+		        model_name, prompt_template_version, generated_at, archived_at, holistic_band::text
+		 FROM ai_result_history
+		 WHERE submission_id = $1
+		 ORDER BY archived_at DESC`,
+		submissionID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AI result history: %w", err)
+	}
+	defer rows.Close()
+
+	items := []models.AIResultHistoryEntry{}
+	for rows.Next() {
+		var item models.AIResultHistoryEntry
+		var batchID, feedback, rubricScores, formula, modelName, templateVersion, holisticBand sql.NullString
+		var generatedAt, archivedAt time.Time
+		if err := rows.Scan(&item.ID, &item.SubmissionID, &batchID, &item.SkorAI, &feedback, &rubricScores, &formula, &modelName, &templateVersion, &generatedAt, &archivedAt, &holisticBand); err != nil {
+			return nil, fmt.Errorf("failed to scan AI result history: %w", err)
+		}
+		item.GeneratedAt = generatedAt
+		item.ArchivedAt = archivedAt
+		if batchID.Valid {
+			item.RegradeBatchID = &batchID.String
+		}
+		if feedback.Valid {
+			item.UmpanBalikAI = &feedback.String
+		}
+		if formula.Valid {
+			item.ScoringFormula = &formula.String
+		}
+		if modelName.Valid {
+			item.ModelName = &modelName.String
+		}
+		if templateVersion.Valid {
+			item.PromptTemplateVersion = &templateVersion.String
+		}
+		if rubricScores.Valid && strings.TrimSpace(rubricScores.String) != "" {
+			var scores []models.GradeEssayAspectScore
+			if err := json.Unmarshal([]byte(rubricScores.String), &scores); err == nil {
+				item.RubricScores = scores
+			}
+		}
+		item.HolisticBand = parseHolisticBand(holisticBand)
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
@@ -0,0 +1,510 @@
+package services
+
+import (
+	"api-backend/internal/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	RescoreScopeQuestion = "question"
+	RescoreScopeClass    = "class"
+)
+
+var ErrRescoreScopeAccess = errors.New("question or class not found or unauthorized")
+
+// rescoreQuestion adalah konfigurasi skor satu soal setelah override diterapkan.
+type rescoreQuestion struct {
+	ID        string
+	Rubrics   json.RawMessage
+	Aspects   []models.RubricAspect
+	RoundOn   bool
+	RoundStep float64
+	Holistic  bool
+	Err       error
+}
+
+// EnsureRescoreAccess memastikan soal/kelas milik guru dan mengembalikan tipe serta ID scope.
+func (s *EssaySubmissionService) EnsureRescoreAccess(req models.RescoreRequest, userID, role string) (string, string, error) {
+	questionID := strings.TrimSpace(req.QuestionID)
+	classID := strings.TrimSpace(req.ClassID)
+	if (questionID == "") == (classID == "") {
+		return "", "", fmt.Errorf("exactly one of question_id or class_id is required")
+	}
+	if questionID != "" {
+		if err := s.EnsureRegradeQuestionAccess(questionID, userID, role); err != nil {
+			if errors.Is(err, ErrRegradeQuestionAccess) {
+				return "", "", ErrRescoreScopeAccess
+			}
+			return "", "", err
+		}
+		return RescoreScopeQuestion, questionID, nil
+	}
+	owned, err := teacherOwnsClass(s.db, classID, userID, role)
+	if err != nil {
+		return "", "", err
+	}
+	if !owned {
+		return "", "", ErrRescoreScopeAccess
+	}
+	return RescoreScopeClass, classID, nil
+}
+
+func validateRescoreConfig(cfg models.RescoreConfig) (models.RescoreConfig, error) {
+	weights := map[string]float64{}
+	for name, weight := range cfg.AspectWeights {
+		key := strings.ToLower(strings.TrimSpace(name))
+		if key == "" {
+			continue
+		}
+		if weight <= 0 || weight > 100 || math.IsNaN(weight) {
+			return cfg, fmt.Errorf("aspect weight for %q must be greater than 0 and at most 100", name)
+		}
+		weights[key] = weight
+	}
+	cfg.AspectWeights = weights
+	if cfg.RoundScoreStep != nil && (*cfg.RoundScoreStep <= 0 || *cfg.RoundScoreStep > 50) {
+		return cfg, fmt.Errorf("round_score_step must be greater than 0 and at most 50")
+	}
+	if strings.TrimSpace(cfg.ScoringNormalization) != "" {
+		normalization, ok := NormalizeScoringNormalization(cfg.ScoringNormalization)
+		if !ok {
+			return cfg, fmt.Errorf("scoring_normalization must be weighted_mean or per_aspect_max")
+		}
+		cfg.ScoringNormalization = normalization
+	}
+	return cfg, nil
+}
+
+func (s *EssaySubmissionService) loadRescoreQuestions(scopeType, scopeID string, cfg models.RescoreConfig) (map[string]*rescoreQuestion, []string, error) {
+	filter := "eq.id = $1"
+	if scopeType == RescoreScopeClass {
+		filter = "m.class_id = $1"
+	}
+	rows, err := s.db.QueryContext(
+		context.Background(),
+		`SELECT eq.id, eq.rubrics, COALESCE(eq.round_score_to_5, FALSE), COALESCE(eq.round_score_step, 5), eq.rubric_type
+		 FROM essay_questions eq
+		 JOIN materials m ON m.id = eq.material_id
+		 WHERE `+filter,
+		scopeID,
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load questions for rescoring: %w", err)
+	}
+	defer rows.Close()
+
+	matchedWeights := map[string]bool{}
+	questions := map[string]*rescoreQuestion{}
+	for rows.Next() {
+		q := &rescoreQuestion{}
+		var rubrics []byte
+		var rubricType string
+		if err := rows.Scan(&q.ID, &rubrics, &q.RoundOn, &q.RoundStep, &rubricType); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan question for rescoring: %w", err)
+		}
+		q.Rubrics = json.RawMessage(rubrics)
+		q.Holistic = isHolisticRubric(rubricType)
+		if cfg.RoundScoreTo5 != nil {
+			q.RoundOn = *cfg.RoundScoreTo5
+		}
+		if cfg.RoundScoreStep != nil {
+			q.RoundStep = *cfg.RoundScoreStep
+		}
+		if q.RoundStep <= 0 {
+			q.RoundStep = 5
+		}
+		q.Aspects, q.Err = questionRubricAspects(q.Rubrics, q.ID)
+		for i := range q.Aspects {
+			key := strings.ToLower(strings.TrimSpace(q.Aspects[i].Aspek))
+			if weight, ok := cfg.AspectWeights[key]; ok {
+				q.Aspects[i].Bobot = weight
+				matchedWeights[key] = true
+			}
+		}
+		questions[q.ID] = q
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to iterate questions for rescoring: %w", err)
+	}
+
+	unmatched := []string{}
+	for key := range cfg.AspectWeights {
+		if !matchedWeights[key] {
+			unmatched = append(unmatched, key)
+		}
+	}
+	sort.Strings(unmatched)
+	return questions, unmatched, nil
+}
+
+// rescoreComputed menyimpan breakdown baru agar apply tidak perlu menghitung ulang.
+type rescoreComputed struct {
+	item      models.RescoreItem
+	breakdown *models.ScoringBreakdown
+}
+
+// computeRescore menghitung ulang skor akhir dari rubric_scores tersimpan dengan konfigurasi yang diusulkan.
+// Model AI tidak dipanggil: skor per aspek dianggap tetap, hanya bobot, pembulatan, dan normalisasi yang berubah.
+func (s *EssaySubmissionService) computeRescore(scopeType, scopeID string, cfg models.RescoreConfig) (*models.RescoreResult, []rescoreComputed, map[string]*rescoreQuestion, error) {
+	cfg, err := validateRescoreConfig(cfg)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	questions, unmatched, err := s.loadRescoreQuestions(scopeType, scopeID, cfg)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	normalization := cfg.ScoringNormalization
+	if normalization == "" && s.settingService != nil {
+		if current, settingErr := s.settingService.GetScoringNormalization(); settingErr == nil {
+			normalization = current
+		}
+	}
+	normalization, _ = NormalizeScoringNormalization(normalization)
+
+	filter := "es.soal_id = $1"
+	if scopeType == RescoreScopeClass {
+		filter = "m.class_id = $1"
+	}
+	rows, err := s.db.QueryContext(
+		context.Background(),
+		`SELECT es.id, es.soal_id, es.siswa_id, COALESCE(u.nama_lengkap, ''), ar.skor_ai, ar.rubric_scores::text, tr.revised_score
+		 FROM essay_submissions es
+		 JOIN ai_results ar ON ar.submission_id = es.id
+		 JOIN users u ON u.id = es.siswa_id
+		 JOIN essay_questions eq ON eq.id = es.soal_id
+		 JOIN materials m ON m.id = eq.material_id
+		 LEFT JOIN teacher_reviews tr ON tr.submission_id = es.id
+		 WHERE es.submission_type = 'essay'
+		   AND es.ai_grading_status = 'completed'
+		   AND `+filter+`
+		 ORDER BY u.nama_lengkap ASC, es.submitted_at ASC`,
+		scopeID,
+	)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to load submissions for rescoring: %w", err)
+	}
+	defer rows.Close()
+
+	result := &models.RescoreResult{
+		ScopeType:        scopeType,
+		ScopeID:          scopeID,
+		Config:           cfg,
+		UnmatchedAspects: unmatched,
+		Items:            []models.RescoreItem{},
+	}
+	result.Config.ScoringNormalization = normalization
+	computed := []rescoreComputed{}
+	before, after := []float64{}, []float64{}
+	var sumDelta, sumAbs float64
+	for rows.Next() {
+		var entry rescoreComputed
+		var rubricScores sql.NullString
+		var teacherScore sql.NullFloat64
+		item := &entry.item
+		if err := rows.Scan(&item.SubmissionID, &item.QuestionID, &item.StudentID, &item.StudentName, &item.OldScore, &rubricScores, &teacherScore); err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to scan submission for rescoring: %w", err)
+		}
+		if teacherScore.Valid {
+			item.TeacherScore = &teacherScore.Float64
+		}
+		result.Matched++
+
+		skip := func(reason string) {
+			item.Skipped = true
+			item.Reason = &reason
+			result.Skipped++
+			result.Items = append(result.Items, *item)
+		}
+		question := questions[item.QuestionID]
+		if question == nil || question.Err != nil {
+			skip("Rubrik soal tidak dapat dibaca")
+			continue
+		}
+		if question.Holistic {
+			skip("Rubrik holistik dinilai per band, tidak ada skor per aspek untuk dihitung ulang")
+			continue
+		}
+		var aspectScores []models.GradeEssayAspectScore
+		if !rubricScores.Valid || json.Unmarshal([]byte(rubricScores.String), &aspectScores) != nil || len(aspectScores) == 0 {
+			skip("Skor per aspek tidak tersimpan")
+			continue
+		}
+		aiAspects := make([]AIAspectScore, 0, len(aspectScores))
+		for _, aspect := range aspectScores {
+			aiAspects = append(aiAspects, AIAspectScore{Aspek: aspect.Aspek, SkorDiperoleh: aspect.SkorDiperoleh})
+		}
+		newScore, breakdown, calcErr := calculateFinalScore(question.Aspects, aiAspects, normalization)
+		if calcErr != nil {
+			skip(calcErr.Error())
+			continue
+		}
+		if question.RoundOn {
+			newScore = roundToNearestStep(newScore, question.RoundStep)
+			step := question.RoundStep
+			breakdown.RoundingStep = &step
+		}
+		newScore = math.Round(newScore*100) / 100
+		breakdown.FinalScore = newScore
+		entry.breakdown = breakdown
+
+		delta := math.Round((newScore-item.OldScore)*100) / 100
+		item.NewScore = &newScore
+		item.Delta = &delta
+		before = append(before, item.OldScore)
+		after = append(after, newScore)
+		if delta != 0 {
+			result.Changed++
+			if delta > 0 {
+				result.Increased++
+			} else {
+				result.Decreased++
+			}
+		}
+		sumDelta += delta
+		sumAbs += math.Abs(delta)
+		if math.Abs(delta) > result.MaxAbsDelta {
+			result.MaxAbsDelta = math.Abs(delta)
+		}
+		result.Items = append(result.Items, *item)
+		computed = append(computed, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to iterate submissions for rescoring: %w", err)
+	}
+	if len(computed) > 0 {
+		result.MeanDelta = math.Round(sumDelta/float64(len(computed))*100) / 100
+		result.MeanAbsDelta = math.Round(sumAbs/float64(len(computed))*100) / 100
+	}
+	result.Before = scoreDistribution(before)
+	result.After = scoreDistribution(after)
+	return result, computed, questions, nil
+}
+
+// scoreDistribution menghitung statistik ringkas dan histogram per rentang 10 poin.
+func scoreDistribution(scores []float64) models.ScoreDistribution {
+	dist := models.ScoreDistribution{Count: len(scores), Buckets: make([]models.ScoreBucket, 10)}
+	for i := range dist.Buckets {
+		if i == 9 {
+			dist.Buckets[i].Label = "90-100"
+		} else {
+			dist.Buckets[i].Label = fmt.Sprintf("%d-%d", i*10, i*10+9)
+		}
+	}
+	if len(scores) == 0 {
+		return dist
+	}
+	sorted := append([]float64(nil), scores...)
+	sort.Float64s(sorted)
+	var sum float64
+	for _, score := range sorted {
+		sum += score
+		bucket := int(score / 10)
+		if bucket < 0 {
+			bucket = 0
+		}
+		if bucket > 9 {
+			bucket = 9
+		}
+		dist.Buckets[bucket].Count++
+	}
+	mean := sum / float64(len(sorted))
+	var variance float64
+	for _, score := range sorted {
+		variance += (score - mean) * (score - mean)
+	}
+	variance /= float64(len(sorted))
+	median := sorted[len(sorted)/2]
+	if len(sorted)%2 == 0 {
+		median = (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+	}
+	dist.Mean = math.Round(mean*100) / 100
+	dist.Median = math.Round(median*100) / 100
+	dist.StdDev = math.Round(math.Sqrt(variance)*100) / 100
+	dist.Min = sorted[0]
+	dist.Max = sorted[len(sorted)-1]
+	return dist
+}
+
+// PreviewRescore menampilkan delta per siswa dan pergeseran distribusi tanpa menyimpan apa pun.
+func (s *EssaySubmissionService) PreviewRescore(scopeType, scopeID string, cfg models.RescoreConfig) (*models.RescoreResult, error) {
+	result, _, _, err := s.computeRescore(scopeType, scopeID, cfg)
+	return result, err
+}
+
+// ApplyRescore menyimpan skor hasil rescoring. Hasil AI lama diarsipkan ke ai_result_history dan setiap
+// perubahan dicatat di score_rescore_runs/items. updateQuestionConfig ikut menyimpan bobot aspek dan
+// pembulatan ke soal; normalisasi adalah setting global sehingga tidak disimpan per soal.
+func (s *EssaySubmissionService) ApplyRescore(scopeType, scopeID, actorID string, req models.RescoreRequest) (*models.RescoreResult, error) {
+	result, computed, questions, err := s.computeRescore(scopeType, scopeID, req.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	configJSON, err := json.Marshal(result.Config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode rescore config: %w", err)
+	}
+
+	tx, err := s.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start rescore transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var runID string
+	if err := tx.QueryRowContext(
+		context.Background(),
+		`INSERT INTO score_rescore_runs (scope_type, scope_id, config, requested_by, note, matched, changed, mean_delta, updated_question_config)
+		 VALUES ($1, $2, $3, NULLIF($4, '')::uuid, NULLIF($5, ''), $6, $7, $8, $9)
+		 RETURNING id`,
+		scopeType, scopeID, string(configJSON), actorID, strings.TrimSpace(req.Note),
+		result.Matched, result.Changed, result.MeanDelta, req.UpdateQuestionConfig,
+	).Scan(&runID); err != nil {
+		return nil, fmt.Errorf("failed to create rescore run: %w", err)
+	}
+
+	for _, entry := range computed {
+		if entry.item.Delta == nil || *entry.item.Delta == 0 {
+			continue
+		}
+		if _, err := tx.ExecContext(
+			context.Background(),
+			`INSERT INTO ai_result_history (
+				submission_id, rescore_run_id, skor_ai, umpan_balik_ai, logs_rag, rubric_scores, aspect_evidence,
+				scoring_formula, scoring_details, score_spread, ensemble_details, prompt_template_version,
+				ai_provider, model_name, prompt_hash, generated_at, holistic_band
+			 )
+			 SELECT submission_id, $2, skor_ai, umpan_balik_ai, logs_rag, rubric_scores, aspect_evidence,
+			        scoring_formula, scoring_details, score_spread, ensemble_details, prompt_template_version,
+			        ai_provider, model_name, prompt_hash, generated_at, holistic_band
+			 FROM ai_results
+			 WHERE submission_id = $1`,
+			entry.item.SubmissionID, runID,
+		); err != nil {
+			return nil, fmt.Errorf("failed to archive AI result: %w", err)
+		}
+		detailsJSON, err := json.Marshal(entry.breakdown)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode scoring details: %w", err)
+		}
+		if _, err := tx.ExecContext(
+			context.Background(),
+			`UPDATE ai_results
+			 SET skor_ai = $2, scoring_formula = $3, scoring_details = $4
+			 WHERE submission_id = $1`,
+			entry.item.SubmissionID, *entry.item.NewScore, scoringFormulaLabel(entry.breakdown), string(detailsJSON),
+		); err != nil {
+			return nil, fmt.Errorf("failed to update AI result: %w", err)
+		}
+		if _, err := tx.ExecContext(
+			context.Background(),
+			`INSERT INTO score_rescore_items (run_id, submission_id, old_score, new_score) VALUES ($1, $2, $3, $4)`,
+			runID, entry.item.SubmissionID, entry.item.OldScore, *entry.item.NewScore,
+		); err != nil {
+			return nil, fmt.Errorf("failed to record rescore item: %w", err)
+		}
+	}
+
+	if req.UpdateQuestionConfig {
+		for _, question := range questions {
+			if err := updateQuestionScoringConfig(tx, question, result.Config); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit rescore: %w", err)
+	}
+
+	result.Applied = true
+	result.RunID = &runID
+	return result, nil
+}
+
+// updateQuestionScoringConfig menulis bobot aspek dan pembulatan baru ke soal. Rubrik diubah sebagai
+// JSON generik agar field lain yang tidak dikenal model tetap utuh.
+func updateQuestionScoringConfig(tx *sql.Tx, question *rescoreQuestion, cfg models.RescoreConfig) error {
+	rubricsValue := interface{}(nil)
+	if len(cfg.AspectWeights) > 0 && len(question.Rubrics) > 0 && string(question.Rubrics) != "null" {
+		var rubrics []map[string]interface{}
+		if err := json.Unmarshal(question.Rubrics, &rubrics); err == nil {
+			changed := false
+			for _, rubric := range rubrics {
+				name, _ := rubric["nama_aspek"].(string)
+				if weight, ok := cfg.AspectWeights[strings.ToLower(strings.TrimSpace(name))]; ok {
+					rubric["bobot"] = weight
+					changed = true
+				}
+			}
+			if changed {
+				encoded, err := json.Marshal(rubrics)
+				if err != nil {
+					return fmt.Errorf("failed to encode rubrics for question %s: %w", question.ID, err)
+				}
+				rubricsValue = string(encoded)
+			}
+		}
+	}
+	if _, err := tx.ExecContext(
+		context.Background(),
+		`UPDATE essay_questions
+		 SET rubrics = COALESCE($2::jsonb, rubrics),
+		     round_score_to_5 = COALESCE($3, round_score_to_5),
+		     round_score_step = COALESCE($4, round_score_step),
+		     updated_at = NOW()
+		 WHERE id = $1`,
+		question.ID, rubricsValue, cfg.RoundScoreTo5, cfg.RoundScoreStep,
+	); err != nil {
+		return fmt.Errorf("failed to update scoring config for question %s: %w", question.ID, err)
+	}
+	return nil
+}
+
+// ListRescoreRuns mengembalikan jejak apply rescoring untuk satu soal/kelas, terbaru lebih dulu.
+func (s *EssaySubmissionService) ListRescoreRuns(scopeType, scopeID string) ([]models.RescoreRun, error) {
+	rows, err := s.db.QueryContext(
+		context.Background(),
+		`SELECT r.id, r.scope_type, r.scope_id, r.config::text, r.requested_by, u.nama_lengkap, r.note,
+		        r.matched, r.changed, r.mean_delta, r.updated_question_config, r.created_at
+		 FROM score_rescore_runs r
+		 LEFT JOIN users u ON u.id = r.requested_by
+		 WHERE r.scope_type = $1 AND r.scope_id = $2
+		 ORDER BY r.created_at DESC`,
+		scopeType, scopeID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rescore runs: %w", err)
+	}
+	defer rows.Close()
+
+	items := []models.RescoreRun{}
+	for rows.Next() {
+		var run models.RescoreRun
+		var configJSON string
+		var requestedBy, requestedByName, note sql.NullString
+		if err := rows.Scan(&run.ID, &run.ScopeType, &run.ScopeID, &configJSON, &requestedBy, &requestedByName, &note,
+			&run.Matched, &run.Changed, &run.MeanDelta, &run.UpdatedQuestionConfig, &run.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan rescore run: %w", err)
+		}
+		_ = json.Unmarshal([]byte(configJSON), &run.Config)
+		if requestedBy.Valid {
+			run.RequestedBy = &requestedBy.String
+		}
+		if requestedByName.Valid {
+			run.RequestedByName = &requestedByName.String
+		}
+		if note.Valid {
+			run.Note = &note.String
+		}
+		items = append(items, run)
+	}
+	return items, rows.Err()
+}
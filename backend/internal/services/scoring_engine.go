@@ -0,0 +1,164 @@
+package services
+
+import (
+	"api-backend/internal/models"
+	"fmt"
+	"strings"
+)
+
+// Mode normalisasi skor akhir esai.
+const (
+	// ScoringNormalizationWeightedMean: Σ(bobot×S) / Σ(bobot×M) × 100.
+	// Dengan bobot yang sama, hasilnya identik dengan rumus lama (S / M) × 100.
+	ScoringNormalizationWeightedMean = "weighted_mean"
+	// ScoringNormalizationPerAspectMax: Σ(bobot×S/M) / Σbobot × 100.
+	// Setiap aspek dinormalisasi ke skor maksimumnya sendiri sebelum dirata-rata.
+	ScoringNormalizationPerAspectMax = "per_aspect_max"
+	// scoringNormalizationHolisticBand: rubrik holistik, skor berasal dari band yang dipilih.
+	scoringNormalizationHolisticBand = "holistic_band"
+
+	scoringNormalizationSettingKey = "scoring_normalization"
+	scoringFormulaVersion          = "v1"
+)
+
+// NormalizeScoringNormalization memvalidasi nama mode normalisasi.
+// Nilai kosong/tidak dikenal jatuh ke weighted_mean dan ok=false untuk nilai tidak dikenal.
+func NormalizeScoringNormalization(value string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", ScoringNormalizationWeightedMean:
+		return ScoringNormalizationWeightedMean, true
+	case ScoringNormalizationPerAspectMax:
+		return ScoringNormalizationPerAspectMax, true
+	default:
+		return ScoringNormalizationWeightedMean, false
+	}
+}
+
+// NormalizeRubricType memvalidasi tipe rubrik soal. Nilai kosong jatuh ke analitik.
+func NormalizeRubricType(value string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", models.RubricTypeAnalitik:
+		return models.RubricTypeAnalitik, true
+	case models.RubricTypeHolistik:
+		return models.RubricTypeHolistik, true
+	default:
+		return models.RubricTypeAnalitik, false
+	}
+}
+
+func isHolisticRubric(rubricType string) bool {
+	normalized, _ := NormalizeRubricType(rubricType)
+	return normalized == models.RubricTypeHolistik
+}
+
+func scoringFormulaDescription(normalization string) string {
+	if normalization == ScoringNormalizationPerAspectMax {
+		return "Σ(bobot×S/M) / Σbobot × 100"
+	}
+	return "Σ(bobot×S) / Σ(bobot×M) × 100"
+}
+
+// scoringFormulaLabel menghasilkan label ringkas yang disimpan di ai_results.scoring_formula.
+func scoringFormulaLabel(breakdown *models.ScoringBreakdown) string {
+	if breakdown == nil {
+		return ""
+	}
+	label := fmt.Sprintf("%s@%s: %s", breakdown.Normalization, breakdown.Version, breakdown.Formula)
+	if breakdown.RoundingStep != nil {
+		label += fmt.Sprintf(", dibulatkan ke kelipatan %g", *breakdown.RoundingStep)
+	}
+	return label
+}
+
+// weightedFinalScoreAvgSQL menghitung rata-rata nilai akhir (revisi guru atau skor AI)
+// berbobot EssayQuestion.Weight. Bobot soal kosong/0 dianggap 1 dan submission tanpa nilai
+// tidak ikut menjadi penyebut. Membutuhkan alias eq, tr, dan ar pada query pemanggil.
+const weightedFinalScoreAvgSQL = `(SUM(COALESCE(tr.revised_score, ar.skor_ai) * COALESCE(NULLIF(eq.weight, 0), 1))
+				/ NULLIF(SUM(CASE WHEN COALESCE(tr.revised_score, ar.skor_ai) IS NOT NULL THEN COALESCE(NULLIF(eq.weight, 0), 1) END), 0))::float8`
+
+// calculateGradingScore memilih rumus sesuai tipe rubrik: analitik menjumlahkan aspek berbobot,
+// holistik memetakan satu band ke skor 0-100.
+func calculateGradingScore(rubricType string, rubric []models.RubricAspect, aspectScores []AIAspectScore, normalization string) (float64, *models.ScoringBreakdown, error) {
+	if !isHolisticRubric(rubricType) {
+		return calculateFinalScore(rubric, aspectScores, normalization)
+	}
+	if len(aspectScores) == 0 {
+		return 0, nil, fmt.Errorf("holistic band is missing")
+	}
+	return calculateHolisticScore(rubric, aspectScores[0].SkorDiperoleh)
+}
+
+// calculateHolisticScore memetakan band terpilih ke skor 0-100. Pemetaan memakai skor_band dari
+// rubrik bila tersedia; selain itu linear band / band tertinggi × 100, sama seperti S/M pada analitik.
+func calculateHolisticScore(rubric []models.RubricAspect, band int) (float64, *models.ScoringBreakdown, error) {
+	if len(rubric) != 1 {
+		return 0, nil, fmt.Errorf("holistic rubric must contain exactly one band scale, got %d", len(rubric))
+	}
+	scale := rubric[0]
+	if len(scale.Kriteria) == 0 {
+		return 0, nil, fmt.Errorf("holistic rubric has no bands")
+	}
+	minBand, maxBand := scale.Kriteria[0].Skor, scale.Kriteria[0].Skor
+	for _, criterion := range scale.Kriteria {
+		if criterion.Skor < minBand {
+			minBand = criterion.Skor
+		}
+		if criterion.Skor > maxBand {
+			maxBand = criterion.Skor
+		}
+	}
+	if band < minBand {
+		band = minBand
+	} else if band > maxBand {
+		band = maxBand
+	}
+
+	holistic := &models.HolisticBand{Skala: scale.Aspek, Band: band, MaxBand: maxBand, Mapping: "linear"}
+	for _, criterion := range scale.Kriteria {
+		if criterion.Skor == band {
+			holistic.Deskriptor = criterion.Deskripsi
+			break
+		}
+	}
+
+	formula := "B / Bmax × 100"
+	if mapped, ok := scale.SkorBand[band]; ok {
+		holistic.Skor = mapped
+		holistic.Mapping = "custom"
+		formula = "skor_band[B]"
+	} else {
+		if maxBand <= 0 {
+			return 0, nil, fmt.Errorf("maximum possible score is zero")
+		}
+		holistic.Skor = float64(band) / float64(maxBand) * 100
+	}
+	if holistic.Skor < 0 {
+		holistic.Skor = 0
+	} else if holistic.Skor > 100 {
+		holistic.Skor = 100
+	}
+
+	breakdown := &models.ScoringBreakdown{
+		Version:       scoringFormulaVersion,
+		Normalization: scoringNormalizationHolisticBand,
+		Formula:       formula,
+		Aspects:       []models.ScoringAspectBreakdown{},
+		Holistic:      holistic,
+		RawScore:      holistic.Skor,
+		FinalScore:    holistic.Skor,
+	}
+	return holistic.Skor, breakdown, nil
+}
+
+// formatHolisticScaleForPrompt menulis skala holistik sebagai daftar band untuk prompt grading.
+func formatHolisticScaleForPrompt(rubric []models.RubricAspect) string {
+	var b strings.Builder
+	for _, scale := range rubric {
+		b.WriteString(fmt.Sprintf("Skala: %s\n", scale.Aspek))
+		for _, criterion := range scale.Kriteria {
+			b.WriteString(fmt.Sprintf("- Band %d: %s\n", criterion.Skor, criterion.Deskripsi))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
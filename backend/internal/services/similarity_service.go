@@ -0,0 +1,894 @@
+package services
+
+import (
+	"api-backend/internal/indotext"
+	"api-backend/internal/models"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// DefaultSimilarityPairThreshold adalah batas Jaccard shingle antarjawaban sebelum ditandai mirip.
+	DefaultSimilarityPairThreshold = 0.5
+	// DefaultSimilaritySourceThreshold adalah batas porsi jawaban yang ditemukan di materi/jawaban ideal.
+	DefaultSimilaritySourceThreshold = 0.6
+
+	similarityEnabledSettingKey         = "similarity_check_enabled"
+	similarityPairThresholdSettingKey   = "similarity_pair_threshold"
+	similaritySourceThresholdSettingKey = "similarity_source_threshold"
+
+	// similarityShingleSize adalah panjang shingle kata (n-gram).
+	similarityShingleSize = 3
+	// similarityMinHashSize adalah jumlah fungsi hash pada signature MinHash.
+	similarityMinHashSize = 128
+	// similarityCandidateMargin melonggarkan estimasi MinHash agar pasangan di dekat batas tetap dihitung persis.
+	similarityCandidateMargin = 0.1
+	// similarityMinShingles: jawaban yang terlalu pendek tidak dibandingkan karena rawan positif palsu.
+	similarityMinShingles       = 5
+	similarityMinHighlightWords = 6
+	similarityMaxHighlights     = 8
+)
+
+var (
+	ErrSimilarityQuestionAccess = errors.New("question not found or unauthorized")
+	ErrSimilarityClassAccess    = errors.New("class not found or unauthorized")
+
+	similarityHTMLTagPattern = regexp.MustCompile(`<[^>]*>`)
+	similarityMinHashSeeds   = buildMinHashSeeds(similarityMinHashSize)
+)
+
+// SimilarityService mendeteksi jawaban yang hampir sama (kolusi) antarsiswa pada soal yang sama,
+// serta jawaban yang menyalin teks materi atau jawaban ideal.
+type SimilarityService struct {
+	db       *sql.DB
+	settings *SystemSettingService
+	inFlight sync.Map
+}
+
+func NewSimilarityService(db *sql.DB, settings *SystemSettingService) *SimilarityService {
+	return &SimilarityService{db: db, settings: settings}
+}
+
+// Config membaca ambang deteksi dari system_settings; key yang belum diset memakai default.
+func (s *SimilarityService) Config() models.SimilarityConfig {
+	if s.settings == nil {
+		return defaultSimilarityConfig()
+	}
+	cfg, err := s.settings.GetSimilarityConfig()
+	if err != nil {
+		log.Printf("WARNING: failed to load similarity config: %v", err)
+		return defaultSimilarityConfig()
+	}
+	return cfg
+}
+
+func defaultSimilarityConfig() models.SimilarityConfig {
+	return models.SimilarityConfig{
+		Enabled:         true,
+		PairThreshold:   DefaultSimilarityPairThreshold,
+		SourceThreshold: DefaultSimilaritySourceThreshold,
+	}
+}
+
+// EnsureQuestionAccess memastikan guru boleh melihat laporan kemiripan jawaban untuk soal ini.
+func (s *SimilarityService) EnsureQuestionAccess(questionID, userID, role string) error {
+	owned, err := teacherOwnsQuestion(s.db, questionID, userID, role)
+	if err != nil {
+		return err
+	}
+	if !owned {
+		return ErrSimilarityQuestionAccess
+	}
+	return nil
+}
+
+// EnsureClassAccess memastikan guru boleh melihat laporan kemiripan jawaban untuk kelas ini.
+func (s *SimilarityService) EnsureClassAccess(classID, userID, role string) error {
+	owned, err := teacherOwnsClass(s.db, classID, userID, role)
+	if err != nil {
+		return err
+	}
+	if !owned {
+		return ErrSimilarityClassAccess
+	}
+	return nil
+}
+
+// ScheduleCheck menjalankan pemeriksaan kemiripan di background bila teks submission berubah
+// sejak sidik jari terakhir. Pemanggilan ganda untuk submission yang sama digabung.
+func (s *SimilarityService) ScheduleCheck(submissionID string) {
+	if s == nil || s.db == nil || strings.TrimSpace(submissionID) == "" {
+		return
+	}
+	if !s.Config().Enabled {
+		return
+	}
+	if _, running := s.inFlight.LoadOrStore(submissionID, struct{}{}); running {
+		return
+	}
+	go func() {
+		defer s.inFlight.Delete(submissionID)
+		if err := s.checkSubmission(submissionID, false); err != nil {
+			log.Printf("WARNING: similarity check failed for submission %s: %v", submissionID, err)
+		}
+	}()
+}
+
+// RecomputeQuestion menghitung ulang sidik jari dan pasangan mirip untuk semua submission soal.
+func (s *SimilarityService) RecomputeQuestion(questionID string) (int, error) {
+	rows, err := s.db.QueryContext(
+		context.Background(),
+		`SELECT id FROM essay_submissions WHERE soal_id = $1 ORDER BY submitted_at ASC`,
+		questionID,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load submissions: %w", err)
+	}
+	ids := make([]string, 0)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan submission: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to iterate submissions: %w", err)
+	}
+
+	for _, id := range ids {
+		if err := s.checkSubmission(id, true); err != nil {
+			return 0, err
+		}
+	}
+	return len(ids), nil
+}
+
+type similaritySubmission struct {
+	ID           string
+	QuestionID   string
+	Text         string
+	IdealAnswer  sql.NullString
+	MaterialName sql.NullString
+	MaterialBody sql.NullString
+}
+
+// checkSubmission membandingkan satu submission dengan submission lain pada soal yang sama
+// (MinHash untuk kandidat, lalu Jaccard persis), serta dengan materi dan jawaban ideal soal.
+// Pasangan lama yang melibatkan submission ini diganti hasil terbaru.
+func (s *SimilarityService) checkSubmission(submissionID string, force bool) error {
+	var sub similaritySubmission
+	err := s.db.QueryRowContext(
+		context.Background(),
+		`SELECT es.id, es.soal_id, COALESCE(es.teks_jawaban, ''), eq.ideal_answer, m.judul, m.isi_materi
+		 FROM essay_submissions es
+		 JOIN essay_questions eq ON eq.id = es.soal_id
+		 LEFT JOIN materials m ON m.id = eq.material_id
+		 WHERE es.id = $1`,
+		submissionID,
+	).Scan(&sub.ID, &sub.QuestionID, &sub.Text, &sub.IdealAnswer, &sub.MaterialName, &sub.MaterialBody)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return fmt.Errorf("failed to load submission: %w", err)
+	}
+
+	cfg := s.Config()
+	doc := newSimilarityDoc(sub.Text)
+	textHash := doc.hash()
+	if !force {
+		var storedHash string
+		err := s.db.QueryRowContext(
+			context.Background(),
+			`SELECT text_hash FROM submission_fingerprints WHERE submission_id = $1`,
+			submissionID,
+		).Scan(&storedHash)
+		if err == nil && storedHash == textHash {
+			return nil
+		}
+		if err != nil && err != sql.ErrNoRows {
+			return fmt.Errorf("failed to load fingerprint: %w", err)
+		}
+	}
+	if err := s.storeFingerprint(sub.ID, sub.QuestionID, doc); err != nil {
+		return err
+	}
+
+	pairs := make([]similarityMatch, 0)
+	if len(doc.shingles) >= similarityMinShingles {
+		peerMatches, err := s.matchPeers(sub, doc, cfg.PairThreshold)
+		if err != nil {
+			return err
+		}
+		pairs = append(pairs, peerMatches...)
+
+		if sub.IdealAnswer.Valid {
+			if match, ok := matchSource(doc, newSimilarityDoc(sub.IdealAnswer.String), cfg.SourceThreshold); ok {
+				match.SourceType = "ideal_answer"
+				match.SourceLabel = "Jawaban ideal"
+				pairs = append(pairs, match)
+			}
+		}
+		if sub.MaterialBody.Valid {
+			materialText := similarityHTMLTagPattern.ReplaceAllString(sub.MaterialBody.String, " ")
+			if match, ok := matchSource(doc, newSimilarityDoc(materialText), cfg.SourceThreshold); ok {
+				match.SourceType = "material"
+				match.SourceLabel = strings.TrimSpace(sub.MaterialName.String)
+				pairs = append(pairs, match)
+			}
+		}
+	}
+	return s.replacePairs(sub, pairs)
+}
+
+func (s *SimilarityService) storeFingerprint(submissionID, questionID string, doc *similarityDoc) error {
+	signature := doc.signature()
+	if _, err := s.db.ExecContext(
+		context.Background(),
+		`INSERT INTO submission_fingerprints (submission_id, question_id, text_hash, signature, shingle_count, computed_at)
+		 VALUES ($1, $2, $3, $4, $5, NOW())
+		 ON CONFLICT (submission_id) DO UPDATE
+		 SET question_id = EXCLUDED.question_id,
+		     text_hash = EXCLUDED.text_hash,
+		     signature = EXCLUDED.signature,
+		     shingle_count = EXCLUDED.shingle_count,
+		     computed_at = NOW()`,
+		submissionID, questionID, doc.hash(), pq.Array(signature), len(doc.shingles),
+	); err != nil {
+		return fmt.Errorf("failed to store fingerprint: %w", err)
+	}
+	return nil
+}
+
+type similarityMatch struct {
+	OtherSubmissionID string
+	SourceType        string
+	SourceLabel       string
+	Similarity        float64
+	Containment       float64
+	Highlights        []models.SimilarityHighlight
+}
+
+// matchPeers mencari submission lain pada soal yang sama dengan estimasi MinHash di atas batas,
+// lalu menghitung Jaccard persis dan potongan teks yang sama untuk kandidat tersebut.
+func (s *SimilarityService) matchPeers(sub similaritySubmission, doc *similarityDoc, threshold float64) ([]similarityMatch, error) {
+	if err := s.backfillFingerprints(sub.QuestionID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(
+		context.Background(),
+		`SELECT submission_id, signature
+		 FROM submission_fingerprints
+		 WHERE question_id = $1 AND submission_id <> $2 AND shingle_count >= $3`,
+		sub.QuestionID, sub.ID, similarityMinShingles,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load fingerprints: %w", err)
+	}
+	signature := doc.signature()
+	candidates := make([]string, 0)
+	for rows.Next() {
+		var (
+			otherID        string
+			otherSignature []int64
+		)
+		if err := rows.Scan(&otherID, pq.Array(&otherSignature)); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan fingerprint: %w", err)
+		}
+		if estimateJaccard(signature, otherSignature) >= threshold-similarityCandidateMargin {
+			candidates = append(candidates, otherID)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate fingerprints: %w", err)
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	textRows, err := s.db.QueryContext(
+		context.Background(),
+		`SELECT id, COALESCE(teks_jawaban, '') FROM essay_submissions WHERE id = ANY($1)`,
+		pq.Array(candidates),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load candidate submissions: %w", err)
+	}
+	defer textRows.Close()
+
+	matches := make([]similarityMatch, 0, len(candidates))
+	for textRows.Next() {
+		var otherID, otherText string
+		if err := textRows.Scan(&otherID, &otherText); err != nil {
+			return nil, fmt.Errorf("failed to scan candidate submission: %w", err)
+		}
+		other := newSimilarityDoc(otherText)
+		jaccard, containment := shingleOverlap(doc, other)
+		if jaccard < threshold {
+			continue
+		}
+		matches = append(matches, similarityMatch{
+			OtherSubmissionID: otherID,
+			SourceType:        "submission",
+			Similarity:        jaccard,
+			Containment:       containment,
+			Highlights:        commonSpans(doc, other, true),
+		})
+	}
+	if err := textRows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate candidate submissions: %w", err)
+	}
+	return matches, nil
+}
+
+// backfillFingerprints membuat sidik jari untuk submission lama pada soal yang belum punya.
+func (s *SimilarityService) backfillFingerprints(questionID string) error {
+	rows, err := s.db.QueryContext(
+		context.Background(),
+		`SELECT es.id, COALESCE(es.teks_jawaban, '')
+		 FROM essay_submissions es
+		 LEFT JOIN submission_fingerprints sf ON sf.submission_id = es.id
+		 WHERE es.soal_id = $1 AND sf.submission_id IS NULL`,
+		questionID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to load submissions without fingerprint: %w", err)
+	}
+	type pending struct{ id, text string }
+	items := make([]pending, 0)
+	for rows.Next() {
+		var item pending
+		if err := rows.Scan(&item.id, &item.text); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan submission: %w", err)
+		}
+		items = append(items, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate submissions: %w", err)
+	}
+	for _, item := range items {
+		if err := s.storeFingerprint(item.id, questionID, newSimilarityDoc(item.text)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchSource mengukur porsi jawaban yang ditemukan di teks sumber (containment), bukan Jaccard,
+// karena materi jauh lebih panjang dari jawaban.
+func matchSource(doc, source *similarityDoc, threshold float64) (similarityMatch, bool) {
+	if len(source.shingles) == 0 {
+		return similarityMatch{}, false
+	}
+	jaccard, containment := shingleOverlap(doc, source)
+	if containment < threshold {
+		return similarityMatch{}, false
+	}
+	return similarityMatch{
+		Similarity:  jaccard,
+		Containment: containment,
+		Highlights:  commonSpans(doc, source, false),
+	}, true
+}
+
+func (s *SimilarityService) replacePairs(sub similaritySubmission, matches []similarityMatch) error {
+	tx, err := s.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin similarity transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(
+		context.Background(),
+		`DELETE FROM submission_similarity_pairs WHERE submission_id = $1 OR other_submission_id = $1`,
+		sub.ID,
+	); err != nil {
+		return fmt.Errorf("failed to clear similarity pairs: %w", err)
+	}
+	for _, match := range matches {
+		highlights, err := json.Marshal(match.Highlights)
+		if err != nil {
+			return fmt.Errorf("failed to encode highlights: %w", err)
+		}
+		if _, err := tx.ExecContext(
+			context.Background(),
+			`INSERT INTO submission_similarity_pairs
+			     (question_id, submission_id, other_submission_id, source_type, source_label, similarity, containment, highlights)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+			sub.QuestionID, sub.ID, nullIfEmpty(match.OtherSubmissionID), match.SourceType, nullIfEmpty(match.SourceLabel),
+			roundTo(match.Similarity, 4), roundTo(match.Containment, 4), string(highlights),
+		); err != nil {
+			return fmt.Errorf("failed to store similarity pair: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// QuestionReport mengembalikan pasangan mirip pada soal sesuai ambang yang berlaku saat ini.
+func (s *SimilarityService) QuestionReport(questionID string) (*models.QuestionSimilarityReport, error) {
+	cfg := s.Config()
+	report := &models.QuestionSimilarityReport{
+		QuestionID:    questionID,
+		Config:        cfg,
+		Pairs:         []models.SimilarityPair{},
+		SourceMatches: []models.SimilarityPair{},
+	}
+	if err := s.db.QueryRowContext(
+		context.Background(),
+		`SELECT COUNT(*),
+		        (SELECT COUNT(*) FROM submission_fingerprints sf WHERE sf.question_id = $1)
+		 FROM essay_submissions WHERE soal_id = $1`,
+		questionID,
+	).Scan(&report.SubmissionCount, &report.FingerprintCount); err != nil {
+		return nil, fmt.Errorf("failed to count submissions: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(
+		context.Background(),
+		`SELECT sp.id, sp.question_id, sp.submission_id, es.siswa_id, COALESCE(u.nama_lengkap, ''),
+		        sp.source_type, sp.source_label, sp.other_submission_id, oes.siswa_id, ou.nama_lengkap,
+		        sp.similarity, sp.containment, sp.highlights, sp.created_at
+		 FROM submission_similarity_pairs sp
+		 JOIN essay_submissions es ON es.id = sp.submission_id
+		 LEFT JOIN users u ON u.id = es.siswa_id
+		 LEFT JOIN essay_submissions oes ON oes.id = sp.other_submission_id
+		 LEFT JOIN users ou ON ou.id = oes.siswa_id
+		 WHERE sp.question_id = $1
+		   AND ((sp.source_type = 'submission' AND sp.similarity >= $2)
+		        OR (sp.source_type <> 'submission' AND sp.containment >= $3))
+		 ORDER BY sp.similarity DESC, sp.containment DESC`,
+		questionID, cfg.PairThreshold, cfg.SourceThreshold,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load similarity pairs: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		item, err := scanSimilarityPair(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan similarity pair: %w", err)
+		}
+		if item.SourceType == "submission" {
+			report.Pairs = append(report.Pairs, *item)
+		} else {
+			report.SourceMatches = append(report.SourceMatches, *item)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate similarity pairs: %w", err)
+	}
+	return report, nil
+}
+
+func scanSimilarityPair(scanner interface{ Scan(...interface{}) error }) (*models.SimilarityPair, error) {
+	var (
+		item           models.SimilarityPair
+		sourceLabel    sql.NullString
+		otherID        sql.NullString
+		otherStudentID sql.NullString
+		otherName      sql.NullString
+		highlights     []byte
+	)
+	if err := scanner.Scan(
+		&item.ID, &item.QuestionID, &item.SubmissionID, &item.StudentID, &item.StudentName,
+		&item.SourceType, &sourceLabel, &otherID, &otherStudentID, &otherName,
+		&item.Similarity, &item.Containment, &highlights, &item.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	if sourceLabel.Valid {
+		item.SourceLabel = &sourceLabel.String
+	}
+	if otherID.Valid {
+		item.OtherSubmissionID = &otherID.String
+	}
+	if otherStudentID.Valid {
+		item.OtherStudentID = &otherStudentID.String
+	}
+	if otherName.Valid {
+		item.OtherStudentName = &otherName.String
+	}
+	item.Highlights = []models.SimilarityHighlight{}
+	if len(highlights) > 0 {
+		_ = json.Unmarshal(highlights, &item.Highlights)
+	}
+	return &item, nil
+}
+
+// ClassReport mengelompokkan siswa yang jawabannya saling mirip di semua soal kelas.
+// Siswa menjadi simpul, pasangan mirip menjadi sisi; tiap komponen terhubung adalah satu kelompok.
+func (s *SimilarityService) ClassReport(classID string) (*models.ClassSimilarityReport, error) {
+	cfg := s.Config()
+	report := &models.ClassSimilarityReport{
+		ClassID:     classID,
+		Config:      cfg,
+		Clusters:    []models.SimilarityCluster{},
+		SourceCopy:  []models.SimilaritySourceCopy{},
+		GeneratedAt: time.Now(),
+	}
+
+	rows, err := s.db.QueryContext(
+		context.Background(),
+		`SELECT es.siswa_id, COALESCE(u.nama_lengkap, ''), oes.siswa_id, COALESCE(ou.nama_lengkap, ''),
+		        eq.id, eq.teks_soal, sp.similarity
+		 FROM submission_similarity_pairs sp
+		 JOIN essay_questions eq ON eq.id = sp.question_id
+		 JOIN materials m ON m.id = eq.material_id
+		 JOIN essay_submissions es ON es.id = sp.submission_id
+		 JOIN essay_submissions oes ON oes.id = sp.other_submission_id
+		 LEFT JOIN users u ON u.id = es.siswa_id
+		 LEFT JOIN users ou ON ou.id = oes.siswa_id
+		 WHERE m.class_id = $1
+		   AND sp.source_type = 'submission'
+		   AND sp.similarity >= $2
+		   AND es.siswa_id <> oes.siswa_id`,
+		classID, cfg.PairThreshold,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load class similarity pairs: %w", err)
+	}
+	defer rows.Close()
+
+	type edge struct {
+		a, b         string
+		questionID   string
+		questionText string
+		similarity   float64
+	}
+	edges := make([]edge, 0)
+	names := map[string]string{}
+	for rows.Next() {
+		var (
+			item         edge
+			nameA, nameB string
+		)
+		if err := rows.Scan(&item.a, &nameA, &item.b, &nameB, &item.questionID, &item.questionText, &item.similarity); err != nil {
+			return nil, fmt.Errorf("failed to scan class similarity pair: %w", err)
+		}
+		names[item.a] = nameA
+		names[item.b] = nameB
+		edges = append(edges, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate class similarity pairs: %w", err)
+	}
+	report.PairCount = len(edges)
+
+	uf := newUnionFind()
+	for _, item := range edges {
+		uf.union(item.a, item.b)
+	}
+	type clusterAcc struct {
+		cluster   models.SimilarityCluster
+		members   map[string]struct{}
+		questions map[string]int
+		sum       float64
+	}
+	clusters := map[string]*clusterAcc{}
+	order := make([]string, 0)
+	for _, item := range edges {
+		root := uf.find(item.a)
+		acc, ok := clusters[root]
+		if !ok {
+			acc = &clusterAcc{members: map[string]struct{}{}, questions: map[string]int{}}
+			clusters[root] = acc
+			order = append(order, root)
+		}
+		for _, studentID := range []string{item.a, item.b} {
+			if _, seen := acc.members[studentID]; !seen {
+				acc.members[studentID] = struct{}{}
+				acc.cluster.Students = append(acc.cluster.Students, models.SimilarityClusterStudent{StudentID: studentID, StudentName: names[studentID]})
+			}
+		}
+		idx, seen := acc.questions[item.questionID]
+		if !seen {
+			idx = len(acc.cluster.Questions)
+			acc.questions[item.questionID] = idx
+			acc.cluster.Questions = append(acc.cluster.Questions, models.SimilarityClusterQuestion{QuestionID: item.questionID, QuestionText: item.questionText})
+		}
+		question := &acc.cluster.Questions[idx]
+		question.PairCount++
+		if item.similarity > question.MaxSimilarity {
+			question.MaxSimilarity = item.similarity
+		}
+		acc.cluster.PairCount++
+		acc.sum += item.similarity
+		if item.similarity > acc.cluster.MaxSimilarity {
+			acc.cluster.MaxSimilarity = item.similarity
+		}
+	}
+	for _, root := range order {
+		acc := clusters[root]
+		acc.cluster.AvgSimilarity = roundTo(acc.sum/float64(acc.cluster.PairCount), 4)
+		sort.SliceStable(acc.cluster.Students, func(i, j int) bool {
+			return acc.cluster.Students[i].StudentName < acc.cluster.Students[j].StudentName
+		})
+		sort.SliceStable(acc.cluster.Questions, func(i, j int) bool {
+			return acc.cluster.Questions[i].MaxSimilarity > acc.cluster.Questions[j].MaxSimilarity
+		})
+		report.Clusters = append(report.Clusters, acc.cluster)
+	}
+	sort.SliceStable(report.Clusters, func(i, j int) bool {
+		if len(report.Clusters[i].Students) != len(report.Clusters[j].Students) {
+			return len(report.Clusters[i].Students) > len(report.Clusters[j].Students)
+		}
+		return report.Clusters[i].MaxSimilarity > report.Clusters[j].MaxSimilarity
+	})
+
+	sourceRows, err := s.db.QueryContext(
+		context.Background(),
+		`SELECT es.siswa_id, COALESCE(u.nama_lengkap, ''),
+		        COUNT(*) FILTER (WHERE sp.source_type = 'material'),
+		        COUNT(*) FILTER (WHERE sp.source_type = 'ideal_answer'),
+		        MAX(sp.containment)
+		 FROM submission_similarity_pairs sp
+		 JOIN essay_questions eq ON eq.id = sp.question_id
+		 JOIN materials m ON m.id = eq.material_id
+		 JOIN essay_submissions es ON es.id = sp.submission_id
+		 LEFT JOIN users u ON u.id = es.siswa_id
+		 WHERE m.class_id = $1
+		   AND sp.source_type <> 'submission'
+		   AND sp.containment >= $2
+		 GROUP BY es.siswa_id, u.nama_lengkap
+		 ORDER BY MAX(sp.containment) DESC`,
+		classID, cfg.SourceThreshold,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load class source matches: %w", err)
+	}
+	defer sourceRows.Close()
+	for sourceRows.Next() {
+		var item models.SimilaritySourceCopy
+		if err := sourceRows.Scan(&item.StudentID, &item.StudentName, &item.MaterialCount, &item.IdealCount, &item.MaxContainment); err != nil {
+			return nil, fmt.Errorf("failed to scan class source match: %w", err)
+		}
+		report.SourceCopy = append(report.SourceCopy, item)
+	}
+	if err := sourceRows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate class source matches: %w", err)
+	}
+	return report, nil
+}
+
+type unionFind struct {
+	parent map[string]string
+}
+
+func newUnionFind() *unionFind {
+	return &unionFind{parent: map[string]string{}}
+}
+
+func (u *unionFind) find(key string) string {
+	parent, ok := u.parent[key]
+	if !ok {
+		u.parent[key] = key
+		return key
+	}
+	if parent == key {
+		return key
+	}
+	root := u.find(parent)
+	u.parent[key] = root
+	return root
+}
+
+func (u *unionFind) union(a, b string) {
+	rootA, rootB := u.find(a), u.find(b)
+	if rootA != rootB {
+		u.parent[rootB] = rootA
+	}
+}
+
+type similarityToken struct {
+	word       string
+	start, end int // offset byte pada teks asli
+}
+
+// similarityDoc adalah teks yang sudah dinormalisasi (huruf kecil, tanpa tanda baca, ter-stem) beserta shingle katanya.
+type similarityDoc struct {
+	text      string
+	tokens    []similarityToken
+	posHashes []uint64 // hash shingle yang dimulai di tiap posisi token
+	shingles  map[uint64]struct{}
+}
+
+func newSimilarityDoc(text string) *similarityDoc {
+	doc := &similarityDoc{text: text, shingles: map[uint64]struct{}{}}
+	doc.tokens = tokenizeForSimilarity(text)
+	if len(doc.tokens) == 0 {
+		return doc
+	}
+	size := similarityShingleSize
+	if len(doc.tokens) < size {
+		size = len(doc.tokens)
+	}
+	doc.posHashes = make([]uint64, 0, len(doc.tokens)-size+1)
+	for i := 0; i+size <= len(doc.tokens); i++ {
+		h := fnv.New64a()
+		for j := i; j < i+size; j++ {
+			h.Write([]byte(doc.tokens[j].word))
+			h.Write([]byte{' '})
+		}
+		sum := h.Sum64()
+		doc.posHashes = append(doc.posHashes, sum)
+		doc.shingles[sum] = struct{}{}
+	}
+	return doc
+}
+
+// tokenizeForSimilarity memakai normalisasi indotext (huruf kecil, tanpa diakritik, bentuk ulang diringkas)
+// lalu men-stem tiap kata agar perubahan imbuhan saja ("menghasilkan" -> "hasil") tidak menyamarkan salinan.
+func tokenizeForSimilarity(text string) []similarityToken {
+	words := indotext.Tokenize(text)
+	tokens := make([]similarityToken, 0, len(words))
+	for _, word := range words {
+		tokens = append(tokens, similarityToken{word: indotext.Stem(word.Text), start: word.Start, end: word.End})
+	}
+	return tokens
+}
+
+// hash adalah sidik jari teks ternormalisasi untuk mendeteksi perubahan jawaban.
+func (d *similarityDoc) hash() string {
+	words := make([]string, 0, len(d.tokens))
+	for _, token := range d.tokens {
+		words = append(words, token.word)
+	}
+	sum := sha256.Sum256([]byte(strings.Join(words, " ")))
+	return hex.EncodeToString(sum[:])
+}
+
+// signature menghitung MinHash: untuk tiap fungsi hash diambil nilai minimum atas semua shingle.
+func (d *similarityDoc) signature() []int64 {
+	signature := make([]int64, similarityMinHashSize)
+	if len(d.shingles) == 0 {
+		return signature
+	}
+	mins := make([]uint64, similarityMinHashSize)
+	for i := range mins {
+		mins[i] = ^uint64(0)
+	}
+	for shingle := range d.shingles {
+		for i, seed := range similarityMinHashSeeds {
+			if value := mixHash64(shingle ^ seed); value < mins[i] {
+				mins[i] = value
+			}
+		}
+	}
+	for i, value := range mins {
+		signature[i] = int64(value)
+	}
+	return signature
+}
+
+func buildMinHashSeeds(n int) []uint64 {
+	seeds := make([]uint64, n)
+	state := uint64(0x9E3779B97F4A7C15)
+	for i := range seeds {
+		state += 0x9E3779B97F4A7C15
+		seeds[i] = mixHash64(state)
+	}
+	return seeds
+}
+
+// mixHash64 adalah finalizer splitmix64.
+func mixHash64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xBF58476D1CE4E5B9
+	x ^= x >> 27
+	x *= 0x94D049BB133111EB
+	x ^= x >> 31
+	return x
+}
+
+func estimateJaccard(a, b []int64) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	equal := 0
+	for i := range a {
+		if a[i] == b[i] {
+			equal++
+		}
+	}
+	return float64(equal) / float64(len(a))
+}
+
+// shingleOverlap mengembalikan Jaccard |A∩B|/|A∪B| dan containment |A∩B|/|A|.
+func shingleOverlap(a, b *similarityDoc) (float64, float64) {
+	if len(a.shingles) == 0 || len(b.shingles) == 0 {
+		return 0, 0
+	}
+	intersection := 0
+	for shingle := range a.shingles {
+		if _, ok := b.shingles[shingle]; ok {
+			intersection++
+		}
+	}
+	union := len(a.shingles) + len(b.shingles) - intersection
+	return float64(intersection) / float64(union), float64(intersection) / float64(len(a.shingles))
+}
+
+// commonSpans mencari potongan kata berurutan yang sama persis (minimal similarityMinHighlightWords kata)
+// antara a dan b secara greedy dari awal a. withOther=true menyertakan potongan dan offset di b.
+func commonSpans(a, b *similarityDoc, withOther bool) []models.SimilarityHighlight {
+	positions := map[uint64][]int{}
+	for j, h := range b.posHashes {
+		if len(positions[h]) < 32 {
+			positions[h] = append(positions[h], j)
+		}
+	}
+
+	spans := make([]models.SimilarityHighlight, 0)
+	for i := 0; i < len(a.posHashes); {
+		bestLen, bestJ := 0, -1
+		for _, j := range positions[a.posHashes[i]] {
+			length := 0
+			for i+length < len(a.tokens) && j+length < len(b.tokens) && a.tokens[i+length].word == b.tokens[j+length].word {
+				length++
+			}
+			if length > bestLen {
+				bestLen, bestJ = length, j
+			}
+		}
+		if bestLen < similarityMinHighlightWords {
+			i++
+			continue
+		}
+		start, end := a.tokens[i].start, a.tokens[i+bestLen-1].end
+		span := models.SimilarityHighlight{
+			Text:  a.text[start:end],
+			Words: bestLen,
+			Start: utf8.RuneCountInString(a.text[:start]),
+			End:   utf8.RuneCountInString(a.text[:end]),
+		}
+		if withOther {
+			otherStart, otherEnd := b.tokens[bestJ].start, b.tokens[bestJ+bestLen-1].end
+			runeStart := utf8.RuneCountInString(b.text[:otherStart])
+			runeEnd := utf8.RuneCountInString(b.text[:otherEnd])
+			span.OtherText = b.text[otherStart:otherEnd]
+			span.OtherStart = &runeStart
+			span.OtherEnd = &runeEnd
+		}
+		spans = append(spans, span)
+		i += bestLen
+	}
+
+	// Potongan terpanjang ditampilkan lebih dulu.
+	sort.SliceStable(spans, func(i, j int) bool { return spans[i].Words > spans[j].Words })
+	if len(spans) > similarityMaxHighlights {
+		spans = spans[:similarityMaxHighlights]
+	}
+	return spans
+}
+
+func parseSimilarityThreshold(value string, fallback float64) float64 {
+	parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || parsed <= 0 || parsed > 1 {
+		return fallback
+	}
+	return parsed
+}
@@ -1,8 +1,10 @@
 package services
 
 import (
+	"api-backend/internal/models"
 	"database/sql"
 	"fmt"
+	"strconv"
 	"strings"
 )
 
@@ -67,3 +69,201 @@ func (s *SystemSettingService) SetGradingMode(mode string) error {
 	}
 	return s.SetSetting("grading_mode", mode)
 }
+
+// GetScoringNormalization membaca setting scoring_normalization, default weighted_mean.
+func (s *SystemSettingService) GetScoringNormalization() (string, error) {
+	value, err := s.GetSetting(scoringNormalizationSettingKey)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ScoringNormalizationWeightedMean, nil
+		}
+		return "", err
+	}
+	normalized, _ := NormalizeScoringNormalization(value)
+	return normalized, nil
+}
+
+// GetEnsembleConfig membaca konfigurasi ensemble grading global.
+// Key yang belum diset memakai default (ensemble nonaktif, 3 sampel, batas selisih 15 poin).
+func (s *SystemSettingService) GetEnsembleConfig() (EnsembleConfig, error) {
+	cfg := EnsembleConfig{Samples: DefaultEnsembleSamples, SpreadThreshold: DefaultEnsembleSpreadThreshold}
+	read := func(key string) (string, error) {
+		value, err := s.GetSetting(key)
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return strings.TrimSpace(value), err
+	}
+
+	enabled, err := read(ensembleEnabledSettingKey)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Enabled = strings.EqualFold(enabled, "true")
+
+	samples, err := read(ensembleSamplesSettingKey)
+	if err != nil {
+		return cfg, err
+	}
+	if parsed, parseErr := strconv.Atoi(samples); parseErr == nil && parsed > 0 {
+		cfg.Samples = parsed
+	}
+
+	modelsRaw, err := read(ensembleModelsSettingKey)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Models = ParseEnsembleModels(modelsRaw)
+
+	threshold, err := read(ensembleSpreadThresholdSettingKey)
+	if err != nil {
+		return cfg, err
+	}
+	if parsed, parseErr := strconv.ParseFloat(threshold, 64); parseErr == nil && parsed > 0 {
+		cfg.SpreadThreshold = parsed
+	}
+	return cfg, nil
+}
+
+// GetGradingCacheConfig membaca konfigurasi cache grading.
+// Key yang belum diset memakai default (aktif, TTL 720 jam, maksimum 20000 entri).
+func (s *SystemSettingService) GetGradingCacheConfig() (GradingCacheConfig, error) {
+	cfg := defaultGradingCacheConfig()
+	read := func(key string) (string, error) {
+		value, err := s.GetSetting(key)
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return strings.TrimSpace(value), err
+	}
+
+	enabled, err := read(gradingCacheEnabledSettingKey)
+	if err != nil {
+		return cfg, err
+	}
+	if enabled != "" {
+		cfg.Enabled = strings.EqualFold(enabled, "true")
+	}
+
+	ttlHours, err := read(gradingCacheTTLHoursSettingKey)
+	if err != nil {
+		return cfg, err
+	}
+	if parsed, parseErr := strconv.Atoi(ttlHours); parseErr == nil && parsed >= 0 {
+		cfg.TTLHours = parsed
+	}
+
+	maxEntries, err := read(gradingCacheMaxEntriesSettingKey)
+	if err != nil {
+		return cfg, err
+	}
+	if parsed, parseErr := strconv.Atoi(maxEntries); parseErr == nil && parsed >= 0 {
+		cfg.MaxEntries = parsed
+	}
+	return cfg, nil
+}
+
+// GetGradingQueueConfig membaca konfigurasi retry dan kapasitas antrean grading.
+// Key yang belum diset memakai default (retry aktif, 2 retry, maksimum 1000 job queued, tidak dijeda, round-robin per kelas).
+func (s *SystemSettingService) GetGradingQueueConfig() (GradingQueueConfig, error) {
+	cfg := defaultGradingQueueConfig()
+	read := func(key string) (string, error) {
+		value, err := s.GetSetting(key)
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return strings.TrimSpace(value), err
+	}
+
+	enabled, err := read(aiRetryEnabledSettingKey)
+	if err != nil {
+		return cfg, err
+	}
+	if enabled != "" {
+		cfg.RetryEnabled = strings.EqualFold(enabled, "true")
+	}
+
+	maxAttempts, err := read(aiRetryMaxAttemptsSettingKey)
+	if err != nil {
+		return cfg, err
+	}
+	if parsed, parseErr := strconv.Atoi(maxAttempts); parseErr == nil && parsed >= 0 {
+		cfg.RetryMaxAttempts = parsed
+	}
+
+	maxSize, err := read(queueMaxSizeSettingKey)
+	if err != nil {
+		return cfg, err
+	}
+	if parsed, parseErr := strconv.Atoi(maxSize); parseErr == nil && parsed > 0 {
+		cfg.MaxSize = parsed
+	}
+
+	paused, err := read(gradingQueuePausedSettingKey)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Paused = strings.EqualFold(paused, "true")
+
+	fairness, err := read(gradingQueueFairnessSettingKey)
+	if err != nil {
+		return cfg, err
+	}
+	if normalized, ok := NormalizeGradingQueueFairness(fairness); ok {
+		cfg.Fairness = normalized
+	}
+	return cfg, nil
+}
+
+// GetFewShotExemplarCount membaca jumlah maksimum contoh guru per prompt grading (0 = nonaktif).
+func (s *SystemSettingService) GetFewShotExemplarCount() (int, error) {
+	value, err := s.GetSetting(fewShotExemplarCountSettingKey)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return DefaultFewShotExemplarCount, nil
+		}
+		return 0, err
+	}
+	parsed, parseErr := strconv.Atoi(strings.TrimSpace(value))
+	if parseErr != nil || parsed < 0 {
+		return DefaultFewShotExemplarCount, nil
+	}
+	if parsed > MaxFewShotExemplarCount {
+		parsed = MaxFewShotExemplarCount
+	}
+	return parsed, nil
+}
+
+// GetSimilarityConfig membaca konfigurasi deteksi kemiripan jawaban.
+// Key yang belum diset memakai default (aktif, batas pasangan 0.5, batas sumber 0.6).
+func (s *SystemSettingService) GetSimilarityConfig() (models.SimilarityConfig, error) {
+	cfg := defaultSimilarityConfig()
+	read := func(key string) (string, error) {
+		value, err := s.GetSetting(key)
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return strings.TrimSpace(value), err
+	}
+
+	enabled, err := read(similarityEnabledSettingKey)
+	if err != nil {
+		return cfg, err
+	}
+	if enabled != "" {
+		cfg.Enabled = strings.EqualFold(enabled, "true")
+	}
+
+	pairThreshold, err := read(similarityPairThresholdSettingKey)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.PairThreshold = parseSimilarityThreshold(pairThreshold, cfg.PairThreshold)
+
+	sourceThreshold, err := read(similaritySourceThresholdSettingKey)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.SourceThreshold = parseSimilarityThreshold(sourceThreshold, cfg.SourceThreshold)
+	return cfg, nil
+}
@@ -0,0 +1,53 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Pemeriksaan kepemilikan bersama untuk fitur guru: soal, submission, dan kelas dianggap milik guru bila
+// berada di kelas yang teacher_id-nya sama. Superadmin boleh mengakses semuanya. Setiap service memetakan
+// hasil false ke error akses miliknya sendiri.
+
+// teacherOwnsQuestion melaporkan apakah soal berada di kelas milik guru.
+func teacherOwnsQuestion(db *sql.DB, questionID, userID, role string) (bool, error) {
+	return ownershipExists(db, "question",
+		`SELECT eq.id
+		 FROM essay_questions eq
+		 JOIN materials m ON m.id = eq.material_id
+		 JOIN classes c ON c.id = m.class_id
+		 WHERE eq.id = $1 AND ($3 = 'superadmin' OR c.teacher_id = $2)`,
+		questionID, userID, role)
+}
+
+// teacherOwnsSubmission melaporkan apakah submission menjawab soal di kelas milik guru.
+func teacherOwnsSubmission(db *sql.DB, submissionID, userID, role string) (bool, error) {
+	return ownershipExists(db, "submission",
+		`SELECT es.id
+		 FROM essay_submissions es
+		 JOIN essay_questions eq ON eq.id = es.soal_id
+		 JOIN materials m ON m.id = eq.material_id
+		 JOIN classes c ON c.id = m.class_id
+		 WHERE es.id = $1 AND ($3 = 'superadmin' OR c.teacher_id = $2)`,
+		submissionID, userID, role)
+}
+
+// teacherOwnsClass melaporkan apakah kelas milik guru.
+func teacherOwnsClass(db *sql.DB, classID, userID, role string) (bool, error) {
+	return ownershipExists(db, "class",
+		`SELECT id FROM classes WHERE id = $1 AND ($3 = 'superadmin' OR teacher_id = $2)`,
+		classID, userID, role)
+}
+
+func ownershipExists(db *sql.DB, subject, query, id, userID, role string) (bool, error) {
+	var found string
+	err := db.QueryRowContext(context.Background(), query, id, userID, role).Scan(&found)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error validating %s ownership: %w", subject, err)
+	}
+	return true, nil
+}
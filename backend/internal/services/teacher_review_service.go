@@ -4,6 +4,7 @@ import (
 	"api-backend/internal/models"
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
@@ -19,6 +20,29 @@ func NewTeacherReviewService(db *sql.DB) *TeacherReviewService {
 	return &TeacherReviewService{db: db}
 }
 
+// marshalTeacherAspectScores converts per-aspect teacher scores into a JSONB value (nil when empty).
+func marshalTeacherAspectScores(scores []models.GradeEssayAspectScore) (interface{}, error) {
+	if len(scores) == 0 {
+		return nil, nil
+	}
+	payload, err := json.Marshal(scores)
+	if err != nil {
+		return nil, err
+	}
+	return string(payload), nil
+}
+
+func unmarshalTeacherAspectScores(raw sql.NullString) []models.GradeEssayAspectScore {
+	if !raw.Valid || strings.TrimSpace(raw.String) == "" {
+		return nil
+	}
+	var scores []models.GradeEssayAspectScore
+	if err := json.Unmarshal([]byte(raw.String), &scores); err != nil {
+		return nil
+	}
+	return scores
+}
+
 // CreateTeacherReview creates a new teacher review for a submission.
 func (s *TeacherReviewService) CreateTeacherReview(req *models.CreateTeacherReviewRequest, teacherID string) (*models.TeacherReview, error) {
 	newReview := &models.TeacherReview{
@@ -26,21 +50,27 @@ func (s *TeacherReviewService) CreateTeacherReview(req *models.CreateTeacherRevi
 		TeacherID:       teacherID,
 		RevisedScore:    req.RevisedScore,
 		TeacherFeedback: req.TeacherFeedback,
+		AspectScores:    req.AspectScores,
 		CreatedAt:       time.Now(),
 		UpdatedAt:       time.Now(),
 	}
+	aspectScores, err := marshalTeacherAspectScores(newReview.AspectScores)
+	if err != nil {
+		return nil, fmt.Errorf("invalid aspect scores: %w", err)
+	}
 
 	query := `
-		INSERT INTO teacher_reviews (submission_id, teacher_id, revised_score, teacher_feedback, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO teacher_reviews (submission_id, teacher_id, revised_score, teacher_feedback, aspect_scores, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
 		RETURNING id, created_at, updated_at
 	`
-	err := s.db.QueryRowContext(context.Background(),
+	err = s.db.QueryRowContext(context.Background(),
 		query,
 		newReview.SubmissionID,
 		newReview.TeacherID,
 		newReview.RevisedScore,
 		newReview.TeacherFeedback,
+		aspectScores,
 		newReview.CreatedAt,
 		newReview.UpdatedAt,
 	).Scan(&newReview.ID, &newReview.CreatedAt, &newReview.UpdatedAt)
@@ -59,8 +89,9 @@ func (s *TeacherReviewService) UpdateTeacherReview(reviewID string, req *models.
 
 	// First, get the existing review to check for existence
 	var existing models.TeacherReview
-	err := s.db.QueryRowContext(context.Background(), "SELECT id, submission_id, teacher_id, revised_score, teacher_feedback, created_at, updated_at FROM teacher_reviews WHERE id = $1", reviewID).Scan(
-		&existing.ID, &existing.SubmissionID, &existing.TeacherID, &existing.RevisedScore, &existing.TeacherFeedback, &existing.CreatedAt, &existing.UpdatedAt,
+	var existingAspects sql.NullString
+	err := s.db.QueryRowContext(context.Background(), "SELECT id, submission_id, teacher_id, revised_score, teacher_feedback, aspect_scores::text, created_at, updated_at FROM teacher_reviews WHERE id = $1", reviewID).Scan(
+		&existing.ID, &existing.SubmissionID, &existing.TeacherID, &existing.RevisedScore, &existing.TeacherFeedback, &existingAspects, &existing.CreatedAt, &existing.UpdatedAt,
 	)
 
 	if err != nil {
@@ -70,6 +101,10 @@ func (s *TeacherReviewService) UpdateTeacherReview(reviewID string, req *models.
 		return nil, fmt.Errorf("error getting teacher review: %w", err)
 	}
 
+	// Menyimpan review berarti guru sudah mengecek ulang nilai setelah re-grade.
+	existing.NeedsRecheck = false
+	existing.RecheckReason = nil
+
 	// Update fields if provided
 	if req.RevisedScore != nil {
 		existing.RevisedScore = *req.RevisedScore
@@ -77,17 +112,27 @@ func (s *TeacherReviewService) UpdateTeacherReview(reviewID string, req *models.
 	if req.TeacherFeedback != nil {
 		existing.TeacherFeedback = req.TeacherFeedback
 	}
+	existing.AspectScores = unmarshalTeacherAspectScores(existingAspects)
+	if req.AspectScores != nil {
+		existing.AspectScores = *req.AspectScores
+	}
 	existing.UpdatedAt = time.Now()
+	aspectScores, err := marshalTeacherAspectScores(existing.AspectScores)
+	if err != nil {
+		return nil, fmt.Errorf("invalid aspect scores: %w", err)
+	}
 
 	query := `
 		UPDATE teacher_reviews
-		SET revised_score = $1, teacher_feedback = $2, updated_at = $3
-		WHERE id = $4
+		SET revised_score = $1, teacher_feedback = $2, aspect_scores = $3, updated_at = $4,
+			needs_recheck = FALSE, recheck_reason = NULL
+		WHERE id = $5
 	`
 	_, err = s.db.ExecContext(context.Background(),
 		query,
 		existing.RevisedScore,
 		existing.TeacherFeedback,
+		aspectScores,
 		existing.UpdatedAt,
 		reviewID,
 	)
@@ -102,13 +147,16 @@ func (s *TeacherReviewService) UpdateTeacherReview(reviewID string, req *models.
 // GetTeacherReviewBySubmissionID retrieves a teacher review by its submission ID.
 func (s *TeacherReviewService) GetTeacherReviewBySubmissionID(submissionID string) (*models.TeacherReview, error) {
 	query := `
-		SELECT id, submission_id, teacher_id, revised_score, teacher_feedback, created_at, updated_at
+		SELECT id, submission_id, teacher_id, revised_score, teacher_feedback, aspect_scores::text, created_at, updated_at,
+			COALESCE(needs_recheck, FALSE), recheck_reason
 		FROM teacher_reviews
 		WHERE submission_id = $1
 	`
 	var review models.TeacherReview
+	var aspectScores sql.NullString
 	err := s.db.QueryRowContext(context.Background(), query, submissionID).Scan(
-		&review.ID, &review.SubmissionID, &review.TeacherID, &review.RevisedScore, &review.TeacherFeedback, &review.CreatedAt, &review.UpdatedAt,
+		&review.ID, &review.SubmissionID, &review.TeacherID, &review.RevisedScore, &review.TeacherFeedback, &aspectScores, &review.CreatedAt, &review.UpdatedAt,
+		&review.NeedsRecheck, &review.RecheckReason,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -116,6 +164,7 @@ func (s *TeacherReviewService) GetTeacherReviewBySubmissionID(submissionID strin
 		}
 		return nil, fmt.Errorf("error querying teacher review by submission ID: %w", err)
 	}
+	review.AspectScores = unmarshalTeacherAspectScores(aspectScores)
 	return &review, nil
 }
 
@@ -161,19 +210,33 @@ func (s *TeacherReviewService) UpsertTeacherReviewsBatch(req *models.BatchTeache
 			}
 		}
 
-		_, err := s.db.ExecContext(
+		aspectScores, err := marshalTeacherAspectScores(item.AspectScores)
+		if err != nil {
+			response.Failed = append(response.Failed, models.BatchTeacherReviewItemError{
+				SubmissionID: submissionID,
+				Message:      "aspect_scores is invalid",
+			})
+			continue
+		}
+
+		// aspect_scores hanya ditimpa bila dikirim, agar batch lama tanpa skor aspek tidak menghapusnya.
+		_, err = s.db.ExecContext(
 			context.Background(),
-			`INSERT INTO teacher_reviews (submission_id, teacher_id, revised_score, teacher_feedback, created_at, updated_at)
-			 VALUES ($1, $2, $3, $4, NOW(), NOW())
+			`INSERT INTO teacher_reviews (submission_id, teacher_id, revised_score, teacher_feedback, aspect_scores, created_at, updated_at)
+			 VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
 			 ON CONFLICT (submission_id) DO UPDATE
 			 SET teacher_id = EXCLUDED.teacher_id,
 			     revised_score = EXCLUDED.revised_score,
 			     teacher_feedback = EXCLUDED.teacher_feedback,
+			     aspect_scores = COALESCE(EXCLUDED.aspect_scores, teacher_reviews.aspect_scores),
+			     needs_recheck = FALSE,
+			     recheck_reason = NULL,
 			     updated_at = NOW()`,
 			submissionID,
 			teacherID,
 			*item.RevisedScore,
 			feedback,
+			aspectScores,
 		)
 		if err != nil {
 			response.Failed = append(response.Failed, models.BatchTeacherReviewItemError{
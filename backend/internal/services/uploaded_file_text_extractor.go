@@ -0,0 +1,40 @@
+package services
+
+import (
+	"api-backend/internal/doctext"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ExtractTextFromUploadedFile membaca file di /uploads lalu mengekstrak teksnya dengan extractor doctext
+// sesuai jenis file (PDF, DOCX, PPTX, ODT/ODP, TXT/Markdown, HTML).
+func ExtractTextFromUploadedFile(fileURL string) (*doctext.Result, error) {
+	absPath, err := resolveUploadedFilePath(fileURL)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := os.ReadFile(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read uploaded file: %w", err)
+	}
+	return doctext.Extract(absPath, raw)
+}
+
+// resolveUploadedFilePath memetakan URL /uploads/<nama> ke path lokal dan menolak path di luar folder uploads.
+func resolveUploadedFilePath(fileURL string) (string, error) {
+	trimmed := strings.TrimSpace(fileURL)
+	if trimmed == "" {
+		return "", fmt.Errorf("empty file url")
+	}
+	if !strings.HasPrefix(trimmed, "/uploads/") {
+		return "", fmt.Errorf("unsupported file location")
+	}
+
+	fileName := strings.TrimPrefix(trimmed, "/uploads/")
+	if fileName == "" || strings.Contains(fileName, "..") || strings.Contains(fileName, "/") {
+		return "", fmt.Errorf("invalid upload file path")
+	}
+	return filepath.Join("uploads", fileName), nil
+}